@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"github.com/lesovsky/pgscv/internal/log"
+	"github.com/lesovsky/pgscv/internal/model"
 	"github.com/lesovsky/pgscv/internal/pgscv"
 	"gopkg.in/alecthomas/kingpin.v2"
 	"os"
@@ -20,6 +21,8 @@ func main() {
 		showVersion = kingpin.Flag("version", "show version and exit").Default().Bool()
 		logLevel    = kingpin.Flag("log-level", "set log level: debug, info, warn, error").Default("info").Envar("LOG_LEVEL").String()
 		configFile  = kingpin.Flag("config-file", "path to config file").Default("").Envar("PGSCV_CONFIG_FILE").String()
+		checkOnly   = kingpin.Flag("check-collectors", "run enabled collectors once, print results and exit").Default().Bool()
+		printOnly   = kingpin.Flag("print-metrics", "collect metrics once, print them to stdout and exit").Default().Bool()
 	)
 	kingpin.Parse()
 	log.SetLevel(*logLevel)
@@ -41,6 +44,25 @@ func main() {
 		os.Exit(1)
 	}
 
+	config.BuildInfo = model.BuildInfo{Version: gitTag, Commit: gitCommit, Branch: gitBranch}
+	config.ConfigFile = *configFile
+
+	if *checkOnly {
+		if err := pgscv.Check(config, os.Stdout); err != nil {
+			log.Errorln("check collectors failed: ", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if *printOnly {
+		if err := pgscv.PrintMetrics(config, os.Stdout); err != nil {
+			log.Errorln("print metrics failed: ", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 
 	var doExit = make(chan error, 2)
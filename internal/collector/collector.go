@@ -1,38 +1,48 @@
 package collector
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgconn"
 	"github.com/lesovsky/pgscv/internal/filter"
 	"github.com/lesovsky/pgscv/internal/log"
 	"github.com/lesovsky/pgscv/internal/model"
 	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"net"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // Factories defines collector functions which used for collecting metrics.
 type Factories map[string]func(labels, model.CollectorSettings) (Collector, error)
 
 // RegisterSystemCollectors unions all system-related collectors and registers them in single place.
-func (f Factories) RegisterSystemCollectors(disabled []string) {
-	if stringsContains(disabled, "system") {
-		log.Debugln("disable all system collectors")
-		return
-	}
-
+func (f Factories) RegisterSystemCollectors(disabled, enabled []string) {
 	funcs := map[string]func(labels, model.CollectorSettings) (Collector, error){
 		"system/pgscv":       NewPgscvServicesCollector,
 		"system/sysinfo":     NewSysInfoCollector,
 		"system/loadaverage": NewLoadAverageCollector,
 		"system/cpu":         NewCPUCollector,
+		"system/cgroup":      NewCgroupCollector,
 		"system/diskstats":   NewDiskstatsCollector,
 		"system/filesystems": NewFilesystemCollector,
 		"system/netdev":      NewNetdevCollector,
+		"system/netstat":     NewNetstatCollector,
 		"system/network":     NewNetworkCollector,
 		"system/memory":      NewMeminfoCollector,
+		"system/pressure":    NewPressureCollector,
 		"system/sysconfig":   NewSysconfigCollector,
 	}
 
 	for name, fn := range funcs {
-		if stringsContains(disabled, name) {
+		if isCollectorDisabled(name, disabled, enabled) {
 			log.Debugln("disable ", name)
 			continue
 		}
@@ -43,36 +53,41 @@ func (f Factories) RegisterSystemCollectors(disabled []string) {
 }
 
 // RegisterPostgresCollectors unions all postgres-related collectors and registers them in single place.
-func (f Factories) RegisterPostgresCollectors(disabled []string) {
-	if stringsContains(disabled, "postgres") {
-		log.Debugln("disable all postgres collectors")
-		return
-	}
-
+func (f Factories) RegisterPostgresCollectors(disabled, enabled []string) {
 	funcs := map[string]func(labels, model.CollectorSettings) (Collector, error){
-		"postgres/pgscv":             NewPgscvServicesCollector,
-		"postgres/activity":          NewPostgresActivityCollector,
-		"postgres/archiver":          NewPostgresWalArchivingCollector,
-		"postgres/bgwriter":          NewPostgresBgwriterCollector,
-		"postgres/conflicts":         NewPostgresConflictsCollector,
-		"postgres/databases":         NewPostgresDatabasesCollector,
-		"postgres/indexes":           NewPostgresIndexesCollector,
-		"postgres/functions":         NewPostgresFunctionsCollector,
-		"postgres/locks":             NewPostgresLocksCollector,
-		"postgres/logs":              NewPostgresLogsCollector,
-		"postgres/replication":       NewPostgresReplicationCollector,
-		"postgres/replication_slots": NewPostgresReplicationSlotsCollector,
-		"postgres/statements":        NewPostgresStatementsCollector,
-		"postgres/schemas":           NewPostgresSchemasCollector,
-		"postgres/settings":          NewPostgresSettingsCollector,
-		"postgres/storage":           NewPostgresStorageCollector,
-		"postgres/tables":            NewPostgresTablesCollector,
-		"postgres/wal":               NewPostgresWalCollector,
-		"postgres/custom":            NewPostgresCustomCollector,
+		"postgres/pgscv":               NewPgscvServicesCollector,
+		"postgres/activity":            NewPostgresActivityCollector,
+		"postgres/backend_memory":      NewPostgresBackendMemoryCollector,
+		"postgres/archiver":            NewPostgresWalArchivingCollector,
+		"postgres/bgwriter":            NewPostgresBgwriterCollector,
+		"postgres/conflicts":           NewPostgresConflictsCollector,
+		"postgres/databases":           NewPostgresDatabasesCollector,
+		"postgres/indexes":             NewPostgresIndexesCollector,
+		"postgres/functions":           NewPostgresFunctionsCollector,
+		"postgres/locks":               NewPostgresLocksCollector,
+		"postgres/logs":                NewPostgresLogsCollector,
+		"postgres/partitions":          NewPostgresPartitionsCollector,
+		"postgres/pgstattuple":         NewPostgresPgstattupleCollector,
+		"postgres/progress_copy":       NewPostgresProgressCopyCollector,
+		"postgres/progress_vacuum":     NewPostgresProgressVacuumCollector,
+		"postgres/recovery":            NewPostgresRecoveryCollector,
+		"postgres/replication":         NewPostgresReplicationCollector,
+		"postgres/replication_origins": NewPostgresReplicationOriginsCollector,
+		"postgres/replication_slots":   NewPostgresReplicationSlotsCollector,
+		"postgres/statements":          NewPostgresStatementsCollector,
+		"postgres/schemas":             NewPostgresSchemasCollector,
+		"postgres/settings":            NewPostgresSettingsCollector,
+		"postgres/storage":             NewPostgresStorageCollector,
+		"postgres/subscriptions":       NewPostgresSubscriptionsCollector,
+		"postgres/tables":              NewPostgresTablesCollector,
+		"postgres/wal":                 NewPostgresWalCollector,
+		"postgres/wal_lsn":             NewPostgresWalLsnCollector,
+		"postgres/wal_receiver":        NewPostgresWalReceiverCollector,
+		"postgres/custom":              NewPostgresCustomCollector,
 	}
 
 	for name, fn := range funcs {
-		if stringsContains(disabled, name) {
+		if isCollectorDisabled(name, disabled, enabled) {
 			log.Debugln("disable ", name)
 			continue
 		}
@@ -82,12 +97,7 @@ func (f Factories) RegisterPostgresCollectors(disabled []string) {
 }
 
 // RegisterPgbouncerCollectors unions all pgbouncer-related collectors and registers them in single place.
-func (f Factories) RegisterPgbouncerCollectors(disabled []string) {
-	if stringsContains(disabled, "pgbouncer") {
-		log.Debugln("disable all pgbouncer collectors")
-		return
-	}
-
+func (f Factories) RegisterPgbouncerCollectors(disabled, enabled []string) {
 	funcs := map[string]func(labels, model.CollectorSettings) (Collector, error){
 		"pgbouncer/pgscv":    NewPgscvServicesCollector,
 		"pgbouncer/pools":    NewPgbouncerPoolsCollector,
@@ -96,7 +106,7 @@ func (f Factories) RegisterPgbouncerCollectors(disabled []string) {
 	}
 
 	for name, fn := range funcs {
-		if stringsContains(disabled, name) {
+		if isCollectorDisabled(name, disabled, enabled) {
 			log.Debugln("disable ", name)
 			continue
 		}
@@ -106,6 +116,109 @@ func (f Factories) RegisterPgbouncerCollectors(disabled []string) {
 	}
 }
 
+// isCollectorDisabled returns true if collector name matches to any of disabled patterns and doesn't match to any of
+// enabled patterns, hence enabled patterns have precedence over disabled.
+func isCollectorDisabled(name string, disabled, enabled []string) bool {
+	if matchCollectorPatterns(name, enabled) {
+		return false
+	}
+
+	return matchCollectorPatterns(name, disabled)
+}
+
+// matchCollectorPatterns returns true if collector name matches to any of passed patterns. Pattern could be an exact
+// collector name (e.g. 'postgres/tables'), a collectors group (e.g. 'system') or a glob (e.g. 'postgres/schema*').
+func matchCollectorPatterns(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if pattern == name || strings.HasPrefix(name, pattern+"/") {
+			return true
+		}
+
+		if ok, err := path.Match(pattern, name); err == nil && ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ValidateCollectorPattern checks pattern used for disabling/enabling collectors is valid.
+func ValidateCollectorPattern(pattern string) error {
+	_, err := path.Match(pattern, "")
+	return err
+}
+
+// ValidateMetricPattern checks pattern used in metrics allow-list is valid.
+func ValidateMetricPattern(pattern string) error {
+	_, err := path.Match(pattern, "")
+	return err
+}
+
+// reservedLabels defines labels attached to metrics by pgscv itself, user-defined labels can't override them.
+var reservedLabels = []string{"service_id", "role"}
+
+// builtinLabels defines variable labels of metrics of builtin collectors. User-defined constant labels with such names
+// would collide with variable labels of builtin metrics. The list has to be updated when new labels are added.
+var builtinLabels = []string{
+	"access", "active", "address", "application_name", "backend_type", "boot_val", "checkpoint", "client_addr",
+	"collector", "column", "columns", "command", "conflict", "constraint", "cpu", "database", "device", "error",
+	"event", "fstype", "function", "governor", "guc", "host", "index", "indexdef", "kernel", "key", "kind", "lag",
+	"level", "mode", "model", "mountpoint", "msg", "name", "op", "origin", "path", "peer", "pending_restart", "pid",
+	"pool_mode", "port", "process", "product_name", "query", "queryid", "reason", "redundantdef", "refcolumn",
+	"referenced", "refschema", "reftable", "relation", "rotational", "scheduler", "schema", "sender_host", "sequence",
+	"service", "setting", "size", "slot_name", "slot_type", "source", "stage", "state", "status", "subname", "sysctl",
+	"table", "tablespace", "tuples", "type", "unit", "usage", "user", "vartype", "vendor", "version", "virtual", "wal",
+	"window", "worker", "xid_from",
+}
+
+// labelNameRE defines regexp for valid Prometheus label names.
+var labelNameRE = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// ValidateMetricsPrefix checks prefix of metrics names is valid.
+func ValidateMetricsPrefix(prefix string) error {
+	if prefix != "" && !labelNameRE.MatchString(prefix) {
+		return fmt.Errorf("invalid metrics prefix '%s'", prefix)
+	}
+
+	return nil
+}
+
+// ValidateConstLabels checks user-defined constant labels have valid names and don't override builtin labels.
+func ValidateConstLabels(l map[string]string) error {
+	for name := range l {
+		if !labelNameRE.MatchString(name) || strings.HasPrefix(name, "__") {
+			return fmt.Errorf("invalid label name '%s'", name)
+		}
+
+		for _, reserved := range reservedLabels {
+			if name == reserved {
+				return fmt.Errorf("label name '%s' is reserved", name)
+			}
+		}
+
+		for _, builtin := range builtinLabels {
+			if name == builtin {
+				return fmt.Errorf("label name '%s' is used by builtin collectors", name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// newConstLabels creates constant labels of the service from its ID and user-defined labels. Builtin labels have
+// precedence over user-defined ones.
+func newConstLabels(serviceID string, userLabels map[string]string) labels {
+	constLabels := labels{}
+	for k, v := range userLabels {
+		constLabels[k] = v
+	}
+
+	constLabels["service_id"] = serviceID
+
+	return constLabels
+}
+
 // register is the generic routine which register any kind of collectors.
 func (f Factories) register(collector string, factory func(labels, model.CollectorSettings) (Collector, error)) {
 	f[collector] = factory
@@ -123,21 +236,77 @@ type PgscvCollector struct {
 	Collectors map[string]Collector
 	// anchorDesc is a metric descriptor used for distinguishing collectors when unregister is required.
 	anchorDesc typedDesc
+	// successDesc is a metric descriptor used for reporting collectors success.
+	successDesc typedDesc
+	// durationDesc is a metric descriptor used for reporting collectors execution time.
+	durationDesc typedDesc
+	// lastErrorDesc is a metric descriptor used for reporting error of collectors failed during last scrape.
+	lastErrorDesc typedDesc
+	// scrapeDesc is a metric descriptor used for reporting total time of service scrape.
+	scrapeDesc typedDesc
+	// upDesc is a metric descriptor used for reporting whether connection to the service succeeded during last scrape.
+	upDesc typedDesc
+	// upState tracks state of the service used for producing 'up' metric.
+	upState *upState
+	// connFailuresDesc is a metric descriptor used for reporting failed connections to the service.
+	connFailuresDesc typedDesc
+	// connFailures is the total number of failed connections to the service.
+	connFailures *uint64
+	// seriesDroppedDesc is a metric descriptor used for reporting series dropped due to exceeded series limits.
+	seriesDroppedDesc typedDesc
+	// seriesDropped is the total number of series dropped due to exceeded series limits.
+	seriesDropped *uint64
+	// collectorsSettings defines settings of each collector, including builtin ones.
+	collectorsSettings model.CollectorsSettings
+	// pgStatStatements keeps location of pg_stat_statements between scrapes.
+	pgStatStatements *pgStatStatementsDiscovery
+	// lastScrape keeps result of the last scrape of the service.
+	lastScrape *scrapeStatus
+}
+
+// ScrapeStatus describes result of the service scrape.
+type ScrapeStatus struct {
+	// Time defines when the scrape has been finished, zero if the service has not been scraped yet.
+	Time time.Time
+	// Err defines error occurred during the scrape, nil if the service and all its collectors succeeded.
+	Err error
+}
+
+// scrapeStatus is the concurrent-safe store of the scrape result.
+type scrapeStatus struct {
+	mu     sync.Mutex
+	status ScrapeStatus
+}
+
+// set updates stored scrape result.
+func (s *scrapeStatus) set(err error) {
+	s.mu.Lock()
+	s.status = ScrapeStatus{Time: time.Now(), Err: err}
+	s.mu.Unlock()
+}
+
+// get returns stored scrape result.
+func (s *scrapeStatus) get() ScrapeStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
 }
 
 // NewPgscvCollector accepts Factories and creates per-service instance of Collector.
 func NewPgscvCollector(serviceID string, factories Factories, config Config) (*PgscvCollector, error) {
 	collectors := make(map[string]Collector)
-	constLabels := labels{"service_id": serviceID}
+	collectorsSettings := make(model.CollectorsSettings)
+	constLabels := newConstLabels(serviceID, config.Labels)
 
 	for key := range factories {
-		settings := config.Settings[key]
+		settings := newCollectorSettings(key, config.Settings)
 
 		collector, err := factories[key](constLabels, settings)
 		if err != nil {
 			return nil, err
 		}
 		collectors[key] = collector
+		collectorsSettings[key] = settings
 	}
 
 	// anchorDesc is a metric descriptor used for distinguish collectors. Creating many collectors with uniq anchorDesc makes
@@ -149,7 +318,78 @@ func NewPgscvCollector(serviceID string, factories Factories, config Config) (*P
 		filter.New(),
 	)
 
-	return &PgscvCollector{Config: config, Collectors: collectors, anchorDesc: desc}, nil
+	successDesc := newBuiltinTypedDesc(
+		descOpts{"pgscv", "collector", "success", "Whether the collector succeeded during last scrape: 0 is failed, 1 is succeeded.", 0},
+		prometheus.GaugeValue,
+		[]string{"collector"}, constLabels,
+		filter.New(),
+	)
+
+	durationDesc := newBuiltinTypedDesc(
+		descOpts{"pgscv", "collector", "duration_seconds", "Time spent by the collector during last scrape, in seconds.", 0},
+		prometheus.GaugeValue,
+		[]string{"collector"}, constLabels,
+		filter.New(),
+	)
+
+	lastErrorDesc := newBuiltinTypedDesc(
+		descOpts{"pgscv", "collector", "last_error", "Labeled information about class of error of the collector failed during last scrape, always 1.", 0},
+		prometheus.GaugeValue,
+		[]string{"collector", "error"}, constLabels,
+		filter.New(),
+	)
+
+	scrapeDesc := newBuiltinTypedDesc(
+		descOpts{"pgscv", "scrape", "duration_seconds", "Time spent on collecting metrics of the service during last scrape, in seconds.", 0},
+		prometheus.GaugeValue,
+		nil, constLabels,
+		filter.New(),
+	)
+
+	upDesc := newBuiltinTypedDesc(
+		descOpts{"pgscv", "", "up", "State of the service connection during last scrape: 0 is down, 1 is up.", 0},
+		prometheus.GaugeValue,
+		nil, constLabels,
+		filter.New(),
+	)
+
+	connFailuresDesc := newBuiltinTypedDesc(
+		descOpts{"pgscv", "service", "connect_failures_total", "Total number of failed connections to the service.", 0},
+		prometheus.CounterValue,
+		nil, constLabels,
+		filter.New(),
+	)
+
+	seriesDroppedDesc := newBuiltinTypedDesc(
+		descOpts{"pgscv", "series", "dropped_total", "Total number of series dropped due to exceeded max_series_per_service or max_series_per_collector limits, or duplicated after labels values truncation.", 0},
+		prometheus.CounterValue,
+		nil, constLabels,
+		filter.New(),
+	)
+
+	return &PgscvCollector{
+		Config:             config,
+		Collectors:         collectors,
+		anchorDesc:         desc,
+		successDesc:        successDesc,
+		durationDesc:       durationDesc,
+		lastErrorDesc:      lastErrorDesc,
+		scrapeDesc:         scrapeDesc,
+		upDesc:             upDesc,
+		upState:            newUpState(),
+		connFailuresDesc:   connFailuresDesc,
+		connFailures:       new(uint64),
+		seriesDroppedDesc:  seriesDroppedDesc,
+		seriesDropped:      new(uint64),
+		collectorsSettings: collectorsSettings,
+		pgStatStatements:   newPgStatStatementsDiscovery(config.Statements.Database),
+		lastScrape:         &scrapeStatus{},
+	}, nil
+}
+
+// LastScrape returns result of the last scrape of the service.
+func (n PgscvCollector) LastScrape() ScrapeStatus {
+	return n.lastScrape.get()
 }
 
 // Describe implements the prometheus.Collector interface.
@@ -159,65 +399,432 @@ func (n PgscvCollector) Describe(ch chan<- *prometheus.Desc) {
 
 // Collect implements the prometheus.Collector interface.
 func (n PgscvCollector) Collect(out chan<- prometheus.Metric) {
-	// Update settings of Postgres collectors
-	if n.Config.ServiceType == "postgres" {
-		cfg, err := newPostgresServiceConfig(n.Config.ConnString)
+	n.CollectContext(context.Background(), out)
+}
+
+// CollectContext collects metrics of the service. When passed context is done, queries of collectors are cancelled and
+// metrics collected so far are sent, collectors which have not been finished are considered as failed.
+func (n PgscvCollector) CollectContext(ctx context.Context, out chan<- prometheus.Metric) {
+	scrapeStart := time.Now()
+	n.Config.ctx = ctx
+
+	// Check connection to the service and count failures. The 'up' metric is produced regardless of enabled collectors.
+	switch n.Config.ServiceType {
+	case model.ServiceTypePostgresql:
+		// Update settings of Postgres collectors
+		cfg, err := newPostgresServiceConfig(ctx, n.Config.Pool, n.Config.ConnString, n.pgStatStatements)
 		if err != nil {
+			atomic.AddUint64(n.connFailures, 1)
+			sendMetric(out, n.upDesc.newConstMetric(n.upState.update(false, n.Config.WarmupPeriod)))
+			sendMetric(out, n.connFailuresDesc.newConstMetric(float64(atomic.LoadUint64(n.connFailures))))
 			log.Errorf("update service config failed: %s, skip collect", err.Error())
+			n.lastScrape.set(fmt.Errorf("update service config failed: %s", err))
 			return
 		}
 
 		n.Config.postgresServiceConfig = cfg
+		sendMetric(out, n.upDesc.newConstMetric(n.upState.update(true, n.Config.WarmupPeriod)))
+	case model.ServiceTypePgbouncer:
+		err := checkPgbouncerConn(ctx, n.Config.Pool, n.Config.ConnString)
+		if err != nil {
+			atomic.AddUint64(n.connFailures, 1)
+			log.Errorf("check connection to service failed: %s", err.Error())
+		}
+		sendMetric(out, n.upDesc.newConstMetric(n.upState.update(err == nil, n.Config.WarmupPeriod)))
+	default:
+		// System service doesn't require connection.
+		sendMetric(out, n.upDesc.newConstMetric(1))
+	}
+
+	// Skip collectors which are not intended for current recovery state of the service.
+	collectors := make(map[string]Collector, len(n.Collectors))
+	for name, c := range n.Collectors {
+		if !isCollectorAllowed(n.collectorsSettings[name], n.Config) {
+			log.Debugf("%s collector is not intended for current recovery state of the service, skip", name)
+			continue
+		}
+		collectors[name] = c
 	}
 
 	wgCollector := sync.WaitGroup{}
 	wgSender := sync.WaitGroup{}
 
-	// Create pipe channel used transmitting metrics from collectors to sender.
+	// Create pipe channels used transmitting metrics from collectors to sender. Metrics of collectors are relayed
+	// to the sender until context is done.
+	collectorsOut := make(chan prometheus.Metric)
 	pipelineIn := make(chan prometheus.Metric)
 
 	// Run collectors.
-	wgCollector.Add(len(n.Collectors))
-	for name, c := range n.Collectors {
+	var droppedByCollectors uint64
+	var failed []string
+	var finished = make(map[string]bool, len(collectors))
+	var aborted bool  // collecting has been aborted, unfinished collectors are accounted as failed
+	var mu sync.Mutex // protects failed, finished and aborted
+	wgCollector.Add(len(collectors))
+	for name, c := range collectors {
 		go func(name string, c Collector) {
-			collect(name, n.Config, c, pipelineIn)
+			start := time.Now()
+			success := float64(1)
+
+			dropped, err := collectLimited(n.Config, c, collectorsOut, n.Config.MaxSeriesPerCollector)
+			if err != nil {
+				log.Errorf("%s collector failed; %s", name, err)
+				success = 0
+				collectorsOut <- n.lastErrorDesc.newConstMetric(1, name, errorClass(err))
+			}
+
+			if dropped > 0 {
+				log.Warnf("%s collector exceeded max_series_per_collector limit %d, %d series dropped", name, n.Config.MaxSeriesPerCollector, dropped)
+				atomic.AddUint64(&droppedByCollectors, dropped)
+			}
+
+			collectorsOut <- n.successDesc.newConstMetric(success, name)
+			collectorsOut <- n.durationDesc.newConstMetric(time.Since(start).Seconds(), name)
+
+			// Collectors finished after collecting has been aborted are already accounted as failed.
+			mu.Lock()
+			if !aborted {
+				finished[name] = true
+				if err != nil {
+					failed = append(failed, fmt.Sprintf("%s collector failed: %s", name, err))
+				}
+			}
+			mu.Unlock()
 			wgCollector.Done()
 		}(name, c)
 	}
 
+	go func() {
+		wgCollector.Wait()
+		close(collectorsOut)
+	}()
+
 	// Run sender.
+	var dropped, duplicated uint64
 	wgSender.Add(1)
 	go func() {
-		send(pipelineIn, out)
+		dropped, duplicated = send(pipelineIn, out, n.Config.MaxSeriesPerService, n.isServiceMetric, maxLabelValueLength > 0)
 		wgSender.Done()
 	}()
 
-	// Wait until all collectors have been finished. Close the channel and allow to sender to send metrics.
-	wgCollector.Wait()
+	// Wait until all collectors have been finished or context is done. In the latter case, collectors which have not
+	// been finished yet are considered as failed, their queries are cancelled and their remaining metrics are discarded.
+	if !relay(collectorsOut, pipelineIn, ctx.Done()) {
+		var unfinished []string
+		mu.Lock()
+		aborted = true
+		for name := range collectors {
+			if !finished[name] {
+				unfinished = append(unfinished, name)
+				failed = append(failed, fmt.Sprintf("%s collector aborted: %s", name, ctx.Err()))
+			}
+		}
+		mu.Unlock()
+
+		for _, name := range unfinished {
+			log.Warnf("%s collector aborted: %s; skip it", name, ctx.Err())
+			pipelineIn <- n.successDesc.newConstMetric(0, name)
+			pipelineIn <- n.lastErrorDesc.newConstMetric(1, name, errorClass(ctx.Err()))
+		}
+	}
+
+	// Send service metrics, close the channel and allow to sender to send metrics.
+
+	if n.Config.ServiceType == model.ServiceTypePostgresql || n.Config.ServiceType == model.ServiceTypePgbouncer {
+		pipelineIn <- n.connFailuresDesc.newConstMetric(float64(atomic.LoadUint64(n.connFailures)))
+	}
+	pipelineIn <- n.scrapeDesc.newConstMetric(time.Since(scrapeStart).Seconds())
+
 	close(pipelineIn)
 
 	// Wait until metrics have been sent.
 	wgSender.Wait()
+
+	if dropped > 0 {
+		log.Warnf("max_series_per_service limit %d exceeded, %d series dropped", n.Config.MaxSeriesPerService, dropped)
+	}
+
+	if duplicated > 0 {
+		log.Warnf("%d series duplicated after labels values truncation, duplicates dropped", duplicated)
+	}
+
+	sendMetric(out, n.seriesDroppedDesc.newConstMetric(float64(atomic.AddUint64(n.seriesDropped, dropped+duplicated+atomic.LoadUint64(&droppedByCollectors)))))
+
+	// Remember result of the scrape, errors of failed collectors are sorted to make the result stable.
+	var scrapeErr error
+	mu.Lock()
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		scrapeErr = errors.New(strings.Join(failed, "; "))
+	}
+	mu.Unlock()
+	n.lastScrape.set(scrapeErr)
+}
+
+// CheckResult describes result of single run of the collector.
+type CheckResult struct {
+	// Collector defines name of the collector.
+	Collector string
+	// Metrics defines number of metrics produced by the collector.
+	Metrics int
+	// Err defines error returned by the collector, nil if the collector succeeded.
+	Err error
+}
+
+// Check runs each collector once and returns results sorted by collectors names. Collectors which are not intended
+// for current recovery state of the service are skipped. Error is returned if service config can't be updated.
+func (n PgscvCollector) Check() ([]CheckResult, error) {
+	if n.Config.ServiceType == model.ServiceTypePostgresql {
+		cfg, err := newPostgresServiceConfig(context.Background(), n.Config.Pool, n.Config.ConnString, n.pgStatStatements)
+		if err != nil {
+			return nil, fmt.Errorf("update service config failed: %s", err)
+		}
+
+		n.Config.postgresServiceConfig = cfg
+	}
+
+	names := make([]string, 0, len(n.Collectors))
+	for name := range n.Collectors {
+		if !isCollectorAllowed(n.collectorsSettings[name], n.Config) {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	results := make([]CheckResult, 0, len(names))
+	for _, name := range names {
+		ch := make(chan prometheus.Metric)
+		counted := make(chan int)
+
+		go func() {
+			var count int
+			for m := range ch {
+				if m != nil {
+					count++
+				}
+			}
+			counted <- count
+		}()
+
+		err := collect(n.Config, n.Collectors[name], ch)
+		close(ch)
+
+		results = append(results, CheckResult{Collector: name, Metrics: <-counted, Err: err})
+	}
+
+	return results, nil
+}
+
+// builtinCollectorsSettings defines settings declared by builtin collectors. These settings are merged with settings
+// specified in configuration.
+var builtinCollectorsSettings = model.CollectorsSettings{
+	"postgres/conflicts":    {RunOnlyOnStandby: true},
+	"postgres/recovery":     {RunOnlyOnStandby: true},
+	"postgres/wal_receiver": {RunOnlyOnStandby: true},
+}
+
+// newCollectorSettings returns settings of the collector specified in configuration merged with builtin settings.
+func newCollectorSettings(name string, settings model.CollectorsSettings) model.CollectorSettings {
+	s := settings[name]
+
+	if builtin, ok := builtinCollectorsSettings[name]; ok {
+		// Settings from configuration have precedence.
+		if !s.RunOnlyOnPrimary && !s.RunOnlyOnStandby {
+			s.RunOnlyOnPrimary = builtin.RunOnlyOnPrimary
+			s.RunOnlyOnStandby = builtin.RunOnlyOnStandby
+		}
+	}
+
+	return s
+}
+
+// isCollectorAllowed returns true if collector should run for the service depending on its recovery state.
+func isCollectorAllowed(settings model.CollectorSettings, config Config) bool {
+	if config.ServiceType != model.ServiceTypePostgresql {
+		return true
+	}
+
+	if settings.RunOnlyOnPrimary && config.inRecovery {
+		return false
+	}
+
+	if settings.RunOnlyOnStandby && !config.inRecovery {
+		return false
+	}
+
+	return true
+}
+
+// isServiceMetric returns true if metric describes the service itself and is not produced by collectors.
+func (n PgscvCollector) isServiceMetric(m prometheus.Metric) bool {
+	switch m.Desc() {
+	case n.successDesc.desc, n.durationDesc.desc, n.lastErrorDesc.desc, n.scrapeDesc.desc, n.connFailuresDesc.desc:
+		return true
+	default:
+		return false
+	}
 }
 
 // send acts like a middleware between metric collector functions which produces metrics and Prometheus who accepts metrics.
-func send(in <-chan prometheus.Metric, out chan<- prometheus.Metric) {
+// If limit is greater than zero, metrics produced by collectors above the limit are dropped. If dedupe is true, series
+// with the same name and labels as already sent ones are dropped, such series appear when different labels values
+// become identical after truncation and fail the whole gathering. Returns number of dropped and duplicated metrics.
+func send(in <-chan prometheus.Metric, out chan<- prometheus.Metric, limit int, exempt func(prometheus.Metric) bool, dedupe bool) (uint64, uint64) {
+	var sent, dropped, duplicated uint64
+	var seen map[string]struct{}
+	if dedupe {
+		seen = map[string]struct{}{}
+	}
+
 	for m := range in {
 		// Skip received nil values
 		if m == nil {
 			continue
 		}
 
+		// Drop duplicated series.
+		if dedupe {
+			if key, ok := seriesKey(m); ok {
+				if _, ok := seen[key]; ok {
+					duplicated++
+					continue
+				}
+				seen[key] = struct{}{}
+			}
+		}
+
+		// Drop metrics which exceed series limit.
+		if limit > 0 && !exempt(m) {
+			if sent >= uint64(limit) {
+				dropped++
+				continue
+			}
+			sent++
+		}
+
 		// implement other middlewares here.
 
 		out <- m
 	}
+
+	return dropped, duplicated
+}
+
+// sendMetric sends metric directly to the channel, bypassing the sender. Nil metrics (e.g. suppressed by metrics
+// allow-list) are skipped, Prometheus registry doesn't accept them.
+func sendMetric(ch chan<- prometheus.Metric, m prometheus.Metric) {
+	if m == nil {
+		return
+	}
+
+	ch <- m
 }
 
-// collect runs metric collection function and wraps it into instrumenting logic.
-func collect(name string, config Config, c Collector, ch chan<- prometheus.Metric) {
-	err := c.Update(config, ch)
+// seriesKey returns string identifying series of the metric, it consists of metric descriptor and labels values.
+func seriesKey(m prometheus.Metric) (string, bool) {
+	metric := &dto.Metric{}
+	err := m.Write(metric)
 	if err != nil {
-		log.Errorf("%s collector failed; %s", name, err)
+		return "", false
+	}
+
+	var b strings.Builder
+	b.WriteString(m.Desc().String())
+	for _, lp := range metric.GetLabel() {
+		b.WriteByte(0xff)
+		b.WriteString(lp.GetName())
+		b.WriteByte(0xfe)
+		b.WriteString(lp.GetValue())
+	}
+
+	return b.String(), true
+}
+
+// relay forwards metrics from in to out until in is closed or done is closed. When done is closed, remaining metrics
+// are drained in background and discarded. Returns false if done has been closed before in is closed.
+func relay(in <-chan prometheus.Metric, out chan<- prometheus.Metric, done <-chan struct{}) bool {
+	drain := func() {
+		for range in {
+		}
+	}
+
+	for {
+		select {
+		case m, ok := <-in:
+			if !ok {
+				return true
+			}
+
+			select {
+			case out <- m:
+			case <-done:
+				go drain()
+				return false
+			}
+		case <-done:
+			go drain()
+			return false
+		}
+	}
+}
+
+// collectLimited runs collect and drops metrics produced by the collector above the limit. Zero limit means unlimited.
+// Returns number of dropped metrics.
+func collectLimited(config Config, c Collector, out chan<- prometheus.Metric, limit int) (uint64, error) {
+	if limit <= 0 {
+		return 0, collect(config, c, out)
 	}
+
+	ch := make(chan prometheus.Metric)
+	dropped := make(chan uint64)
+
+	go func() {
+		n, _ := send(ch, out, limit, func(prometheus.Metric) bool { return false }, false)
+		dropped <- n
+	}()
+
+	err := collect(config, c, ch)
+	close(ch)
+
+	return <-dropped, err
+}
+
+// errCollectorPanic is the error returned when collector panics.
+var errCollectorPanic = errors.New("collector panic")
+
+// errorClass returns class of the collector's error. Errors text could contain arbitrary details (queries, names of
+// objects, addresses, etc.), hence only the class is used as label value to keep number of series bounded.
+func errorClass(err error) string {
+	var pgErr *pgconn.PgError
+	var netErr net.Error
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, errCollectorPanic):
+		return "panic"
+	case errors.As(err, &pgErr):
+		return "server"
+	case errors.As(err, &netErr):
+		if netErr.Timeout() {
+			return "timeout"
+		}
+		return "connection"
+	default:
+		return "other"
+	}
+}
+
+// collect runs metric collection function and isolates its failures (including panics) from other collectors.
+func collect(config Config, c Collector, ch chan<- prometheus.Metric) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: %v", errCollectorPanic, r)
+		}
+	}()
+
+	return c.Update(config, ch)
 }
@@ -8,9 +8,15 @@ import (
 	"github.com/lesovsky/pgscv/internal/model"
 	"github.com/lesovsky/pgscv/internal/store"
 	"github.com/prometheus/client_golang/prometheus"
+	"path"
+	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
 )
 
 // labels is a local wrapper over prometheus.Labels which is a simple map[string]string.
@@ -20,6 +26,8 @@ type labels prometheus.Labels
 type typedDesc struct {
 	// desc is the descriptor used by every Prometheus Metric.
 	desc *prometheus.Desc
+	// name is the fully-qualified name of the metric.
+	name string
 	// valueType is an enumeration of metric types that represent a simple value.
 	valueType prometheus.ValueType
 	// multiplier used to cast value to necessary units.
@@ -29,12 +37,19 @@ type typedDesc struct {
 	// labeledValues used for user-defined metrics and defines pairs with labelname:[]column_name,
 	// where column name used as label values, column values used as metric values
 	labeledValues map[string][]string
+	// buckets used for user-defined histograms and defines buckets upper bounds
+	buckets []float64
+	// sum and count used for user-defined histograms and define column names where sum and count of observations should be collected
+	sum   string
+	count string
 	// labelNames defines list of all labels names (including those from labeledValues)
 	labelNames []string
 	// labels defines pairs label:value
 	labels labels
 	// filters defines settings for label-based metrics filtering
 	filters filter.Filters
+	// suppressed defines metric is not allowed by metrics allow-list and should not be emitted
+	suppressed bool
 }
 
 // descOpts defines metric descriptor options.
@@ -46,49 +61,209 @@ type descOpts struct {
 	factor    float64
 }
 
+// metricsPrefix defines prefix prepended to names of all metrics, empty prefix means names are not changed.
+var metricsPrefix string
+
+// SetMetricsPrefix sets prefix prepended to names of all metrics. It has to be called before collectors are created.
+func SetMetricsPrefix(prefix string) {
+	metricsPrefix = prefix
+}
+
+// withMetricsPrefix returns passed namespace prepended with metrics prefix.
+func withMetricsPrefix(namespace string) string {
+	if metricsPrefix == "" {
+		return namespace
+	}
+
+	return metricsPrefix + "_" + namespace
+}
+
+// WithMetricsPrefix is a public wrapper on withMetricsPrefix function. It is used by metrics defined outside of
+// collectors.
+func WithMetricsPrefix(namespace string) string {
+	return withMetricsPrefix(namespace)
+}
+
+// metricsAllowList defines glob patterns of metrics names allowed to be emitted, empty list means all metrics are allowed.
+var metricsAllowList []string
+
+// SetMetricsAllowList sets glob patterns of metrics names allowed to be emitted, metrics which don't match any pattern
+// are suppressed. Patterns are matched against full metrics names including prefix. It has to be called before
+// collectors are created.
+func SetMetricsAllowList(patterns []string) {
+	metricsAllowList = patterns
+}
+
+// isMetricAllowed returns true if metric with passed name matches metrics allow-list.
+func isMetricAllowed(name string) bool {
+	if len(metricsAllowList) == 0 {
+		return true
+	}
+
+	for _, pattern := range metricsAllowList {
+		if ok, err := path.Match(pattern, name); err == nil && ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsMetricAllowed is a public wrapper on isMetricAllowed function. It is used by metrics defined outside of collectors.
+func IsMetricAllowed(name string) bool {
+	return isMetricAllowed(name)
+}
+
+// maxLabelValueLength defines max length of labels values in bytes, 0 means unlimited.
+var maxLabelValueLength int
+
+// SetMaxLabelValueLength sets max length of labels values, longer values are truncated. Zero means unlimited.
+func SetMaxLabelValueLength(n int) {
+	maxLabelValueLength = n
+}
+
+// labelValueEllipsis is appended to truncated labels values.
+const labelValueEllipsis = "..."
+
+// truncateLabelValues returns label values truncated to max length. Values are truncated at UTF-8 character boundary
+// and end with ellipsis, hence the result is always a valid label value. Passed slice is not modified.
+func truncateLabelValues(values []string) []string {
+	if maxLabelValueLength <= 0 {
+		return values
+	}
+
+	var truncated []string
+	for i, v := range values {
+		if len(v) <= maxLabelValueLength {
+			continue
+		}
+
+		if truncated == nil {
+			truncated = make([]string, len(values))
+			copy(truncated, values)
+		}
+
+		truncated[i] = truncateLabelValue(v, maxLabelValueLength)
+	}
+
+	if truncated == nil {
+		return values
+	}
+
+	return truncated
+}
+
+// truncateLabelValue truncates value to passed length including ellipsis.
+func truncateLabelValue(v string, length int) string {
+	if len(v) <= length {
+		return v
+	}
+
+	// Too short limit leaves no room for ellipsis, just cut the value.
+	suffix := labelValueEllipsis
+	if length <= len(suffix) {
+		suffix = ""
+	}
+
+	cut := length - len(suffix)
+	for cut > 0 && !utf8.RuneStart(v[cut]) {
+		cut--
+	}
+
+	return v[:cut] + suffix
+}
+
+const (
+	// defaultProcfsPath defines default mount point of proc filesystem.
+	defaultProcfsPath = "/proc"
+	// defaultSysfsPath defines default mount point of sys filesystem.
+	defaultSysfsPath = "/sys"
+)
+
+var (
+	// procfsPath defines mount point of proc filesystem used for reading system stats.
+	procfsPath = defaultProcfsPath
+	// sysfsPath defines mount point of sys filesystem used for reading system stats.
+	sysfsPath = defaultSysfsPath
+)
+
+// SetSystemPaths sets mount points of proc and sys filesystems, empty values mean default mount points. It is useful
+// when pgSCV runs in container and monitors the host with its filesystems bind-mounted into the container.
+func SetSystemPaths(procfs, sysfs string) {
+	procfsPath, sysfsPath = defaultProcfsPath, defaultSysfsPath
+
+	if procfs != "" {
+		procfsPath = procfs
+	}
+	if sysfs != "" {
+		sysfsPath = sysfs
+	}
+}
+
+// procPath returns path of passed elements relative to proc filesystem mount point.
+func procPath(elem ...string) string {
+	return filepath.Join(append([]string{procfsPath}, elem...)...)
+}
+
+// sysPath returns path of passed elements relative to sys filesystem mount point.
+func sysPath(elem ...string) string {
+	return filepath.Join(append([]string{sysfsPath}, elem...)...)
+}
+
 // newBuiltinTypedDesc is a constructor for builtin metric descriptor.
 func newBuiltinTypedDesc(opts descOpts, dtype prometheus.ValueType, varLabelNames []string, constLabels labels, filters filter.Filters) typedDesc {
+	name := prometheus.BuildFQName(withMetricsPrefix(opts.namespace), opts.subsystem, opts.name)
+
 	return typedDesc{
 		desc: prometheus.NewDesc(
-			prometheus.BuildFQName(opts.namespace, opts.subsystem, opts.name),
+			name,
 			opts.help,
 			varLabelNames,
 			prometheus.Labels(constLabels),
 		),
+		name:       name,
 		factor:     opts.factor,
 		valueType:  dtype,
 		labelNames: varLabelNames,
 		labels:     map[string]string{},
 		filters:    filters,
+		suppressed: !isMetricAllowed(name),
 	}
 }
 
 // newCustomTypedDesc is a constructor for user-defined metric descriptor.
 func newCustomTypedDesc(opts descOpts, dtype prometheus.ValueType, valueSource string, labeledValues map[string][]string, varLabelNames []string, constLabels labels, filters filter.Filters) typedDesc {
+	name := prometheus.BuildFQName(withMetricsPrefix(opts.namespace), opts.subsystem, opts.name)
+
 	return typedDesc{
 		desc: prometheus.NewDesc(
-			prometheus.BuildFQName(opts.namespace, opts.subsystem, opts.name),
+			name,
 			opts.help,
 			varLabelNames,
 			prometheus.Labels(constLabels),
 		),
+		name:          name,
 		valueType:     dtype,
 		labelNames:    varLabelNames,
 		labels:        map[string]string{},
 		value:         valueSource,
 		labeledValues: labeledValues,
 		filters:       filters,
+		suppressed:    !isMetricAllowed(name),
 	}
 }
 
 // newConstMetric is the wrapper on prometheus.NewConstMetric
 func (d *typedDesc) newConstMetric(value float64, labelValues ...string) prometheus.Metric {
+	if d.suppressed {
+		return nil
+	}
+
 	if d.factor != 0 {
 		value *= d.factor
 	}
 
-	if len(d.labelNames) != len(labelValues) {
-		log.Errorf("number of labels and collected label values does not match, want: %v; got %v; metric description: %s; skip metric", d.labelNames, labelValues, d.desc.String())
+	if !d.isLabelValuesValid(labelValues) {
 		return nil
 	}
 
@@ -97,7 +272,7 @@ func (d *typedDesc) newConstMetric(value float64, labelValues ...string) prometh
 		return nil
 	}
 
-	m, err := prometheus.NewConstMetric(d.desc, d.valueType, value, labelValues...)
+	m, err := prometheus.NewConstMetric(d.desc, d.valueType, value, truncateLabelValues(labelValues)...)
 	if err != nil {
 		log.Errorf("create const metric failed: %s; skip. Failed metric descriptor: '%s'", err, d.desc.String())
 	}
@@ -105,6 +280,47 @@ func (d *typedDesc) newConstMetric(value float64, labelValues ...string) prometh
 	return m
 }
 
+// newConstHistogram is the wrapper on prometheus.NewConstHistogram
+func (d *typedDesc) newConstHistogram(count uint64, sum float64, buckets map[float64]uint64, labelValues ...string) prometheus.Metric {
+	if d.suppressed {
+		return nil
+	}
+
+	if !d.isLabelValuesValid(labelValues) {
+		return nil
+	}
+
+	// Check passed label values against configured filters.
+	if d.hasFilter(labelValues) {
+		return nil
+	}
+
+	m, err := prometheus.NewConstHistogram(d.desc, count, sum, buckets, truncateLabelValues(labelValues)...)
+	if err != nil {
+		log.Errorf("create const histogram failed: %s; skip. Failed metric descriptor: '%s'", err, d.desc.String())
+	}
+
+	return m
+}
+
+// isHistogram returns true if descriptor describes user-defined histogram.
+func (d *typedDesc) isHistogram() bool {
+	return len(d.buckets) > 0
+}
+
+// isLabelValuesValid checks number of label values against number of labels in descriptor. Returns false and logs
+// warning if numbers don't match.
+func (d *typedDesc) isLabelValuesValid(labelValues []string) bool {
+	if len(d.labelNames) == len(labelValues) {
+		return true
+	}
+
+	log.Warnf("metric %s: number of labels and collected label values does not match, want %d: %v; got %d: %v; skip metric",
+		d.name, len(d.labelNames), d.labelNames, len(labelValues), labelValues)
+
+	return false
+}
+
 // hasFilter checks label values against configured filters. Returns true if metric has to be filtered and false otherwise.
 func (d *typedDesc) hasFilter(labelValues []string) bool {
 	for i, key := range d.labelNames {
@@ -126,6 +342,46 @@ type typedDescSet struct {
 	databasesRE *regexp.Regexp // compiled regexp.Regexp object with databases from which metrics should be collected
 	query       string         // query used for requesting stats
 	descs       []typedDesc    // metrics descriptors
+	cache       *queryCache    // cache of query results, nil if caching is disabled
+}
+
+// queryCache keeps query results which could be reused until TTL elapses.
+type queryCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]queryCacheEntry
+}
+
+// queryCacheEntry is a single query result with time of its update.
+type queryCacheEntry struct {
+	res     *model.PGResult
+	updated time.Time
+}
+
+// newQueryCache creates new query results cache.
+func newQueryCache(ttl time.Duration) *queryCache {
+	return &queryCache{ttl: ttl, entries: map[string]queryCacheEntry{}}
+}
+
+// get returns cached query result associated with passed key, if result exists and is not expired.
+func (c *queryCache) get(key string) (*model.PGResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok || time.Since(e.updated) >= c.ttl {
+		return nil, false
+	}
+
+	return e.res, true
+}
+
+// put stores query result associated with passed key.
+func (c *queryCache) put(key string, res *model.PGResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[key] = queryCacheEntry{res: res, updated: time.Now()}
 }
 
 // newDeskSetsFromSubsystems parses subsystem object and produces []typedDescSet object.
@@ -145,7 +401,8 @@ func newDeskSetsFromSubsystems(namespace string, subsystems model.Subsystems, co
 	return sets
 }
 
-// newDescSet creates new typedDescSet based on passed metrics attributes.
+// newDescSet creates new typedDescSet based on passed metrics attributes. Every metric of the subsystem produces its
+// own descriptor, hence a single query row could be mapped to many distinct metrics using different value columns.
 func newDescSet(namespace string, subsystemName string, subsystem model.MetricsSubsystem, constLabels labels) (typedDescSet, error) {
 
 	// Compile regexp object if databases are specified
@@ -164,7 +421,14 @@ func newDescSet(namespace string, subsystemName string, subsystem model.MetricsS
 	}
 
 	var descs []typedDesc
+	var names = map[string]bool{}
 	for _, m := range subsystem.Metrics {
+		// Metrics with the same name would conflict each other, skip duplicates.
+		if names[m.ShortName] {
+			log.Warnf("metric '%s' is already defined in subsystem '%s'; skip", m.ShortName, subsystemName)
+			continue
+		}
+		names[m.ShortName] = true
 
 		// When particular databases specified in user-defined metrics, add 'database' label for metric labels.
 		var labels []string
@@ -184,6 +448,27 @@ func newDescSet(namespace string, subsystemName string, subsystem model.MetricsS
 			continue
 		}
 
+		if m.Usage == "HISTOGRAM" {
+			if len(m.Buckets) == 0 || m.Sum == "" || m.Count == "" || m.Value == "" {
+				log.Warnf("metric '%s' values of 'value', 'buckets', 'sum' and 'count' must not be empty for histogram; skip", m.ShortName)
+				continue
+			}
+
+			d := newCustomTypedDesc(
+				descOpts{namespace, subsystemName, m.ShortName, m.Description, 0},
+				prometheus.UntypedValue,
+				m.Value,
+				nil,
+				labels,
+				constLabels,
+				filter.New(),
+			)
+			d.buckets, d.sum, d.count = m.Buckets, m.Sum, m.Count
+
+			descs = append(descs, d)
+			continue
+		}
+
 		if _, ok := promValueTypes[m.Usage]; !ok {
 			log.Warnf("metric '%s' value of 'usage' is unknown: %s; skip", m.ShortName, m.Usage)
 			continue
@@ -202,12 +487,18 @@ func newDescSet(namespace string, subsystemName string, subsystem model.MetricsS
 		descs = append(descs, d)
 	}
 
+	var cache *queryCache
+	if subsystem.CacheTTL > 0 {
+		cache = newQueryCache(subsystem.CacheTTL)
+	}
+
 	return typedDescSet{
 		namespace:   namespace,
 		subsystem:   subsystemName,
 		databasesRE: databasesRE,
 		query:       subsystem.Query,
 		descs:       descs,
+		cache:       cache,
 	}, nil
 }
 
@@ -232,7 +523,7 @@ func updateAllDescSets(config Config, descSets []typedDescSet, ch chan<- prometh
 
 // updateFromMultipleDatabases method visits all requested databases and collects necessary metrics.
 func updateFromMultipleDatabases(config Config, descSets []typedDescSet, ch chan<- prometheus.Metric) error {
-	conn, err := store.New(config.ConnString)
+	conn, err := store.NewPooled(config.scrapeContext(), config.Pool, config.ConnString)
 	if err != nil {
 		return err
 	}
@@ -252,14 +543,15 @@ func updateFromMultipleDatabases(config Config, descSets []typedDescSet, ch chan
 	// walk through all databases, connect to it and collect schema-specific stats
 	for _, dbname := range realDatabases {
 		for _, s := range descSets {
-			// Skip sets with update on single database, and databases which are not matched to user-defined databases.
-			if s.databasesRE == nil || !s.databasesRE.MatchString(dbname) {
+			// Skip sets with update on single database, databases which are not matched to user-defined databases
+			// and databases which are excluded.
+			if s.databasesRE == nil || !isDatabaseAllowed(dbname, s.databasesRE, config.DatabasesExcludeRE) {
 				continue
 			}
 
 			// Connect to the database and update metrics.
 			pgconfig.Database = dbname
-			conn, err := store.NewWithConfig(pgconfig)
+			conn, err := store.NewPooledWithConfig(config.scrapeContext(), config.Pool, pgconfig)
 			if err != nil {
 				return err
 			}
@@ -279,7 +571,7 @@ func updateFromMultipleDatabases(config Config, descSets []typedDescSet, ch chan
 
 // updateFromSingleDatabase method visit only one database and collect necessary metrics.
 func updateFromSingleDatabase(config Config, descSets []typedDescSet, ch chan<- prometheus.Metric) error {
-	conn, err := store.New(config.ConnString)
+	conn, err := store.NewPooled(config.scrapeContext(), config.Pool, config.ConnString)
 	if err != nil {
 		return err
 	}
@@ -307,7 +599,7 @@ func updateFromSingleDatabase(config Config, descSets []typedDescSet, ch chan<-
 
 // updateSingleDescSet requests data using passed connection, parses returned result and update metrics in passed descs.
 func updateSingleDescSet(conn *store.DB, descs typedDescSet, ch chan<- prometheus.Metric, addDatabaseLabel bool) error {
-	res, err := conn.Query(descs.query)
+	res, err := queryDescSet(conn, descs)
 	if err != nil {
 		return err
 	}
@@ -322,8 +614,14 @@ func updateSingleDescSet(conn *store.DB, descs typedDescSet, ch chan<- prometheu
 		databaseLabelValue = conn.Conn().Config().Database
 	}
 
-	for _, row := range res.Rows {
-		for _, d := range descs.descs {
+	for _, d := range descs.descs {
+		// Histogram is built from multiple rows (a row per bucket), hence all rows should be processed at once.
+		if d.isHistogram() {
+			updateHistogramMetrics(res.Rows, d, colnames, ch, databaseLabelValue)
+			continue
+		}
+
+		for _, row := range res.Rows {
 			updateMetrics(row, d, colnames, ch, databaseLabelValue)
 		}
 	}
@@ -331,7 +629,32 @@ func updateSingleDescSet(conn *store.DB, descs typedDescSet, ch chan<- prometheu
 	return nil
 }
 
-// updateMetrics
+// queryDescSet requests data for passed descs set. If caching is enabled, cached result is used until TTL elapses.
+func queryDescSet(conn *store.DB, descs typedDescSet) (*model.PGResult, error) {
+	if descs.cache == nil {
+		return conn.Query(descs.query)
+	}
+
+	key := descs.subsystem + "/" + conn.Conn().Config().Database
+
+	if res, ok := descs.cache.get(key); ok {
+		log.Debugf("use cached result for %s", key)
+		return res, nil
+	}
+
+	res, err := conn.Query(descs.query)
+	if err != nil {
+		return nil, err
+	}
+
+	descs.cache.put(key, res)
+
+	return res, nil
+}
+
+// updateMetrics parses data row and update metrics using passed metric descriptor. Descriptor refers to its own value
+// column (or labeled values columns) and ignores columns of other descriptors, hence the same row is passed to every
+// descriptor of the set.
 func updateMetrics(row []sql.NullString, desc typedDesc, colnames []string, ch chan<- prometheus.Metric, databaseLabelValue string) {
 	// Using the descriptor a many metrics could be produced (with different label values).
 
@@ -404,11 +727,17 @@ func updateMultipleMetrics(row []sql.NullString, desc typedDesc, colnames []stri
 			// Update metric only when value and all necessary labels are collected.
 
 			if !valueOK || !labelValuesOK {
-				log.Warnln("metric value or labels are not collected, skip")
+				log.Warnf("metric %s: value or labels are not collected, skip", desc.name)
+				continue
+			}
+
+			if !desc.isLabelValuesValid(labelValues) {
 				continue
 			}
 
-			ch <- desc.newConstMetric(value, labelValues...)
+			if m := desc.newConstMetric(value, labelValues...); m != nil {
+				ch <- m
+			}
 		}
 	}
 }
@@ -465,11 +794,124 @@ func updateSingleMetric(row []sql.NullString, desc typedDesc, colnames []string,
 	// Update metric only when value and all necessary labels are collected.
 
 	if !valueOK || !labelValuesOK {
-		log.Warnln("metric value or labels are not collected, skip")
+		log.Warnf("metric %s: value or labels are not collected, skip", desc.name)
 		return
 	}
 
-	ch <- desc.newConstMetric(value, labelValues...)
+	if !desc.isLabelValuesValid(labelValues) {
+		return
+	}
+
+	if m := desc.newConstMetric(value, labelValues...); m != nil {
+		ch <- m
+	}
+}
+
+// histogramStat accumulates values of a single histogram collected from multiple rows.
+type histogramStat struct {
+	labelValues []string
+	buckets     map[float64]uint64
+	sum         float64
+	count       uint64
+	countOK     bool
+}
+
+// updateHistogramMetrics parses data rows and update histogram metrics using passed metric descriptor. Each row should
+// contain bucket upper bound in 'le' column and cumulative count of observations in the bucket. Rows with the same label
+// values are combined into single histogram.
+func updateHistogramMetrics(rows [][]sql.NullString, desc typedDesc, colnames []string, ch chan<- prometheus.Metric, databaseLabelValue string) {
+	var stats = map[string]*histogramStat{}
+	var keys []string
+
+	for _, row := range rows {
+		labelValues := []string{}
+
+		// Insert into labels passed database name in case when there is no 'database' value in data row.
+		if databaseLabelValue != "" && !stringsContains(colnames, "database") {
+			labelValues = append(labelValues, databaseLabelValue)
+		}
+
+		for i, colname := range colnames {
+			if stringsContains(desc.labelNames, colname) {
+				labelValues = append(labelValues, row[i].String)
+			}
+		}
+
+		key := strings.Join(labelValues, "/")
+		stat, ok := stats[key]
+		if !ok {
+			stat = &histogramStat{labelValues: labelValues, buckets: map[float64]uint64{}}
+			stats[key] = stat
+			keys = append(keys, key)
+		}
+
+		var le, bucketCount float64
+		var leOK, bucketCountOK bool
+
+		for i, colname := range colnames {
+			if colname != "le" && colname != desc.value && colname != desc.sum && colname != desc.count {
+				continue
+			}
+
+			// Skip NULL values - metric must not be unknown (NULL)
+			if !row[i].Valid {
+				continue
+			}
+
+			v, err := strconv.ParseFloat(row[i].String, 64)
+			if err != nil {
+				log.Errorf("invalid input, parse '%s' failed: %s; skip", row[i].String, err)
+				continue
+			}
+
+			switch colname {
+			case "le":
+				le, leOK = v, true
+			case desc.value:
+				bucketCount, bucketCountOK = v, true
+			case desc.sum:
+				stat.sum = v
+			case desc.count:
+				stat.count, stat.countOK = uint64(v), true
+			}
+		}
+
+		if leOK && bucketCountOK {
+			stat.buckets[le] = uint64(bucketCount)
+		}
+	}
+
+	for _, key := range keys {
+		stat := stats[key]
+
+		if !stat.countOK {
+			log.Warnf("metric %s: count of observations is not collected, skip", desc.name)
+			continue
+		}
+
+		if m := desc.newConstHistogram(stat.count, stat.sum, histogramBuckets(desc.buckets, stat.buckets), stat.labelValues...); m != nil {
+			ch <- m
+		}
+	}
+}
+
+// histogramBuckets returns cumulative counts for configured buckets upper bounds. Buckets missing in collected values
+// inherit count of the previous bucket.
+func histogramBuckets(bounds []float64, collected map[float64]uint64) map[float64]uint64 {
+	sorted := append([]float64{}, bounds...)
+	sort.Float64s(sorted)
+
+	buckets := make(map[float64]uint64, len(sorted))
+
+	var prev uint64
+	for _, b := range sorted {
+		if v, ok := collected[b]; ok {
+			prev = v
+		}
+		buckets[b] = prev
+	}
+
+	return buckets
 }
 
 // needMultipleUpdate returns true if databases regexp has been found.
@@ -496,3 +938,36 @@ func parseLabeledValue(s string) (string, string) {
 
 	return ff[0], ff[1]
 }
+
+// upState tracks state of the service between scrapes and used for producing 'up' metric. During warm-up period after
+// start, failures don't mark service as down and previous state is kept.
+type upState struct {
+	mu      sync.Mutex
+	started time.Time
+	value   float64
+}
+
+// newUpState creates new upState. Services are checked for availability before registration, so initial state is 'up'.
+func newUpState() *upState {
+	return &upState{started: time.Now(), value: 1}
+}
+
+// update accepts result of the service availability check and returns value which should be used for 'up' metric.
+func (s *upState) update(ok bool, warmup time.Duration) float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if ok {
+		s.value = 1
+		return s.value
+	}
+
+	// Keep previous value when service failed during warm-up period.
+	if time.Since(s.started) < warmup {
+		log.Debugf("service check failed during warm-up period, keep previous up value %v", s.value)
+		return s.value
+	}
+
+	s.value = 0
+	return s.value
+}
@@ -1,17 +1,22 @@
 package collector
 
 import (
+	"bytes"
 	"database/sql"
 	"fmt"
 	"github.com/lesovsky/pgscv/internal/filter"
+	"github.com/lesovsky/pgscv/internal/log"
 	"github.com/lesovsky/pgscv/internal/model"
 	"github.com/lesovsky/pgscv/internal/store"
 	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
 	"regexp"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 )
 
 func Test_newConstMetric(t *testing.T) {
@@ -28,6 +33,193 @@ func Test_newConstMetric(t *testing.T) {
 	assert.Nil(t, m)
 }
 
+func Test_newTypedDesc_metricsPrefix(t *testing.T) {
+	opts := descOpts{"postgres", "archiver", "archived_total", "Test description.", 0}
+
+	// Empty prefix preserves names.
+	d := newBuiltinTypedDesc(opts, prometheus.CounterValue, nil, nil, filter.New())
+	assert.Equal(t, "postgres_archiver_archived_total", d.name)
+
+	SetMetricsPrefix("acme")
+	defer SetMetricsPrefix("")
+
+	d = newBuiltinTypedDesc(opts, prometheus.CounterValue, nil, nil, filter.New())
+	assert.Equal(t, "acme_postgres_archiver_archived_total", d.name)
+	assert.Contains(t, d.desc.String(), `fqName: "acme_postgres_archiver_archived_total"`)
+
+	d = newCustomTypedDesc(descOpts{"postgres", "custom", "example", "Test description.", 0}, prometheus.GaugeValue, "", nil, nil, nil, filter.New())
+	assert.Equal(t, "acme_postgres_custom_example", d.name)
+	assert.Contains(t, d.desc.String(), `fqName: "acme_postgres_custom_example"`)
+
+	ch := make(chan prometheus.Metric, 1)
+	NewBuildInfoCollector(model.BuildInfo{}).Collect(ch)
+	close(ch)
+	assert.Contains(t, (<-ch).Desc().String(), `fqName: "acme_pgscv_build_info"`)
+}
+
+func Test_SetSystemPaths(t *testing.T) {
+	assert.Equal(t, "/proc/loadavg", procPath("loadavg"))
+	assert.Equal(t, "/sys/block/*", sysPath("block", "*"))
+
+	SetSystemPaths("/host/proc", "/host/sys/")
+	defer SetSystemPaths("", "")
+
+	assert.Equal(t, "/host/proc", procPath())
+	assert.Equal(t, "/host/proc/net/dev", procPath("net", "dev"))
+	assert.Equal(t, "/host/sys/fs/cgroup", sysPath("fs", "cgroup"))
+
+	// Empty values reset paths to defaults.
+	SetSystemPaths("", "")
+	assert.Equal(t, "/proc/loadavg", procPath("loadavg"))
+	assert.Equal(t, "/sys/block/*", sysPath("block", "*"))
+}
+
+func Test_truncateLabelValue(t *testing.T) {
+	testcases := []struct {
+		value  string
+		length int
+		want   string
+	}{
+		{value: "example", length: 10, want: "example"},
+		{value: "example", length: 7, want: "example"},
+		{value: "SELECT * FROM example", length: 10, want: "SELECT ..."},
+		{value: "SELECT 'привет'", length: 12, want: "SELECT '..."},
+		{value: "SELECT 'привет'", length: 13, want: "SELECT 'п..."}, // multibyte character is not split
+		{value: "SELECT 'привет'", length: 14, want: "SELECT 'п..."},
+		{value: "example", length: 3, want: "exa"}, // no room for ellipsis
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.want, func(t *testing.T) {
+			got := truncateLabelValue(tc.value, tc.length)
+			assert.Equal(t, tc.want, got)
+			assert.LessOrEqual(t, len(got), tc.length)
+		})
+	}
+}
+
+func Test_newConstMetric_maxLabelValueLength(t *testing.T) {
+	d := newBuiltinTypedDesc(
+		descOpts{"postgres", "statements", "query_info", "Test description.", 0},
+		prometheus.GaugeValue,
+		[]string{"queryid", "query"}, nil,
+		filter.New(),
+	)
+
+	values := []string{"1", "SELECT * FROM example WHERE id = 1"}
+
+	// Unlimited by default.
+	metric := &dto.Metric{}
+	assert.NoError(t, d.newConstMetric(1, values...).Write(metric))
+	assert.Equal(t, "SELECT * FROM example WHERE id = 1", metric.GetLabel()[0].GetValue())
+
+	SetMaxLabelValueLength(16)
+	defer SetMaxLabelValueLength(0)
+
+	metric = &dto.Metric{}
+	assert.NoError(t, d.newConstMetric(1, values...).Write(metric))
+	assert.Equal(t, "SELECT * FROM...", metric.GetLabel()[0].GetValue())
+	assert.Equal(t, "1", metric.GetLabel()[1].GetValue())
+
+	// Passed values are not modified.
+	assert.Equal(t, []string{"1", "SELECT * FROM example WHERE id = 1"}, values)
+
+	h := newCustomTypedDesc(
+		descOpts{"postgres", "custom", "example", "Test description.", 0},
+		prometheus.UntypedValue, "", nil, []string{"query"}, nil,
+		filter.New(),
+	)
+
+	metric = &dto.Metric{}
+	assert.NoError(t, h.newConstHistogram(1, 1, map[float64]uint64{1: 1}, values[1]).Write(metric))
+	assert.Equal(t, "SELECT * FROM...", metric.GetLabel()[0].GetValue())
+}
+
+func Test_isMetricAllowed(t *testing.T) {
+	// Empty allow-list allows everything.
+	assert.True(t, isMetricAllowed("postgres_up"))
+
+	SetMetricsAllowList([]string{"postgres_up", "postgres_database_*", "node_cpu_?econds_total", "pgbouncer_pool_[cs]*"})
+	defer SetMetricsAllowList(nil)
+
+	testcases := []struct {
+		name string
+		want bool
+	}{
+		{name: "postgres_up", want: true},
+		{name: "postgres_up_total", want: false},
+		{name: "postgres_database_size_bytes", want: true},
+		{name: "postgres_database_", want: true},
+		{name: "postgres_databases_total", want: false},
+		{name: "node_cpu_seconds_total", want: true},
+		{name: "node_cpu_guest_seconds_total", want: false},
+		{name: "pgbouncer_pool_client_connections_in_flight", want: true},
+		{name: "pgbouncer_pool_server_connections_in_flight", want: true},
+		{name: "pgbouncer_pool_max_wait_seconds", want: false},
+		{name: "pgbouncer_up", want: false},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, isMetricAllowed(tc.name))
+		})
+	}
+}
+
+func Test_newConstMetric_metricsAllowList(t *testing.T) {
+	f := filter.New()
+	f.Add("database", filter.Filter{Exclude: "template"})
+	assert.NoError(t, f.Compile())
+
+	SetMetricsAllowList([]string{"postgres_database_*", "acme_postgres_custom_*"})
+	defer SetMetricsAllowList(nil)
+
+	allowed := newBuiltinTypedDesc(
+		descOpts{"postgres", "database", "size_bytes", "Test description.", 0},
+		prometheus.GaugeValue,
+		[]string{"database"}, nil,
+		f,
+	)
+	suppressed := newBuiltinTypedDesc(
+		descOpts{"postgres", "recovery", "conflicts_total", "Test description.", 0},
+		prometheus.CounterValue,
+		[]string{"database"}, nil,
+		f,
+	)
+
+	assert.NotNil(t, allowed.newConstMetric(1, "example"))
+	assert.Nil(t, suppressed.newConstMetric(1, "example"))
+
+	// Allowed metrics are still subject of label-based filters.
+	assert.Nil(t, allowed.newConstMetric(1, "template1"))
+
+	// Patterns are matched against names including prefix.
+	SetMetricsPrefix("acme")
+	defer SetMetricsPrefix("")
+
+	prefixed := newBuiltinTypedDesc(
+		descOpts{"postgres", "database", "size_bytes", "Test description.", 0},
+		prometheus.GaugeValue,
+		[]string{"database"}, nil,
+		f,
+	)
+	assert.Nil(t, prefixed.newConstMetric(1, "example"))
+
+	h := newCustomTypedDesc(
+		descOpts{"postgres", "custom", "example", "Test description.", 0},
+		prometheus.UntypedValue, "", nil, nil, nil,
+		filter.New(),
+	)
+	assert.NotNil(t, h.newConstHistogram(1, 1, map[float64]uint64{1: 1}))
+
+	h = newCustomTypedDesc(
+		descOpts{"postgres", "other", "example", "Test description.", 0},
+		prometheus.UntypedValue, "", nil, nil, nil,
+		filter.New(),
+	)
+	assert.Nil(t, h.newConstHistogram(1, 1, map[float64]uint64{1: 1}))
+}
+
 func Test_typedDesc_hasFilter(t *testing.T) {
 	f := filter.New()
 	f.Add("target", filter.Filter{Exclude: "unwanted"})
@@ -130,6 +322,52 @@ func Test_newDescSet(t *testing.T) {
 	assert.Equal(t, 2, len(desc2.descs))
 }
 
+func Test_newDescSet_multipleValues(t *testing.T) {
+	subsys := model.MetricsSubsystem{
+		Query: "SELECT 'example' as relname, 1 as v1, 2 as v2, 3 as v3, 4 as v4, 5 as v5",
+		Metrics: model.Metrics{
+			{ShortName: "metric1", Usage: "COUNTER", Labels: []string{"relname"}, Value: "v1", Description: "description"},
+			{ShortName: "metric2", Usage: "COUNTER", Labels: []string{"relname"}, Value: "v2", Description: "description"},
+			{ShortName: "metric3", Usage: "GAUGE", Labels: []string{"relname"}, Value: "v3", Description: "description"},
+			{ShortName: "metric4", Usage: "GAUGE", Value: "v4", Description: "description"},
+			{ShortName: "metric5", Usage: "GAUGE", Value: "v5", Description: "description"},
+			{ShortName: "metric5", Usage: "GAUGE", Value: "v1", Description: "duplicate"},
+		},
+	}
+
+	set, err := newDescSet("example", "test", subsys, labels{"const": "constlabel"})
+	assert.NoError(t, err)
+	assert.Equal(t, 5, len(set.descs))
+
+	row := []sql.NullString{
+		{String: "example", Valid: true}, {String: "1", Valid: true}, {String: "2", Valid: true},
+		{String: "3", Valid: true}, {String: "4", Valid: true}, {String: "5", Valid: true},
+	}
+	colnames := []string{"relname", "v1", "v2", "v3", "v4", "v5"}
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		for _, d := range set.descs {
+			updateMetrics(row, d, colnames, ch, "")
+		}
+		close(ch)
+	}()
+
+	got := map[string]float64{}
+	for m := range ch {
+		metric := &dto.Metric{}
+		assert.NoError(t, m.Write(metric))
+
+		name := regexp.MustCompile(`fqName: "([a-z0-9_]+)"`).FindStringSubmatch(m.Desc().String())[1]
+		got[name] = metric.GetCounter().GetValue() + metric.GetGauge().GetValue()
+	}
+
+	assert.Equal(t, map[string]float64{
+		"example_test_metric1": 1, "example_test_metric2": 2, "example_test_metric3": 3,
+		"example_test_metric4": 4, "example_test_metric5": 5,
+	}, got)
+}
+
 func Test_updateAllDescSets(t *testing.T) {
 	config := Config{ConnString: store.TestPostgresConnStr}
 
@@ -326,6 +564,20 @@ func Test_updateSingleDescSet(t *testing.T) {
 			},
 			want: []string{"postgres_class2_metric", `constlabel="example2"`, `variableLabels: [database label1]`},
 		},
+		{
+			// descSet with histogram
+			constLabels: labels{"constlabel": "example3"},
+			subsysName:  "class3",
+			subsys: model.MetricsSubsystem{
+				Query: "SELECT 'l1' as label1, le, bucket, 12.5 as sum, 20 as count " +
+					"FROM (VALUES (0.1::float8, 5), (1, 15), ('Infinity', 20)) AS v(le, bucket)",
+				Metrics: model.Metrics{
+					{ShortName: "metric_seconds", Usage: "HISTOGRAM", Labels: []string{"label1"}, Value: "bucket",
+						Buckets: []float64{0.1, 1, 10}, Sum: "sum", Count: "count", Description: "metric description"},
+				},
+			},
+			want: []string{"postgres_class3_metric_seconds", `constlabel="example3"`, `variableLabels: [label1]`},
+		},
 	}
 
 	for i, tc := range testcases {
@@ -580,6 +832,159 @@ func Test_updateSingleMetric(t *testing.T) {
 	}
 }
 
+func Test_updateMetrics_labelsMismatch(t *testing.T) {
+	// Capture log output for checking warnings.
+	var buf bytes.Buffer
+	logger := log.Logger
+	log.Logger = zerolog.New(&buf)
+	defer func() { log.Logger = logger }()
+
+	// Data row with duplicated label columns, e.g. when query returns the same column twice.
+	row := []sql.NullString{
+		{String: "123", Valid: true}, {String: "example1", Valid: true}, {String: "example2", Valid: true},
+	}
+	colnames := []string{"seq_scan", "relname", "relname"}
+
+	testcases := []struct {
+		desc typedDesc
+		want string
+	}{
+		{
+			// more label values than labels
+			desc: newCustomTypedDesc(
+				descOpts{"postgres", "table", "seq_scan_total", "description", 0},
+				prometheus.CounterValue,
+				"seq_scan", nil,
+				[]string{"database", "relname"}, labels{"const": "example"},
+				filter.New(),
+			),
+			want: "does not match",
+		},
+		{
+			// fewer label values than labels
+			desc: newCustomTypedDesc(
+				descOpts{"postgres", "table", "seq_scan_total", "description", 0},
+				prometheus.CounterValue,
+				"", map[string][]string{"scan": {"seq_scan"}},
+				[]string{"database", "relname", "schema", "table", "scan"}, labels{"const": "example"},
+				filter.New(),
+			),
+			want: "not collected",
+		},
+	}
+
+	for _, tc := range testcases {
+		buf.Reset()
+
+		ch := make(chan prometheus.Metric)
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			assert.NotPanics(t, func() { updateMetrics(row, tc.desc, colnames, ch, "testdb") })
+			close(ch)
+			wg.Done()
+		}()
+
+		var counter int
+		for range ch {
+			counter++
+		}
+
+		assert.Equal(t, 0, counter)
+		assert.Contains(t, buf.String(), "postgres_table_seq_scan_total")
+		assert.Contains(t, buf.String(), tc.want)
+		wg.Wait()
+	}
+}
+
+func Test_updateHistogramMetrics(t *testing.T) {
+	rows := [][]sql.NullString{
+		{{String: "example1", Valid: true}, {String: "0.1", Valid: true}, {String: "5", Valid: true}, {String: "12.5", Valid: true}, {String: "20", Valid: true}},
+		{{String: "example1", Valid: true}, {String: "1", Valid: true}, {String: "15", Valid: true}, {String: "12.5", Valid: true}, {String: "20", Valid: true}},
+		{{String: "example1", Valid: true}, {String: "+Inf", Valid: true}, {String: "20", Valid: true}, {String: "12.5", Valid: true}, {String: "20", Valid: true}},
+		{{String: "example2", Valid: true}, {String: "0.1", Valid: true}, {String: "1", Valid: true}, {String: "0.05", Valid: true}, {String: "1", Valid: true}},
+		{{String: "example3", Valid: true}, {String: "0.1", Valid: true}, {String: "1", Valid: true}, {String: "0.05", Valid: true}, {String: "", Valid: false}},
+	}
+	colnames := []string{"relname", "le", "bucket", "sum", "count"}
+
+	desc := newCustomTypedDesc(
+		descOpts{"postgres", "example", "latency_seconds", "description", 0},
+		prometheus.UntypedValue,
+		"bucket", nil,
+		[]string{"database", "relname"}, labels{"const": "example"},
+		filter.New(),
+	)
+	desc.buckets, desc.sum, desc.count = []float64{0.1, 1, 10}, "sum", "count"
+
+	ch := make(chan prometheus.Metric)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		updateHistogramMetrics(rows, desc, colnames, ch, "testdb")
+		close(ch)
+		wg.Done()
+	}()
+
+	var got []*dto.Metric
+	for m := range ch {
+		pb := &dto.Metric{}
+		assert.NoError(t, m.Write(pb))
+		got = append(got, pb)
+	}
+	wg.Wait()
+
+	// example3 has no count and should be skipped.
+	assert.Len(t, got, 2)
+
+	h := got[0].GetHistogram()
+	assert.Equal(t, uint64(20), h.GetSampleCount())
+	assert.Equal(t, 12.5, h.GetSampleSum())
+	assert.Len(t, h.GetBucket(), 3)
+	for i, want := range []struct {
+		le    float64
+		count uint64
+	}{{0.1, 5}, {1, 15}, {10, 15}} {
+		assert.Equal(t, want.le, h.GetBucket()[i].GetUpperBound())
+		assert.Equal(t, want.count, h.GetBucket()[i].GetCumulativeCount())
+	}
+
+	h = got[1].GetHistogram()
+	assert.Equal(t, uint64(1), h.GetSampleCount())
+	assert.Equal(t, 0.05, h.GetSampleSum())
+}
+
+func Test_histogramBuckets(t *testing.T) {
+	assert.Equal(t,
+		map[float64]uint64{0.1: 5, 1: 15, 10: 15, 100: 20},
+		histogramBuckets([]float64{100, 1, 10, 0.1}, map[float64]uint64{0.1: 5, 1: 15, 100: 20}),
+	)
+	assert.Equal(t,
+		map[float64]uint64{0.1: 0, 1: 0},
+		histogramBuckets([]float64{0.1, 1}, map[float64]uint64{}),
+	)
+}
+
+func Test_queryCache(t *testing.T) {
+	c := newQueryCache(100 * time.Millisecond)
+
+	_, ok := c.get("example/testdb")
+	assert.False(t, ok)
+
+	res := &model.PGResult{Nrows: 1, Ncols: 1}
+	c.put("example/testdb", res)
+
+	got, ok := c.get("example/testdb")
+	assert.True(t, ok)
+	assert.Equal(t, res, got)
+
+	_, ok = c.get("example/otherdb")
+	assert.False(t, ok)
+
+	time.Sleep(150 * time.Millisecond)
+	_, ok = c.get("example/testdb")
+	assert.False(t, ok)
+}
+
 func Test_needMultipleUpdate(t *testing.T) {
 	testcases := []struct {
 		sets []typedDescSet
@@ -627,3 +1032,20 @@ func Test_parseLabeledValue(t *testing.T) {
 		assert.Equal(t, tc.s2, s2)
 	}
 }
+
+func Test_upState_update(t *testing.T) {
+	// Failures within warm-up period keep previous value.
+	s := newUpState()
+	assert.Equal(t, float64(1), s.update(false, time.Minute))
+	assert.Equal(t, float64(1), s.update(false, time.Minute))
+
+	// Failures after warm-up period mark service as down.
+	s.started = time.Now().Add(-2 * time.Minute)
+	assert.Equal(t, float64(0), s.update(false, time.Minute))
+	assert.Equal(t, float64(1), s.update(true, time.Minute))
+
+	// No warm-up period.
+	s = newUpState()
+	assert.Equal(t, float64(0), s.update(false, 0))
+	assert.Equal(t, float64(1), s.update(true, 0))
+}
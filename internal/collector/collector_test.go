@@ -1,15 +1,27 @@
 package collector
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgconn"
+	"github.com/lesovsky/pgscv/internal/model"
 	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
+	"unsafe"
 )
 
 func TestPgscvCollector_Collect(t *testing.T) {
 	// Create test stuff - factory and collector, register system only metrics.
 	f := Factories{}
-	f.RegisterSystemCollectors([]string{})
+	f.RegisterSystemCollectors([]string{}, []string{})
 	c, err := NewPgscvCollector("test:0", f, Config{})
 	assert.NoError(t, err)
 	assert.NotNil(t, c)
@@ -36,3 +48,863 @@ func TestPgscvCollector_Collect(t *testing.T) {
 	assert.NotNil(t, metrics)
 	assert.Greater(t, len(metrics), 0)
 }
+
+func TestPgscvCollector_Collect_up(t *testing.T) {
+	testcases := []struct {
+		name   string
+		config Config
+		want   float64
+	}{
+		{name: "system", config: Config{ServiceType: model.ServiceTypeSystem}, want: 1},
+		{name: "postgres, connection failed", config: Config{ServiceType: model.ServiceTypePostgresql, ConnString: "host=127.0.0.1 port=1 user=pgscv"}, want: 0},
+		{name: "pgbouncer, connection failed", config: Config{ServiceType: model.ServiceTypePgbouncer, ConnString: "host=127.0.0.1 port=1 user=pgscv"}, want: 0},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			// No collectors enabled, 'up' metric doesn't depend on them.
+			c, err := NewPgscvCollector("test:0", Factories{}, tc.config)
+			assert.NoError(t, err)
+
+			ch := make(chan prometheus.Metric)
+			go func() {
+				c.Collect(ch)
+				close(ch)
+			}()
+
+			var found int
+			for m := range ch {
+				if !strings.Contains(m.Desc().String(), `"pgscv_up"`) {
+					continue
+				}
+				found++
+
+				metric := &dto.Metric{}
+				assert.NoError(t, m.Write(metric))
+				assert.Equal(t, tc.want, metric.GetGauge().GetValue())
+				assert.Equal(t, "service_id", metric.GetLabel()[0].GetName())
+				assert.Equal(t, "test:0", metric.GetLabel()[0].GetValue())
+			}
+
+			assert.Equal(t, 1, found)
+		})
+	}
+}
+
+func TestPgscvCollector_Collect_up_warmup(t *testing.T) {
+	// Failures during warm-up period don't mark the service as down.
+	c, err := NewPgscvCollector("test:0", Factories{}, Config{
+		ServiceType:  model.ServiceTypePgbouncer,
+		ConnString:   "host=127.0.0.1 port=1 user=pgscv",
+		WarmupPeriod: time.Minute,
+	})
+	assert.NoError(t, err)
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		c.Collect(ch)
+		close(ch)
+	}()
+
+	for m := range ch {
+		if strings.Contains(m.Desc().String(), `"pgscv_up"`) {
+			metric := &dto.Metric{}
+			assert.NoError(t, m.Write(metric))
+			assert.Equal(t, float64(1), metric.GetGauge().GetValue())
+		}
+	}
+}
+
+func TestValidateConstLabels(t *testing.T) {
+	testcases := []struct {
+		valid  bool
+		labels map[string]string
+	}{
+		{valid: true, labels: nil},
+		{valid: true, labels: map[string]string{"env": "prod", "_cluster_1": "payments"}},
+		{valid: false, labels: map[string]string{"1env": "prod"}},
+		{valid: false, labels: map[string]string{"env-name": "prod"}},
+		{valid: false, labels: map[string]string{"__env": "prod"}},
+		{valid: false, labels: map[string]string{"service_id": "example"}},
+		{valid: false, labels: map[string]string{"role": "primary"}},
+		{valid: false, labels: map[string]string{"database": "example"}},
+		{valid: false, labels: map[string]string{"user": "example"}},
+		{valid: false, labels: map[string]string{"collector": "example"}},
+		{valid: false, labels: map[string]string{"device": "example"}},
+	}
+
+	for _, tc := range testcases {
+		if tc.valid {
+			assert.NoError(t, ValidateConstLabels(tc.labels))
+		} else {
+			assert.Error(t, ValidateConstLabels(tc.labels))
+		}
+	}
+}
+
+func Test_builtinLabels(t *testing.T) {
+	f := Factories{}
+	f.RegisterSystemCollectors(nil, nil)
+	f.RegisterPostgresCollectors(nil, nil)
+	f.RegisterPgbouncerCollectors(nil, nil)
+
+	c, err := NewPgscvCollector("test:0", f, Config{})
+	assert.NoError(t, err)
+
+	// Variable labels of all descriptors of builtin collectors should be in the list.
+	var descs int
+	walkDescs(reflect.ValueOf(c), map[uintptr]bool{}, func(d *prometheus.Desc) {
+		descs++
+		s := d.String()
+		s = s[strings.LastIndex(s, "variableLabels: [")+len("variableLabels: [") : len(s)-2]
+		for _, name := range strings.Fields(s) {
+			assert.Contains(t, builtinLabels, name, d.String())
+		}
+	})
+	assert.Greater(t, descs, 100)
+}
+
+// walkDescs calls passed function for each metric descriptor found in passed value, including unexported fields.
+func walkDescs(v reflect.Value, seen map[uintptr]bool, fn func(d *prometheus.Desc)) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() || seen[v.Pointer()] {
+			return
+		}
+		seen[v.Pointer()] = true
+		// Values of unexported fields can't be used as is, use descriptors through their addresses.
+		if v.Type() == reflect.TypeOf(&prometheus.Desc{}) {
+			fn((*prometheus.Desc)(unsafe.Pointer(v.Pointer())))
+			return
+		}
+		walkDescs(v.Elem(), seen, fn)
+	case reflect.Interface:
+		walkDescs(v.Elem(), seen, fn)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			walkDescs(v.Field(i), seen, fn)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			walkDescs(v.Index(i), seen, fn)
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			walkDescs(iter.Value(), seen, fn)
+		}
+	}
+}
+
+func TestValidateMetricsPrefix(t *testing.T) {
+	assert.NoError(t, ValidateMetricsPrefix(""))
+	assert.NoError(t, ValidateMetricsPrefix("acme"))
+	assert.NoError(t, ValidateMetricsPrefix("acme_prod"))
+	assert.Error(t, ValidateMetricsPrefix("1acme"))
+	assert.Error(t, ValidateMetricsPrefix("acme-prod"))
+}
+
+func Test_newConstLabels(t *testing.T) {
+	assert.Equal(t, labels{"service_id": "test:0"}, newConstLabels("test:0", nil))
+
+	// Builtin labels have precedence over user-defined.
+	assert.Equal(t,
+		labels{"service_id": "test:0", "env": "prod"},
+		newConstLabels("test:0", map[string]string{"env": "prod", "service_id": "override"}),
+	)
+}
+
+func TestNewPgscvCollector_labels(t *testing.T) {
+	f := Factories{"test/labels": newTestCollectorFactory("")}
+	c, err := NewPgscvCollector("test:0", f, Config{Labels: map[string]string{"env": "prod"}})
+	assert.NoError(t, err)
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		c.Collect(ch)
+		close(ch)
+	}()
+
+	// All metrics of the service, including pgscv own metrics, should have user-defined labels.
+	var n int
+	for m := range ch {
+		metric := &dto.Metric{}
+		assert.NoError(t, m.Write(metric))
+
+		got := map[string]string{}
+		for _, lp := range metric.GetLabel() {
+			got[lp.GetName()] = lp.GetValue()
+		}
+		assert.Equal(t, "prod", got["env"])
+		assert.Equal(t, "test:0", got["service_id"])
+		n++
+	}
+	assert.Greater(t, n, 0)
+}
+
+func TestPgscvCollector_multipleInstances(t *testing.T) {
+	// Two Postgres instances running on the same host, distinguished by ports. System service type is used to avoid
+	// connecting to Postgres, test collectors are enough for checking series of the instances.
+	registry := prometheus.NewRegistry()
+	for _, id := range []string{"postgres:5432", "postgres:5433"} {
+		c, err := NewPgscvCollector(id, Factories{"test/ok": newTestCollectorFactory("ok")}, Config{ServiceType: model.ServiceTypeSystem})
+		assert.NoError(t, err)
+		assert.NoError(t, registry.Register(c))
+	}
+
+	// Gather fails if series of the instances collide.
+	families, err := registry.Gather()
+	assert.NoError(t, err)
+
+	for _, mf := range families {
+		if mf.GetName() != "test_metric_ok" {
+			continue
+		}
+
+		var ids []string
+		for _, m := range mf.GetMetric() {
+			for _, lp := range m.GetLabel() {
+				if lp.GetName() == "service_id" {
+					ids = append(ids, lp.GetValue())
+				}
+			}
+		}
+		assert.ElementsMatch(t, []string{"postgres:5432", "postgres:5433"}, ids)
+		return
+	}
+
+	t.Fatal("test_metric_ok not found")
+}
+
+func TestFactories_RegisterPostgresCollectors(t *testing.T) {
+	testcases := []struct {
+		name     string
+		disabled []string
+		enabled  []string
+		excluded []string
+		included []string
+	}{
+		{
+			name:     "exact names",
+			disabled: []string{"postgres/tables", "postgres/schemas"},
+			excluded: []string{"postgres/tables", "postgres/schemas"},
+			included: []string{"postgres/activity", "postgres/indexes"},
+		},
+		{
+			name:     "wildcard",
+			disabled: []string{"postgres/repl*"},
+			excluded: []string{"postgres/replication", "postgres/replication_slots"},
+			included: []string{"postgres/activity", "postgres/tables"},
+		},
+		{
+			name:     "group",
+			disabled: []string{"postgres"},
+			excluded: []string{"postgres/activity", "postgres/tables", "postgres/replication"},
+		},
+		{
+			name:     "enable beats disable",
+			disabled: []string{"postgres/*"},
+			enabled:  []string{"postgres/activity", "postgres/repl*"},
+			excluded: []string{"postgres/tables", "postgres/schemas"},
+			included: []string{"postgres/activity", "postgres/replication", "postgres/replication_slots"},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			f := Factories{}
+			f.RegisterPostgresCollectors(tc.disabled, tc.enabled)
+
+			for _, name := range tc.excluded {
+				assert.NotContains(t, f, name)
+			}
+			for _, name := range tc.included {
+				assert.Contains(t, f, name)
+			}
+		})
+	}
+}
+
+func Test_matchCollectorPatterns(t *testing.T) {
+	testcases := []struct {
+		name     string
+		patterns []string
+		want     bool
+	}{
+		{name: "system/cpu", patterns: []string{"system/cpu"}, want: true},
+		{name: "system/cpu", patterns: []string{"system"}, want: true},
+		{name: "system/cpu", patterns: []string{"system/*"}, want: true},
+		{name: "system/cpu", patterns: []string{"*/cpu"}, want: true},
+		{name: "system/cpu", patterns: []string{"sys"}, want: false},
+		{name: "system/cpu", patterns: []string{"postgres/*", "pgbouncer"}, want: false},
+		{name: "postgres/schemas", patterns: []string{"postgres/schema*"}, want: true},
+		{name: "postgres/schemas", patterns: []string{"postgres/[invalid"}, want: false},
+		{name: "postgres/schemas", patterns: nil, want: false},
+	}
+
+	for _, tc := range testcases {
+		assert.Equal(t, tc.want, matchCollectorPatterns(tc.name, tc.patterns))
+	}
+}
+
+func TestValidateCollectorPattern(t *testing.T) {
+	assert.NoError(t, ValidateCollectorPattern("postgres/*"))
+	assert.NoError(t, ValidateCollectorPattern("postgres/tables"))
+	assert.Error(t, ValidateCollectorPattern("postgres/[invalid"))
+}
+
+// testCollector is the collector used for testing collectors isolation.
+type testCollector struct {
+	desc    typedDesc
+	fail    string
+	aborted chan struct{} // closed when 'slow' collector is aborted by scrape context
+}
+
+func newTestCollectorFactory(fail string) func(labels, model.CollectorSettings) (Collector, error) {
+	return func(constLabels labels, settings model.CollectorSettings) (Collector, error) {
+		return &testCollector{
+			desc: newBuiltinTypedDesc(
+				descOpts{"test", "", "metric_" + fail, "Test metric.", 0},
+				prometheus.GaugeValue,
+				nil, constLabels,
+				settings.Filters,
+			),
+			fail:    fail,
+			aborted: make(chan struct{}),
+		}, nil
+	}
+}
+
+// Update method sends single metric and fails accordingly to specified failure mode.
+func (c *testCollector) Update(config Config, ch chan<- prometheus.Metric) error {
+	ch <- c.desc.newConstMetric(1)
+
+	switch c.fail {
+	case "panic":
+		panic("test panic")
+	case "error":
+		return errors.New("test error")
+	case "slow":
+		select {
+		case <-time.After(time.Second):
+			return nil
+		case <-config.scrapeContext().Done():
+			close(c.aborted)
+			return config.scrapeContext().Err()
+		}
+	default:
+		return nil
+	}
+}
+
+func TestPgscvCollector_Collect_isolation(t *testing.T) {
+	f := Factories{
+		"test/ok":    newTestCollectorFactory("ok"),
+		"test/panic": newTestCollectorFactory("panic"),
+		"test/error": newTestCollectorFactory("error"),
+	}
+
+	c, err := NewPgscvCollector("test:0", f, Config{})
+	assert.NoError(t, err)
+
+	ch := make(chan prometheus.Metric)
+
+	go func() {
+		c.Collect(ch)
+		close(ch)
+	}()
+
+	names := map[string]int{}
+	success := map[string]float64{}
+	for m := range ch {
+		desc := m.Desc().String()
+		for _, name := range []string{"test_metric_ok", "test_metric_panic", "test_metric_error", "pgscv_collector_duration_seconds"} {
+			if strings.Contains(desc, `"`+name+`"`) {
+				names[name]++
+			}
+		}
+
+		if strings.Contains(desc, `"pgscv_collector_success"`) {
+			metric := &dto.Metric{}
+			assert.NoError(t, m.Write(metric))
+			success[metric.GetLabel()[0].GetValue()] = metric.GetGauge().GetValue()
+		}
+	}
+
+	// All collectors produce their metrics, including failed ones.
+	assert.Equal(t, map[string]int{"test_metric_ok": 1, "test_metric_panic": 1, "test_metric_error": 1, "pgscv_collector_duration_seconds": 3}, names)
+	assert.Equal(t, map[string]float64{"test/ok": 1, "test/panic": 0, "test/error": 0}, success)
+}
+
+func TestPgscvCollector_Collect_lastError(t *testing.T) {
+	c, err := NewPgscvCollector("test:0", Factories{"test/flaky": newTestCollectorFactory("error")}, Config{})
+	assert.NoError(t, err)
+
+	// lastErrors returns labels of collectors' errors produced during the scrape.
+	lastErrors := func() []map[string]string {
+		ch := make(chan prometheus.Metric)
+		go func() {
+			c.Collect(ch)
+			close(ch)
+		}()
+
+		var res []map[string]string
+		for m := range ch {
+			if !strings.Contains(m.Desc().String(), `"pgscv_collector_last_error"`) {
+				continue
+			}
+
+			metric := &dto.Metric{}
+			assert.NoError(t, m.Write(metric))
+			assert.Equal(t, float64(1), metric.GetGauge().GetValue())
+
+			got := map[string]string{}
+			for _, lp := range metric.GetLabel() {
+				got[lp.GetName()] = lp.GetValue()
+			}
+			res = append(res, got)
+		}
+		return res
+	}
+
+	// Error is exposed when collector fails.
+	assert.Equal(t, []map[string]string{{"collector": "test/flaky", "error": "other", "service_id": "test:0"}}, lastErrors())
+
+	// Error is cleared when collector succeeds.
+	c.Collectors["test/flaky"].(*testCollector).fail = "ok"
+	assert.Nil(t, lastErrors())
+}
+
+func TestPgscvCollector_CollectContext(t *testing.T) {
+	f := Factories{
+		"test/ok":   newTestCollectorFactory("ok"),
+		"test/slow": newTestCollectorFactory("slow"),
+	}
+
+	c, err := NewPgscvCollector("test:0", f, Config{})
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	ch := make(chan prometheus.Metric)
+	start := time.Now()
+	go func() {
+		c.CollectContext(ctx, ch)
+		close(ch)
+	}()
+
+	success := map[string]float64{}
+	lastErrors := map[string]string{}
+	for m := range ch {
+		desc := m.Desc().String()
+		metric := &dto.Metric{}
+		assert.NoError(t, m.Write(metric))
+
+		switch {
+		case strings.Contains(desc, `"pgscv_collector_success"`):
+			success[metric.GetLabel()[0].GetValue()] = metric.GetGauge().GetValue()
+		case strings.Contains(desc, `"pgscv_collector_last_error"`):
+			lastErrors[metric.GetLabel()[0].GetValue()] = metric.GetLabel()[1].GetValue()
+		}
+	}
+
+	// Collect returns when context is done, slow collector is considered as failed.
+	assert.True(t, time.Since(start) < time.Second)
+	assert.Equal(t, map[string]float64{"test/ok": 1, "test/slow": 0}, success)
+	assert.Equal(t, map[string]string{"test/slow": "timeout"}, lastErrors)
+	assert.EqualError(t, c.LastScrape().Err, "test/slow collector aborted: context deadline exceeded")
+
+	// Context is passed to collectors, hence slow collector is aborted too.
+	select {
+	case <-c.Collectors["test/slow"].(*testCollector).aborted:
+	case <-time.After(time.Second):
+		t.Fatal("slow collector has not been aborted")
+	}
+}
+
+func Test_errorClass(t *testing.T) {
+	var testcases = []struct {
+		err  error
+		want string
+	}{
+		{err: context.DeadlineExceeded, want: "timeout"},
+		{err: fmt.Errorf("query 'SELECT 1' cancelled: %w", context.DeadlineExceeded), want: "timeout"},
+		{err: context.Canceled, want: "canceled"},
+		{err: fmt.Errorf("%w: %v", errCollectorPanic, "test panic"), want: "panic"},
+		{err: &pgconn.PgError{Code: "42P01", Message: `relation "example" does not exist`}, want: "server"},
+		{err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, want: "connection"},
+		{err: &net.DNSError{Err: "i/o timeout", IsTimeout: true}, want: "timeout"},
+		{err: errors.New("parse value failed: invalid input 'example'"), want: "other"},
+	}
+
+	for _, tc := range testcases {
+		assert.Equal(t, tc.want, errorClass(tc.err))
+	}
+}
+
+func TestPgscvCollector_LastScrape(t *testing.T) {
+	collectAll := func(c *PgscvCollector) {
+		ch := make(chan prometheus.Metric)
+		go func() {
+			c.Collect(ch)
+			close(ch)
+		}()
+		for range ch {
+		}
+	}
+
+	// Not scraped yet.
+	c, err := NewPgscvCollector("test:0", Factories{"test/ok": newTestCollectorFactory("ok")}, Config{})
+	assert.NoError(t, err)
+	assert.Equal(t, ScrapeStatus{}, c.LastScrape())
+
+	collectAll(c)
+	status := c.LastScrape()
+	assert.False(t, status.Time.IsZero())
+	assert.NoError(t, status.Err)
+
+	// Errors of all failed collectors are reported.
+	c, err = NewPgscvCollector("test:0", Factories{
+		"test/ok":    newTestCollectorFactory("ok"),
+		"test/panic": newTestCollectorFactory("panic"),
+		"test/error": newTestCollectorFactory("error"),
+	}, Config{})
+	assert.NoError(t, err)
+
+	collectAll(c)
+	status = c.LastScrape()
+	assert.False(t, status.Time.IsZero())
+	assert.EqualError(t, status.Err, "test/error collector failed: test error; test/panic collector failed: collector panic: test panic")
+}
+
+func Test_newCollectorSettings(t *testing.T) {
+	// Builtin settings are used if not specified in configuration.
+	got := newCollectorSettings("postgres/conflicts", nil)
+	assert.True(t, got.RunOnlyOnStandby)
+	assert.False(t, got.RunOnlyOnPrimary)
+
+	// Settings from configuration have precedence.
+	got = newCollectorSettings("postgres/conflicts", model.CollectorsSettings{"postgres/conflicts": {RunOnlyOnPrimary: true}})
+	assert.True(t, got.RunOnlyOnPrimary)
+	assert.False(t, got.RunOnlyOnStandby)
+
+	got = newCollectorSettings("postgres/tables", model.CollectorsSettings{"postgres/tables": {RunOnlyOnPrimary: true}})
+	assert.True(t, got.RunOnlyOnPrimary)
+	assert.False(t, got.RunOnlyOnStandby)
+
+	got = newCollectorSettings("postgres/tables", nil)
+	assert.False(t, got.RunOnlyOnPrimary)
+	assert.False(t, got.RunOnlyOnStandby)
+}
+
+func Test_isCollectorAllowed(t *testing.T) {
+	primary := Config{ServiceType: model.ServiceTypePostgresql}
+	standby := Config{ServiceType: model.ServiceTypePostgresql, postgresServiceConfig: postgresServiceConfig{inRecovery: true}}
+
+	testcases := []struct {
+		name     string
+		settings model.CollectorSettings
+		config   Config
+		want     bool
+	}{
+		{name: "any on primary", settings: model.CollectorSettings{}, config: primary, want: true},
+		{name: "any on standby", settings: model.CollectorSettings{}, config: standby, want: true},
+		{name: "primary only on primary", settings: model.CollectorSettings{RunOnlyOnPrimary: true}, config: primary, want: true},
+		{name: "primary only on standby", settings: model.CollectorSettings{RunOnlyOnPrimary: true}, config: standby, want: false},
+		{name: "standby only on primary", settings: model.CollectorSettings{RunOnlyOnStandby: true}, config: primary, want: false},
+		{name: "standby only on standby", settings: model.CollectorSettings{RunOnlyOnStandby: true}, config: standby, want: true},
+		{name: "not postgres", settings: model.CollectorSettings{RunOnlyOnStandby: true}, config: Config{ServiceType: model.ServiceTypePgbouncer}, want: true},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, isCollectorAllowed(tc.settings, tc.config))
+		})
+	}
+}
+
+func TestPgscvCollector_Collect_recoveryState(t *testing.T) {
+	f := Factories{
+		"test/primary": newTestCollectorFactory("primary"),
+		"test/standby": newTestCollectorFactory("standby"),
+	}
+
+	// Service config with empty connection string is considered as not in recovery.
+	c, err := NewPgscvCollector("test:0", f, Config{
+		ServiceType: model.ServiceTypePostgresql,
+		Settings: model.CollectorsSettings{
+			"test/primary": {RunOnlyOnPrimary: true},
+			"test/standby": {RunOnlyOnStandby: true},
+		},
+	})
+	assert.NoError(t, err)
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		c.Collect(ch)
+		close(ch)
+	}()
+
+	names := map[string]int{}
+	for m := range ch {
+		desc := m.Desc().String()
+		for _, name := range []string{"test_metric_primary", "test_metric_standby"} {
+			if strings.Contains(desc, `"`+name+`"`) {
+				names[name]++
+			}
+		}
+	}
+
+	assert.Equal(t, map[string]int{"test_metric_primary": 1}, names)
+}
+
+func TestPgscvCollector_Check(t *testing.T) {
+	f := Factories{
+		"test/ok":      newTestCollectorFactory("ok"),
+		"test/error":   newTestCollectorFactory("error"),
+		"test/panic":   newTestCollectorFactory("panic"),
+		"test/standby": newTestCollectorFactory("standby"),
+	}
+
+	// Service config with empty connection string is considered as not in recovery.
+	c, err := NewPgscvCollector("test:0", f, Config{
+		ServiceType: model.ServiceTypePostgresql,
+		Settings:    model.CollectorsSettings{"test/standby": {RunOnlyOnStandby: true}},
+	})
+	assert.NoError(t, err)
+
+	got, err := c.Check()
+	assert.NoError(t, err)
+	assert.Len(t, got, 3)
+
+	assert.Equal(t, "test/error", got[0].Collector)
+	assert.Equal(t, 1, got[0].Metrics)
+	assert.Error(t, got[0].Err)
+
+	assert.Equal(t, "test/ok", got[1].Collector)
+	assert.Equal(t, 1, got[1].Metrics)
+	assert.NoError(t, got[1].Err)
+
+	assert.Equal(t, "test/panic", got[2].Collector)
+	assert.Equal(t, 1, got[2].Metrics)
+	assert.Error(t, got[2].Err)
+
+	// Service is not available.
+	c, err = NewPgscvCollector("test:0", f, Config{ServiceType: model.ServiceTypePostgresql, ConnString: "invalid"})
+	assert.NoError(t, err)
+
+	_, err = c.Check()
+	assert.Error(t, err)
+}
+
+// testSeriesCollector is the collector used for testing series limit.
+type testSeriesCollector struct {
+	desc   typedDesc
+	n      int
+	prefix string // prefix of labels values
+}
+
+// Update method sends specified number of series.
+func (c *testSeriesCollector) Update(_ Config, ch chan<- prometheus.Metric) error {
+	for i := 0; i < c.n; i++ {
+		ch <- c.desc.newConstMetric(float64(i), c.prefix+strconv.Itoa(i))
+	}
+	return nil
+}
+
+func TestPgscvCollector_Collect_seriesLimit(t *testing.T) {
+	f := Factories{
+		"test/series": func(constLabels labels, settings model.CollectorSettings) (Collector, error) {
+			return &testSeriesCollector{
+				desc: newBuiltinTypedDesc(
+					descOpts{"test", "", "series", "Test metric.", 0},
+					prometheus.GaugeValue,
+					[]string{"id"}, constLabels,
+					settings.Filters,
+				),
+				n: 10,
+			}, nil
+		},
+	}
+
+	c, err := NewPgscvCollector("test:0", f, Config{MaxSeriesPerService: 4})
+	assert.NoError(t, err)
+
+	// Run collect twice, dropped series counter should grow between scrapes.
+	for _, want := range []float64{6, 12} {
+		ch := make(chan prometheus.Metric)
+
+		go func() {
+			c.Collect(ch)
+			close(ch)
+		}()
+
+		var series int
+		var dropped float64
+		var success bool
+		for m := range ch {
+			desc := m.Desc().String()
+			switch {
+			case strings.Contains(desc, `"test_series"`):
+				series++
+			case strings.Contains(desc, `"pgscv_series_dropped_total"`):
+				metric := &dto.Metric{}
+				assert.NoError(t, m.Write(metric))
+				dropped = metric.GetCounter().GetValue()
+			case strings.Contains(desc, `"pgscv_collector_success"`):
+				success = true
+			}
+		}
+
+		assert.Equal(t, 4, series)
+		assert.Equal(t, want, dropped)
+		assert.True(t, success) // service metrics are not limited
+	}
+}
+
+func TestPgscvCollector_Collect_allowList(t *testing.T) {
+	SetMetricsAllowList([]string{"node_*", "pgscv_up"})
+	defer SetMetricsAllowList(nil)
+
+	f := Factories{
+		"test/series": func(constLabels labels, settings model.CollectorSettings) (Collector, error) {
+			return &testSeriesCollector{
+				desc: newBuiltinTypedDesc(
+					descOpts{"node", "", "series", "Test metric.", 0},
+					prometheus.GaugeValue,
+					[]string{"id"}, constLabels,
+					settings.Filters,
+				),
+				n: 2,
+			}, nil
+		},
+	}
+
+	c, err := NewPgscvCollector("system:0", f, Config{ServiceType: model.ServiceTypeSystem})
+	assert.NoError(t, err)
+
+	// Own metrics of pgSCV not matched by allow-list are not sent, gathering doesn't fail.
+	reg := prometheus.NewRegistry()
+	assert.NoError(t, reg.Register(c))
+
+	families, err := reg.Gather()
+	assert.NoError(t, err)
+
+	var names []string
+	for _, f := range families {
+		names = append(names, f.GetName())
+	}
+	assert.Equal(t, []string{"node_series", "pgscv_up"}, names)
+}
+
+func TestPgscvCollector_Collect_allowListUp(t *testing.T) {
+	SetMetricsAllowList([]string{"node_*"})
+	defer SetMetricsAllowList(nil)
+
+	c, err := NewPgscvCollector("system:0", Factories{}, Config{ServiceType: model.ServiceTypeSystem})
+	assert.NoError(t, err)
+
+	// The 'up' metric is not sent when it is not allowed.
+	reg := prometheus.NewRegistry()
+	assert.NoError(t, reg.Register(c))
+
+	families, err := reg.Gather()
+	assert.NoError(t, err)
+	assert.Len(t, families, 0)
+}
+
+func TestPgscvCollector_Collect_truncatedDuplicates(t *testing.T) {
+	SetMaxLabelValueLength(8)
+	defer SetMaxLabelValueLength(0)
+
+	f := Factories{
+		"test/series": func(constLabels labels, settings model.CollectorSettings) (Collector, error) {
+			return &testSeriesCollector{
+				desc: newBuiltinTypedDesc(
+					descOpts{"test", "", "series", "Test metric.", 0},
+					prometheus.GaugeValue,
+					[]string{"id"}, constLabels,
+					settings.Filters,
+				),
+				n:      12,
+				prefix: "long_value_",
+			}, nil
+		},
+	}
+
+	c, err := NewPgscvCollector("test:0", f, Config{})
+	assert.NoError(t, err)
+
+	// Series with labels values identical after truncation are dropped, hence gathering doesn't fail.
+	reg := prometheus.NewRegistry()
+	assert.NoError(t, reg.Register(c))
+
+	families, err := reg.Gather()
+	assert.NoError(t, err)
+
+	var series int
+	var dropped float64
+	for _, f := range families {
+		switch f.GetName() {
+		case "test_series":
+			series = len(f.GetMetric())
+		case "pgscv_series_dropped_total":
+			dropped = f.GetMetric()[0].GetCounter().GetValue()
+		}
+	}
+
+	assert.Equal(t, 1, series)
+	assert.Equal(t, float64(11), dropped)
+}
+
+func TestPgscvCollector_Collect_collectorSeriesLimit(t *testing.T) {
+	newFactory := func(name string, n int) func(labels, model.CollectorSettings) (Collector, error) {
+		return func(constLabels labels, settings model.CollectorSettings) (Collector, error) {
+			return &testSeriesCollector{
+				desc: newBuiltinTypedDesc(
+					descOpts{"test", "", name, "Test metric.", 0},
+					prometheus.GaugeValue,
+					[]string{"id"}, constLabels,
+					settings.Filters,
+				),
+				n: n,
+			}, nil
+		}
+	}
+
+	f := Factories{
+		"test/large": newFactory("large", 10),
+		"test/small": newFactory("small", 3),
+	}
+
+	c, err := NewPgscvCollector("test:0", f, Config{MaxSeriesPerCollector: 4})
+	assert.NoError(t, err)
+
+	ch := make(chan prometheus.Metric)
+
+	go func() {
+		c.Collect(ch)
+		close(ch)
+	}()
+
+	series := map[string]int{}
+	var dropped float64
+	for m := range ch {
+		desc := m.Desc().String()
+		switch {
+		case strings.Contains(desc, `"test_large"`):
+			series["large"]++
+		case strings.Contains(desc, `"test_small"`):
+			series["small"]++
+		case strings.Contains(desc, `"pgscv_series_dropped_total"`):
+			metric := &dto.Metric{}
+			assert.NoError(t, m.Write(metric))
+			dropped = metric.GetCounter().GetValue()
+		}
+	}
+
+	// Only the collector exceeding the limit is truncated.
+	assert.Equal(t, map[string]int{"large": 4, "small": 3}, series)
+	assert.Equal(t, float64(6), dropped)
+}
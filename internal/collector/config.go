@@ -10,6 +10,8 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 // Config defines collector's global configuration.
@@ -19,16 +21,66 @@ type Config struct {
 	ServiceType string
 	// ConnString defines a connection string used to connecting to the service
 	ConnString string
+	// Pool defines pool of connections of the service used for connecting to the service, connections are not pooled if nil.
+	Pool *store.Pool
 	// NoTrackMode controls collector to gather and send sensitive information, such as queries texts.
 	NoTrackMode bool
 	// postgresServiceConfig defines collector's options specific for Postgres service
 	postgresServiceConfig
 	// DatabasesRE defines regexp with databases from which builtin metrics should be collected.
 	DatabasesRE *regexp.Regexp
+	// DatabasesExcludeRE defines regexp with databases which should be skipped by per-database collectors.
+	DatabasesExcludeRE *regexp.Regexp
 	// Settings defines collectors settings propagated from main YAML configuration.
 	Settings model.CollectorsSettings
+	// WarmupPeriod defines period after start during which failed services are not marked as down.
+	WarmupPeriod time.Duration
+	// DatabasesConcurrency defines max number of databases processed concurrently by per-database collectors.
+	DatabasesConcurrency int
+	// MaxSeriesPerService defines max number of series exported per service during single scrape, 0 means unlimited.
+	MaxSeriesPerService int
+	// MaxSeriesPerCollector defines max number of series exported by each collector during single scrape, 0 means unlimited.
+	MaxSeriesPerCollector int
+	// Statements defines settings of pg_stat_statements collector.
+	Statements StatementsConfig
+	// Labels defines user-defined constant labels attached to all metrics of the service.
+	Labels map[string]string
+	// ctx defines context of the current scrape, queries of collectors are cancelled when it is done.
+	ctx context.Context
 }
 
+// scrapeContext returns context of the current scrape which should be used for connecting and executing queries.
+func (cfg Config) scrapeContext() context.Context {
+	if cfg.ctx == nil {
+		return context.Background()
+	}
+	return cfg.ctx
+}
+
+// StatementsConfig defines settings of pg_stat_statements collector.
+type StatementsConfig struct {
+	// ExecTimeStats enables collecting of mean, min, max and stddev of statements execution time (since Postgres 13).
+	ExecTimeStats bool `yaml:"exec_time_stats"`
+	// TopN defines max number of statements (with the highest total time) exposed per service, the rest statements are
+	// aggregated into 'others' per database. Zero means unlimited.
+	TopN int `yaml:"top_n"`
+	// Redact defines how statements texts are exposed: 'hash' replaces query text with SHA-256 of the normalized query,
+	// 'drop' doesn't expose query texts at all. Empty means query texts are exposed as is.
+	Redact string `yaml:"redact"`
+	// Normalize enables replacing constants in query texts with placeholders before query texts are exposed or hashed.
+	Normalize bool `yaml:"normalize"`
+	// Database defines database where pg_stat_statements is looked up first. It is useful when the extension is
+	// installed in several databases. Empty means the database from connection string is looked up first.
+	Database string `yaml:"database"`
+}
+
+const (
+	// StatementsRedactHash defines redact mode which replaces query text with its hash.
+	StatementsRedactHash = "hash"
+	// StatementsRedactDrop defines redact mode which drops query text.
+	StatementsRedactDrop = "drop"
+)
+
 // postgresServiceConfig defines Postgres-specific stuff required during collecting Postgres metrics.
 type postgresServiceConfig struct {
 	// localService defines service is running on the local host.
@@ -49,10 +101,13 @@ type postgresServiceConfig struct {
 	pgStatStatementsDatabase string
 	// pgStatStatementsSchema defines the schema name where pg_stat_statements is installed
 	pgStatStatementsSchema string
+	// inRecovery defines Postgres is in recovery (is a standby).
+	inRecovery bool
 }
 
-// newPostgresServiceConfig defines new config for Postgres-based collectors.
-func newPostgresServiceConfig(connStr string) (postgresServiceConfig, error) {
+// newPostgresServiceConfig defines new config for Postgres-based collectors. Location of pg_stat_statements is taken
+// from passed discovery, which refreshes it periodically. Connection is acquired from passed pool of the service.
+func newPostgresServiceConfig(ctx context.Context, pool *store.Pool, connStr string, statements *pgStatStatementsDiscovery) (postgresServiceConfig, error) {
 	var config = postgresServiceConfig{}
 
 	// Return empty config if empty connection string.
@@ -68,7 +123,7 @@ func newPostgresServiceConfig(connStr string) (postgresServiceConfig, error) {
 	// Determine is service running locally.
 	config.localService = isAddressLocal(pgconfig.Host)
 
-	conn, err := store.NewWithConfig(pgconfig)
+	conn, err := store.NewPooledWithConfig(ctx, pool, pgconfig)
 	if err != nil {
 		return config, err
 	}
@@ -77,7 +132,10 @@ func newPostgresServiceConfig(connStr string) (postgresServiceConfig, error) {
 	var setting string
 
 	// Get Postgres block size.
-	err = conn.Conn().QueryRow(context.Background(), "SELECT setting FROM pg_settings WHERE name = 'block_size'").Scan(&setting)
+	ctx, cancel := conn.Context()
+	defer cancel()
+
+	err = conn.Conn().QueryRow(ctx, "SELECT setting FROM pg_settings WHERE name = 'block_size'").Scan(&setting)
 	if err != nil {
 		return config, err
 	}
@@ -89,7 +147,7 @@ func newPostgresServiceConfig(connStr string) (postgresServiceConfig, error) {
 	config.blockSize = bsize
 
 	// Get Postgres WAL segment size.
-	err = conn.Conn().QueryRow(context.Background(), "SELECT setting FROM pg_settings WHERE name = 'wal_segment_size'").Scan(&setting)
+	err = conn.Conn().QueryRow(ctx, "SELECT setting FROM pg_settings WHERE name = 'wal_segment_size'").Scan(&setting)
 	if err != nil {
 		return config, err
 	}
@@ -101,7 +159,7 @@ func newPostgresServiceConfig(connStr string) (postgresServiceConfig, error) {
 	config.walSegmentSize = walSegSize
 
 	// Get Postgres server version
-	err = conn.Conn().QueryRow(context.Background(), "SELECT setting FROM pg_settings WHERE name = 'server_version_num'").Scan(&setting)
+	err = conn.Conn().QueryRow(ctx, "SELECT setting FROM pg_settings WHERE name = 'server_version_num'").Scan(&setting)
 	if err != nil {
 		return config, err
 	}
@@ -117,7 +175,7 @@ func newPostgresServiceConfig(connStr string) (postgresServiceConfig, error) {
 	config.serverVersionNum = version
 
 	// Get Postgres data directory
-	err = conn.Conn().QueryRow(context.Background(), "SELECT setting FROM pg_settings WHERE name = 'data_directory'").Scan(&setting)
+	err = conn.Conn().QueryRow(ctx, "SELECT setting FROM pg_settings WHERE name = 'data_directory'").Scan(&setting)
 	if err != nil {
 		return config, err
 	}
@@ -125,7 +183,7 @@ func newPostgresServiceConfig(connStr string) (postgresServiceConfig, error) {
 	config.dataDirectory = setting
 
 	// Get setting of 'logging_collector' GUC.
-	err = conn.Conn().QueryRow(context.Background(), "SELECT setting FROM pg_settings WHERE name = 'logging_collector'").Scan(&setting)
+	err = conn.Conn().QueryRow(ctx, "SELECT setting FROM pg_settings WHERE name = 'logging_collector'").Scan(&setting)
 	if err != nil {
 		return config, err
 	}
@@ -134,14 +192,16 @@ func newPostgresServiceConfig(connStr string) (postgresServiceConfig, error) {
 		config.loggingCollector = true
 	}
 
-	// Discover pg_stat_statements.
-	exists, database, schema, err := discoverPgStatStatements(connStr)
+	// Get recovery state, it is used for running collectors which are specific for primary or standby.
+	err = conn.Conn().QueryRow(ctx, "SELECT pg_is_in_recovery()").Scan(&config.inRecovery)
 	if err != nil {
 		return config, err
 	}
 
-	if !exists {
-		log.Warnln("pg_stat_statements not found, skip collecting statements metrics")
+	// Discover pg_stat_statements.
+	exists, database, schema, err := statements.get(connStr)
+	if err != nil {
+		return config, err
 	}
 
 	config.pgStatStatements = exists
@@ -181,8 +241,60 @@ func isAddressLocal(addr string) bool {
 	return false
 }
 
-// discoverPgStatStatements discovers pg_stat_statements, what database and schema it is installed.
-func discoverPgStatStatements(connStr string) (bool, string, string, error) {
+// pgStatStatementsDiscoveryInterval defines number of scrapes after which pg_stat_statements is discovered again.
+const pgStatStatementsDiscoveryInterval = 10
+
+// pgStatStatementsDiscovery keeps results of pg_stat_statements discovery. Discovery is expensive, because it might
+// walk through all databases, hence its results are reused between scrapes and refreshed periodically to notice
+// the extension has been created, dropped or moved.
+type pgStatStatementsDiscovery struct {
+	mu        sync.Mutex
+	scrapes   int
+	interval  int
+	preferred string
+	exists    bool
+	database  string
+	schema    string
+	// discover defines function used for discovering pg_stat_statements.
+	discover func(connStr string, preferred string) (bool, string, string, error)
+}
+
+// newPgStatStatementsDiscovery creates a new pg_stat_statements discovery. Preferred database is looked up first.
+func newPgStatStatementsDiscovery(preferred string) *pgStatStatementsDiscovery {
+	return &pgStatStatementsDiscovery{
+		interval:  pgStatStatementsDiscoveryInterval,
+		preferred: preferred,
+		discover:  discoverPgStatStatements,
+	}
+}
+
+// get returns whether pg_stat_statements is available, what database and schema it is installed. Discovery is made
+// at first call and then each time the interval is exceeded. Failed discovery is retried at next call.
+func (d *pgStatStatementsDiscovery) get(connStr string) (bool, string, string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.scrapes%d.interval == 0 {
+		exists, database, schema, err := d.discover(connStr, d.preferred)
+		if err != nil {
+			return false, "", "", err
+		}
+
+		if !exists {
+			log.Warnln("pg_stat_statements not found, skip collecting statements metrics")
+		}
+
+		d.exists, d.database, d.schema = exists, database, schema
+	}
+
+	d.scrapes++
+
+	return d.exists, d.database, d.schema, nil
+}
+
+// discoverPgStatStatements discovers pg_stat_statements, what database and schema it is installed. Databases are
+// looked up in the following order: preferred database, database from connection string, the rest databases.
+func discoverPgStatStatements(connStr string, preferred string) (bool, string, string, error) {
 	pgconfig, err := pgx.ParseConfig(connStr)
 	if err != nil {
 		return false, "", "", err
@@ -194,7 +306,10 @@ func discoverPgStatStatements(connStr string) (bool, string, string, error) {
 	}
 
 	var setting string
-	err = conn.Conn().QueryRow(context.Background(), "SELECT setting FROM pg_settings WHERE name = 'shared_preload_libraries'").Scan(&setting)
+	ctx, cancel := conn.Context()
+	defer cancel()
+
+	err = conn.Conn().QueryRow(ctx, "SELECT setting FROM pg_settings WHERE name = 'shared_preload_libraries'").Scan(&setting)
 	if err != nil {
 		conn.Close()
 		return false, "", "", err
@@ -206,14 +321,17 @@ func discoverPgStatStatements(connStr string) (bool, string, string, error) {
 		return false, "", "", nil
 	}
 
-	// Check for pg_stat_statements in default database specified in connection string.
-	if schema := extensionInstalledSchema(conn, "pg_stat_statements"); schema != "" {
-		conn.Close()
-		return true, conn.Conn().Config().Database, schema, nil
+	// Check for pg_stat_statements in default database specified in connection string, unless other database is preferred.
+	current := conn.Conn().Config().Database
+	if preferred == "" || preferred == current {
+		if schema := extensionInstalledSchema(conn, "pg_stat_statements"); schema != "" {
+			conn.Close()
+			return true, current, schema, nil
+		}
 	}
 
 	// Pessimistic case.
-	// If we're here it means pg_stat_statements is not available
+	// If we're here it means pg_stat_statements is not available (or other database is preferred)
 	// and we have to walk through all database and looking for it.
 
 	// Get databases list from current connection.
@@ -227,7 +345,7 @@ func discoverPgStatStatements(connStr string) (bool, string, string, error) {
 	conn.Close()
 
 	// Establish connection to each database in the list and check where pg_stat_statements is installed.
-	for _, d := range databases {
+	for _, d := range orderPgStatStatementsDatabases(databases, preferred, current) {
 		pgconfig.Database = d
 		conn, err := store.NewWithConfig(pgconfig)
 		if err != nil {
@@ -251,13 +369,39 @@ func discoverPgStatStatements(connStr string) (bool, string, string, error) {
 	return false, "", "", nil
 }
 
-// extensionInstalledSchema returns schema name where extension is installed, or empty if not installed.
+// orderPgStatStatementsDatabases returns databases in order they should be checked for pg_stat_statements: preferred
+// database (if exists), current database (if it has not been checked yet), and the rest databases.
+func orderPgStatStatementsDatabases(databases []string, preferred, current string) []string {
+	var head, tail []string
+
+	for _, d := range databases {
+		switch {
+		case d == preferred:
+			head = append([]string{d}, head...)
+		case d == current:
+			// Current database has been checked already if there is no preferred one.
+			if preferred != "" {
+				head = append(head, d)
+			}
+		default:
+			tail = append(tail, d)
+		}
+	}
+
+	return append(head, tail...)
+}
+
+// extensionInstalledSchema returns schema name where extension is installed, or empty if not installed. Schema name is
+// returned as is, hence it should be sanitized when used in queries.
 func extensionInstalledSchema(db *store.DB, name string) string {
 	log.Debugf("check %s extension availability", name)
 
 	var schema string
+	ctx, cancel := db.Context()
+	defer cancel()
+
 	err := db.Conn().
-		QueryRow(context.Background(), "SELECT extnamespace::regnamespace FROM pg_extension WHERE extname = $1", name).
+		QueryRow(ctx, "SELECT n.nspname FROM pg_extension e JOIN pg_namespace n ON e.extnamespace = n.oid WHERE e.extname = $1", name).
 		Scan(&schema)
 	if err != nil && err != pgx.ErrNoRows {
 		log.Errorf("failed to check extensions '%s' in pg_extension: %s", name, err)
@@ -1,6 +1,8 @@
 package collector
 
 import (
+	"context"
+	"fmt"
 	"github.com/lesovsky/pgscv/internal/store"
 	"github.com/stretchr/testify/assert"
 	"testing"
@@ -18,7 +20,7 @@ func Test_newPostgresServiceConfig(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			_, err := newPostgresServiceConfig(tc.connStr)
+			_, err := newPostgresServiceConfig(context.Background(), nil, tc.connStr, newPgStatStatementsDiscovery(""))
 			if tc.valid {
 				assert.NoError(t, err)
 			} else {
@@ -28,6 +30,97 @@ func Test_newPostgresServiceConfig(t *testing.T) {
 	}
 }
 
+func Test_pgStatStatementsDiscovery_get(t *testing.T) {
+	var calls int
+	d := newPgStatStatementsDiscovery("")
+
+	// pg_stat_statements is not installed at startup.
+	d.discover = func(_ string, _ string) (bool, string, string, error) {
+		calls++
+		return false, "", "", nil
+	}
+
+	// At first call discovery is made, at subsequent calls within the interval the cached values are used.
+	for i := 0; i < d.interval; i++ {
+		exists, database, schema, err := d.get("")
+		assert.NoError(t, err)
+		assert.False(t, exists)
+		assert.Equal(t, "", database)
+		assert.Equal(t, "", schema)
+	}
+	assert.Equal(t, 1, calls)
+
+	// Discovery failed, error is returned and discovery is repeated at next call.
+	d.discover = func(_ string, _ string) (bool, string, string, error) {
+		calls++
+		return false, "", "", fmt.Errorf("failed")
+	}
+	_, _, _, err := d.get("")
+	assert.Error(t, err)
+	assert.Equal(t, 2, calls)
+
+	// Extension has appeared after startup.
+	d.discover = func(_ string, _ string) (bool, string, string, error) {
+		calls++
+		return true, "testdb", "public", nil
+	}
+	exists, database, schema, err := d.get("")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, "testdb", database)
+	assert.Equal(t, "public", schema)
+	assert.Equal(t, 3, calls)
+
+	// Extension has been dropped, but it is not noticed until the interval is exceeded.
+	d.discover = func(_ string, _ string) (bool, string, string, error) {
+		calls++
+		return false, "", "", nil
+	}
+	for i := 1; i < d.interval; i++ {
+		exists, _, _, err = d.get("")
+		assert.NoError(t, err)
+		assert.True(t, exists)
+	}
+	exists, _, _, err = d.get("")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+	assert.Equal(t, 4, calls)
+}
+
+func Test_pgStatStatementsDiscovery_get_preferred(t *testing.T) {
+	d := newPgStatStatementsDiscovery("monitoringdb")
+	d.discover = func(_ string, preferred string) (bool, string, string, error) {
+		return true, preferred, "monitoring", nil
+	}
+
+	exists, database, schema, err := d.get("")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, "monitoringdb", database)
+	assert.Equal(t, "monitoring", schema)
+}
+
+func Test_orderPgStatStatementsDatabases(t *testing.T) {
+	testcases := []struct {
+		name      string
+		preferred string
+		current   string
+		want      []string
+	}{
+		{name: "no preferred", preferred: "", current: "postgres", want: []string{"db1", "db2", "db3"}},
+		{name: "preferred", preferred: "db2", current: "postgres", want: []string{"db2", "postgres", "db1", "db3"}},
+		{name: "preferred is current", preferred: "postgres", current: "postgres", want: []string{"postgres", "db1", "db2", "db3"}},
+		{name: "preferred not exists", preferred: "unknown", current: "postgres", want: []string{"postgres", "db1", "db2", "db3"}},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := orderPgStatStatementsDatabases([]string{"db1", "postgres", "db2", "db3"}, tc.preferred, tc.current)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
 func Test_isAddressLocal(t *testing.T) {
 	testcases := []struct {
 		addr string
@@ -60,7 +153,7 @@ func Test_discoverPgStatStatements(t *testing.T) {
 	}
 
 	for _, tc := range testcases {
-		exists, database, schema, err := discoverPgStatStatements(tc.connstr)
+		exists, database, schema, err := discoverPgStatStatements(tc.connstr, "")
 		if tc.valid {
 			assert.True(t, exists)
 			assert.Equal(t, "pgscv_fixtures", database)
@@ -0,0 +1,303 @@
+package collector
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/lesovsky/pgscv/internal/log"
+	"github.com/lesovsky/pgscv/internal/model"
+	"github.com/prometheus/client_golang/prometheus"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	// cgroupV1MemoryUnlimited defines threshold above which cgroup v1 memory limit is considered as unlimited. Cgroup v1
+	// uses max page counter value (aligned to page size) for unlimited memory.
+	cgroupV1MemoryUnlimited = 1 << 62
+)
+
+type cgroupCollector struct {
+	memoryLimit typedDesc
+	memoryUsage typedDesc
+	cpuQuota    typedDesc
+	cpuUsage    typedDesc
+}
+
+// NewCgroupCollector returns a new Collector exposing cgroup resources accounting stats.
+// For details see https://docs.kernel.org/admin-guide/cgroup-v2.html
+func NewCgroupCollector(constLabels labels, settings model.CollectorSettings) (Collector, error) {
+	return &cgroupCollector{
+		memoryLimit: newBuiltinTypedDesc(
+			descOpts{"node", "cgroup", "memory_limit_bytes", "Memory limit of the cgroup, in bytes.", 0},
+			prometheus.GaugeValue,
+			[]string{"version"}, constLabels,
+			settings.Filters,
+		),
+		memoryUsage: newBuiltinTypedDesc(
+			descOpts{"node", "cgroup", "memory_usage_bytes", "Memory used by the cgroup, in bytes.", 0},
+			prometheus.GaugeValue,
+			[]string{"version"}, constLabels,
+			settings.Filters,
+		),
+		cpuQuota: newBuiltinTypedDesc(
+			descOpts{"node", "cgroup", "cpu_quota", "Number of CPUs available to the cgroup according to its quota.", 0},
+			prometheus.GaugeValue,
+			[]string{"version"}, constLabels,
+			settings.Filters,
+		),
+		cpuUsage: newBuiltinTypedDesc(
+			descOpts{"node", "cgroup", "cpu_usage_seconds_total", "Total CPU time consumed by the cgroup, in seconds.", 0},
+			prometheus.CounterValue,
+			[]string{"version"}, constLabels,
+			settings.Filters,
+		),
+	}, nil
+}
+
+// Update method collects cgroup resources accounting stats.
+func (c *cgroupCollector) Update(_ Config, ch chan<- prometheus.Metric) error {
+	stat, err := getCgroupStats(sysPath("fs", "cgroup"))
+	if err != nil {
+		return fmt.Errorf("get cgroup stats failed: %s", err)
+	}
+
+	if stat.version == "" {
+		log.Debugln("cgroup filesystem is not available, skip")
+		return nil
+	}
+
+	// Limits are not sent when they are not set (unlimited), usage is not sent when it is not available (root cgroup).
+	if stat.memoryLimitOK {
+		ch <- c.memoryLimit.newConstMetric(stat.memoryLimit, stat.version)
+	}
+	if stat.cpuQuotaOK {
+		ch <- c.cpuQuota.newConstMetric(stat.cpuQuota, stat.version)
+	}
+	if stat.memoryUsageOK {
+		ch <- c.memoryUsage.newConstMetric(stat.memoryUsage, stat.version)
+	}
+	if stat.cpuUsageOK {
+		ch <- c.cpuUsage.newConstMetric(stat.cpuUsage, stat.version)
+	}
+
+	return nil
+}
+
+// cgroupStat describes cgroup resources accounting stats.
+type cgroupStat struct {
+	version       string // version of cgroup, empty if cgroup is not available
+	memoryLimit   float64
+	memoryLimitOK bool
+	memoryUsage   float64
+	memoryUsageOK bool
+	cpuQuota      float64
+	cpuQuotaOK    bool
+	cpuUsage      float64
+	cpuUsageOK    bool
+}
+
+// getCgroupStats detects cgroup version mounted into root and reads its stats. Cgroup v2 is preferred, v1 is used
+// as a fallback. Returns empty stats if none of cgroup versions are detected.
+func getCgroupStats(root string) (cgroupStat, error) {
+	if _, err := os.Stat(filepath.Join(root, "cgroup.controllers")); err == nil {
+		return getCgroupV2Stats(root)
+	}
+
+	if _, err := os.Stat(filepath.Join(root, "memory")); err == nil {
+		return getCgroupV1Stats(root)
+	}
+
+	return cgroupStat{}, nil
+}
+
+// getCgroupV2Stats reads stats of cgroup v2 from unified hierarchy. Root cgroup (e.g. on non-containerized host) has no
+// limits and memory usage files, hence missing files mean limits are not set and usage is not available.
+func getCgroupV2Stats(root string) (cgroupStat, error) {
+	stat := cgroupStat{version: "v2"}
+
+	limit, ok, err := readOptionalCgroupFile(filepath.Join(root, "memory.max"))
+	if err != nil {
+		return stat, err
+	}
+	if ok && limit != "max" {
+		stat.memoryLimit, err = strconv.ParseFloat(limit, 64)
+		if err != nil {
+			return stat, fmt.Errorf("invalid input, parse '%s' failed: %w", limit, err)
+		}
+		stat.memoryLimitOK = true
+	}
+
+	usage, ok, err := readOptionalCgroupFile(filepath.Join(root, "memory.current"))
+	if err != nil {
+		return stat, err
+	}
+	if ok {
+		stat.memoryUsage, err = strconv.ParseFloat(usage, 64)
+		if err != nil {
+			return stat, fmt.Errorf("invalid input, parse '%s' failed: %w", usage, err)
+		}
+		stat.memoryUsageOK = true
+	}
+
+	// cpu.max contains quota and period in format '$MAX $PERIOD', where quota could be 'max' (unlimited).
+	cpumax, ok, err := readOptionalCgroupFile(filepath.Join(root, "cpu.max"))
+	if err != nil {
+		return stat, err
+	}
+	if ok {
+		parts := strings.Fields(cpumax)
+		if len(parts) != 2 {
+			return stat, fmt.Errorf("invalid input, '%s': wrong number of values", cpumax)
+		}
+		if parts[0] != "max" {
+			stat.cpuQuota, err = parseCgroupCPUQuota(parts[0], parts[1])
+			if err != nil {
+				return stat, err
+			}
+			stat.cpuQuotaOK = true
+		}
+	}
+
+	stat.cpuUsage, err = parseCgroupCPUStat(filepath.Join(root, "cpu.stat"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return stat, nil
+		}
+		return stat, err
+	}
+	stat.cpuUsageOK = true
+
+	return stat, nil
+}
+
+// getCgroupV1Stats reads stats of cgroup v1 from per-controller hierarchies.
+func getCgroupV1Stats(root string) (cgroupStat, error) {
+	stat := cgroupStat{version: "v1"}
+
+	limit, err := readCgroupFile(filepath.Join(root, "memory", "memory.limit_in_bytes"))
+	if err != nil {
+		return stat, err
+	}
+	stat.memoryLimit, err = strconv.ParseFloat(limit, 64)
+	if err != nil {
+		return stat, fmt.Errorf("invalid input, parse '%s' failed: %w", limit, err)
+	}
+	stat.memoryLimitOK = stat.memoryLimit < cgroupV1MemoryUnlimited
+
+	usage, err := readCgroupFile(filepath.Join(root, "memory", "memory.usage_in_bytes"))
+	if err != nil {
+		return stat, err
+	}
+	stat.memoryUsage, err = strconv.ParseFloat(usage, 64)
+	if err != nil {
+		return stat, fmt.Errorf("invalid input, parse '%s' failed: %w", usage, err)
+	}
+	stat.memoryUsageOK = true
+
+	// Quota equal to -1 means unlimited.
+	quota, err := readCgroupFile(filepath.Join(root, "cpu", "cpu.cfs_quota_us"))
+	if err != nil {
+		return stat, err
+	}
+	if quota != "-1" {
+		period, err := readCgroupFile(filepath.Join(root, "cpu", "cpu.cfs_period_us"))
+		if err != nil {
+			return stat, err
+		}
+
+		stat.cpuQuota, err = parseCgroupCPUQuota(quota, period)
+		if err != nil {
+			return stat, err
+		}
+		stat.cpuQuotaOK = true
+	}
+
+	// cpuacct.usage contains consumed CPU time in nanoseconds.
+	cpuusage, err := readCgroupFile(filepath.Join(root, "cpuacct", "cpuacct.usage"))
+	if err != nil {
+		return stat, err
+	}
+	v, err := strconv.ParseFloat(cpuusage, 64)
+	if err != nil {
+		return stat, fmt.Errorf("invalid input, parse '%s' failed: %w", cpuusage, err)
+	}
+	stat.cpuUsage = v / 1e9
+	stat.cpuUsageOK = true
+
+	return stat, nil
+}
+
+// readCgroupFile reads single-line cgroup file and returns its trimmed content.
+func readCgroupFile(path string) (string, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(data)), nil
+}
+
+// readOptionalCgroupFile reads single-line cgroup file which might not exist. False is returned if file doesn't exist.
+func readOptionalCgroupFile(path string) (string, bool, error) {
+	content, err := readCgroupFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+
+	return content, true, nil
+}
+
+// parseCgroupCPUQuota parses CPU quota and period and returns number of CPUs available.
+func parseCgroupCPUQuota(quota, period string) (float64, error) {
+	q, err := strconv.ParseFloat(quota, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid input, parse '%s' failed: %w", quota, err)
+	}
+
+	p, err := strconv.ParseFloat(period, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid input, parse '%s' failed: %w", period, err)
+	}
+
+	if p <= 0 {
+		return 0, fmt.Errorf("invalid input, period '%s' must be positive", period)
+	}
+
+	return q / p, nil
+}
+
+// parseCgroupCPUStat reads cgroup v2 cpu.stat file and returns consumed CPU time in seconds.
+func parseCgroupCPUStat(path string) (float64, error) {
+	file, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = file.Close() }()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		parts := strings.Fields(scanner.Text())
+		if len(parts) != 2 || parts[0] != "usage_usec" {
+			continue
+		}
+
+		v, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid input, parse '%s' failed: %w", parts[1], err)
+		}
+
+		return v / 1e6, nil
+	}
+
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+
+	return 0, fmt.Errorf("usage_usec not found in %s", path)
+}
@@ -0,0 +1,142 @@
+package collector
+
+import (
+	"github.com/lesovsky/pgscv/internal/model"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCgroupCollector_Update(t *testing.T) {
+	var input = pipelineInput{
+		optional: []string{
+			"node_cgroup_memory_limit_bytes",
+			"node_cgroup_memory_usage_bytes",
+			"node_cgroup_cpu_quota",
+			"node_cgroup_cpu_usage_seconds_total",
+		},
+		collector: NewCgroupCollector,
+	}
+
+	pipeline(t, input)
+}
+
+func TestCgroupCollector_Update_sysfsPath(t *testing.T) {
+	cgroup, err := filepath.Abs("testdata/sys/fs.cgroup.v2")
+	assert.NoError(t, err)
+
+	sysfs := t.TempDir()
+	assert.NoError(t, os.Mkdir(filepath.Join(sysfs, "fs"), 0755))
+	assert.NoError(t, os.Symlink(cgroup, filepath.Join(sysfs, "fs", "cgroup")))
+
+	SetSystemPaths("", sysfs)
+	defer SetSystemPaths("", "")
+
+	c, err := NewCgroupCollector(labels{}, model.CollectorSettings{})
+	assert.NoError(t, err)
+
+	ch := make(chan prometheus.Metric, 10)
+	assert.NoError(t, c.Update(Config{}, ch))
+	close(ch)
+
+	// Fixture has memory and CPU limits, hence all four metrics are expected.
+	assert.Len(t, ch, 4)
+}
+
+func TestCgroupCollector_Update_rootCgroup(t *testing.T) {
+	cgroup, err := filepath.Abs("testdata/sys/fs.cgroup.v2.root")
+	assert.NoError(t, err)
+
+	sysfs := t.TempDir()
+	assert.NoError(t, os.Mkdir(filepath.Join(sysfs, "fs"), 0755))
+	assert.NoError(t, os.Symlink(cgroup, filepath.Join(sysfs, "fs", "cgroup")))
+
+	SetSystemPaths("", sysfs)
+	defer SetSystemPaths("", "")
+
+	c, err := NewCgroupCollector(labels{}, model.CollectorSettings{})
+	assert.NoError(t, err)
+
+	ch := make(chan prometheus.Metric, 10)
+	assert.NoError(t, c.Update(Config{}, ch))
+	close(ch)
+
+	// Root cgroup has no limits and memory usage, only CPU usage is expected.
+	assert.Len(t, ch, 1)
+}
+
+func Test_getCgroupStats(t *testing.T) {
+	testcases := []struct {
+		name  string
+		root  string
+		valid bool
+		want  cgroupStat
+	}{
+		{
+			name:  "cgroup v2",
+			root:  "testdata/sys/fs.cgroup.v2",
+			valid: true,
+			want: cgroupStat{
+				version: "v2", memoryLimit: 2147483648, memoryLimitOK: true, memoryUsage: 1073741824, memoryUsageOK: true,
+				cpuQuota: 2, cpuQuotaOK: true, cpuUsage: 123.456789, cpuUsageOK: true,
+			},
+		},
+		{
+			name:  "cgroup v2 unlimited",
+			root:  "testdata/sys/fs.cgroup.v2.unlimited",
+			valid: true,
+			want:  cgroupStat{version: "v2", memoryUsage: 1073741824, memoryUsageOK: true, cpuUsage: 123.456789, cpuUsageOK: true},
+		},
+		{
+			name:  "cgroup v2 root",
+			root:  "testdata/sys/fs.cgroup.v2.root",
+			valid: true,
+			want:  cgroupStat{version: "v2", cpuUsage: 4567.891234, cpuUsageOK: true},
+		},
+		{
+			name:  "cgroup v1",
+			root:  "testdata/sys/fs.cgroup.v1",
+			valid: true,
+			want: cgroupStat{
+				version: "v1", memoryLimit: 536870912, memoryLimitOK: true, memoryUsage: 268435456, memoryUsageOK: true,
+				cpuQuota: 0.5, cpuQuotaOK: true, cpuUsage: 98.7654321, cpuUsageOK: true,
+			},
+		},
+		{
+			name:  "no cgroup",
+			root:  "testdata/sys/unknown",
+			valid: true,
+			want:  cgroupStat{},
+		},
+		{
+			name:  "invalid cgroup",
+			root:  "testdata/sys/fs.cgroup.v2.invalid",
+			valid: false,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := getCgroupStats(tc.root)
+			if tc.valid {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.want, got)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func Test_parseCgroupCPUQuota(t *testing.T) {
+	got, err := parseCgroupCPUQuota("150000", "100000")
+	assert.NoError(t, err)
+	assert.Equal(t, 1.5, got)
+
+	for _, tc := range [][2]string{{"invalid", "100000"}, {"150000", "invalid"}, {"150000", "0"}} {
+		_, err = parseCgroupCPUQuota(tc[0], tc[1])
+		assert.Error(t, err)
+	}
+}
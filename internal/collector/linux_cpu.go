@@ -20,8 +20,10 @@ type cpuCollector struct {
 	cpu      typedDesc
 	cpuAll   typedDesc
 	cpuGuest typedDesc
+	cpuCore  typedDesc
 	uptime   typedDesc
 	idletime typedDesc
+	perCPU   bool // collect usage stats of each CPU core
 }
 
 // NewCPUCollector returns a new Collector exposing kernel/system statistics.
@@ -39,6 +41,7 @@ func NewCPUCollector(constLabels labels, settings model.CollectorSettings) (Coll
 
 	c := &cpuCollector{
 		systicks: systicks,
+		perCPU:   settings.PerCPU,
 		cpu: newBuiltinTypedDesc(
 			descOpts{"node", "cpu", "seconds_total", "Seconds the CPUs spent in each mode.", 0},
 			prometheus.CounterValue,
@@ -57,6 +60,12 @@ func NewCPUCollector(constLabels labels, settings model.CollectorSettings) (Coll
 			[]string{"mode"}, constLabels,
 			settings.Filters,
 		),
+		cpuCore: newBuiltinTypedDesc(
+			descOpts{"node", "cpu_core", "seconds_total", "Seconds each CPU core spent in each mode.", 0},
+			prometheus.CounterValue,
+			[]string{"cpu", "mode"}, constLabels,
+			settings.Filters,
+		),
 		uptime: newBuiltinTypedDesc(
 			descOpts{"node", "uptime", "up_seconds_total", "Total number of seconds the system has been up, accordingly to /proc/uptime.", 0},
 			prometheus.CounterValue,
@@ -74,13 +83,13 @@ func NewCPUCollector(constLabels labels, settings model.CollectorSettings) (Coll
 }
 
 // Update implements Collector and exposes cpu related metrics from /proc/stat and /sys/.../cpu/.
-func (c *cpuCollector) Update(_ Config, ch chan<- prometheus.Metric) error {
+func (c *cpuCollector) Update(config Config, ch chan<- prometheus.Metric) error {
 	stat, err := getCPUStat(c.systicks)
 	if err != nil {
 		return fmt.Errorf("collect cpu usage stats failed: %s; skip", err)
 	}
 
-	uptime, idletime, err := getProcUptime("/proc/uptime")
+	uptime, idletime, err := getProcUptime(procPath("uptime"))
 	if err != nil {
 		return fmt.Errorf("collect uptime stats failed: %s; skip", err)
 	}
@@ -105,6 +114,27 @@ func (c *cpuCollector) Update(_ Config, ch chan<- prometheus.Metric) error {
 	ch <- c.uptime.newConstMetric(uptime)
 	ch <- c.idletime.newConstMetric(idletime)
 
+	// Per-core stats are collected only if explicitly enabled, because of high cardinality on hosts with many cores.
+	if !c.perCPU {
+		return nil
+	}
+
+	cores, err := getCPUCoresStat(c.systicks)
+	if err != nil {
+		return fmt.Errorf("collect per-cpu usage stats failed: %s; skip", err)
+	}
+
+	for cpu, s := range cores {
+		ch <- c.cpuCore.newConstMetric(s.user, cpu, "user")
+		ch <- c.cpuCore.newConstMetric(s.nice, cpu, "nice")
+		ch <- c.cpuCore.newConstMetric(s.system, cpu, "system")
+		ch <- c.cpuCore.newConstMetric(s.idle, cpu, "idle")
+		ch <- c.cpuCore.newConstMetric(s.iowait, cpu, "iowait")
+		ch <- c.cpuCore.newConstMetric(s.irq, cpu, "irq")
+		ch <- c.cpuCore.newConstMetric(s.softirq, cpu, "softirq")
+		ch <- c.cpuCore.newConstMetric(s.steal, cpu, "steal")
+	}
+
 	return nil
 }
 
@@ -124,7 +154,7 @@ type cpuStat struct {
 
 // getCPUStat opens stat file and executes parser.
 func getCPUStat(systicks float64) (cpuStat, error) {
-	file, err := os.Open("/proc/stat")
+	file, err := os.Open(procPath("stat"))
 	if err != nil {
 		return cpuStat{}, err
 	}
@@ -157,6 +187,57 @@ func parseProcCPUStat(r io.Reader, systicks float64) (cpuStat, error) {
 	return cpuStat{}, fmt.Errorf("total cpu stats not found")
 }
 
+// getCPUCoresStat opens stat file and executes per-core stats parser.
+func getCPUCoresStat(systicks float64) (map[string]cpuStat, error) {
+	file, err := os.Open(procPath("stat"))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+
+	return parseProcCPUCoresStat(file, systicks)
+}
+
+// parseProcCPUCoresStat parses stat file and returns usage stats of each CPU core, keyed by number of the core.
+func parseProcCPUCoresStat(r io.Reader, systicks float64) (map[string]cpuStat, error) {
+	log.Debug("parse per-CPU stats")
+
+	var (
+		scanner = bufio.NewScanner(r)
+		stats   = map[string]cpuStat{}
+	)
+
+	for scanner.Scan() {
+		parts := strings.Fields(scanner.Text())
+		if len(parts) < 2 {
+			log.Debug("CPU stat invalid input: too few values; skip")
+			continue
+		}
+
+		// Looking only for per-CPU stats, skip total stat.
+		if !strings.HasPrefix(parts[0], "cpu") || parts[0] == "cpu" {
+			continue
+		}
+
+		s, err := parseCPUStat(scanner.Text(), systicks)
+		if err != nil {
+			return nil, err
+		}
+
+		stats[strings.TrimPrefix(parts[0], "cpu")] = s
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(stats) == 0 {
+		return nil, fmt.Errorf("per-cpu stats not found")
+	}
+
+	return stats, nil
+}
+
 // parseCPUStat parses single line from stats file and returns parsed stats.
 func parseCPUStat(line string, systicks float64) (cpuStat, error) {
 	s := cpuStat{}
@@ -1,9 +1,12 @@
 package collector
 
 import (
+	"github.com/lesovsky/pgscv/internal/model"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -58,6 +61,52 @@ func Test_parseProcCPUStat(t *testing.T) {
 	}
 }
 
+func TestCPUCollector_Update_perCPU(t *testing.T) {
+	c, err := NewCPUCollector(labels{}, model.CollectorSettings{PerCPU: true})
+	assert.NoError(t, err)
+
+	ch := make(chan prometheus.Metric, 1000)
+	assert.NoError(t, c.Update(Config{}, ch))
+	close(ch)
+
+	var found bool
+	for m := range ch {
+		if strings.Contains(m.Desc().String(), `fqName: "node_cpu_core_seconds_total"`) {
+			found = true
+			break
+		}
+	}
+	assert.True(t, found)
+}
+
+func Test_parseProcCPUCoresStat(t *testing.T) {
+	file, err := os.Open(filepath.Clean("testdata/proc/stat.golden"))
+	assert.NoError(t, err)
+
+	got, err := parseProcCPUCoresStat(file, 100)
+	assert.NoError(t, err)
+	assert.NoError(t, file.Close())
+
+	assert.Len(t, got, 8)
+	assert.NotContains(t, got, "")
+	assert.Equal(t, cpuStat{
+		user: 3915.44, nice: 4.34, system: 1772.76, idle: 165439.83, iowait: 49.24,
+		irq: 0, softirq: 2002.82, steal: 0, guest: 0, guestnice: 0,
+	}, got["0"])
+	assert.Equal(t, cpuStat{
+		user: 3920.63, nice: 2.36, system: 1662.54, idle: 165612.04, iowait: 60.81,
+		irq: 0, softirq: 18.69, steal: 0, guest: 0, guestnice: 0,
+	}, got["7"])
+
+	// Stats without per-CPU lines.
+	_, err = parseProcCPUCoresStat(strings.NewReader("cpu  3097668 1593 1419618 132242258 42535 0 384686 0 0 0\nctxt 3253088019\n"), 100)
+	assert.Error(t, err)
+
+	// Invalid per-CPU line.
+	_, err = parseProcCPUCoresStat(strings.NewReader("cpu0 invalid\n"), 100)
+	assert.Error(t, err)
+}
+
 func Test_parseCPUStat(t *testing.T) {
 	var testcases = []struct {
 		valid bool
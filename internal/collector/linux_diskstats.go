@@ -48,7 +48,7 @@ func NewDiskstatsCollector(constLabels labels, settings model.CollectorSettings)
 			settings.Filters = filter.New()
 		}
 
-		settings.Filters.Add("device", filter.Filter{Exclude: `^(ram|loop|fd|sr|(h|s|v|xv)d[a-z]|nvme\d+n\d+p)\d+$`})
+		settings.Filters.Add("device", filter.Filter{Exclude: `^(z?ram|loop|fd|sr|(h|s|v|xv)d[a-z]|nvme\d+n\d+p)\d+$`})
 		err := settings.Filters.Compile()
 		if err != nil {
 			return nil, err
@@ -196,7 +196,7 @@ func (c *diskstatsCollector) Update(_ Config, ch chan<- prometheus.Metric) error
 	}
 
 	// Collect storages properties.
-	storages, err := getStorageProperties("/sys/block/*")
+	storages, err := getStorageProperties(sysPath("block", "*"))
 	if err != nil {
 		log.Warnf("get storage devices properties failed: %s; skip", err)
 	} else {
@@ -211,7 +211,7 @@ func (c *diskstatsCollector) Update(_ Config, ch chan<- prometheus.Metric) error
 
 // getDiskstats opens stats file and executes stats parser.
 func getDiskstats() (map[string][]float64, error) {
-	file, err := os.Open("/proc/diskstats")
+	file, err := os.Open(procPath("diskstats"))
 	if err != nil {
 		return nil, err
 	}
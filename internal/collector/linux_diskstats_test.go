@@ -33,6 +33,42 @@ func TestDiskstatsCollector_Update(t *testing.T) {
 	pipeline(t, input)
 }
 
+func TestNewDiskstatsCollector_filters(t *testing.T) {
+	testcases := []struct {
+		filters  filter.Filters
+		device   string
+		filtered bool
+	}{
+		// default filter
+		{device: "sda", filtered: false},
+		{device: "nvme0n1", filtered: false},
+		{device: "dm-0", filtered: false},
+		{device: "sda1", filtered: true},
+		{device: "nvme0n1p1", filtered: true},
+		{device: "loop0", filtered: true},
+		{device: "ram0", filtered: true},
+		{device: "zram0", filtered: true},
+		// configured filter replaces default one
+		{filters: filter.Filters{"device": {Include: `^sd[a-z]$`}}, device: "sda", filtered: false},
+		{filters: filter.Filters{"device": {Include: `^sd[a-z]$`}}, device: "dm-0", filtered: true},
+		{filters: filter.Filters{"device": {Exclude: `^dm-`}}, device: "loop0", filtered: false},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.device, func(t *testing.T) {
+			if tc.filters != nil {
+				assert.NoError(t, tc.filters.Compile())
+			}
+
+			c, err := NewDiskstatsCollector(labels{}, model.CollectorSettings{Filters: tc.filters})
+			assert.NoError(t, err)
+
+			completed := c.(*diskstatsCollector).completed
+			assert.Equal(t, tc.filtered, completed.hasFilter([]string{tc.device, "reads"}))
+		})
+	}
+}
+
 func Test_parseDiskstats(t *testing.T) {
 	file, err := os.Open(filepath.Clean("testdata/proc/diskstats.golden"))
 	assert.NoError(t, err)
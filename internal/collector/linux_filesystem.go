@@ -22,6 +22,8 @@ type filesystemCollector struct {
 	bytesTotal typedDesc
 	files      typedDesc
 	filesTotal typedDesc
+	// fstypes defines filter of filesystems types, used for skipping reading stats of unwanted filesystems.
+	fstypes filter.Filter
 }
 
 // NewFilesystemCollector returns a new Collector exposing filesystem stats.
@@ -33,7 +35,7 @@ func NewFilesystemCollector(constLabels labels, settings model.CollectorSettings
 			settings.Filters = filter.New()
 		}
 
-		settings.Filters.Add("fstype", filter.Filter{Include: `^(ext3|ext4|xfs|btrfs)$`})
+		settings.Filters.Add("fstype", filter.Filter{Include: `^(ext3|ext4|xfs|btrfs|zfs)$`})
 		err := settings.Filters.Compile()
 		if err != nil {
 			return nil, err
@@ -41,6 +43,7 @@ func NewFilesystemCollector(constLabels labels, settings model.CollectorSettings
 	}
 
 	return &filesystemCollector{
+		fstypes: settings.Filters["fstype"],
 		bytes: newBuiltinTypedDesc(
 			descOpts{"node", "filesystem", "bytes", "Number of bytes of filesystem by usage.", 0},
 			prometheus.GaugeValue,
@@ -70,7 +73,7 @@ func NewFilesystemCollector(constLabels labels, settings model.CollectorSettings
 
 // Update method collects filesystem usage statistics.
 func (c *filesystemCollector) Update(_ Config, ch chan<- prometheus.Metric) error {
-	stats, err := getFilesystemStats()
+	stats, err := getFilesystemStats(c.fstypes)
 	if err != nil {
 		return fmt.Errorf("get filesystem stats failed: %s", err)
 	}
@@ -105,18 +108,18 @@ type filesystemStat struct {
 }
 
 // getFilesystemStats opens stats file and execute stats parser.
-func getFilesystemStats() ([]filesystemStat, error) {
-	file, err := os.Open("/proc/mounts")
+func getFilesystemStats(fstypes filter.Filter) ([]filesystemStat, error) {
+	file, err := os.Open(procPath("mounts"))
 	if err != nil {
 		return nil, err
 	}
 	defer func() { _ = file.Close() }()
 
-	return parseFilesystemStats(file)
+	return parseFilesystemStats(file, fstypes)
 }
 
 // parseFilesystemStats parses stats file and return stats.
-func parseFilesystemStats(r io.Reader) ([]filesystemStat, error) {
+func parseFilesystemStats(r io.Reader, fstypes filter.Filter) ([]filesystemStat, error) {
 	mounts, err := parseProcMounts(r)
 	if err != nil {
 		return nil, err
@@ -124,6 +127,11 @@ func parseFilesystemStats(r io.Reader) ([]filesystemStat, error) {
 
 	var stats []filesystemStat
 	for _, m := range mounts {
+		// Skip filesystems filtered by type, reading stats of pseudo and network filesystems is useless or might stuck.
+		if !fstypes.Pass(m.fstype) {
+			continue
+		}
+
 		stat, err := readMountpointStat(m.mountpoint)
 		if err != nil {
 			log.Warnf("read %s stats failed: %s", m.mountpoint, err)
@@ -6,6 +6,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -26,7 +27,7 @@ func TestFilesystemCollector_Update(t *testing.T) {
 }
 
 func Test_getFilesystemStats(t *testing.T) {
-	got, err := getFilesystemStats()
+	got, err := getFilesystemStats(filter.Filter{})
 	assert.NoError(t, err)
 	assert.NotNil(t, got)
 	assert.Greater(t, len(got), 0)
@@ -36,7 +37,7 @@ func Test_parseFilesystemStats(t *testing.T) {
 	file, err := os.Open(filepath.Clean("testdata/proc/mounts.golden"))
 	assert.NoError(t, err)
 
-	stats, err := parseFilesystemStats(file)
+	stats, err := parseFilesystemStats(file, filter.Filter{})
 	assert.NoError(t, err)
 	assert.Greater(t, len(stats), 1)
 	assert.Greater(t, stats[0].size, float64(0))
@@ -51,12 +52,67 @@ func Test_parseFilesystemStats(t *testing.T) {
 	file, err = os.Open(filepath.Clean("testdata/proc/netdev.golden"))
 	assert.NoError(t, err)
 
-	stats, err = parseFilesystemStats(file)
+	stats, err = parseFilesystemStats(file, filter.Filter{})
 	assert.Error(t, err)
 	assert.Nil(t, stats)
 	_ = file.Close()
 }
 
+func Test_parseFilesystemStats_fstypes(t *testing.T) {
+	mounts := "/dev/sda1 / ext4 rw,relatime 0 0\n" +
+		"proc /proc proc rw,nosuid,nodev,noexec,relatime 0 0\n" +
+		"sysfs /sys sysfs rw,nosuid,nodev,noexec,relatime 0 0\n" +
+		"tmpfs /tmp tmpfs rw,nosuid,nodev 0 0\n"
+
+	testcases := []struct {
+		name    string
+		filter  filter.Filter
+		fstypes []string
+	}{
+		{name: "no filter", filter: filter.Filter{}, fstypes: []string{"ext4", "proc", "sysfs", "tmpfs"}},
+		{name: "include", filter: filter.Filter{Include: `^(ext3|ext4|xfs|btrfs|zfs)$`}, fstypes: []string{"ext4"}},
+		{name: "exclude", filter: filter.Filter{Exclude: `^(proc|sysfs|tmpfs|overlay)$`}, fstypes: []string{"ext4"}},
+		{name: "exclude all", filter: filter.Filter{Exclude: `.*`}, fstypes: nil},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			filters := filter.New()
+			filters.Add("fstype", tc.filter)
+			assert.NoError(t, filters.Compile())
+
+			stats, err := parseFilesystemStats(strings.NewReader(mounts), filters["fstype"])
+			assert.NoError(t, err)
+
+			var fstypes []string
+			for _, s := range stats {
+				fstypes = append(fstypes, s.mount.fstype)
+			}
+			assert.Equal(t, tc.fstypes, fstypes)
+		})
+	}
+}
+
+func TestNewFilesystemCollector_fstypes(t *testing.T) {
+	// Default filter is used when filter is not configured.
+	c, err := NewFilesystemCollector(labels{}, model.CollectorSettings{})
+	assert.NoError(t, err)
+	fc := c.(*filesystemCollector)
+	assert.True(t, fc.fstypes.Pass("zfs"))
+	assert.False(t, fc.fstypes.Pass("tmpfs"))
+
+	// Configured filter is used as-is.
+	filters := filter.New()
+	filters.Add("fstype", filter.Filter{Exclude: `^tmpfs$`})
+	assert.NoError(t, filters.Compile())
+
+	c, err = NewFilesystemCollector(labels{}, model.CollectorSettings{Filters: filters})
+	assert.NoError(t, err)
+	fc = c.(*filesystemCollector)
+	assert.True(t, fc.fstypes.Pass("nfs"))
+	assert.False(t, fc.fstypes.Pass("tmpfs"))
+}
+
 func Test_readMountpointStat(t *testing.T) {
 	stat, err := readMountpointStat("/")
 	assert.NoError(t, err)
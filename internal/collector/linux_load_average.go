@@ -56,7 +56,7 @@ func (c *loadaverageCollector) Update(_ Config, ch chan<- prometheus.Metric) err
 
 // getLoadAverageStats reads /proc/loadavg and return load stats.
 func getLoadAverageStats() ([]float64, error) {
-	data, err := os.ReadFile("/proc/loadavg")
+	data, err := os.ReadFile(procPath("loadavg"))
 	if err != nil {
 		return nil, err
 	}
@@ -3,6 +3,7 @@ package collector
 import (
 	"github.com/stretchr/testify/assert"
 	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -25,6 +26,26 @@ func Test_getLoadAverageStats(t *testing.T) {
 	assert.Len(t, loads, 3)
 }
 
+func Test_getLoadAverageStats_procfsPath(t *testing.T) {
+	data, err := os.ReadFile("./testdata/proc/loadavg.golden")
+	assert.NoError(t, err)
+
+	procfs := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(procfs, "loadavg"), data, 0600))
+
+	SetSystemPaths(procfs, "")
+	defer SetSystemPaths("", "")
+
+	loads, err := getLoadAverageStats()
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{1.15, 1.36, 1.24}, loads)
+
+	// Stats file is missing in procfs.
+	SetSystemPaths(t.TempDir(), "")
+	_, err = getLoadAverageStats()
+	assert.Error(t, err)
+}
+
 func Test_parseLoadAverageStats(t *testing.T) {
 	data, err := os.ReadFile("./testdata/proc/loadavg.golden")
 	assert.NoError(t, err)
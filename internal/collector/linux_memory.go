@@ -97,7 +97,7 @@ func (c *meminfoCollector) Update(_ Config, ch chan<- prometheus.Metric) error {
 
 // getMeminfoStats is the intermediate function which opens stats file and run stats parser for extracting stats.
 func getMeminfoStats() (map[string]float64, error) {
-	file, err := os.Open("/proc/meminfo")
+	file, err := os.Open(procPath("meminfo"))
 	if err != nil {
 		return nil, err
 	}
@@ -143,7 +143,7 @@ func parseMeminfoStats(r io.Reader) (map[string]float64, error) {
 
 // getVmstatStats is the intermediate function which opens stats file and run stats parser for extracting stats.
 func getVmstatStats() (map[string]float64, error) {
-	file, err := os.Open("/proc/vmstat")
+	file, err := os.Open(procPath("vmstat"))
 	if err != nil {
 		return nil, err
 	}
@@ -22,13 +22,14 @@ type netdevCollector struct {
 // NewNetdevCollector returns a new Collector exposing network interfaces stats.
 func NewNetdevCollector(constLabels labels, settings model.CollectorSettings) (Collector, error) {
 
-	// Define default filters (if no already present) to avoid collecting metrics about virtual interfaces.
+	// Define default filters (if no already present) to avoid collecting metrics about loopback and virtual interfaces
+	// (including veth pairs created by containers runtimes).
 	if _, ok := settings.Filters["device"]; !ok {
 		if settings.Filters == nil {
 			settings.Filters = filter.New()
 		}
 
-		settings.Filters.Add("device", filter.Filter{Exclude: `docker|virbr`})
+		settings.Filters.Add("device", filter.Filter{Exclude: `^(lo|veth.+)$|docker|virbr`})
 		err := settings.Filters.Compile()
 		if err != nil {
 			return nil, err
@@ -96,7 +97,7 @@ func (c *netdevCollector) Update(_ Config, ch chan<- prometheus.Metric) error {
 
 // getNetdevStats is the intermediate function which opens stats file and run stats parser for extracting stats.
 func getNetdevStats() (map[string][]float64, error) {
-	file, err := os.Open("/proc/net/dev")
+	file, err := os.Open(procPath("net", "dev"))
 	if err != nil {
 		return nil, err
 	}
@@ -23,6 +23,41 @@ func TestNetdevCollector_Update(t *testing.T) {
 	pipeline(t, input)
 }
 
+func TestNewNetdevCollector_filters(t *testing.T) {
+	testcases := []struct {
+		filters  filter.Filters
+		device   string
+		filtered bool
+	}{
+		// default filter
+		{device: "eth0", filtered: false},
+		{device: "ens3", filtered: false},
+		{device: "lo", filtered: true},
+		{device: "veth7a2c1f9", filtered: true},
+		{device: "docker0", filtered: true},
+		{device: "virbr0", filtered: true},
+		// configured filter replaces default one
+		{filters: filter.Filters{"device": {Include: `^eth`}}, device: "eth0", filtered: false},
+		{filters: filter.Filters{"device": {Include: `^eth`}}, device: "ens3", filtered: true},
+		{filters: filter.Filters{"device": {Include: `^eth`}}, device: "lo", filtered: true},
+		{filters: filter.Filters{"device": {Exclude: `^ens`}}, device: "lo", filtered: false},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.device, func(t *testing.T) {
+			if tc.filters != nil {
+				assert.NoError(t, tc.filters.Compile())
+			}
+
+			c, err := NewNetdevCollector(labels{}, model.CollectorSettings{Filters: tc.filters})
+			assert.NoError(t, err)
+
+			bytes := c.(*netdevCollector).bytes
+			assert.Equal(t, tc.filtered, bytes.hasFilter([]string{tc.device, "recv"}))
+		})
+	}
+}
+
 func Test_parseNetdevStats(t *testing.T) {
 	file, err := os.Open(filepath.Clean("testdata/proc/netdev.golden"))
 	assert.NoError(t, err)
@@ -0,0 +1,144 @@
+package collector
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/lesovsky/pgscv/internal/log"
+	"github.com/lesovsky/pgscv/internal/model"
+	"github.com/prometheus/client_golang/prometheus"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type netstatCollector struct {
+	activeOpens  typedDesc
+	passiveOpens typedDesc
+	currEstab    typedDesc
+	retransSegs  typedDesc
+	inErrs       typedDesc
+	outRsts      typedDesc
+}
+
+// NewNetstatCollector returns a new Collector exposing TCP statistics from /proc/net/snmp.
+func NewNetstatCollector(constLabels labels, settings model.CollectorSettings) (Collector, error) {
+	return &netstatCollector{
+		activeOpens: newBuiltinTypedDesc(
+			descOpts{"node", "netstat", "tcp_active_opens_total", "Total number of TCP connections actively opened by the system.", 0},
+			prometheus.CounterValue,
+			nil, constLabels,
+			settings.Filters,
+		),
+		passiveOpens: newBuiltinTypedDesc(
+			descOpts{"node", "netstat", "tcp_passive_opens_total", "Total number of TCP connections passively accepted by the system.", 0},
+			prometheus.CounterValue,
+			nil, constLabels,
+			settings.Filters,
+		),
+		currEstab: newBuiltinTypedDesc(
+			descOpts{"node", "netstat", "tcp_curr_estab", "Number of TCP connections currently in ESTABLISHED or CLOSE-WAIT state.", 0},
+			prometheus.GaugeValue,
+			nil, constLabels,
+			settings.Filters,
+		),
+		retransSegs: newBuiltinTypedDesc(
+			descOpts{"node", "netstat", "tcp_retrans_segs_total", "Total number of TCP segments retransmitted.", 0},
+			prometheus.CounterValue,
+			nil, constLabels,
+			settings.Filters,
+		),
+		inErrs: newBuiltinTypedDesc(
+			descOpts{"node", "netstat", "tcp_in_errs_total", "Total number of TCP segments received in error.", 0},
+			prometheus.CounterValue,
+			nil, constLabels,
+			settings.Filters,
+		),
+		outRsts: newBuiltinTypedDesc(
+			descOpts{"node", "netstat", "tcp_out_rsts_total", "Total number of TCP segments sent with RST flag.", 0},
+			prometheus.CounterValue,
+			nil, constLabels,
+			settings.Filters,
+		),
+	}, nil
+}
+
+// Update method collects TCP statistics.
+func (c *netstatCollector) Update(_ Config, ch chan<- prometheus.Metric) error {
+	stats, err := getNetstatStats(procPath("net", "snmp"))
+	if err != nil {
+		// Stats file might be absent, e.g. in restricted containers, skip silently.
+		if os.IsNotExist(err) {
+			log.Debugln("/proc/net/snmp is not available, skip")
+			return nil
+		}
+
+		return fmt.Errorf("get /proc/net/snmp stats failed: %s", err)
+	}
+
+	tcp, ok := stats["Tcp"]
+	if !ok {
+		log.Warnln("TCP stats not found in /proc/net/snmp, skip")
+		return nil
+	}
+
+	ch <- c.activeOpens.newConstMetric(tcp["ActiveOpens"])
+	ch <- c.passiveOpens.newConstMetric(tcp["PassiveOpens"])
+	ch <- c.currEstab.newConstMetric(tcp["CurrEstab"])
+	ch <- c.retransSegs.newConstMetric(tcp["RetransSegs"])
+	ch <- c.inErrs.newConstMetric(tcp["InErrs"])
+	ch <- c.outRsts.newConstMetric(tcp["OutRsts"])
+
+	return nil
+}
+
+// getNetstatStats is the intermediate function which opens stats file and run stats parser for extracting stats.
+func getNetstatStats(path string) (map[string]map[string]float64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+
+	return parseNetstatStats(file)
+}
+
+// parseNetstatStats accepts file descriptor, reads file content and produces stats. Stats are organized as pairs of
+// lines: the first line contains protocol name and fields names, the second line contains protocol name and values.
+func parseNetstatStats(r io.Reader) (map[string]map[string]float64, error) {
+	log.Debug("parse netstat stats")
+
+	var stats = map[string]map[string]float64{}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		names := strings.Fields(scanner.Text())
+		if !scanner.Scan() {
+			return nil, fmt.Errorf("invalid input, '%s': no values line", strings.Join(names, " "))
+		}
+		values := strings.Fields(scanner.Text())
+
+		if len(names) == 0 || len(names) != len(values) {
+			return nil, fmt.Errorf("invalid input, '%s': wrong number of values", scanner.Text())
+		}
+
+		if names[0] != values[0] {
+			return nil, fmt.Errorf("invalid input, '%s': protocol mismatch with '%s'", values[0], names[0])
+		}
+
+		proto := strings.TrimSuffix(names[0], ":")
+		stats[proto] = map[string]float64{}
+
+		for i := 1; i < len(names); i++ {
+			v, err := strconv.ParseFloat(values[i], 64)
+			if err != nil {
+				log.Errorf("invalid input, parse '%s' failed: %s, skip", values[i], err.Error())
+				continue
+			}
+
+			stats[proto][names[i]] = v
+		}
+	}
+
+	return stats, scanner.Err()
+}
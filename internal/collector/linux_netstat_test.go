@@ -0,0 +1,67 @@
+package collector
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNetstatCollector_Update(t *testing.T) {
+	var input = pipelineInput{
+		optional: []string{
+			"node_netstat_tcp_active_opens_total",
+			"node_netstat_tcp_passive_opens_total",
+			"node_netstat_tcp_curr_estab",
+			"node_netstat_tcp_retrans_segs_total",
+			"node_netstat_tcp_in_errs_total",
+			"node_netstat_tcp_out_rsts_total",
+		},
+		collector: NewNetstatCollector,
+	}
+
+	pipeline(t, input)
+}
+
+func Test_getNetstatStats(t *testing.T) {
+	stats, err := getNetstatStats("testdata/proc/snmp.golden")
+	assert.NoError(t, err)
+	assert.Len(t, stats, 4)
+
+	_, err = getNetstatStats("testdata/proc/snmp.unknown")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func Test_parseNetstatStats(t *testing.T) {
+	file, err := os.Open(filepath.Clean("testdata/proc/snmp.golden"))
+	assert.NoError(t, err)
+	defer func() { _ = file.Close() }()
+
+	stats, err := parseNetstatStats(file)
+	assert.NoError(t, err)
+
+	want := map[string]float64{
+		"RtoAlgorithm": 1, "RtoMin": 200, "RtoMax": 120000, "MaxConn": -1, "ActiveOpens": 148522, "PassiveOpens": 51730,
+		"AttemptFails": 4215, "EstabResets": 3318, "CurrEstab": 27, "InSegs": 24941380, "OutSegs": 25784212,
+		"RetransSegs": 18544, "InErrs": 31, "OutRsts": 9871, "InCsumErrors": 2,
+	}
+	assert.Equal(t, want, stats["Tcp"])
+	assert.Equal(t, float64(1544), stats["Udp"]["IgnoredMulti"])
+
+	// Invalid inputs.
+	file2, err := os.Open(filepath.Clean("testdata/proc/snmp.invalid"))
+	assert.NoError(t, err)
+	defer func() { _ = file2.Close() }()
+
+	_, err = parseNetstatStats(file2)
+	assert.Error(t, err)
+
+	for _, s := range []string{
+		"Tcp: RtoAlgorithm RtoMin",
+		"Tcp: RtoAlgorithm RtoMin\nUdp: 1 200",
+	} {
+		_, err = parseNetstatStats(strings.NewReader(s))
+		assert.Error(t, err)
+	}
+}
@@ -0,0 +1,167 @@
+package collector
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/lesovsky/pgscv/internal/log"
+	"github.com/lesovsky/pgscv/internal/model"
+	"github.com/prometheus/client_golang/prometheus"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// pressureResources defines resources for which pressure stall information is available.
+var pressureResources = []string{"cpu", "memory", "io"}
+
+type pressureCollector struct {
+	waiting map[string]typedDesc
+	stalled map[string]typedDesc
+	someAvg map[string]typedDesc
+	fullAvg map[string]typedDesc
+}
+
+// NewPressureCollector returns a new Collector exposing pressure stall information (PSI) stats.
+// For details see https://docs.kernel.org/accounting/psi.html
+func NewPressureCollector(constLabels labels, settings model.CollectorSettings) (Collector, error) {
+	c := &pressureCollector{
+		waiting: map[string]typedDesc{},
+		stalled: map[string]typedDesc{},
+		someAvg: map[string]typedDesc{},
+		fullAvg: map[string]typedDesc{},
+	}
+
+	for _, r := range pressureResources {
+		c.waiting[r] = newBuiltinTypedDesc(
+			descOpts{"node", "pressure", r + "_waiting_seconds_total", fmt.Sprintf("Total time in seconds that some tasks have waited due to %s contention.", r), .000001},
+			prometheus.CounterValue,
+			nil, constLabels,
+			settings.Filters,
+		)
+		c.stalled[r] = newBuiltinTypedDesc(
+			descOpts{"node", "pressure", r + "_stalled_seconds_total", fmt.Sprintf("Total time in seconds that all non-idle tasks have stalled due to %s contention.", r), .000001},
+			prometheus.CounterValue,
+			nil, constLabels,
+			settings.Filters,
+		)
+		c.someAvg[r] = newBuiltinTypedDesc(
+			descOpts{"node", "pressure", r + "_some_avg", fmt.Sprintf("Percentage of time that some tasks have waited due to %s contention, averaged over window.", r), 0},
+			prometheus.GaugeValue,
+			[]string{"window"}, constLabels,
+			settings.Filters,
+		)
+		c.fullAvg[r] = newBuiltinTypedDesc(
+			descOpts{"node", "pressure", r + "_full_avg", fmt.Sprintf("Percentage of time that all non-idle tasks have stalled due to %s contention, averaged over window.", r), 0},
+			prometheus.GaugeValue,
+			[]string{"window"}, constLabels,
+			settings.Filters,
+		)
+	}
+
+	return c, nil
+}
+
+// Update method collects pressure stall information stats.
+func (c *pressureCollector) Update(_ Config, ch chan<- prometheus.Metric) error {
+	for _, r := range pressureResources {
+		stats, err := getPressureStats(procPath("pressure", r))
+		if err != nil {
+			// Kernel might be built without PSI or PSI might be disabled, skip silently.
+			if errors.Is(err, os.ErrNotExist) || errors.Is(err, syscall.EOPNOTSUPP) {
+				log.Debugf("pressure stall information for %s is not available, skip", r)
+				continue
+			}
+
+			return fmt.Errorf("get /proc/pressure/%s stats failed: %s", r, err)
+		}
+
+		waiting, stalled, someAvg, fullAvg := c.waiting[r], c.stalled[r], c.someAvg[r], c.fullAvg[r]
+
+		if some, ok := stats["some"]; ok {
+			ch <- waiting.newConstMetric(some.total)
+			ch <- someAvg.newConstMetric(some.avg10, "10s")
+			ch <- someAvg.newConstMetric(some.avg60, "60s")
+			ch <- someAvg.newConstMetric(some.avg300, "300s")
+		}
+
+		// 'full' line for cpu is available since Linux 5.13.
+		if full, ok := stats["full"]; ok {
+			ch <- stalled.newConstMetric(full.total)
+			ch <- fullAvg.newConstMetric(full.avg10, "10s")
+			ch <- fullAvg.newConstMetric(full.avg60, "60s")
+			ch <- fullAvg.newConstMetric(full.avg300, "300s")
+		}
+	}
+
+	return nil
+}
+
+// pressureStat represents a single line of pressure stall information.
+type pressureStat struct {
+	avg10  float64
+	avg60  float64
+	avg300 float64
+	total  float64
+}
+
+// getPressureStats opens pressure stats file and run stats parser for extracting stats.
+func getPressureStats(path string) (map[string]pressureStat, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+
+	return parsePressureStats(file)
+}
+
+// parsePressureStats accepts file descriptor, reads file content and produces stats.
+func parsePressureStats(r io.Reader) (map[string]pressureStat, error) {
+	log.Debug("parse pressure stall information stats")
+
+	var stats = map[string]pressureStat{}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		values := strings.Fields(scanner.Text())
+		if len(values) != 5 {
+			return nil, fmt.Errorf("invalid input, '%s': wrong number of values", scanner.Text())
+		}
+
+		kind := values[0]
+		if kind != "some" && kind != "full" {
+			return nil, fmt.Errorf("invalid input, '%s': unknown kind", scanner.Text())
+		}
+
+		stat := pressureStat{}
+		for _, v := range values[1:] {
+			kv := strings.SplitN(v, "=", 2)
+			if len(kv) != 2 {
+				return nil, fmt.Errorf("invalid input, '%s': wrong format", v)
+			}
+
+			value, err := strconv.ParseFloat(kv[1], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid input, parse '%s' failed: %w", kv[1], err)
+			}
+
+			switch kv[0] {
+			case "avg10":
+				stat.avg10 = value
+			case "avg60":
+				stat.avg60 = value
+			case "avg300":
+				stat.avg300 = value
+			case "total":
+				stat.total = value
+			}
+		}
+
+		stats[kind] = stat
+	}
+
+	return stats, scanner.Err()
+}
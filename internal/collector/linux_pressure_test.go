@@ -0,0 +1,82 @@
+package collector
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPressureCollector_Update(t *testing.T) {
+	var input = pipelineInput{
+		optional: []string{
+			"node_pressure_cpu_waiting_seconds_total",
+			"node_pressure_cpu_stalled_seconds_total",
+			"node_pressure_cpu_some_avg",
+			"node_pressure_cpu_full_avg",
+			"node_pressure_memory_waiting_seconds_total",
+			"node_pressure_memory_stalled_seconds_total",
+			"node_pressure_memory_some_avg",
+			"node_pressure_memory_full_avg",
+			"node_pressure_io_waiting_seconds_total",
+			"node_pressure_io_stalled_seconds_total",
+			"node_pressure_io_some_avg",
+			"node_pressure_io_full_avg",
+		},
+		collector: NewPressureCollector,
+	}
+
+	pipeline(t, input)
+}
+
+func Test_getPressureStats(t *testing.T) {
+	stats, err := getPressureStats("testdata/proc/pressure-io.golden")
+	assert.NoError(t, err)
+	assert.Len(t, stats, 2)
+
+	_, err = getPressureStats("testdata/proc/pressure-unknown.golden")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func Test_parsePressureStats(t *testing.T) {
+	testcases := []struct {
+		file string
+		want map[string]pressureStat
+	}{
+		{
+			file: "testdata/proc/pressure-cpu.golden",
+			want: map[string]pressureStat{
+				"some": {avg10: 1.48, avg60: 2.50, avg300: 2.37, total: 17832317},
+			},
+		},
+		{
+			file: "testdata/proc/pressure-io.golden",
+			want: map[string]pressureStat{
+				"some": {avg10: 0.12, avg60: 0.05, avg300: 0.07, total: 2164792},
+				"full": {avg10: 0.10, avg60: 0.04, avg300: 0.06, total: 1793227},
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		file, err := os.Open(filepath.Clean(tc.file))
+		assert.NoError(t, err)
+
+		stats, err := parsePressureStats(file)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.want, stats)
+		assert.NoError(t, file.Close())
+	}
+
+	// Invalid inputs.
+	for _, s := range []string{
+		"some avg10=1.48 avg60=2.50 avg300=2.37",
+		"invalid avg10=1.48 avg60=2.50 avg300=2.37 total=17832317",
+		"some avg10=1.48 avg60 avg300=2.37 total=17832317",
+		"some avg10=1.48 avg60=invalid avg300=2.37 total=17832317",
+	} {
+		_, err := parsePressureStats(strings.NewReader(s))
+		assert.Error(t, err)
+	}
+}
@@ -9,7 +9,6 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"io"
 	"os"
-	"path"
 	"path/filepath"
 	"regexp"
 	"strconv"
@@ -23,8 +22,14 @@ type systemCollector struct {
 	governors  typedDesc
 	numanodes  typedDesc
 	ctxt       typedDesc
+	intr       typedDesc
 	forks      typedDesc
 	btime      typedDesc
+	running    typedDesc
+	blocked    typedDesc
+	filefd     typedDesc
+	filefdMax  typedDesc
+	entropy    typedDesc
 }
 
 // NewSystemCollector returns a new Collector exposing system-wide stats.
@@ -74,6 +79,12 @@ func NewSysconfigCollector(constLabels labels, settings model.CollectorSettings)
 			nil, constLabels,
 			settings.Filters,
 		),
+		intr: newBuiltinTypedDesc(
+			descOpts{"node", "", "interrupts_total", "Total number of interrupts serviced.", 0},
+			prometheus.CounterValue,
+			nil, constLabels,
+			settings.Filters,
+		),
 		forks: newBuiltinTypedDesc(
 			descOpts{"node", "", "forks_total", "Total number of forks.", 0},
 			prometheus.CounterValue,
@@ -86,6 +97,36 @@ func NewSysconfigCollector(constLabels labels, settings model.CollectorSettings)
 			nil, constLabels,
 			settings.Filters,
 		),
+		running: newBuiltinTypedDesc(
+			descOpts{"node", "procs", "running", "Number of processes (threads) in runnable state.", 0},
+			prometheus.GaugeValue,
+			nil, constLabels,
+			settings.Filters,
+		),
+		blocked: newBuiltinTypedDesc(
+			descOpts{"node", "procs", "blocked", "Number of processes (threads) blocked waiting for I/O to complete.", 0},
+			prometheus.GaugeValue,
+			nil, constLabels,
+			settings.Filters,
+		),
+		filefd: newBuiltinTypedDesc(
+			descOpts{"node", "filefd", "allocated", "Number of allocated file descriptors.", 0},
+			prometheus.GaugeValue,
+			nil, constLabels,
+			settings.Filters,
+		),
+		filefdMax: newBuiltinTypedDesc(
+			descOpts{"node", "filefd", "maximum", "Maximum number of file descriptors could be allocated.", 0},
+			prometheus.GaugeValue,
+			nil, constLabels,
+			settings.Filters,
+		),
+		entropy: newBuiltinTypedDesc(
+			descOpts{"node", "entropy", "available_bits", "Number of bits of entropy available in the kernel's pool.", 0},
+			prometheus.GaugeValue,
+			nil, constLabels,
+			settings.Filters,
+		),
 	}, nil
 }
 
@@ -98,7 +139,7 @@ func (c *systemCollector) Update(_ Config, ch chan<- prometheus.Metric) error {
 	}
 
 	// Count CPU cores by state.
-	cpuonline, cpuoffline, err := countCPUCores("/sys/devices/system/cpu/cpu*")
+	cpuonline, cpuoffline, err := countCPUCores(sysPath("devices", "system", "cpu", "cpu*"))
 	if err != nil {
 		log.Warnf("cpu count failed: %s; skip", err)
 	} else {
@@ -107,7 +148,7 @@ func (c *systemCollector) Update(_ Config, ch chan<- prometheus.Metric) error {
 	}
 
 	// Count CPU scaling governors.
-	governors, err := countScalingGovernors("/sys/devices/system/cpu/cpu*")
+	governors, err := countScalingGovernors(sysPath("devices", "system", "cpu", "cpu*"))
 	if err != nil {
 		log.Warnf("count CPU scaling governors failed: %s; skip", err)
 	} else {
@@ -117,7 +158,7 @@ func (c *systemCollector) Update(_ Config, ch chan<- prometheus.Metric) error {
 	}
 
 	// Count NUMA nodes.
-	nodes, err := countNumaNodes("/sys/devices/system/node/node*")
+	nodes, err := countNumaNodes(sysPath("devices", "system", "node", "node*"))
 	if err != nil {
 		log.Warnf("count NUMA nodes failed: %s; skip", err)
 	} else {
@@ -130,18 +171,82 @@ func (c *systemCollector) Update(_ Config, ch chan<- prometheus.Metric) error {
 		log.Warnf("parse /proc/stat failed: %s; skip", err)
 	} else {
 		ch <- c.ctxt.newConstMetric(stat.ctxt)
+		ch <- c.intr.newConstMetric(stat.intr)
 		ch <- c.btime.newConstMetric(stat.btime)
 		ch <- c.forks.newConstMetric(stat.forks)
+		ch <- c.running.newConstMetric(stat.running)
+		ch <- c.blocked.newConstMetric(stat.blocked)
+	}
+
+	// Collect file descriptors usage.
+	allocated, maximum, err := getFilefdStats(procPath("sys", "fs", "file-nr"))
+	if err != nil {
+		log.Warnf("get file descriptors stats failed: %s; skip", err)
+	} else {
+		ch <- c.filefd.newConstMetric(allocated)
+		ch <- c.filefdMax.newConstMetric(maximum)
+	}
+
+	// Collect available entropy.
+	entropy, err := getEntropyAvailable(procPath("sys", "kernel", "random", "entropy_avail"))
+	if err != nil {
+		log.Warnf("get available entropy failed: %s; skip", err)
+	} else {
+		ch <- c.entropy.newConstMetric(entropy)
 	}
 
 	return nil
 }
 
+// getFilefdStats reads file-nr file (e.g. /proc/sys/fs/file-nr) and returns number of allocated file descriptors and
+// max number of file descriptors. The second value of the file (number of unused descriptors) is always zero since
+// Linux 2.6, hence it is ignored.
+func getFilefdStats(procfile string) (float64, float64, error) {
+	data, err := os.ReadFile(filepath.Clean(procfile))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	parts := strings.Fields(string(data))
+	if len(parts) != 3 {
+		return 0, 0, fmt.Errorf("invalid input, '%s': wrong number of values", strings.TrimSpace(string(data)))
+	}
+
+	allocated, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid input, parse '%s' failed: %s", parts[0], err)
+	}
+
+	maximum, err := strconv.ParseFloat(parts[2], 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid input, parse '%s' failed: %s", parts[2], err)
+	}
+
+	return allocated, maximum, nil
+}
+
+// getEntropyAvailable reads entropy_avail file (e.g. /proc/sys/kernel/random/entropy_avail) and returns number of
+// available entropy bits.
+func getEntropyAvailable(procfile string) (float64, error) {
+	data, err := os.ReadFile(filepath.Clean(procfile))
+	if err != nil {
+		return 0, err
+	}
+
+	value := strings.TrimSpace(string(data))
+	entropy, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid input, parse '%s' failed: %s", value, err)
+	}
+
+	return entropy, nil
+}
+
 // readSysctls reads list of passed sysctls and return map with its names and values.
 func readSysctls(list []string) map[string]float64 {
 	var sysctls = map[string]float64{}
 	for _, item := range list {
-		data, err := os.ReadFile(path.Join("/proc/sys", strings.Replace(item, ".", "/", -1)))
+		data, err := os.ReadFile(procPath("sys", strings.Replace(item, ".", "/", -1)))
 		if err != nil {
 			log.Warnf("read '%s' failed: %s; skip", item, err)
 			continue
@@ -256,13 +361,16 @@ func countNumaNodes(path string) (n float64, err error) {
 
 // systemProcStat represents some stats from /proc/stat file.
 type systemProcStat struct {
-	ctxt  float64
-	btime float64
-	forks float64
+	ctxt    float64
+	intr    float64
+	btime   float64
+	forks   float64
+	running float64
+	blocked float64
 }
 
 func getProcStat() (systemProcStat, error) {
-	file, err := os.Open("/proc/stat")
+	file, err := os.Open(procPath("stat"))
 	if err != nil {
 		return systemProcStat{}, err
 	}
@@ -293,6 +401,12 @@ func parseProcStat(r io.Reader) (systemProcStat, error) {
 			if err != nil {
 				return stat, fmt.Errorf("invalid input, parse '%s' (ctxt) failed: %s; skip", parts[1], err)
 			}
+		case "intr":
+			// The first value is the total number of interrupts, the rest are per-interrupt counters.
+			stat.intr, err = strconv.ParseFloat(parts[1], 64)
+			if err != nil {
+				return stat, fmt.Errorf("invalid input, parse '%s' (intr) failed: %s; skip", parts[1], err)
+			}
 		case "btime":
 			stat.btime, err = strconv.ParseFloat(parts[1], 64)
 			if err != nil {
@@ -303,6 +417,16 @@ func parseProcStat(r io.Reader) (systemProcStat, error) {
 			if err != nil {
 				return stat, fmt.Errorf("invalid input, parse '%s' (processes) failed: %s; skip", parts[1], err)
 			}
+		case "procs_running":
+			stat.running, err = strconv.ParseFloat(parts[1], 64)
+			if err != nil {
+				return stat, fmt.Errorf("invalid input, parse '%s' (procs_running) failed: %s; skip", parts[1], err)
+			}
+		case "procs_blocked":
+			stat.blocked, err = strconv.ParseFloat(parts[1], 64)
+			if err != nil {
+				return stat, fmt.Errorf("invalid input, parse '%s' (procs_blocked) failed: %s; skip", parts[1], err)
+			}
 		default:
 			continue
 		}
@@ -14,8 +14,14 @@ func TestSystemCollector_Update(t *testing.T) {
 			"node_system_cpu_cores_total",
 			"node_system_numa_nodes_total",
 			"node_context_switches_total",
+			"node_interrupts_total",
 			"node_forks_total",
 			"node_boot_time_seconds",
+			"node_procs_running",
+			"node_procs_blocked",
+			"node_filefd_allocated",
+			"node_filefd_maximum",
+			"node_entropy_available_bits",
 		},
 		optional: []string{
 			"node_system_scaling_governors_total",
@@ -81,9 +87,12 @@ func Test_parseProcStat(t *testing.T) {
 		want  systemProcStat
 	}{
 		{in: "testdata/proc/stat.golden", valid: true, want: systemProcStat{
-			ctxt:  3253088019,
-			btime: 1596255715,
-			forks: 214670,
+			ctxt:    3253088019,
+			intr:    1569470757,
+			btime:   1596255715,
+			forks:   214670,
+			running: 1,
+			blocked: 0,
 		}},
 		{in: "testdata/proc/stat.invalid", valid: false},
 	}
@@ -102,3 +111,28 @@ func Test_parseProcStat(t *testing.T) {
 		assert.NoError(t, file.Close())
 	}
 }
+
+func Test_getFilefdStats(t *testing.T) {
+	allocated, maximum, err := getFilefdStats("testdata/proc/file-nr.golden")
+	assert.NoError(t, err)
+	assert.Equal(t, float64(3936), allocated)
+	assert.Equal(t, float64(9223372036854775807), maximum)
+
+	_, _, err = getFilefdStats("testdata/proc/loadavg.golden")
+	assert.Error(t, err)
+
+	_, _, err = getFilefdStats("testdata/proc/invalid")
+	assert.Error(t, err)
+}
+
+func Test_getEntropyAvailable(t *testing.T) {
+	entropy, err := getEntropyAvailable("testdata/proc/entropy_avail.golden")
+	assert.NoError(t, err)
+	assert.Equal(t, float64(3754), entropy)
+
+	_, err = getEntropyAvailable("testdata/proc/loadavg.golden")
+	assert.Error(t, err)
+
+	_, err = getEntropyAvailable("testdata/proc/invalid")
+	assert.Error(t, err)
+}
@@ -60,22 +60,22 @@ type sysInfo struct {
 
 // getSysInfo reads various information about platform and system.
 func getSysInfo() (*sysInfo, error) {
-	vendor, err := os.ReadFile("/sys/class/dmi/id/sys_vendor")
+	vendor, err := os.ReadFile(sysPath("class", "dmi", "id", "sys_vendor"))
 	if err != nil {
 		return nil, err
 	}
 
-	name, err := os.ReadFile("/sys/class/dmi/id/product_name")
+	name, err := os.ReadFile(sysPath("class", "dmi", "id", "product_name"))
 	if err != nil {
 		return nil, err
 	}
 
-	kernel, err := os.ReadFile("/proc/sys/kernel/osrelease")
+	kernel, err := os.ReadFile(procPath("sys", "kernel", "osrelease"))
 	if err != nil {
 		return nil, err
 	}
 
-	osType, err := os.ReadFile("/proc/sys/kernel/ostype")
+	osType, err := os.ReadFile(procPath("sys", "kernel", "ostype"))
 	if err != nil {
 		return nil, err
 	}
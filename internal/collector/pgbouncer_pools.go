@@ -11,15 +11,28 @@ import (
 
 const (
 	// admin console queries used for retrieving stats.
-	poolsQuery   = "SHOW POOLS"
-	clientsQuery = "SHOW CLIENTS"
+	poolsQuery     = "SHOW POOLS"
+	clientsQuery   = "SHOW CLIENTS"
+	serversQuery   = "SHOW SERVERS"
+	databasesQuery = "SHOW DATABASES"
+	// peers queries are available since Pgbouncer 1.21.
+	peersQuery     = "SHOW PEERS"
+	peerPoolsQuery = "SHOW PEER_POOLS"
 )
 
 type pgbouncerPoolsCollector struct {
 	labelNames []string
 	conns      typedDesc
 	maxwait    typedDesc
+	poolUtil   typedDesc
 	clients    typedDesc
+	clStates   typedDesc
+	svStates   typedDesc
+	peerSize   typedDesc
+	peerActive typedDesc
+	peerWait   typedDesc
+	peerLogin  typedDesc
+	peerCancel typedDesc
 }
 
 // NewPgbouncerPoolsCollector returns a new Collector exposing pgbouncer pools connections usage stats.
@@ -40,19 +53,67 @@ func NewPgbouncerPoolsCollector(constLabels labels, settings model.CollectorSett
 			[]string{"user", "database", "pool_mode"}, constLabels,
 			settings.Filters,
 		),
+		poolUtil: newBuiltinTypedDesc(
+			descOpts{"pgbouncer", "pool", "utilization_ratio", "Ratio of server connections in use (active and used) to the pool size.", 0},
+			prometheus.GaugeValue,
+			[]string{"user", "database", "pool_mode"}, constLabels,
+			settings.Filters,
+		),
 		clients: newBuiltinTypedDesc(
 			descOpts{"pgbouncer", "client", "connections_in_flight", "The total number of client connections established by source address.", 0},
 			prometheus.GaugeValue,
 			[]string{"user", "database", "address"}, constLabels,
 			settings.Filters,
 		),
+		clStates: newBuiltinTypedDesc(
+			descOpts{"pgbouncer", "", "clients_in_flight", "The total number of client connections in each state.", 0},
+			prometheus.GaugeValue,
+			[]string{"user", "database", "state"}, constLabels,
+			settings.Filters,
+		),
+		svStates: newBuiltinTypedDesc(
+			descOpts{"pgbouncer", "", "servers_in_flight", "The total number of server connections in each state.", 0},
+			prometheus.GaugeValue,
+			[]string{"user", "database", "state"}, constLabels,
+			settings.Filters,
+		),
+		peerSize: newBuiltinTypedDesc(
+			descOpts{"pgbouncer", "peer", "pool_size", "Max number of connections to the peer allowed by configuration.", 0},
+			prometheus.GaugeValue,
+			[]string{"peer", "host", "port"}, constLabels,
+			settings.Filters,
+		),
+		peerActive: newBuiltinTypedDesc(
+			descOpts{"pgbouncer", "peer_pool", "active", "The number of active cancel requests forwarded to the peer, by state.", 0},
+			prometheus.GaugeValue,
+			[]string{"peer", "state"}, constLabels,
+			settings.Filters,
+		),
+		peerWait: newBuiltinTypedDesc(
+			descOpts{"pgbouncer", "peer_pool", "waiting", "The number of cancel requests waiting to be forwarded to the peer.", 0},
+			prometheus.GaugeValue,
+			[]string{"peer"}, constLabels,
+			settings.Filters,
+		),
+		peerLogin: newBuiltinTypedDesc(
+			descOpts{"pgbouncer", "peer_pool", "login", "The number of connections to the peer currently in the process of logging in.", 0},
+			prometheus.GaugeValue,
+			[]string{"peer"}, constLabels,
+			settings.Filters,
+		),
+		peerCancel: newBuiltinTypedDesc(
+			descOpts{"pgbouncer", "peer_pool", "being_canceled", "The number of server connections to the peer waiting for in-flight cancel requests to complete.", 0},
+			prometheus.GaugeValue,
+			[]string{"peer"}, constLabels,
+			settings.Filters,
+		),
 		labelNames: poolsLabelNames,
 	}, nil
 }
 
 // Update method collects statistics, parse it and produces metrics that are sent to Prometheus.
 func (c *pgbouncerPoolsCollector) Update(config Config, ch chan<- prometheus.Metric) error {
-	conn, err := store.New(config.ConnString)
+	conn, err := store.NewPooled(config.scrapeContext(), config.Pool, config.ConnString)
 	if err != nil {
 		return err
 	}
@@ -71,6 +132,29 @@ func (c *pgbouncerPoolsCollector) Update(config Config, ch chan<- prometheus.Met
 	}
 
 	clientsStats := parsePgbouncerClientsStats(res)
+	clientsStates := parsePgbouncerConnectionsStates(res)
+
+	res, err = conn.Query(serversQuery)
+	if err != nil {
+		return err
+	}
+
+	serversStates := parsePgbouncerConnectionsStates(res)
+
+	// Pool sizes are taken from per-database settings, default pool size is used when it is not specified.
+	res, err = conn.Query(settingsQuery)
+	if err != nil {
+		return err
+	}
+
+	defaultPoolSize, _ := strconv.ParseFloat(parsePgbouncerSettings(res)["default_pool_size"], 64)
+
+	res, err = conn.Query(databasesQuery)
+	if err != nil {
+		return err
+	}
+
+	poolSizes := parsePgbouncerDatabasesPoolSizes(res)
 
 	// Process pools stats.
 	for _, stat := range poolsStats {
@@ -82,6 +166,10 @@ func (c *pgbouncerPoolsCollector) Update(config Config, ch chan<- prometheus.Met
 		ch <- c.conns.newConstMetric(stat.svTested, stat.user, stat.database, stat.mode, "sv_tested")
 		ch <- c.conns.newConstMetric(stat.svLogin, stat.user, stat.database, stat.mode, "sv_login")
 		ch <- c.maxwait.newConstMetric(stat.maxWait, stat.user, stat.database, stat.mode)
+
+		if ratio, ok := poolUtilizationRatio(stat, poolSizes, defaultPoolSize); ok {
+			ch <- c.poolUtil.newConstMetric(ratio, stat.user, stat.database, stat.mode)
+		}
 	}
 
 	// Process client connections stats.
@@ -97,6 +185,45 @@ func (c *pgbouncerPoolsCollector) Update(config Config, ch chan<- prometheus.Met
 		ch <- c.clients.newConstMetric(v, user, database, address)
 	}
 
+	// Process client and server connections states.
+	for k, v := range clientsStates {
+		ch <- c.clStates.newConstMetric(v, k.user, k.database, k.state)
+	}
+
+	for k, v := range serversStates {
+		ch <- c.svStates.newConstMetric(v, k.user, k.database, k.state)
+	}
+
+	// Process peers stats. Peering is available since Pgbouncer 1.21, older versions don't support
+	// SHOW PEERS and SHOW PEER_POOLS commands, in this case just skip peers stats.
+	res, err = conn.Query(peersQuery)
+	if err != nil {
+		log.Debugf("query peers failed: %s; skip", err)
+		return nil
+	}
+
+	peersStats := parsePgbouncerPeersStats(res)
+
+	res, err = conn.Query(peerPoolsQuery)
+	if err != nil {
+		log.Debugf("query peer pools failed: %s; skip", err)
+		return nil
+	}
+
+	peerPoolsStats := parsePgbouncerPeerPoolsStats(res)
+
+	for _, stat := range peersStats {
+		ch <- c.peerSize.newConstMetric(stat.poolSize, stat.peer, stat.host, stat.port)
+	}
+
+	for _, stat := range peerPoolsStats {
+		ch <- c.peerActive.newConstMetric(stat.clActiveCancel, stat.peer, "cl_active_cancel_req")
+		ch <- c.peerActive.newConstMetric(stat.svActiveCancel, stat.peer, "sv_active_cancel")
+		ch <- c.peerWait.newConstMetric(stat.clWaitingCancel, stat.peer)
+		ch <- c.peerLogin.newConstMetric(stat.svLogin, stat.peer)
+		ch <- c.peerCancel.newConstMetric(stat.svBeingCanceled, stat.peer)
+	}
+
 	return nil
 }
 
@@ -190,6 +317,57 @@ func parsePgbouncerPoolsStats(r *model.PGResult, labelNames []string) map[string
 	return stats
 }
 
+// parsePgbouncerDatabasesPoolSizes parses result of SHOW DATABASES and returns pool sizes of databases. Databases
+// without pool size are skipped.
+func parsePgbouncerDatabasesPoolSizes(r *model.PGResult) map[string]float64 {
+	log.Debug("parse pgbouncer databases pool sizes")
+
+	var sizes = map[string]float64{}
+
+	for _, row := range r.Rows {
+		var name, size string
+
+		for i, colname := range r.Colnames {
+			switch string(colname.Name) {
+			case "name":
+				name = row[i].String
+			case "pool_size":
+				size = row[i].String
+			}
+			// skip all other columns
+		}
+
+		if size == "" {
+			continue
+		}
+
+		v, err := strconv.ParseFloat(size, 64)
+		if err != nil {
+			log.Errorf("invalid input, parse '%s' failed: %s, skip", size, err)
+			continue
+		}
+
+		sizes[name] = v
+	}
+
+	return sizes
+}
+
+// poolUtilizationRatio returns ratio of server connections in use to the size of the pool. Size of the pool is taken
+// from per-database sizes, default size is used if database size is unknown. False is returned if size is zero.
+func poolUtilizationRatio(stat pgbouncerPoolStat, sizes map[string]float64, defaultSize float64) (float64, bool) {
+	size, ok := sizes[stat.database]
+	if !ok || size <= 0 {
+		size = defaultSize
+	}
+
+	if size <= 0 {
+		return 0, false
+	}
+
+	return (stat.svActive + stat.svUsed) / size, true
+}
+
 // parsePgbouncerClientsStats parses query result and returns connected clients stats.
 func parsePgbouncerClientsStats(r *model.PGResult) map[string]float64 {
 	log.Debug("parse pgbouncer clients stats")
@@ -219,3 +397,143 @@ func parsePgbouncerClientsStats(r *model.PGResult) map[string]float64 {
 
 	return stats
 }
+
+// pgbouncerConnectionState is a key used for aggregating connections by user, database and state.
+type pgbouncerConnectionState struct {
+	user     string
+	database string
+	state    string
+}
+
+// parsePgbouncerConnectionsStates parses result of SHOW CLIENTS or SHOW SERVERS and returns number of connections
+// aggregated by user, database and state.
+func parsePgbouncerConnectionsStates(r *model.PGResult) map[pgbouncerConnectionState]float64 {
+	log.Debug("parse pgbouncer connections states")
+
+	var stats = map[pgbouncerConnectionState]float64{}
+
+	for _, row := range r.Rows {
+		var key pgbouncerConnectionState
+
+		for i, colname := range r.Colnames {
+			switch string(colname.Name) {
+			case "user":
+				key.user = row[i].String
+			case "database":
+				key.database = row[i].String
+			case "state":
+				key.state = row[i].String
+			}
+			// skip all other columns
+		}
+
+		stats[key]++
+	}
+
+	return stats
+}
+
+// pgbouncerPeerStat describes peer configured in Pgbouncer.
+type pgbouncerPeerStat struct {
+	peer     string
+	host     string
+	port     string
+	poolSize float64
+}
+
+// parsePgbouncerPeersStats parses result of SHOW PEERS and returns peers stats.
+func parsePgbouncerPeersStats(r *model.PGResult) map[string]pgbouncerPeerStat {
+	log.Debug("parse pgbouncer peers stats")
+
+	var stats = map[string]pgbouncerPeerStat{}
+
+	for _, row := range r.Rows {
+		stat := pgbouncerPeerStat{}
+
+		for i, colname := range r.Colnames {
+			switch string(colname.Name) {
+			case "peer_id":
+				stat.peer = row[i].String
+			case "host":
+				stat.host = row[i].String
+			case "port":
+				stat.port = row[i].String
+			case "pool_size":
+				// Skip empty (NULL) values.
+				if !row[i].Valid {
+					continue
+				}
+
+				v, err := strconv.ParseFloat(row[i].String, 64)
+				if err != nil {
+					log.Errorf("invalid input, parse '%s' failed: %s, skip", row[i].String, err)
+					continue
+				}
+				stat.poolSize = v
+			}
+		}
+
+		stats[stat.peer] = stat
+	}
+
+	return stats
+}
+
+// pgbouncerPeerPoolStat is a per-peer store for cancel requests metrics.
+type pgbouncerPeerPoolStat struct {
+	peer            string
+	clActiveCancel  float64
+	clWaitingCancel float64
+	svActiveCancel  float64
+	svBeingCanceled float64
+	svLogin         float64
+}
+
+// parsePgbouncerPeerPoolsStats parses result of SHOW PEER_POOLS and returns per-peer stats.
+func parsePgbouncerPeerPoolsStats(r *model.PGResult) map[string]pgbouncerPeerPoolStat {
+	log.Debug("parse pgbouncer peer pools stats")
+
+	var stats = map[string]pgbouncerPeerPoolStat{}
+
+	for _, row := range r.Rows {
+		stat := pgbouncerPeerPoolStat{}
+
+		for i, colname := range r.Colnames {
+			if string(colname.Name) == "peer_id" {
+				stat.peer = row[i].String
+			}
+		}
+
+		for i, colname := range r.Colnames {
+			// Skip label column and empty (NULL) values.
+			if string(colname.Name) == "peer_id" || !row[i].Valid {
+				continue
+			}
+
+			v, err := strconv.ParseFloat(row[i].String, 64)
+			if err != nil {
+				log.Errorf("invalid input, parse '%s' failed: %s, skip", row[i].String, err)
+				continue
+			}
+
+			switch string(colname.Name) {
+			case "cl_active_cancel_req":
+				stat.clActiveCancel = v
+			case "cl_waiting_cancel_req":
+				stat.clWaitingCancel = v
+			case "sv_active_cancel":
+				stat.svActiveCancel = v
+			case "sv_being_canceled":
+				stat.svBeingCanceled = v
+			case "sv_login":
+				stat.svLogin = v
+			default:
+				continue
+			}
+		}
+
+		stats[stat.peer] = stat
+	}
+
+	return stats
+}
@@ -13,7 +13,17 @@ func TestPgbouncerPoolsCollector_Update(t *testing.T) {
 		required: []string{
 			"pgbouncer_pool_connections_in_flight",
 			"pgbouncer_pool_max_wait_seconds",
+			"pgbouncer_pool_utilization_ratio",
 			"pgbouncer_client_connections_in_flight",
+			"pgbouncer_clients_in_flight",
+		},
+		optional: []string{
+			"pgbouncer_servers_in_flight",
+			"pgbouncer_peer_pool_size",
+			"pgbouncer_peer_pool_active",
+			"pgbouncer_peer_pool_waiting",
+			"pgbouncer_peer_pool_login",
+			"pgbouncer_peer_pool_being_canceled",
 		},
 		collector: NewPgbouncerPoolsCollector,
 		service:   model.ServiceTypePgbouncer,
@@ -73,6 +83,51 @@ func Test_parsePgbouncerPoolsStats(t *testing.T) {
 	}
 }
 
+func Test_parsePgbouncerDatabasesPoolSizes(t *testing.T) {
+	res := &model.PGResult{
+		Nrows: 4,
+		Ncols: 4,
+		Colnames: []pgproto3.FieldDescription{
+			{Name: []byte("name")}, {Name: []byte("host")}, {Name: []byte("database")}, {Name: []byte("pool_size")},
+		},
+		Rows: [][]sql.NullString{
+			{{String: "testdb1", Valid: true}, {String: "127.0.0.1", Valid: true}, {String: "testdb1", Valid: true}, {String: "20", Valid: true}},
+			{{String: "testdb2", Valid: true}, {String: "127.0.0.1", Valid: true}, {String: "testdb2", Valid: true}, {String: "50", Valid: true}},
+			{{String: "testdb3", Valid: true}, {String: "127.0.0.1", Valid: true}, {String: "testdb3", Valid: true}, {Valid: false}},
+			{{String: "testdb4", Valid: true}, {String: "127.0.0.1", Valid: true}, {String: "testdb4", Valid: true}, {String: "invalid", Valid: true}},
+		},
+	}
+
+	want := map[string]float64{"testdb1": 20, "testdb2": 50}
+	assert.Equal(t, want, parsePgbouncerDatabasesPoolSizes(res))
+}
+
+func Test_poolUtilizationRatio(t *testing.T) {
+	sizes := map[string]float64{"testdb1": 20, "testdb2": 0}
+
+	testcases := []struct {
+		name        string
+		stat        pgbouncerPoolStat
+		defaultSize float64
+		want        float64
+		ok          bool
+	}{
+		{name: "per-database size", stat: pgbouncerPoolStat{database: "testdb1", svActive: 15, svUsed: 3}, defaultSize: 10, want: 0.9, ok: true},
+		{name: "zero per-database size", stat: pgbouncerPoolStat{database: "testdb2", svActive: 5, svUsed: 0}, defaultSize: 10, want: 0.5, ok: true},
+		{name: "default size", stat: pgbouncerPoolStat{database: "testdb3", svActive: 8, svUsed: 2}, defaultSize: 10, want: 1, ok: true},
+		{name: "idle pool", stat: pgbouncerPoolStat{database: "testdb1", svIdle: 5}, defaultSize: 10, want: 0, ok: true},
+		{name: "unknown size", stat: pgbouncerPoolStat{database: "testdb3", svActive: 8}, defaultSize: 0, want: 0, ok: false},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := poolUtilizationRatio(tc.stat, sizes, tc.defaultSize)
+			assert.Equal(t, tc.ok, ok)
+			assert.InDelta(t, tc.want, got, 0.0001)
+		})
+	}
+}
+
 func Test_parsePgbouncerClientsStats(t *testing.T) {
 	var testCases = []struct {
 		name string
@@ -115,3 +170,150 @@ func Test_parsePgbouncerClientsStats(t *testing.T) {
 		})
 	}
 }
+
+func Test_parsePgbouncerConnectionsStates(t *testing.T) {
+	var testCases = []struct {
+		name string
+		res  *model.PGResult
+		want map[pgbouncerConnectionState]float64
+	}{
+		{
+			name: "normal output",
+			res: &model.PGResult{
+				Nrows: 6,
+				Ncols: 5,
+				Colnames: []pgproto3.FieldDescription{
+					{Name: []byte("type")}, {Name: []byte("user")}, {Name: []byte("database")}, {Name: []byte("state")}, {Name: []byte("addr")},
+				},
+				Rows: [][]sql.NullString{
+					{{String: "C", Valid: true}, {String: "user1", Valid: true}, {String: "db1", Valid: true}, {String: "active", Valid: true}, {String: "1.1.1.1", Valid: true}},
+					{{String: "C", Valid: true}, {String: "user1", Valid: true}, {String: "db1", Valid: true}, {String: "active", Valid: true}, {String: "1.1.1.2", Valid: true}},
+					{{String: "C", Valid: true}, {String: "user1", Valid: true}, {String: "db1", Valid: true}, {String: "waiting", Valid: true}, {String: "1.1.1.1", Valid: true}},
+					{{String: "C", Valid: true}, {String: "user2", Valid: true}, {String: "db2", Valid: true}, {String: "active", Valid: true}, {String: "2.2.2.2", Valid: true}},
+					{{String: "C", Valid: true}, {String: "user2", Valid: true}, {String: "db2", Valid: true}, {String: "idle", Valid: true}, {String: "unix", Valid: true}},
+					{{String: "C", Valid: true}, {String: "user2", Valid: true}, {String: "db2", Valid: true}, {String: "idle", Valid: true}, {String: "unix", Valid: true}},
+				},
+			},
+			want: map[pgbouncerConnectionState]float64{
+				{user: "user1", database: "db1", state: "active"}:  2,
+				{user: "user1", database: "db1", state: "waiting"}: 1,
+				{user: "user2", database: "db2", state: "active"}:  1,
+				{user: "user2", database: "db2", state: "idle"}:    2,
+			},
+		},
+		{
+			name: "empty output",
+			res: &model.PGResult{
+				Colnames: []pgproto3.FieldDescription{
+					{Name: []byte("type")}, {Name: []byte("user")}, {Name: []byte("database")}, {Name: []byte("state")}, {Name: []byte("addr")},
+				},
+			},
+			want: map[pgbouncerConnectionState]float64{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := parsePgbouncerConnectionsStates(tc.res)
+			assert.EqualValues(t, tc.want, got)
+		})
+	}
+}
+
+func Test_parsePgbouncerPeersStats(t *testing.T) {
+	var testCases = []struct {
+		name string
+		res  *model.PGResult
+		want map[string]pgbouncerPeerStat
+	}{
+		{
+			name: "normal output",
+			res: &model.PGResult{
+				Nrows: 2,
+				Ncols: 4,
+				Colnames: []pgproto3.FieldDescription{
+					{Name: []byte("peer_id")}, {Name: []byte("host")}, {Name: []byte("port")}, {Name: []byte("pool_size")},
+				},
+				Rows: [][]sql.NullString{
+					{{String: "1", Valid: true}, {String: "/tmp/.s.PGSQL.6432", Valid: true}, {String: "6432", Valid: true}, {String: "100", Valid: true}},
+					{{String: "2", Valid: true}, {String: "10.0.0.2", Valid: true}, {String: "6433", Valid: true}, {String: "50", Valid: true}},
+				},
+			},
+			want: map[string]pgbouncerPeerStat{
+				"1": {peer: "1", host: "/tmp/.s.PGSQL.6432", port: "6432", poolSize: 100},
+				"2": {peer: "2", host: "10.0.0.2", port: "6433", poolSize: 50},
+			},
+		},
+		{
+			name: "empty output",
+			res: &model.PGResult{
+				Nrows: 0,
+				Ncols: 4,
+				Colnames: []pgproto3.FieldDescription{
+					{Name: []byte("peer_id")}, {Name: []byte("host")}, {Name: []byte("port")}, {Name: []byte("pool_size")},
+				},
+				Rows: [][]sql.NullString{},
+			},
+			want: map[string]pgbouncerPeerStat{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := parsePgbouncerPeersStats(tc.res)
+			assert.EqualValues(t, tc.want, got)
+		})
+	}
+}
+
+func Test_parsePgbouncerPeerPoolsStats(t *testing.T) {
+	var testCases = []struct {
+		name string
+		res  *model.PGResult
+		want map[string]pgbouncerPeerPoolStat
+	}{
+		{
+			name: "normal output",
+			res: &model.PGResult{
+				Nrows: 2,
+				Ncols: 5,
+				Colnames: []pgproto3.FieldDescription{
+					{Name: []byte("peer_id")}, {Name: []byte("cl_active_cancel_req")}, {Name: []byte("cl_waiting_cancel_req")},
+					{Name: []byte("sv_active_cancel")}, {Name: []byte("sv_login")},
+				},
+				Rows: [][]sql.NullString{
+					{{String: "1", Valid: true}, {String: "2", Valid: true}, {String: "1", Valid: true}, {String: "2", Valid: true}, {String: "0", Valid: true}},
+					{{String: "2", Valid: true}, {String: "5", Valid: true}, {String: "0", Valid: true}, {String: "4", Valid: true}, {String: "1", Valid: true}},
+				},
+			},
+			want: map[string]pgbouncerPeerPoolStat{
+				"1": {peer: "1", clActiveCancel: 2, clWaitingCancel: 1, svActiveCancel: 2, svLogin: 0},
+				"2": {peer: "2", clActiveCancel: 5, clWaitingCancel: 0, svActiveCancel: 4, svLogin: 1},
+			},
+		},
+		{
+			name: "output with sv_being_canceled",
+			res: &model.PGResult{
+				Nrows: 1,
+				Ncols: 6,
+				Colnames: []pgproto3.FieldDescription{
+					{Name: []byte("peer_id")}, {Name: []byte("cl_active_cancel_req")}, {Name: []byte("cl_waiting_cancel_req")},
+					{Name: []byte("sv_active_cancel")}, {Name: []byte("sv_being_canceled")}, {Name: []byte("sv_login")},
+				},
+				Rows: [][]sql.NullString{
+					{{String: "1", Valid: true}, {String: "3", Valid: true}, {String: "1", Valid: true}, {String: "3", Valid: true}, {String: "2", Valid: true}, {String: "1", Valid: true}},
+				},
+			},
+			want: map[string]pgbouncerPeerPoolStat{
+				"1": {peer: "1", clActiveCancel: 3, clWaitingCancel: 1, svActiveCancel: 3, svBeingCanceled: 2, svLogin: 1},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := parsePgbouncerPeerPoolsStats(tc.res)
+			assert.EqualValues(t, tc.want, got)
+		})
+	}
+}
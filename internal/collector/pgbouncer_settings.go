@@ -21,11 +21,34 @@ const (
 	versionQuery  = "SHOW VERSION"
 )
 
+// pgbouncerConfigValues defines numeric settings from 'SHOW CONFIG' exposed as values. These settings limit pools and
+// clients and are useful for correlating pools saturation with pools sizing.
+var pgbouncerConfigValues = map[string]struct{}{
+	"max_client_conn":          {},
+	"default_pool_size":        {},
+	"min_pool_size":            {},
+	"reserve_pool_size":        {},
+	"reserve_pool_timeout":     {},
+	"max_db_connections":       {},
+	"max_user_connections":     {},
+	"server_lifetime":          {},
+	"server_idle_timeout":      {},
+	"server_connect_timeout":   {},
+	"server_login_retry":       {},
+	"query_timeout":            {},
+	"query_wait_timeout":       {},
+	"client_idle_timeout":      {},
+	"client_login_timeout":     {},
+	"idle_transaction_timeout": {},
+}
+
 type pgbouncerSettingsCollector struct {
-	version    typedDesc
-	settings   typedDesc
-	dbSettings typedDesc
-	poolSize   typedDesc
+	version     typedDesc
+	versionInfo typedDesc
+	settings    typedDesc
+	configValue typedDesc
+	dbSettings  typedDesc
+	poolSize    typedDesc
 }
 
 // NewPgbouncerSettingsCollector returns a new Collector exposing pgbouncer configuration.
@@ -38,12 +61,24 @@ func NewPgbouncerSettingsCollector(constLabels labels, settings model.CollectorS
 			[]string{"version"}, constLabels,
 			settings.Filters,
 		),
+		versionInfo: newBuiltinTypedDesc(
+			descOpts{"pgbouncer", "version", "info", "Labeled information about Pgbouncer version.", 0},
+			prometheus.GaugeValue,
+			[]string{"version"}, constLabels,
+			settings.Filters,
+		),
 		settings: newBuiltinTypedDesc(
 			descOpts{"pgbouncer", "service", "settings_info", "Labeled information about Pgbouncer configuration settings.", 0},
 			prometheus.GaugeValue,
 			[]string{"name", "setting"}, constLabels,
 			settings.Filters,
 		),
+		configValue: newBuiltinTypedDesc(
+			descOpts{"pgbouncer", "config", "value", "Values of Pgbouncer numeric configuration settings, timeouts are in seconds.", 0},
+			prometheus.GaugeValue,
+			[]string{"name"}, constLabels,
+			settings.Filters,
+		),
 		dbSettings: newBuiltinTypedDesc(
 			descOpts{"pgbouncer", "service", "database_settings_info", "Labeled information about Pgbouncer's per-database configuration settings.", 0},
 			prometheus.GaugeValue,
@@ -61,7 +96,7 @@ func NewPgbouncerSettingsCollector(constLabels labels, settings model.CollectorS
 
 // Update method collects statistics, parse it and produces metrics that are sent to Prometheus.
 func (c *pgbouncerSettingsCollector) Update(config Config, ch chan<- prometheus.Metric) error {
-	conn, err := store.New(config.ConnString)
+	conn, err := store.NewPooled(config.scrapeContext(), config.Pool, config.ConnString)
 	if err != nil {
 		return err
 	}
@@ -77,6 +112,7 @@ func (c *pgbouncerSettingsCollector) Update(config Config, ch chan<- prometheus.
 	}
 	if version != 0 {
 		ch <- c.version.newConstMetric(float64(version), versionStr)
+		ch <- c.versionInfo.newConstMetric(1, versionStr)
 	}
 
 	// Query pgbouncer settings.
@@ -97,6 +133,10 @@ func (c *pgbouncerSettingsCollector) Update(config Config, ch chan<- prometheus.
 		}
 	}
 
+	for k, v := range parsePgbouncerConfigValues(settings) {
+		ch <- c.configValue.newConstMetric(v, k)
+	}
+
 	if conffile, ok := settings["conffile"]; ok {
 		dbSettings, err := getPerDatabaseSettings(
 			conffile,
@@ -124,10 +164,26 @@ func (c *pgbouncerSettingsCollector) Update(config Config, ch chan<- prometheus.
 	return nil
 }
 
+// checkPgbouncerConn checks Pgbouncer is available by querying its version, connection itself might be reused and
+// doesn't guarantee Pgbouncer is up.
+func checkPgbouncerConn(ctx context.Context, pool *store.Pool, connString string) error {
+	conn, err := store.NewPooled(ctx, pool, connString)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, _, err = queryPgbouncerVersion(conn)
+	return err
+}
+
 // queryPgbouncerVersion queries version info from Pgbouncer and return numeric and string version representation.
 func queryPgbouncerVersion(conn *store.DB) (int, string, error) {
 	var versionStr string
-	err := conn.Conn().QueryRow(context.Background(), versionQuery).Scan(&versionStr)
+	ctx, cancel := conn.Context()
+	defer cancel()
+
+	err := conn.Conn().QueryRow(ctx, versionQuery).Scan(&versionStr)
 	if err != nil {
 		// Pgbouncer before 1.12 returns version string as a NOTICE, and it seems there is no way to extract
 		// message text from the NOTICE. Return zero value and nil as error.
@@ -170,6 +226,27 @@ func parsePgbouncerSettings(r *model.PGResult) map[string]string {
 	return settings
 }
 
+// parsePgbouncerConfigValues returns values of known numeric settings. Unknown and non-numeric settings are skipped.
+func parsePgbouncerConfigValues(settings map[string]string) map[string]float64 {
+	values := make(map[string]float64)
+
+	for k, v := range settings {
+		if _, ok := pgbouncerConfigValues[k]; !ok {
+			continue
+		}
+
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			log.Debugf("invalid input, parse '%s' value '%s' failed: %s; skip", k, v, err)
+			continue
+		}
+
+		values[k] = f
+	}
+
+	return values
+}
+
 // dbSettings describes per-database settings specified inside [database] section of pgbouncer config file.
 type dbSettings struct {
 	name string
@@ -13,6 +13,8 @@ func TestPgbouncerSettingsCollector_Update(t *testing.T) {
 	var input = pipelineInput{
 		required: []string{
 			"pgbouncer_version",
+			"pgbouncer_version_info",
+			"pgbouncer_config_value",
 			"pgbouncer_service_settings_info",
 			"pgbouncer_service_database_settings_info",
 			"pgbouncer_service_database_pool_size",
@@ -67,6 +69,36 @@ func Test_parsePgbouncerSettings(t *testing.T) {
 	}
 }
 
+func Test_parsePgbouncerConfigValues(t *testing.T) {
+	res := &model.PGResult{
+		Nrows: 8,
+		Ncols: 3,
+		Colnames: []pgproto3.FieldDescription{
+			{Name: []byte("key")}, {Name: []byte("value")}, {Name: []byte("changeable")},
+		},
+		Rows: [][]sql.NullString{
+			{{String: "listen_addr", Valid: true}, {String: "127.0.0.1", Valid: true}, {String: "no", Valid: true}},
+			{{String: "listen_port", Valid: true}, {String: "6432", Valid: true}, {String: "no", Valid: true}},
+			{{String: "max_client_conn", Valid: true}, {String: "1000", Valid: true}, {String: "yes", Valid: true}},
+			{{String: "default_pool_size", Valid: true}, {String: "20", Valid: true}, {String: "yes", Valid: true}},
+			{{String: "reserve_pool_timeout", Valid: true}, {String: "5", Valid: true}, {String: "yes", Valid: true}},
+			{{String: "query_wait_timeout", Valid: true}, {String: "120", Valid: true}, {String: "yes", Valid: true}},
+			{{String: "server_lifetime", Valid: true}, {String: "invalid", Valid: true}, {String: "yes", Valid: true}},
+			{{String: "pool_mode", Valid: true}, {String: "transaction", Valid: true}, {String: "yes", Valid: true}},
+		},
+	}
+
+	want := map[string]float64{
+		"max_client_conn":      1000,
+		"default_pool_size":    20,
+		"reserve_pool_timeout": 5,
+		"query_wait_timeout":   120,
+	}
+
+	assert.Equal(t, want, parsePgbouncerConfigValues(parsePgbouncerSettings(res)))
+	assert.Equal(t, map[string]float64{}, parsePgbouncerConfigValues(map[string]string{}))
+}
+
 func Test_getPerDatabaseSettings(t *testing.T) {
 	defaults := map[string]string{
 		"pool_mode":         "transaction",
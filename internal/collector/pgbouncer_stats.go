@@ -17,6 +17,7 @@ type pgbouncerStatsCollector struct {
 	bytes      typedDesc
 	time       typedDesc
 	labelNames []string
+	upState    *upState
 }
 
 // NewPgbouncerStatsCollector returns a new Collector exposing pgbouncer pools usage stats (except averages).
@@ -26,6 +27,7 @@ func NewPgbouncerStatsCollector(constLabels labels, settings model.CollectorSett
 
 	return &pgbouncerStatsCollector{
 		labelNames: pgbouncerLabelNames,
+		upState:    newUpState(),
 		up: newBuiltinTypedDesc(
 			descOpts{"pgbouncer", "", "up", "State of Pgbouncer service: 0 is down, 1 is up.", 0},
 			prometheus.CounterValue,
@@ -65,9 +67,9 @@ func NewPgbouncerStatsCollector(constLabels labels, settings model.CollectorSett
 
 // Update method collects statistics, parse it and produces metrics that are sent to Prometheus.
 func (c *pgbouncerStatsCollector) Update(config Config, ch chan<- prometheus.Metric) error {
-	conn, err := store.New(config.ConnString)
+	conn, err := store.NewPooled(config.scrapeContext(), config.Pool, config.ConnString)
 	if err != nil {
-		ch <- c.up.newConstMetric(0)
+		ch <- c.up.newConstMetric(c.upState.update(false, config.WarmupPeriod))
 		return err
 	}
 	defer conn.Close()
@@ -90,7 +92,7 @@ func (c *pgbouncerStatsCollector) Update(config Config, ch chan<- prometheus.Met
 	}
 
 	// All is ok, collect up metric.
-	ch <- c.up.newConstMetric(1)
+	ch <- c.up.newConstMetric(c.upState.update(true, config.WarmupPeriod))
 
 	return nil
 }
@@ -0,0 +1,26 @@
+package collector
+
+import (
+	"github.com/lesovsky/pgscv/internal/model"
+	"github.com/prometheus/client_golang/prometheus"
+	"runtime"
+)
+
+// NewBuildInfoCollector creates new collector which exposes information about pgSCV build. The collector doesn't depend
+// on services and should be registered once.
+func NewBuildInfoCollector(info model.BuildInfo) prometheus.Collector {
+	return prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Namespace: withMetricsPrefix("pgscv"),
+			Name:      "build_info",
+			Help:      "Labeled information about pgSCV build.",
+			ConstLabels: prometheus.Labels{
+				"version":   info.Version,
+				"commit":    info.Commit,
+				"branch":    info.Branch,
+				"goversion": runtime.Version(),
+			},
+		},
+		func() float64 { return 1 },
+	)
+}
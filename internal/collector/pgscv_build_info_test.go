@@ -0,0 +1,32 @@
+package collector
+
+import (
+	"github.com/lesovsky/pgscv/internal/model"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"runtime"
+	"testing"
+)
+
+func TestNewBuildInfoCollector(t *testing.T) {
+	c := NewBuildInfoCollector(model.BuildInfo{Version: "v0.0.1", Commit: "abcdef", Branch: "master"})
+
+	ch := make(chan prometheus.Metric, 1)
+	c.Collect(ch)
+	close(ch)
+
+	m := <-ch
+	assert.Contains(t, m.Desc().String(), `fqName: "pgscv_build_info"`)
+
+	metric := &dto.Metric{}
+	assert.NoError(t, m.Write(metric))
+	assert.Equal(t, float64(1), metric.GetGauge().GetValue())
+
+	got := map[string]string{}
+	for _, l := range metric.GetLabel() {
+		got[l.GetName()] = l.GetValue()
+	}
+
+	assert.Equal(t, map[string]string{"version": "v0.0.1", "commit": "abcdef", "branch": "master", "goversion": runtime.Version()}, got)
+}
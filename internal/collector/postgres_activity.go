@@ -1,33 +1,38 @@
 package collector
 
 import (
-	"context"
 	"github.com/lesovsky/pgscv/internal/log"
 	"github.com/lesovsky/pgscv/internal/model"
 	"github.com/lesovsky/pgscv/internal/store"
 	"github.com/prometheus/client_golang/prometheus"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 )
 
+// Activity queries truncate query texts to 32 characters, which is enough for queries classification. Autovacuum
+// queries are not truncated to keep '(to prevent wraparound)' suffix.
 const (
 	// postgresActivityQuery95 defines activity query for 9.5 and older.
-	// Postgres 9.5 doesn't have 'wait_event_type', 'wait_event' and 'backend_type'  attributes.
+	// Postgres 9.5 doesn't have 'wait_event_type', 'wait_event' and 'backend_type'  attributes. Only client backends
+	// are shown in pg_stat_activity, hence backend_type is constant.
 	postgresActivityQuery95 = "SELECT " +
 		"coalesce(usename, 'system') AS user, datname AS database, state, waiting, " +
 		"coalesce(extract(epoch FROM clock_timestamp() - xact_start), 0) AS active_seconds, " +
 		"CASE WHEN waiting = 't' THEN extract(epoch FROM clock_timestamp() - state_change) ELSE 0 END AS waiting_seconds, " +
-		"left(query, 32) AS query " +
+		"extract(epoch FROM clock_timestamp() - xact_start) AS xact_seconds, age(backend_xmin) AS xmin_age, " +
+		"CASE WHEN query LIKE 'autovacuum:%' THEN query ELSE left(query, 32) END AS query, 'client backend' AS backend_type " +
 		"FROM pg_stat_activity"
 
 	// postgresActivityQuery96 defines activity query for 9.6.
-	// Postgres 9.6 doesn't have 'backend_type' attribute.
+	// Postgres 9.6 doesn't have 'backend_type' attribute, only client backends are shown in pg_stat_activity.
 	postgresActivityQuery96 = "SELECT " +
 		"coalesce(usename, 'system') AS user, datname AS database, state, wait_event_type, wait_event, " +
 		"coalesce(extract(epoch FROM clock_timestamp() - xact_start), 0) AS active_seconds, " +
 		"CASE WHEN wait_event_type = 'Lock' THEN extract(epoch FROM clock_timestamp() - state_change) ELSE 0 END AS waiting_seconds, " +
-		"left(query, 32) AS query " +
+		"extract(epoch FROM clock_timestamp() - xact_start) AS xact_seconds, age(backend_xmin) AS xmin_age, " +
+		"CASE WHEN query LIKE 'autovacuum:%' THEN query ELSE left(query, 32) END AS query, 'client backend' AS backend_type " +
 		"FROM pg_stat_activity"
 
 	// postgresActivityQuery13 defines activity query for versions from 10 to 13.
@@ -35,7 +40,8 @@ const (
 		"coalesce(usename, backend_type) AS user, datname AS database, state, wait_event_type, wait_event, " +
 		"coalesce(extract(epoch FROM clock_timestamp() - xact_start), 0) AS active_seconds, " +
 		"CASE WHEN wait_event_type = 'Lock' THEN extract(epoch FROM clock_timestamp() - state_change) ELSE 0 END AS waiting_seconds, " +
-		"left(query, 32) AS query " +
+		"extract(epoch FROM clock_timestamp() - xact_start) AS xact_seconds, age(backend_xmin) AS xmin_age, " +
+		"CASE WHEN query LIKE 'autovacuum:%' THEN query ELSE left(query, 32) END AS query, backend_type " +
 		"FROM pg_stat_activity"
 
 	// postgresActivityQueryLatest defines activity query for recent versions.
@@ -46,9 +52,21 @@ const (
 		"CASE WHEN wait_event_type = 'Lock' " +
 		"THEN (SELECT extract(epoch FROM clock_timestamp() - max(waitstart)) FROM pg_locks l WHERE l.pid = a.pid) " +
 		"ELSE 0 END AS waiting_seconds, " +
-		"left(query, 32) AS query " +
+		"extract(epoch FROM clock_timestamp() - xact_start) AS xact_seconds, age(backend_xmin) AS xmin_age, " +
+		"CASE WHEN query LIKE 'autovacuum:%' THEN query ELSE left(query, 32) END AS query, backend_type " +
 		"FROM pg_stat_activity a"
 
+	// postgresBlockingQuery defines query for pairs of blocked and blocking backends. Available since Postgres 9.6.
+	postgresBlockingQuery = "SELECT a.pid, b.pid AS blocking_pid FROM pg_stat_activity a, unnest(pg_blocking_pids(a.pid)) AS b(pid)"
+
+	// postgresConnectionsByQuery defines query for number of client connections by user, database, application name and state.
+	postgresConnectionsByQuery = "SELECT usename AS user, datname AS database, coalesce(application_name, '') AS application_name, state, count(*) AS total " +
+		"FROM pg_stat_activity WHERE usename IS NOT NULL AND datname IS NOT NULL AND state IS NOT NULL " +
+		"GROUP BY usename, datname, application_name, state"
+
+	// defaultConnectionsByTopN defines default max number of application names used in connections breakdown.
+	defaultConnectionsByTopN = 10
+
 	postgresPreparedXactQuery = "SELECT count(*) AS total FROM pg_prepared_xacts"
 
 	postgresStartTimeQuery = "SELECT extract(epoch FROM pg_postmaster_start_time())"
@@ -68,16 +86,28 @@ const (
 
 // postgresActivityCollector contains metrics related to Postgres activity.
 type postgresActivityCollector struct {
-	up         typedDesc
-	startTime  typedDesc
-	waitEvents typedDesc
-	states     typedDesc
-	statesAll  typedDesc
-	activity   typedDesc
-	prepared   typedDesc
-	inflight   typedDesc
-	vacuums    typedDesc
-	re         queryRegexp // regexps for queries classification
+	up             typedDesc
+	startTime      typedDesc
+	waitEvents     typedDesc
+	waitEventTypes typedDesc
+	states         typedDesc
+	statesAll      typedDesc
+	activity       typedDesc
+	prepared       typedDesc
+	inflight       typedDesc
+	vacuums        typedDesc
+	backendTypes   typedDesc
+	oldestXact     typedDesc
+	oldestXmin     typedDesc
+	blocked        typedDesc
+	blocking       typedDesc
+	connectionsBy  typedDesc
+	avWorkers      typedDesc
+	avMaxDuration  typedDesc
+	re             queryRegexp // regexps for queries classification
+	upState        *upState    // service state used for 'up' metric
+	// connectionsBySettings defines breakdown of connections by user, database, application name and state.
+	connectionsBySettings model.ConnectionsBySettings
 }
 
 // NewPostgresActivityCollector returns a new Collector exposing postgres activity stats.
@@ -104,6 +134,12 @@ func NewPostgresActivityCollector(constLabels labels, settings model.CollectorSe
 			[]string{"type", "event"}, constLabels,
 			settings.Filters,
 		),
+		waitEventTypes: newBuiltinTypedDesc(
+			descOpts{"postgres", "activity", "wait_event_type_total", "Number of wait events in-flight of each type.", 0},
+			prometheus.GaugeValue,
+			[]string{"type"}, constLabels,
+			settings.Filters,
+		),
 		states: newBuiltinTypedDesc(
 			descOpts{"postgres", "activity", "connections_in_flight", "Number of connections in-flight in each state.", 0},
 			prometheus.GaugeValue,
@@ -140,15 +176,65 @@ func NewPostgresActivityCollector(constLabels labels, settings model.CollectorSe
 			[]string{"type"}, constLabels,
 			settings.Filters,
 		),
-		re: newQueryRegexp(),
+		backendTypes: newBuiltinTypedDesc(
+			descOpts{"postgres", "activity", "backends_by_type", "Number of backends of each type.", 0},
+			prometheus.GaugeValue,
+			[]string{"backend_type"}, constLabels,
+			settings.Filters,
+		),
+		oldestXact: newBuiltinTypedDesc(
+			descOpts{"postgres", "activity", "oldest_transaction_seconds", "Age of the oldest transaction among active and idle in transaction backends, in seconds.", 0},
+			prometheus.GaugeValue,
+			nil, constLabels,
+			settings.Filters,
+		),
+		oldestXmin: newBuiltinTypedDesc(
+			descOpts{"postgres", "activity", "oldest_xmin_age", "Age of the oldest backend's xmin horizon, in transactions.", 0},
+			prometheus.GaugeValue,
+			nil, constLabels,
+			settings.Filters,
+		),
+		blocked: newBuiltinTypedDesc(
+			descOpts{"postgres", "activity", "blocked_sessions", "Number of sessions blocked by other sessions.", 0},
+			prometheus.GaugeValue,
+			nil, constLabels,
+			settings.Filters,
+		),
+		blocking: newBuiltinTypedDesc(
+			descOpts{"postgres", "activity", "blocking_sessions", "Number of sessions blocking other sessions.", 0},
+			prometheus.GaugeValue,
+			nil, constLabels,
+			settings.Filters,
+		),
+		connectionsBy: newBuiltinTypedDesc(
+			descOpts{"postgres", "activity", "connections_by", "Number of client connections by user, database, application name and state.", 0},
+			prometheus.GaugeValue,
+			[]string{"user", "database", "application_name", "state"}, constLabels,
+			settings.Filters,
+		),
+		avWorkers: newBuiltinTypedDesc(
+			descOpts{"postgres", "autovacuum", "workers_in_flight", "Number of vacuum workers running in-flight of each type.", 0},
+			prometheus.GaugeValue,
+			[]string{"type"}, constLabels,
+			settings.Filters,
+		),
+		avMaxDuration: newBuiltinTypedDesc(
+			descOpts{"postgres", "autovacuum", "max_duration_seconds", "Longest duration of running autovacuum worker, in seconds.", 0},
+			prometheus.GaugeValue,
+			nil, constLabels,
+			settings.Filters,
+		),
+		re:                    newQueryRegexp(),
+		upState:               newUpState(),
+		connectionsBySettings: settings.ConnectionsBy,
 	}, nil
 }
 
 // Update method collects statistics, parse it and produces metrics that are sent to Prometheus.
 func (c *postgresActivityCollector) Update(config Config, ch chan<- prometheus.Metric) error {
-	conn, err := store.New(config.ConnString)
+	conn, err := store.NewPooled(config.scrapeContext(), config.Pool, config.ConnString)
 	if err != nil {
-		ch <- c.up.newConstMetric(0)
+		ch <- c.up.newConstMetric(c.upState.update(false, config.WarmupPeriod))
 		return err
 	}
 	defer conn.Close()
@@ -162,9 +248,36 @@ func (c *postgresActivityCollector) Update(config Config, ch chan<- prometheus.M
 	// parse pg_stat_activity stats
 	stats := parsePostgresActivityStats(res, c.re)
 
+	// get blocked and blocking sessions, pg_blocking_pids() is available since 9.6.
+	if config.serverVersionNum >= PostgresV96 {
+		res, err = conn.Query(postgresBlockingQuery)
+		if err != nil {
+			log.Warnf("query blocking sessions failed: %s; skip", err)
+		} else {
+			stats.blocked, stats.blocking = parsePostgresBlockingStats(res)
+		}
+	}
+
+	// get connections breakdown, if enabled.
+	var connectionsBy map[[4]string]float64
+	if c.connectionsBySettings.Enabled {
+		res, err = conn.Query(postgresConnectionsByQuery)
+		if err != nil {
+			log.Warnf("query connections breakdown failed: %s; skip", err)
+		} else {
+			connectionsBy = aggregateConnectionsBy(
+				parsePostgresGenericStats(res, []string{"user", "database", "application_name", "state"}),
+				c.connectionsBySettings,
+			)
+		}
+	}
+
 	// get pg_prepared_xacts stats
 	var count int
-	err = conn.Conn().QueryRow(context.Background(), postgresPreparedXactQuery).Scan(&count)
+	ctx, cancel := conn.Context()
+	defer cancel()
+
+	err = conn.Conn().QueryRow(ctx, postgresPreparedXactQuery).Scan(&count)
 	if err != nil {
 		log.Warnf("query pg_prepared_xacts failed: %s; skip", err)
 	} else {
@@ -173,7 +286,7 @@ func (c *postgresActivityCollector) Update(config Config, ch chan<- prometheus.M
 
 	// get postmaster start time
 	var startTime float64
-	err = conn.Conn().QueryRow(context.Background(), postgresStartTimeQuery).Scan(&startTime)
+	err = conn.Conn().QueryRow(ctx, postgresStartTimeQuery).Scan(&startTime)
 	if err != nil {
 		log.Warnf("query postmaster start time failed: %s; skip", err)
 	} else {
@@ -192,6 +305,10 @@ func (c *postgresActivityCollector) Update(config Config, ch chan<- prometheus.M
 		}
 	}
 
+	for k, v := range stats.waitEventTypes {
+		ch <- c.waitEventTypes.newConstMetric(v, k)
+	}
+
 	// connection states
 
 	var total float64
@@ -219,6 +336,11 @@ func (c *postgresActivityCollector) Update(config Config, ch chan<- prometheus.M
 
 	ch <- c.statesAll.newConstMetric(total)
 
+	// connections breakdown
+	for k, v := range connectionsBy {
+		ch <- c.connectionsBy.newConstMetric(v, k[0], k[1], k[2], k[3])
+	}
+
 	// prepared transactions
 	ch <- c.prepared.newConstMetric(stats.prepared)
 
@@ -256,11 +378,32 @@ func (c *postgresActivityCollector) Update(config Config, ch chan<- prometheus.M
 		ch <- c.vacuums.newConstMetric(v, k)
 	}
 
+	// backends by type
+	for k, v := range stats.backendTypes {
+		ch <- c.backendTypes.newConstMetric(v, k)
+	}
+
+	// oldest transaction and xmin horizon
+	ch <- c.oldestXact.newConstMetric(stats.oldestXact)
+	ch <- c.oldestXmin.newConstMetric(stats.oldestXmin)
+
+	// autovacuum workers
+	ch <- c.avWorkers.newConstMetric(stats.vacuumOps["regular"], "regular")
+	ch <- c.avWorkers.newConstMetric(stats.vacuumOps["wraparound"], "antiwraparound")
+	ch <- c.avWorkers.newConstMetric(stats.vacuumOps["user"], "user")
+	ch <- c.avMaxDuration.newConstMetric(stats.maxAutovacuum)
+
+	// blocked and blocking sessions
+	if config.serverVersionNum >= PostgresV96 {
+		ch <- c.blocked.newConstMetric(stats.blocked)
+		ch <- c.blocking.newConstMetric(stats.blocking)
+	}
+
 	// postmaster start time
 	ch <- c.startTime.newConstMetric(stats.startTime)
 
 	// All activity metrics collected successfully, now we can collect up metric.
-	ch <- c.up.newConstMetric(1)
+	ch <- c.up.newConstMetric(c.upState.update(true, config.WarmupPeriod))
 
 	return nil
 }
@@ -302,6 +445,7 @@ type postgresActivityStat struct {
 	other          map[string]float64 // state IN ('fastpath function call','disabled')
 	waiting        map[string]float64 // wait_event_type = 'Lock' (or waiting = 't')
 	waitEvents     map[string]float64 // wait_event_type/wait_event counters
+	waitEventTypes map[string]float64 // wait_event_type counters
 	prepared       float64            // FROM pg_prepared_xacts
 	maxIdleUser    map[string]float64 // longest duration among idle transactions opened by user/database
 	maxIdleMaint   map[string]float64 // longest duration among idle transactions initiated by maintenance operations (autovacuum, vacuum. analyze)
@@ -317,6 +461,12 @@ type postgresActivityStat struct {
 	queryCopy      float64            // number of COPY queries
 	queryOther     float64            // number of queries of other types: BEGIN, END, COMMIT, ABORT, SET, etc...
 	vacuumOps      map[string]float64 // vacuum operations by type
+	backendTypes   map[string]float64 // number of backends by backend_type
+	oldestXact     float64            // age of the oldest transaction among active and idle in transaction backends
+	oldestXmin     float64            // age of the oldest backend_xmin
+	blocked        float64            // number of sessions blocked by other sessions
+	blocking       float64            // number of sessions blocking other sessions
+	maxAutovacuum  float64            // longest duration among running autovacuum workers
 	startTime      float64            // unix time when postmaster has been started
 
 	re queryRegexp // regexps used for query classification, it comes from postgresActivityCollector.
@@ -331,6 +481,7 @@ func newPostgresActivityStat(re queryRegexp) postgresActivityStat {
 		other:          make(map[string]float64),
 		waiting:        make(map[string]float64),
 		waitEvents:     make(map[string]float64),
+		waitEventTypes: make(map[string]float64),
 		maxIdleUser:    make(map[string]float64),
 		maxIdleMaint:   make(map[string]float64),
 		maxActiveUser:  make(map[string]float64),
@@ -342,7 +493,8 @@ func newPostgresActivityStat(re queryRegexp) postgresActivityStat {
 			"regular":    0,
 			"user":       0,
 		},
-		re: re,
+		backendTypes: make(map[string]float64),
+		re:           re,
 	}
 }
 
@@ -405,6 +557,7 @@ func parsePostgresActivityStats(r *model.PGResult, re queryRegexp) postgresActiv
 
 					key := row[i].String + "/" + row[waitEventColIdx].String
 					stats.waitEvents[key]++
+					stats.waitEventTypes[row[i].String]++
 				}
 			case "active_seconds":
 				// Consider type of activity depending on 'state' column.
@@ -428,6 +581,7 @@ func parsePostgresActivityStats(r *model.PGResult, re queryRegexp) postgresActiv
 					stats.updateMaxIdletimeDuration(value, user, database, state, query)
 				} else {
 					stats.updateMaxRuntimeDuration(value, user, database, state, event, query)
+					stats.updateMaxAutovacuumDuration(value, state, query)
 				}
 			case "waiting_seconds":
 				eventIdx := colindexes[waitColumnName]
@@ -455,6 +609,13 @@ func parsePostgresActivityStats(r *model.PGResult, re queryRegexp) postgresActiv
 				value := row[i].String
 				state := row[stateIdx].String
 				stats.updateQueryStat(value, state)
+			case "backend_type":
+				stats.backendTypes[row[i].String]++
+			case "xact_seconds":
+				stateIdx := colindexes["state"]
+				stats.updateOldestXact(row[i].String, row[stateIdx].String)
+			case "xmin_age":
+				stats.updateOldestXmin(row[i].String)
 			default:
 				continue
 			}
@@ -464,6 +625,77 @@ func parsePostgresActivityStats(r *model.PGResult, re queryRegexp) postgresActiv
 	return stats
 }
 
+// parsePostgresBlockingStats parses pairs of blocked and blocking backends and returns number of distinct blocked and
+// blocking backends. Single backend could be blocked by many others, and single backend could block many others.
+func parsePostgresBlockingStats(r *model.PGResult) (float64, float64) {
+	log.Debug("parse postgres blocking stats")
+
+	var blocked, blocking = map[string]struct{}{}, map[string]struct{}{}
+
+	for _, row := range r.Rows {
+		if len(row) < 2 || !row[0].Valid || !row[1].Valid {
+			continue
+		}
+
+		blocked[row[0].String] = struct{}{}
+		blocking[row[1].String] = struct{}{}
+	}
+
+	return float64(len(blocked)), float64(len(blocking))
+}
+
+// aggregateConnectionsBy aggregates number of connections by user, database, application name and state. Application
+// names which are not allowed, or not in the top of names with the most connections, are collapsed into "other".
+func aggregateConnectionsBy(stats map[string]postgresGenericStat, settings model.ConnectionsBySettings) map[[4]string]float64 {
+	allowed := map[string]bool{}
+
+	if len(settings.ApplicationNames) > 0 {
+		for _, name := range settings.ApplicationNames {
+			allowed[name] = true
+		}
+	} else {
+		limit := settings.TopN
+		if limit == 0 {
+			limit = defaultConnectionsByTopN
+		}
+
+		totals := map[string]float64{}
+		for _, stat := range stats {
+			totals[stat.labels["application_name"]] += stat.values["total"]
+		}
+
+		names := make([]string, 0, len(totals))
+		for name := range totals {
+			names = append(names, name)
+		}
+
+		// Sort names by number of connections, use name for stable order of names with equal number of connections.
+		sort.Slice(names, func(i, j int) bool {
+			if totals[names[i]] != totals[names[j]] {
+				return totals[names[i]] > totals[names[j]]
+			}
+			return names[i] < names[j]
+		})
+
+		for i := 0; i < len(names) && i < limit; i++ {
+			allowed[names[i]] = true
+		}
+	}
+
+	connections := map[[4]string]float64{}
+	for _, stat := range stats {
+		appname := stat.labels["application_name"]
+		if !allowed[appname] {
+			appname = "other"
+		}
+
+		key := [4]string{stat.labels["user"], stat.labels["database"], appname, stat.labels["state"]}
+		connections[key] += stat.values["total"]
+	}
+
+	return connections
+}
+
 // updateState increments counter depending on passed state of the backend.
 func (s *postgresActivityStat) updateState(usename, datname, state string) {
 	key := usename + "/" + datname
@@ -551,6 +783,23 @@ func (s *postgresActivityStat) updateMaxRuntimeDuration(value, usename, datname,
 	}
 }
 
+// updateMaxAutovacuumDuration updates max duration of running autovacuum workers.
+func (s *postgresActivityStat) updateMaxAutovacuumDuration(value, state, query string) {
+	if value == "" || state != stActive || !strings.HasPrefix(s.re.vacuum.FindString(query), "autovacuum:") {
+		return
+	}
+
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Errorf("invalid input, parse '%s' failed: %s; skip", value, err.Error())
+		return
+	}
+
+	if v > s.maxAutovacuum {
+		s.maxAutovacuum = v
+	}
+}
+
 // updateMaxWaittimeDuration updates max duration of waiting activity.
 func (s *postgresActivityStat) updateMaxWaittimeDuration(value, usename, datname, waiting, query string) {
 	if value == "" || waiting == "" || query == "" {
@@ -582,6 +831,36 @@ func (s *postgresActivityStat) updateMaxWaittimeDuration(value, usename, datname
 	}
 }
 
+// updateOldestXact updates age of the oldest transaction, only active and idle in transaction backends are considered.
+func (s *postgresActivityStat) updateOldestXact(value, state string) {
+	if state != stActive && state != stIdleXact && state != stIdleXactAborted {
+		return
+	}
+
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Errorf("invalid input, parse '%s' failed: %s; skip", value, err.Error())
+		return
+	}
+
+	if v > s.oldestXact {
+		s.oldestXact = v
+	}
+}
+
+// updateOldestXmin updates age of the oldest backend's xmin.
+func (s *postgresActivityStat) updateOldestXmin(value string) {
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Errorf("invalid input, parse '%s' failed: %s; skip", value, err.Error())
+		return
+	}
+
+	if v > s.oldestXmin {
+		s.oldestXmin = v
+	}
+}
+
 func (s *postgresActivityStat) updateQueryStat(query string, state string) {
 	if state != stActive {
 		return
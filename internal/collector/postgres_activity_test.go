@@ -5,6 +5,8 @@ import (
 	"github.com/jackc/pgproto3/v2"
 	"github.com/lesovsky/pgscv/internal/model"
 	"github.com/stretchr/testify/assert"
+	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -14,12 +16,22 @@ func TestPostgresActivityCollector_Update(t *testing.T) {
 			"postgres_up",
 			"postgres_start_time_seconds",
 			"postgres_activity_wait_events_in_flight",
+			"postgres_activity_wait_event_type_total",
 			"postgres_activity_connections_in_flight",
 			"postgres_activity_connections_all_in_flight",
 			"postgres_activity_max_seconds",
 			"postgres_activity_prepared_transactions_in_flight",
 			"postgres_activity_queries_in_flight",
 			"postgres_activity_vacuums_in_flight",
+			"postgres_activity_backends_by_type",
+			"postgres_activity_oldest_transaction_seconds",
+			"postgres_activity_oldest_xmin_age",
+			"postgres_autovacuum_workers_in_flight",
+			"postgres_autovacuum_max_duration_seconds",
+		},
+		optional: []string{
+			"postgres_activity_blocked_sessions",
+			"postgres_activity_blocking_sessions",
 		},
 		collector: NewPostgresActivityCollector,
 		service:   model.ServiceTypePostgresql,
@@ -116,6 +128,7 @@ func Test_parsePostgresActivityStats(t *testing.T) {
 				other:          map[string]float64{"testuser/testdb": 1},
 				waiting:        map[string]float64{"testuser/testdb": 2},
 				waitEvents:     map[string]float64{"Client/ClientRead": 4, "Lock/transactionid": 2},
+				waitEventTypes: map[string]float64{"Client": 4, "Lock": 2},
 				maxIdleUser:    map[string]float64{"testuser/testdb": 20},
 				maxIdleMaint:   map[string]float64{"testuser/testdb": 28},
 				maxActiveUser:  map[string]float64{"testuser/testdb": 10},
@@ -123,8 +136,10 @@ func Test_parsePostgresActivityStats(t *testing.T) {
 				maxWaitUser:    map[string]float64{"testuser/testdb": 13},
 				maxWaitMaint:   map[string]float64{"testuser/testdb": 12},
 				querySelect:    1, queryMod: 1, queryMaint: 4, queryOther: 1,
-				vacuumOps: map[string]float64{"regular": 1, "user": 2, "wraparound": 0},
-				re:        testRE,
+				vacuumOps:     map[string]float64{"regular": 1, "user": 2, "wraparound": 0},
+				backendTypes:  map[string]float64{},
+				maxAutovacuum: 9,
+				re:            testRE,
 			},
 		},
 		{
@@ -168,7 +183,7 @@ func Test_parsePostgresActivityStats(t *testing.T) {
 				},
 			},
 			want: postgresActivityStat{
-				waitEvents:  map[string]float64{},
+				waitEvents: map[string]float64{}, waitEventTypes: map[string]float64{},
 				maxIdleUser: map[string]float64{}, maxIdleMaint: map[string]float64{},
 				maxActiveUser: map[string]float64{"testuser/testdb": 1}, maxActiveMaint: map[string]float64{"testuser/testdb": 1},
 				maxWaitUser: map[string]float64{}, maxWaitMaint: map[string]float64{},
@@ -178,8 +193,10 @@ func Test_parsePostgresActivityStats(t *testing.T) {
 				other:       map[string]float64{},
 				waiting:     map[string]float64{},
 				querySelect: 2, queryMod: 4, queryDdl: 3, queryMaint: 7, queryWith: 1, queryCopy: 1, queryOther: 4,
-				vacuumOps: map[string]float64{"regular": 1, "user": 1, "wraparound": 0},
-				re:        testRE,
+				vacuumOps:     map[string]float64{"regular": 1, "user": 1, "wraparound": 0},
+				backendTypes:  map[string]float64{},
+				maxAutovacuum: 1,
+				re:            testRE,
 			},
 		},
 		{
@@ -202,18 +219,19 @@ func Test_parsePostgresActivityStats(t *testing.T) {
 				},
 			},
 			want: postgresActivityStat{
-				waitEvents:  map[string]float64{},
+				waitEvents: map[string]float64{}, waitEventTypes: map[string]float64{},
 				maxIdleUser: map[string]float64{}, maxIdleMaint: map[string]float64{},
 				maxActiveUser: map[string]float64{"testuser/testdb": 10}, maxActiveMaint: map[string]float64{},
 				maxWaitUser: map[string]float64{"testuser/testdb": 5}, maxWaitMaint: map[string]float64{},
-				active:      map[string]float64{"testuser/testdb": 1},
-				idle:        map[string]float64{},
-				idlexact:    map[string]float64{},
-				other:       map[string]float64{},
-				waiting:     map[string]float64{"testuser/testdb": 1},
-				querySelect: 2,
-				vacuumOps:   map[string]float64{"regular": 0, "user": 0, "wraparound": 0},
-				re:          testRE,
+				active:       map[string]float64{"testuser/testdb": 1},
+				idle:         map[string]float64{},
+				idlexact:     map[string]float64{},
+				other:        map[string]float64{},
+				waiting:      map[string]float64{"testuser/testdb": 1},
+				querySelect:  2,
+				vacuumOps:    map[string]float64{"regular": 0, "user": 0, "wraparound": 0},
+				backendTypes: map[string]float64{},
+				re:           testRE,
 			},
 		},
 	}
@@ -226,6 +244,263 @@ func Test_parsePostgresActivityStats(t *testing.T) {
 	}
 }
 
+func Test_parsePostgresActivityStats_waitEventTypes(t *testing.T) {
+	res := &model.PGResult{
+		Nrows: 5,
+		Ncols: 5,
+		Colnames: []pgproto3.FieldDescription{
+			{Name: []byte("user")}, {Name: []byte("database")}, {Name: []byte("state")}, {Name: []byte("wait_event_type")}, {Name: []byte("wait_event")},
+		},
+		Rows: [][]sql.NullString{
+			{{String: "testuser", Valid: true}, {String: "testdb", Valid: true}, {String: "active", Valid: true}, {String: "IO", Valid: true}, {String: "DataFileRead", Valid: true}},
+			{{String: "testuser", Valid: true}, {String: "testdb", Valid: true}, {String: "active", Valid: true}, {String: "IO", Valid: true}, {String: "DataFileRead", Valid: true}},
+			{{String: "testuser", Valid: true}, {String: "testdb", Valid: true}, {String: "active", Valid: true}, {String: "IO", Valid: true}, {String: "WALSync", Valid: true}},
+			{{String: "testuser", Valid: true}, {String: "testdb", Valid: true}, {String: "active", Valid: true}, {String: "LWLock", Valid: true}, {String: "WALWrite", Valid: true}},
+			{{String: "testuser", Valid: true}, {String: "testdb", Valid: true}, {String: "active", Valid: true}, {}, {}},
+		},
+	}
+
+	got := parsePostgresActivityStats(res, newQueryRegexp())
+	assert.Equal(t, map[string]float64{"IO": 3, "LWLock": 1}, got.waitEventTypes)
+
+	// Aggregate must be equal to the sum of its events.
+	sums := map[string]float64{}
+	for k, v := range got.waitEvents {
+		sums[strings.Split(k, "/")[0]] += v
+	}
+	assert.Equal(t, sums, got.waitEventTypes)
+}
+
+func Test_parsePostgresActivityStats_backendTypes(t *testing.T) {
+	res := &model.PGResult{
+		Nrows: 6,
+		Ncols: 4,
+		Colnames: []pgproto3.FieldDescription{
+			{Name: []byte("user")}, {Name: []byte("database")}, {Name: []byte("state")}, {Name: []byte("backend_type")},
+		},
+		Rows: [][]sql.NullString{
+			{{String: "testuser", Valid: true}, {String: "testdb", Valid: true}, {String: "active", Valid: true}, {String: "client backend", Valid: true}},
+			{{String: "testuser", Valid: true}, {String: "testdb", Valid: true}, {String: "idle", Valid: true}, {String: "client backend", Valid: true}},
+			{{String: "autovacuum worker", Valid: true}, {String: "testdb", Valid: true}, {String: "active", Valid: true}, {String: "autovacuum worker", Valid: true}},
+			{{String: "replication", Valid: true}, {}, {String: "active", Valid: true}, {String: "walsender", Valid: true}},
+			{{String: "checkpointer", Valid: true}, {}, {}, {String: "checkpointer", Valid: true}},
+			{{String: "testuser", Valid: true}, {String: "testdb", Valid: true}, {String: "active", Valid: true}, {}},
+		},
+	}
+
+	got := parsePostgresActivityStats(res, newQueryRegexp())
+	assert.Equal(t, map[string]float64{"client backend": 2, "autovacuum worker": 1, "walsender": 1, "checkpointer": 1}, got.backendTypes)
+}
+
+func Test_parsePostgresActivityStats_oldest(t *testing.T) {
+	res := &model.PGResult{
+		Nrows: 6,
+		Ncols: 5,
+		Colnames: []pgproto3.FieldDescription{
+			{Name: []byte("user")}, {Name: []byte("database")}, {Name: []byte("state")}, {Name: []byte("xact_seconds")}, {Name: []byte("xmin_age")},
+		},
+		Rows: [][]sql.NullString{
+			{{String: "testuser", Valid: true}, {String: "testdb", Valid: true}, {String: "active", Valid: true}, {String: "12.5", Valid: true}, {String: "100", Valid: true}},
+			{{String: "testuser", Valid: true}, {String: "testdb", Valid: true}, {String: "idle in transaction", Valid: true}, {String: "30", Valid: true}, {String: "250", Valid: true}},
+			{{String: "testuser", Valid: true}, {String: "testdb", Valid: true}, {String: "idle in transaction (aborted)", Valid: true}, {String: "20", Valid: true}, {}},
+			// Idle backends have no transactions, but xact_start might be not cleaned yet.
+			{{String: "testuser", Valid: true}, {String: "testdb", Valid: true}, {String: "idle", Valid: true}, {String: "100", Valid: true}, {}},
+			// Backends without transactions have NULL xact_start and backend_xmin.
+			{{String: "testuser", Valid: true}, {String: "testdb", Valid: true}, {String: "active", Valid: true}, {}, {}},
+			{{String: "checkpointer", Valid: true}, {}, {}, {}, {String: "300", Valid: true}},
+		},
+	}
+
+	got := parsePostgresActivityStats(res, newQueryRegexp())
+	assert.Equal(t, float64(30), got.oldestXact)
+	assert.Equal(t, float64(300), got.oldestXmin)
+
+	// No transactions at all.
+	res.Rows = [][]sql.NullString{
+		{{String: "testuser", Valid: true}, {String: "testdb", Valid: true}, {String: "idle", Valid: true}, {}, {}},
+	}
+	got = parsePostgresActivityStats(res, newQueryRegexp())
+	assert.Equal(t, float64(0), got.oldestXact)
+	assert.Equal(t, float64(0), got.oldestXmin)
+}
+
+func Test_updateOldestXact(t *testing.T) {
+	s := newPostgresActivityStat(newQueryRegexp())
+
+	s.updateOldestXact("10", "active")
+	assert.Equal(t, float64(10), s.oldestXact)
+	s.updateOldestXact("5", "idle in transaction")
+	assert.Equal(t, float64(10), s.oldestXact)
+	s.updateOldestXact("20", "idle in transaction")
+	assert.Equal(t, float64(20), s.oldestXact)
+	s.updateOldestXact("30", "idle")
+	assert.Equal(t, float64(20), s.oldestXact)
+	s.updateOldestXact("invalid", "active")
+	assert.Equal(t, float64(20), s.oldestXact)
+
+	s.updateOldestXmin("100")
+	s.updateOldestXmin("50")
+	s.updateOldestXmin("invalid")
+	assert.Equal(t, float64(100), s.oldestXmin)
+}
+
+func Test_parsePostgresBlockingStats(t *testing.T) {
+	res := &model.PGResult{
+		Nrows: 1,
+		Ncols: 2,
+		Colnames: []pgproto3.FieldDescription{
+			{Name: []byte("pid")}, {Name: []byte("blocking_pid")},
+		},
+		Rows: [][]sql.NullString{
+			{{String: "101", Valid: true}, {String: "100", Valid: true}},
+		},
+	}
+
+	blocked, blocking := parsePostgresBlockingStats(res)
+	assert.Equal(t, float64(1), blocked)
+	assert.Equal(t, float64(1), blocking)
+
+	// Lock queue: 102 is blocked by both 100 and 101, 103 is blocked by 100.
+	res.Rows = append(res.Rows,
+		[]sql.NullString{{String: "102", Valid: true}, {String: "100", Valid: true}},
+		[]sql.NullString{{String: "102", Valid: true}, {String: "101", Valid: true}},
+		[]sql.NullString{{String: "103", Valid: true}, {String: "100", Valid: true}},
+		[]sql.NullString{{String: "104", Valid: true}, {}},
+	)
+	blocked, blocking = parsePostgresBlockingStats(res)
+	assert.Equal(t, float64(3), blocked)
+	assert.Equal(t, float64(2), blocking)
+
+	// No blocked sessions.
+	blocked, blocking = parsePostgresBlockingStats(&model.PGResult{})
+	assert.Equal(t, float64(0), blocked)
+	assert.Equal(t, float64(0), blocking)
+}
+
+func Test_aggregateConnectionsBy(t *testing.T) {
+	res := &model.PGResult{
+		Nrows: 5,
+		Ncols: 5,
+		Colnames: []pgproto3.FieldDescription{
+			{Name: []byte("user")}, {Name: []byte("database")}, {Name: []byte("application_name")}, {Name: []byte("state")}, {Name: []byte("total")},
+		},
+		Rows: [][]sql.NullString{
+			{{String: "app", Valid: true}, {String: "appdb", Valid: true}, {String: "backend", Valid: true}, {String: "active", Valid: true}, {String: "10", Valid: true}},
+			{{String: "app", Valid: true}, {String: "appdb", Valid: true}, {String: "backend", Valid: true}, {String: "idle", Valid: true}, {String: "20", Valid: true}},
+			{{String: "app", Valid: true}, {String: "appdb", Valid: true}, {String: "worker", Valid: true}, {String: "idle", Valid: true}, {String: "5", Valid: true}},
+			{{String: "app", Valid: true}, {String: "appdb", Valid: true}, {String: "psql", Valid: true}, {String: "idle", Valid: true}, {String: "2", Valid: true}},
+			{{String: "app", Valid: true}, {String: "appdb", Valid: true}, {String: "pgbench", Valid: true}, {String: "idle", Valid: true}, {String: "1", Valid: true}},
+		},
+	}
+	stats := parsePostgresGenericStats(res, []string{"user", "database", "application_name", "state"})
+
+	testcases := []struct {
+		name     string
+		settings model.ConnectionsBySettings
+		want     map[[4]string]float64
+	}{
+		{
+			name:     "default limit",
+			settings: model.ConnectionsBySettings{Enabled: true},
+			want: map[[4]string]float64{
+				{"app", "appdb", "backend", "active"}: 10,
+				{"app", "appdb", "backend", "idle"}:   20,
+				{"app", "appdb", "worker", "idle"}:    5,
+				{"app", "appdb", "psql", "idle"}:      2,
+				{"app", "appdb", "pgbench", "idle"}:   1,
+			},
+		},
+		{
+			name:     "top 2",
+			settings: model.ConnectionsBySettings{Enabled: true, TopN: 2},
+			want: map[[4]string]float64{
+				{"app", "appdb", "backend", "active"}: 10,
+				{"app", "appdb", "backend", "idle"}:   20,
+				{"app", "appdb", "worker", "idle"}:    5,
+				{"app", "appdb", "other", "idle"}:     3,
+			},
+		},
+		{
+			name:     "allow-list",
+			settings: model.ConnectionsBySettings{Enabled: true, TopN: 2, ApplicationNames: []string{"psql"}},
+			want: map[[4]string]float64{
+				{"app", "appdb", "other", "active"}: 10,
+				{"app", "appdb", "other", "idle"}:   26,
+				{"app", "appdb", "psql", "idle"}:    2,
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, aggregateConnectionsBy(stats, tc.settings))
+		})
+	}
+}
+
+func Test_aggregateConnectionsBy_cap(t *testing.T) {
+	// Many distinct application names should be collapsed into limited number of series.
+	stats := map[string]postgresGenericStat{}
+	for i := 0; i < 100; i++ {
+		name := "app" + strconv.Itoa(i)
+		stats[name] = postgresGenericStat{
+			labels: map[string]string{"user": "app", "database": "appdb", "application_name": name, "state": "idle"},
+			values: map[string]float64{"total": float64(i + 1)},
+		}
+	}
+
+	got := aggregateConnectionsBy(stats, model.ConnectionsBySettings{Enabled: true})
+	assert.Len(t, got, defaultConnectionsByTopN+1)
+
+	// The most active application names are kept, all others are in "other" and total number of connections is the same.
+	assert.Equal(t, float64(100), got[[4]string{"app", "appdb", "app99", "idle"}])
+	assert.Equal(t, float64(91), got[[4]string{"app", "appdb", "app90", "idle"}])
+
+	var total float64
+	for _, v := range got {
+		total += v
+	}
+	assert.Equal(t, float64(5050), total)
+	assert.Equal(t, float64(5050-955), got[[4]string{"app", "appdb", "other", "idle"}])
+}
+
+func Test_parsePostgresActivityStats_autovacuum(t *testing.T) {
+	res := &model.PGResult{
+		Nrows: 5,
+		Ncols: 6,
+		Colnames: []pgproto3.FieldDescription{
+			{Name: []byte("user")}, {Name: []byte("database")}, {Name: []byte("state")}, {Name: []byte("wait_event_type")},
+			{Name: []byte("active_seconds")}, {Name: []byte("query")},
+		},
+		Rows: [][]sql.NullString{
+			{
+				{String: "autovacuum worker", Valid: true}, {String: "testdb", Valid: true}, {String: "active", Valid: true}, {},
+				{String: "120", Valid: true}, {String: "autovacuum: VACUUM public.example1", Valid: true},
+			},
+			{
+				{String: "autovacuum worker", Valid: true}, {String: "testdb", Valid: true}, {String: "active", Valid: true}, {},
+				{String: "360", Valid: true}, {String: "autovacuum: VACUUM ANALYZE public.example2 (to prevent wraparound)", Valid: true},
+			},
+			{
+				{String: "autovacuum worker", Valid: true}, {String: "testdb", Valid: true}, {String: "active", Valid: true}, {},
+				{String: "60", Valid: true}, {String: "autovacuum: VACUUM pg_catalog.pg_class (to prevent wraparound)", Valid: true},
+			},
+			{
+				{String: "testuser", Valid: true}, {String: "testdb", Valid: true}, {String: "active", Valid: true}, {},
+				{String: "600", Valid: true}, {String: "VACUUM example3", Valid: true},
+			},
+			{
+				{String: "testuser", Valid: true}, {String: "testdb", Valid: true}, {String: "active", Valid: true}, {},
+				{String: "900", Valid: true}, {String: "SELECT 'autovacuum: VACUUM'", Valid: true},
+			},
+		},
+	}
+
+	got := parsePostgresActivityStats(res, newQueryRegexp())
+	assert.Equal(t, map[string]float64{"regular": 1, "wraparound": 2, "user": 1}, got.vacuumOps)
+	assert.Equal(t, float64(360), got.maxAutovacuum)
+}
+
 func Test_selectActivityQuery(t *testing.T) {
 	testcases := []struct {
 		version int
@@ -268,34 +543,37 @@ func Test_updateMaxIdletimeDuration(t *testing.T) {
 		{value: "10", usename: "testuser", datname: "testdb", state: "idle in transaction", query: "UPDATE table",
 			want: postgresActivityStat{
 				active: map[string]float64{}, idle: map[string]float64{}, idlexact: map[string]float64{},
-				waiting: map[string]float64{}, other: map[string]float64{}, waitEvents: map[string]float64{},
+				waiting: map[string]float64{}, other: map[string]float64{}, waitEvents: map[string]float64{}, waitEventTypes: map[string]float64{},
 				maxIdleUser: map[string]float64{"testuser/testdb": 10}, maxIdleMaint: map[string]float64{},
 				maxActiveUser: map[string]float64{}, maxActiveMaint: map[string]float64{},
 				maxWaitUser: map[string]float64{}, maxWaitMaint: map[string]float64{},
-				vacuumOps: map[string]float64{"regular": 0, "user": 0, "wraparound": 0},
-				re:        testRE,
+				vacuumOps:    map[string]float64{"regular": 0, "user": 0, "wraparound": 0},
+				backendTypes: map[string]float64{},
+				re:           testRE,
 			},
 		},
 		{value: "10", usename: "testuser", datname: "testdb", state: "idle in transaction", query: "autovacuum: VACUUM table",
 			want: postgresActivityStat{
 				active: map[string]float64{}, idle: map[string]float64{}, idlexact: map[string]float64{},
-				waiting: map[string]float64{}, other: map[string]float64{}, waitEvents: map[string]float64{},
+				waiting: map[string]float64{}, other: map[string]float64{}, waitEvents: map[string]float64{}, waitEventTypes: map[string]float64{},
 				maxIdleUser: map[string]float64{}, maxIdleMaint: map[string]float64{"testuser/testdb": 10},
 				maxActiveUser: map[string]float64{}, maxActiveMaint: map[string]float64{},
 				maxWaitUser: map[string]float64{}, maxWaitMaint: map[string]float64{},
-				vacuumOps: map[string]float64{"regular": 0, "user": 0, "wraparound": 0},
-				re:        testRE,
+				vacuumOps:    map[string]float64{"regular": 0, "user": 0, "wraparound": 0},
+				backendTypes: map[string]float64{},
+				re:           testRE,
 			},
 		},
 		{value: "10", usename: "testuser", datname: "testdb", state: "idle in transaction", query: "VACUUM table",
 			want: postgresActivityStat{
 				active: map[string]float64{}, idle: map[string]float64{}, idlexact: map[string]float64{},
-				waiting: map[string]float64{}, other: map[string]float64{}, waitEvents: map[string]float64{},
+				waiting: map[string]float64{}, other: map[string]float64{}, waitEvents: map[string]float64{}, waitEventTypes: map[string]float64{},
 				maxIdleUser: map[string]float64{}, maxIdleMaint: map[string]float64{"testuser/testdb": 10},
 				maxActiveUser: map[string]float64{}, maxActiveMaint: map[string]float64{},
 				maxWaitUser: map[string]float64{}, maxWaitMaint: map[string]float64{},
-				vacuumOps: map[string]float64{"regular": 0, "user": 0, "wraparound": 0},
-				re:        testRE,
+				vacuumOps:    map[string]float64{"regular": 0, "user": 0, "wraparound": 0},
+				backendTypes: map[string]float64{},
+				re:           testRE,
 			},
 		},
 	}
@@ -337,23 +615,25 @@ func Test_updateMaxRuntimeDuration(t *testing.T) {
 		{value: "5", usename: "testuser", datname: "testdb", state: "active", query: "UPDATE table",
 			want: postgresActivityStat{
 				active: map[string]float64{}, idle: map[string]float64{}, idlexact: map[string]float64{},
-				waiting: map[string]float64{}, other: map[string]float64{}, waitEvents: map[string]float64{},
+				waiting: map[string]float64{}, other: map[string]float64{}, waitEvents: map[string]float64{}, waitEventTypes: map[string]float64{},
 				maxIdleUser: map[string]float64{}, maxIdleMaint: map[string]float64{},
 				maxActiveUser: map[string]float64{"testuser/testdb": 5}, maxActiveMaint: map[string]float64{},
 				maxWaitUser: map[string]float64{}, maxWaitMaint: map[string]float64{},
-				vacuumOps: map[string]float64{"regular": 0, "user": 0, "wraparound": 0},
-				re:        testRE,
+				vacuumOps:    map[string]float64{"regular": 0, "user": 0, "wraparound": 0},
+				backendTypes: map[string]float64{},
+				re:           testRE,
 			},
 		},
 		{value: "6", usename: "testuser", datname: "testdb", state: "active", query: "autovacuum: VACUUM table",
 			want: postgresActivityStat{
 				active: map[string]float64{}, idle: map[string]float64{}, idlexact: map[string]float64{},
-				waiting: map[string]float64{}, other: map[string]float64{}, waitEvents: map[string]float64{},
+				waiting: map[string]float64{}, other: map[string]float64{}, waitEvents: map[string]float64{}, waitEventTypes: map[string]float64{},
 				maxIdleUser: map[string]float64{}, maxIdleMaint: map[string]float64{},
 				maxActiveUser: map[string]float64{}, maxActiveMaint: map[string]float64{"testuser/testdb": 6},
 				maxWaitUser: map[string]float64{}, maxWaitMaint: map[string]float64{},
-				vacuumOps: map[string]float64{"regular": 0, "user": 0, "wraparound": 0},
-				re:        testRE,
+				vacuumOps:    map[string]float64{"regular": 0, "user": 0, "wraparound": 0},
+				backendTypes: map[string]float64{},
+				re:           testRE,
 			},
 		},
 	}
@@ -388,23 +668,25 @@ func Test_updateMaxWaittimeDuration(t *testing.T) {
 		{value: "5", usename: "testuser", datname: "testdb", waiting: "Lock", query: "UPDATE table",
 			want: postgresActivityStat{
 				active: map[string]float64{}, idle: map[string]float64{}, idlexact: map[string]float64{},
-				waiting: map[string]float64{}, other: map[string]float64{}, waitEvents: map[string]float64{},
+				waiting: map[string]float64{}, other: map[string]float64{}, waitEvents: map[string]float64{}, waitEventTypes: map[string]float64{},
 				maxIdleUser: map[string]float64{}, maxIdleMaint: map[string]float64{},
 				maxActiveUser: map[string]float64{}, maxActiveMaint: map[string]float64{},
 				maxWaitUser: map[string]float64{"testuser/testdb": 5}, maxWaitMaint: map[string]float64{},
-				vacuumOps: map[string]float64{"regular": 0, "user": 0, "wraparound": 0},
-				re:        testRE,
+				vacuumOps:    map[string]float64{"regular": 0, "user": 0, "wraparound": 0},
+				backendTypes: map[string]float64{},
+				re:           testRE,
 			},
 		},
 		{value: "6", usename: "testuser", datname: "testdb", waiting: "t", query: "autovacuum: VACUUM table",
 			want: postgresActivityStat{
 				active: map[string]float64{}, idle: map[string]float64{}, idlexact: map[string]float64{},
-				waiting: map[string]float64{}, other: map[string]float64{}, waitEvents: map[string]float64{},
+				waiting: map[string]float64{}, other: map[string]float64{}, waitEvents: map[string]float64{}, waitEventTypes: map[string]float64{},
 				maxIdleUser: map[string]float64{}, maxIdleMaint: map[string]float64{},
 				maxActiveUser: map[string]float64{}, maxActiveMaint: map[string]float64{},
 				maxWaitUser: map[string]float64{}, maxWaitMaint: map[string]float64{"testuser/testdb": 6},
-				vacuumOps: map[string]float64{"regular": 0, "user": 0, "wraparound": 0},
-				re:        testRE,
+				vacuumOps:    map[string]float64{"regular": 0, "user": 0, "wraparound": 0},
+				backendTypes: map[string]float64{},
+				re:           testRE,
 			},
 		},
 	}
@@ -441,18 +723,19 @@ func Test_updateQueryStat(t *testing.T) {
 
 	assert.Equal(t, postgresActivityStat{
 		active: map[string]float64{}, idle: map[string]float64{}, idlexact: map[string]float64{},
-		waiting: map[string]float64{}, other: map[string]float64{}, waitEvents: map[string]float64{},
+		waiting: map[string]float64{}, other: map[string]float64{}, waitEvents: map[string]float64{}, waitEventTypes: map[string]float64{},
 		maxIdleUser: map[string]float64{}, maxIdleMaint: map[string]float64{},
 		maxActiveUser: map[string]float64{}, maxActiveMaint: map[string]float64{},
 		maxWaitUser: map[string]float64{}, maxWaitMaint: map[string]float64{},
-		querySelect: 2,
-		queryMod:    4,
-		queryDdl:    3,
-		queryMaint:  9,
-		queryWith:   1,
-		queryCopy:   1,
-		queryOther:  20,
-		vacuumOps:   map[string]float64{"regular": 2, "user": 1, "wraparound": 1},
-		re:          testRE,
+		querySelect:  2,
+		queryMod:     4,
+		queryDdl:     3,
+		queryMaint:   9,
+		queryWith:    1,
+		queryCopy:    1,
+		queryOther:   20,
+		vacuumOps:    map[string]float64{"regular": 2, "user": 1, "wraparound": 1},
+		backendTypes: map[string]float64{},
+		re:           testRE,
 	}, s)
 }
@@ -53,7 +53,7 @@ func NewPostgresWalArchivingCollector(constLabels labels, settings model.Collect
 
 // Update method collects statistics, parse it and produces metrics that are sent to Prometheus.
 func (c *postgresWalArchivingCollector) Update(config Config, ch chan<- prometheus.Metric) error {
-	conn, err := store.New(config.ConnString)
+	conn, err := store.NewPooled(config.scrapeContext(), config.Pool, config.ConnString)
 	if err != nil {
 		return err
 	}
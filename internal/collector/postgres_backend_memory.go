@@ -0,0 +1,135 @@
+package collector
+
+import (
+	"github.com/lesovsky/pgscv/internal/log"
+	"github.com/lesovsky/pgscv/internal/model"
+	"github.com/lesovsky/pgscv/internal/store"
+	"github.com/prometheus/client_golang/prometheus"
+	"strconv"
+)
+
+const (
+	// postgresProcessMemoryContextsExistsQuery returns number of pg_get_process_memory_contexts() functions available.
+	postgresProcessMemoryContextsExistsQuery = "SELECT count(*) FROM pg_proc WHERE proname = 'pg_get_process_memory_contexts'"
+
+	// postgresBackendMemoryQuery returns memory allocated by memory contexts of all backends.
+	postgresBackendMemoryQuery = "SELECT a.backend_type, count(DISTINCT a.pid) AS backends, coalesce(sum(m.total_bytes), 0) AS memory " +
+		"FROM pg_stat_activity a, LATERAL pg_get_process_memory_contexts(a.pid, false, 1) m " +
+		"WHERE a.backend_type IS NOT NULL GROUP BY a.backend_type"
+
+	// postgresOwnBackendMemoryQuery returns memory allocated by memory contexts of the current backend.
+	postgresOwnBackendMemoryQuery = "SELECT backend_type, 1 AS backends, (SELECT sum(total_bytes) FROM pg_backend_memory_contexts) AS memory " +
+		"FROM pg_stat_activity WHERE pid = pg_backend_pid()"
+)
+
+type postgresBackendMemoryCollector struct {
+	memory   typedDesc
+	backends typedDesc
+	enabled  bool // memory sampling is explicitly enabled
+}
+
+// NewPostgresBackendMemoryCollector returns a new Collector exposing memory allocated by memory contexts of Postgres
+// backends aggregated by backend type. Memory of all backends is available through pg_get_process_memory_contexts(),
+// if the function is not available only memory of the collector's own backend is sampled using pg_backend_memory_contexts.
+// For details see https://www.postgresql.org/docs/current/view-pg-backend-memory-contexts.html
+func NewPostgresBackendMemoryCollector(constLabels labels, settings model.CollectorSettings) (Collector, error) {
+	return &postgresBackendMemoryCollector{
+		memory: newBuiltinTypedDesc(
+			descOpts{"postgres", "backend", "memory_bytes", "Total memory allocated by memory contexts of backends of each type, in bytes.", 0},
+			prometheus.GaugeValue,
+			[]string{"backend_type"}, constLabels,
+			settings.Filters,
+		),
+		backends: newBuiltinTypedDesc(
+			descOpts{"postgres", "backend", "memory_sampled_backends", "Number of backends of each type which memory has been sampled.", 0},
+			prometheus.GaugeValue,
+			[]string{"backend_type"}, constLabels,
+			settings.Filters,
+		),
+		enabled: settings.Enabled,
+	}, nil
+}
+
+// Update method collects statistics, parse it and produces metrics that are sent to Prometheus.
+func (c *postgresBackendMemoryCollector) Update(config Config, ch chan<- prometheus.Metric) error {
+	if !c.enabled {
+		log.Debugln("[postgres backend memory collector]: backend memory sampling is not enabled, skip")
+		return nil
+	}
+
+	// pg_backend_memory_contexts is available since Postgres 14.
+	if config.serverVersionNum < PostgresV14 {
+		log.Debugln("[postgres backend memory collector]: pg_backend_memory_contexts is not available, required Postgres 14 or newer")
+		return nil
+	}
+
+	conn, err := store.NewPooled(config.scrapeContext(), config.Pool, config.ConnString)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	res, err := conn.Query(postgresProcessMemoryContextsExistsQuery)
+	if err != nil {
+		return err
+	}
+
+	query := postgresOwnBackendMemoryQuery
+	if res.Nrows == 1 && res.Rows[0][0].String != "0" {
+		query = postgresBackendMemoryQuery
+	} else {
+		log.Debugln("[postgres backend memory collector]: pg_get_process_memory_contexts is not available, sample own backend only")
+	}
+
+	res, err = conn.Query(query)
+	if err != nil {
+		return err
+	}
+
+	stats := parsePostgresBackendMemoryStats(res)
+
+	for backendType, stat := range stats {
+		ch <- c.memory.newConstMetric(stat.memory, backendType)
+		ch <- c.backends.newConstMetric(stat.backends, backendType)
+	}
+
+	return nil
+}
+
+// postgresBackendMemoryStat describes memory used by backends of the same type.
+type postgresBackendMemoryStat struct {
+	memory   float64
+	backends float64
+}
+
+// parsePostgresBackendMemoryStats parses PGResult and returns memory stats of backends keyed by backend type.
+func parsePostgresBackendMemoryStats(r *model.PGResult) map[string]postgresBackendMemoryStat {
+	log.Debug("parse postgres backend memory stats")
+
+	stats := map[string]postgresBackendMemoryStat{}
+
+	for _, row := range r.Rows {
+		if len(row) != 3 || !row[0].Valid {
+			continue
+		}
+
+		backends, err := strconv.ParseFloat(row[1].String, 64)
+		if err != nil {
+			log.Errorf("invalid input, parse '%s' failed: %s; skip", row[1].String, err)
+			continue
+		}
+
+		memory, err := strconv.ParseFloat(row[2].String, 64)
+		if err != nil {
+			log.Errorf("invalid input, parse '%s' failed: %s; skip", row[2].String, err)
+			continue
+		}
+
+		stat := stats[row[0].String]
+		stat.memory += memory
+		stat.backends += backends
+		stats[row[0].String] = stat
+	}
+
+	return stats
+}
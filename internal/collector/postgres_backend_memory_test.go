@@ -0,0 +1,46 @@
+package collector
+
+import (
+	"database/sql"
+	"github.com/jackc/pgproto3/v2"
+	"github.com/lesovsky/pgscv/internal/model"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPostgresBackendMemoryCollector_Update(t *testing.T) {
+	c, err := NewPostgresBackendMemoryCollector(labels{}, model.CollectorSettings{})
+	assert.NoError(t, err)
+
+	// Backend memory sampling is not enabled, collector should do nothing (even connect to Postgres).
+	ch := make(chan prometheus.Metric, 10)
+	assert.NoError(t, c.Update(Config{}, ch))
+	assert.Equal(t, 0, len(ch))
+
+	// Unsupported version.
+	c, err = NewPostgresBackendMemoryCollector(labels{}, model.CollectorSettings{Enabled: true})
+	assert.NoError(t, err)
+	config := Config{postgresServiceConfig: postgresServiceConfig{serverVersionNum: PostgresV13}}
+	assert.NoError(t, c.Update(config, ch))
+	assert.Equal(t, 0, len(ch))
+}
+
+func Test_parsePostgresBackendMemoryStats(t *testing.T) {
+	res := &model.PGResult{
+		Nrows:    4,
+		Ncols:    3,
+		Colnames: []pgproto3.FieldDescription{{Name: []byte("backend_type")}, {Name: []byte("backends")}, {Name: []byte("memory")}},
+		Rows: [][]sql.NullString{
+			{{String: "client backend", Valid: true}, {String: "2", Valid: true}, {String: "2097152", Valid: true}},
+			{{String: "checkpointer", Valid: true}, {String: "1", Valid: true}, {String: "524288", Valid: true}},
+			{{String: "walwriter", Valid: true}, {String: "1", Valid: true}, {String: "invalid", Valid: true}},
+			{{String: "", Valid: false}, {String: "1", Valid: true}, {String: "1024", Valid: true}},
+		},
+	}
+
+	assert.Equal(t, map[string]postgresBackendMemoryStat{
+		"client backend": {memory: 2097152, backends: 2},
+		"checkpointer":   {memory: 524288, backends: 1},
+	}, parsePostgresBackendMemoryStats(res))
+}
@@ -15,6 +15,9 @@ const (
 		"buffers_backend, buffers_backend_fsync, buffers_alloc, " +
 		"coalesce(extract('epoch' from age(now(), stats_reset)), 0) as stats_age_seconds " +
 		"FROM pg_stat_bgwriter"
+
+	// postgresLastCheckpointQuery defines query for time elapsed since the last checkpoint. Available since Postgres 9.6.
+	postgresLastCheckpointQuery = "SELECT extract(epoch FROM clock_timestamp() - checkpoint_time) FROM pg_control_checkpoint()"
 )
 
 type postgresBgwriterCollector struct {
@@ -38,6 +41,18 @@ func NewPostgresBgwriterCollector(constLabels labels, settings model.CollectorSe
 				nil, constLabels,
 				settings.Filters,
 			),
+			"checkpoints_req_ratio": newBuiltinTypedDesc(
+				descOpts{"postgres", "checkpoints", "requested_ratio", "Ratio of requested checkpoints to all performed checkpoints.", 0},
+				prometheus.GaugeValue,
+				nil, constLabels,
+				settings.Filters,
+			),
+			"checkpoint_since_last": newBuiltinTypedDesc(
+				descOpts{"postgres", "checkpoints", "since_last_seconds", "Time elapsed since the last checkpoint, in seconds.", 0},
+				prometheus.GaugeValue,
+				nil, constLabels,
+				settings.Filters,
+			),
 			"checkpoint_time": newBuiltinTypedDesc(
 				descOpts{"postgres", "checkpoints", "seconds_total", "Total amount of time that has been spent processing data during checkpoint in each stage, in seconds.", .001},
 				prometheus.CounterValue,
@@ -86,7 +101,7 @@ func NewPostgresBgwriterCollector(constLabels labels, settings model.CollectorSe
 
 // Update method collects statistics, parse it and produces metrics that are sent to Prometheus.
 func (c *postgresBgwriterCollector) Update(config Config, ch chan<- prometheus.Metric) error {
-	conn, err := store.New(config.ConnString)
+	conn, err := store.NewPooled(config.scrapeContext(), config.Pool, config.ConnString)
 	if err != nil {
 		return err
 	}
@@ -100,6 +115,21 @@ func (c *postgresBgwriterCollector) Update(config Config, ch chan<- prometheus.M
 	stats := parsePostgresBgwriterStats(res)
 	blockSize := float64(config.blockSize)
 
+	// Time of the last checkpoint is available through pg_control_checkpoint() since 9.6.
+	var sinceLast float64
+	var sinceLastOK bool
+	if config.serverVersionNum >= PostgresV96 {
+		ctx, cancel := conn.Context()
+		defer cancel()
+
+		err = conn.Conn().QueryRow(ctx, postgresLastCheckpointQuery).Scan(&sinceLast)
+		if err != nil {
+			log.Warnf("query last checkpoint time failed: %s; skip", err)
+		} else {
+			sinceLastOK = true
+		}
+	}
+
 	for name, desc := range c.descs {
 		switch name {
 		case "checkpoints":
@@ -107,6 +137,12 @@ func (c *postgresBgwriterCollector) Update(config Config, ch chan<- prometheus.M
 			ch <- desc.newConstMetric(stats.ckptReq, "req")
 		case "checkpoints_all":
 			ch <- desc.newConstMetric(stats.ckptTimed + stats.ckptReq)
+		case "checkpoints_req_ratio":
+			ch <- desc.newConstMetric(checkpointsRequestedRatio(stats.ckptTimed, stats.ckptReq))
+		case "checkpoint_since_last":
+			if sinceLastOK {
+				ch <- desc.newConstMetric(sinceLast)
+			}
 		case "checkpoint_time":
 			ch <- desc.newConstMetric(stats.ckptWriteTime, "write")
 			ch <- desc.newConstMetric(stats.ckptSyncTime, "sync")
@@ -200,3 +236,13 @@ func parsePostgresBgwriterStats(r *model.PGResult) postgresBgwriterStat {
 
 	return stats
 }
+
+// checkpointsRequestedRatio returns ratio of requested checkpoints to all checkpoints. High ratio means checkpoints are
+// triggered by WAL volume more often than by timeout, e.g. due to too low max_wal_size.
+func checkpointsRequestedRatio(timed, req float64) float64 {
+	if timed+req == 0 {
+		return 0
+	}
+
+	return req / (timed + req)
+}
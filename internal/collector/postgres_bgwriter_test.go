@@ -20,6 +20,10 @@ func TestPostgresBgwriterCollector_Update(t *testing.T) {
 			"postgres_backends_fsync_total",
 			"postgres_backends_allocated_bytes_total",
 			"postgres_bgwriter_stats_age_seconds_total",
+			"postgres_checkpoints_requested_ratio",
+		},
+		optional: []string{
+			"postgres_checkpoints_since_last_seconds",
 		},
 		collector: NewPostgresBgwriterCollector,
 		service:   model.ServiceTypePostgresql,
@@ -70,3 +74,20 @@ func Test_parsePostgresBgwriterStats(t *testing.T) {
 		})
 	}
 }
+
+func Test_checkpointsRequestedRatio(t *testing.T) {
+	testcases := []struct {
+		timed float64
+		req   float64
+		want  float64
+	}{
+		{timed: 0, req: 0, want: 0},
+		{timed: 10, req: 0, want: 0},
+		{timed: 0, req: 10, want: 1},
+		{timed: 30, req: 10, want: 0.25},
+	}
+
+	for _, tc := range testcases {
+		assert.Equal(t, tc.want, checkpointsRequestedRatio(tc.timed, tc.req))
+	}
+}
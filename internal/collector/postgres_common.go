@@ -1,12 +1,13 @@
 package collector
 
 import (
-	"context"
 	"github.com/lesovsky/pgscv/internal/log"
 	"github.com/lesovsky/pgscv/internal/model"
 	"github.com/lesovsky/pgscv/internal/store"
+	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 const (
@@ -18,10 +19,19 @@ const (
 	PostgresV12 = 120000
 	PostgresV13 = 130000
 	PostgresV14 = 140000
+	PostgresV15 = 150000
+	PostgresV16 = 160000
+	PostgresV17 = 170000
 
 	// Minimal required version is 9.5.
 	PostgresVMinNum = PostgresV95
 	PostgresVMinStr = "9.5"
+
+	// DefaultDatabasesConcurrency defines default number of databases processed concurrently by per-database collectors.
+	DefaultDatabasesConcurrency = 4
+
+	// DefaultBloatMinSize defines default minimal size of relations (in bytes) for which bloat is estimated.
+	DefaultBloatMinSize = 10 * 1024 * 1024
 )
 
 // postgresGenericStat represent generic stat suitable for all kind of stats
@@ -145,7 +155,10 @@ func parsePostgresCustomStats(r *model.PGResult, labelNames []string) postgresCu
 // listDatabases returns slice with databases names
 func listDatabases(db *store.DB) ([]string, error) {
 	// getDBList returns the list of databases that allowed for connection
-	rows, err := db.Conn().Query(context.Background(), "SELECT datname FROM pg_database WHERE NOT datistemplate AND datallowconn")
+	ctx, cancel := db.Context()
+	defer cancel()
+
+	rows, err := db.Conn().Query(ctx, "SELECT datname FROM pg_database WHERE NOT datistemplate AND datallowconn")
 	if err != nil {
 		return nil, err
 	}
@@ -161,3 +174,53 @@ func listDatabases(db *store.DB) ([]string, error) {
 	}
 	return list, nil
 }
+
+// filterDatabases returns databases allowed by passed include and exclude regexps.
+func filterDatabases(databases []string, include, exclude *regexp.Regexp) []string {
+	var list = make([]string, 0, len(databases))
+	for _, d := range databases {
+		if isDatabaseAllowed(d, include, exclude) {
+			list = append(list, d)
+		}
+	}
+
+	return list
+}
+
+// isDatabaseAllowed returns true if database matches to include regexp and doesn't match to exclude regexp. Nil include
+// regexp allows all databases, nil exclude regexp doesn't exclude any database.
+func isDatabaseAllowed(database string, include, exclude *regexp.Regexp) bool {
+	if include != nil && !include.MatchString(database) {
+		return false
+	}
+
+	if exclude != nil && exclude.MatchString(database) {
+		return false
+	}
+
+	return true
+}
+
+// runPerDatabase runs passed function for each database concurrently, using no more than 'concurrency' workers at once.
+func runPerDatabase(databases []string, concurrency int, fn func(database string)) {
+	if concurrency <= 0 {
+		concurrency = DefaultDatabasesConcurrency
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+
+	for _, d := range databases {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(database string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			fn(database)
+		}(d)
+	}
+
+	wg.Wait()
+}
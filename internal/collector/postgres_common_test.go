@@ -6,7 +6,12 @@ import (
 	"github.com/lesovsky/pgscv/internal/model"
 	"github.com/lesovsky/pgscv/internal/store"
 	"github.com/stretchr/testify/assert"
+	"regexp"
+	"sort"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func Test_parsePostgresGenericStats(t *testing.T) {
@@ -110,3 +115,68 @@ func Test_listDatabases(t *testing.T) {
 	assert.Greater(t, len(databases), 0)
 	conn.Close()
 }
+
+func Test_filterDatabases(t *testing.T) {
+	databases := []string{"postgres", "pgscv_fixtures", "example"}
+
+	testcases := []struct {
+		name    string
+		include *regexp.Regexp
+		exclude *regexp.Regexp
+		want    []string
+	}{
+		{name: "no filters", want: databases},
+		{name: "include only", include: regexp.MustCompile("^p"), want: []string{"postgres", "pgscv_fixtures"}},
+		{name: "include nothing", include: regexp.MustCompile("^invalid$"), want: []string{}},
+		{name: "exclude only", exclude: regexp.MustCompile("^pgscv"), want: []string{"postgres", "example"}},
+		{name: "include and exclude", include: regexp.MustCompile("^p"), exclude: regexp.MustCompile("fixtures"), want: []string{"postgres"}},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, filterDatabases(databases, tc.include, tc.exclude))
+		})
+	}
+}
+
+func Test_runPerDatabase(t *testing.T) {
+	databases := []string{"db1", "db2", "db3", "db4", "db5", "db6", "db7", "db8", "db9", "db10"}
+
+	for _, concurrency := range []int{0, 1, 3, 20} {
+		var (
+			mu        sync.Mutex
+			collected []string
+			running   int32
+			maxSeen   int32
+		)
+
+		runPerDatabase(databases, concurrency, func(d string) {
+			n := atomic.AddInt32(&running, 1)
+			for {
+				m := atomic.LoadInt32(&maxSeen)
+				if n <= m || atomic.CompareAndSwapInt32(&maxSeen, m, n) {
+					break
+				}
+			}
+
+			time.Sleep(10 * time.Millisecond)
+
+			mu.Lock()
+			collected = append(collected, d)
+			mu.Unlock()
+			atomic.AddInt32(&running, -1)
+		})
+
+		want := concurrency
+		if want <= 0 {
+			want = DefaultDatabasesConcurrency
+		}
+
+		sort.Strings(collected)
+		expected := append([]string{}, databases...)
+		sort.Strings(expected)
+
+		assert.Equal(t, expected, collected)
+		assert.LessOrEqual(t, int(maxSeen), want)
+	}
+}
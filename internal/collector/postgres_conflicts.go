@@ -31,7 +31,7 @@ func NewPostgresConflictsCollector(constLabels labels, settings model.CollectorS
 
 // Update method collects statistics, parse it and produces metrics that are sent to Prometheus.
 func (c *postgresConflictsCollector) Update(config Config, ch chan<- prometheus.Metric) error {
-	conn, err := store.New(config.ConnString)
+	conn, err := store.NewPooled(config.scrapeContext(), config.Pool, config.ConnString)
 	if err != nil {
 		return err
 	}
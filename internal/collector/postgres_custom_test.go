@@ -2,7 +2,12 @@ package collector
 
 import (
 	"github.com/lesovsky/pgscv/internal/model"
+	"github.com/lesovsky/pgscv/internal/store"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
 	"testing"
+	"time"
 )
 
 func TestPostgresCustomCollector_Update(t *testing.T) {
@@ -38,3 +43,45 @@ func TestPostgresCustomCollector_Update(t *testing.T) {
 
 	pipeline(t, input)
 }
+
+func TestPostgresCustomCollector_Update_cache(t *testing.T) {
+	settings := model.CollectorSettings{
+		Subsystems: map[string]model.MetricsSubsystem{
+			"example1": {
+				// Query returns different value on every execution.
+				Query:    "SELECT extract(epoch FROM clock_timestamp()) as v1",
+				CacheTTL: 500 * time.Millisecond,
+				Metrics: model.Metrics{
+					{ShortName: "v1", Usage: "GAUGE", Value: "v1", Description: "v1 description"},
+				},
+			},
+		},
+	}
+
+	c, err := NewPostgresCustomCollector(labels{}, settings)
+	assert.NoError(t, err)
+
+	config := Config{ConnString: store.TestPostgresConnStr}
+
+	collectValue := func() float64 {
+		ch := make(chan prometheus.Metric, 10)
+		assert.NoError(t, c.Update(config, ch))
+		close(ch)
+
+		m := <-ch
+		assert.NotNil(t, m)
+		pb := &dto.Metric{}
+		assert.NoError(t, m.Write(pb))
+		return pb.GetGauge().GetValue()
+	}
+
+	// Query should be executed once within TTL window, cached value is returned.
+	v1 := collectValue()
+	v2 := collectValue()
+	assert.Equal(t, v1, v2)
+
+	// After TTL elapsed, query should be executed again.
+	time.Sleep(600 * time.Millisecond)
+	v3 := collectValue()
+	assert.NotEqual(t, v1, v3)
+}
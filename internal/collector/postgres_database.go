@@ -1,6 +1,7 @@
 package collector
 
 import (
+	"fmt"
 	"github.com/lesovsky/pgscv/internal/log"
 	"github.com/lesovsky/pgscv/internal/model"
 	"github.com/lesovsky/pgscv/internal/store"
@@ -40,6 +41,8 @@ const (
 	xidLimitQuery = "SELECT 'database' AS src, 2147483647 - greatest(max(age(datfrozenxid)), max(age(coalesce(nullif(datminmxid, 1), datfrozenxid)))) AS to_limit FROM pg_database " +
 		"UNION SELECT 'prepared_xacts' AS src, 2147483647 - coalesce(max(age(transaction)), 0) AS to_limit FROM pg_prepared_xacts " +
 		"UNION SELECT 'replication_slots' AS src, 2147483647 - greatest(coalesce(min(age(xmin)), 0), coalesce(min(age(catalog_xmin)), 0)) AS to_limit FROM pg_replication_slots"
+
+	workMemQuery = "SELECT setting, coalesce(unit, '') FROM pg_settings WHERE name = 'work_mem'"
 )
 
 type postgresDatabasesCollector struct {
@@ -65,6 +68,8 @@ type postgresDatabasesCollector struct {
 	sizes              typedDesc
 	statsage           typedDesc
 	xidlimit           typedDesc
+	workmem            typedDesc
+	clustersize        typedDesc
 	labelNames         []string
 }
 
@@ -207,12 +212,24 @@ func NewPostgresDatabasesCollector(constLabels labels, settings model.CollectorS
 			[]string{"xid_from"}, constLabels,
 			settings.Filters,
 		),
+		workmem: newBuiltinTypedDesc(
+			descOpts{"postgres", "", "work_mem_bytes", "Value of work_mem setting used by queries before writing to temporary files, in bytes.", 0},
+			prometheus.GaugeValue,
+			nil, constLabels,
+			settings.Filters,
+		),
+		clustersize: newBuiltinTypedDesc(
+			descOpts{"postgres", "cluster", "size_bytes", "Total size of all databases in the cluster, in bytes.", 0},
+			prometheus.GaugeValue,
+			nil, constLabels,
+			settings.Filters,
+		),
 	}, nil
 }
 
 // Update method collects statistics, parse it and produces metrics that are sent to Prometheus.
 func (c *postgresDatabasesCollector) Update(config Config, ch chan<- prometheus.Metric) error {
-	conn, err := store.New(config.ConnString)
+	conn, err := store.NewPooled(config.scrapeContext(), config.Pool, config.ConnString)
 	if err != nil {
 		return err
 	}
@@ -232,6 +249,22 @@ func (c *postgresDatabasesCollector) Update(config Config, ch chan<- prometheus.
 
 	xidStats := parsePostgresXidLimitStats(res)
 
+	var setting, unit string
+	ctx, cancel := conn.Context()
+	defer cancel()
+
+	err = conn.Conn().QueryRow(ctx, workMemQuery).Scan(&setting, &unit)
+	if err != nil {
+		log.Warnf("query work_mem setting failed: %s; skip", err)
+	} else {
+		workmem, err := parseSettingBytes(setting, unit)
+		if err != nil {
+			log.Warnf("parse work_mem setting failed: %s; skip", err)
+		} else {
+			ch <- c.workmem.newConstMetric(workmem)
+		}
+	}
+
 	for _, stat := range stats {
 		ch <- c.commits.newConstMetric(stat.xactcommit, stat.database)
 		ch <- c.rollbacks.newConstMetric(stat.xactrollback, stat.database)
@@ -271,6 +304,8 @@ func (c *postgresDatabasesCollector) Update(config Config, ch chan<- prometheus.
 		}
 	}
 
+	ch <- c.clustersize.newConstMetric(clusterSizeBytes(stats))
+
 	ch <- c.xidlimit.newConstMetric(xidStats.database, "pg_database")
 	ch <- c.xidlimit.newConstMetric(xidStats.prepared, "pg_prepared_xacts")
 	ch <- c.xidlimit.newConstMetric(xidStats.replSlot, "pg_replication_slots")
@@ -309,6 +344,16 @@ type postgresDatabaseStat struct {
 	statsage           float64
 }
 
+// clusterSizeBytes returns total size of all databases.
+func clusterSizeBytes(stats map[string]postgresDatabaseStat) float64 {
+	var size float64
+	for _, stat := range stats {
+		size += stat.sizebytes
+	}
+
+	return size
+}
+
 // parsePostgresDatabasesStats parses PGResult, extract data and return struct with stats values.
 func parsePostgresDatabasesStats(r *model.PGResult, labelNames []string) map[string]postgresDatabaseStat {
 	log.Debug("parse postgres database stats")
@@ -465,3 +510,22 @@ func selectDatabasesQuery(version int) string {
 		return databasesQueryLatest
 	}
 }
+
+// parseSettingBytes parses memory-related setting value with its unit and returns value normalized to bytes.
+func parseSettingBytes(setting, unit string) (float64, error) {
+	factor, base, err := parseUnit(unit)
+	if err != nil {
+		return 0, err
+	}
+
+	if base != "bytes" {
+		return 0, fmt.Errorf("invalid unit '%s': not a memory unit", unit)
+	}
+
+	v, err := strconv.ParseFloat(setting, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	return v * factor, nil
+}
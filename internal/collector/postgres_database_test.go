@@ -33,6 +33,8 @@ func TestPostgresDatabasesCollector_Update(t *testing.T) {
 			"postgres_database_session_time_seconds_total",
 			"postgres_database_sessions_all_total",
 			"postgres_database_sessions_total",
+			"postgres_work_mem_bytes",
+			"postgres_cluster_size_bytes",
 		},
 		collector: NewPostgresDatabasesCollector,
 		service:   model.ServiceTypePostgresql,
@@ -166,3 +168,43 @@ func Test_selectDatabasesQuery(t *testing.T) {
 		assert.Equal(t, tc.want, selectDatabasesQuery(tc.version))
 	}
 }
+
+func Test_parseSettingBytes(t *testing.T) {
+	testcases := []struct {
+		valid   bool
+		setting string
+		unit    string
+		want    float64
+	}{
+		{valid: true, setting: "4096", unit: "kB", want: 4194304},
+		{valid: true, setting: "64", unit: "MB", want: 67108864},
+		{valid: true, setting: "1", unit: "GB", want: 1073741824},
+		{valid: true, setting: "512", unit: "8kB", want: 4194304},
+		{valid: true, setting: "1024", unit: "B", want: 1024},
+		{valid: false, setting: "4096", unit: "ms"},
+		{valid: false, setting: "4096", unit: ""},
+		{valid: false, setting: "4096", unit: "invalid"},
+		{valid: false, setting: "invalid", unit: "kB"},
+	}
+
+	for _, tc := range testcases {
+		got, err := parseSettingBytes(tc.setting, tc.unit)
+		if tc.valid {
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		} else {
+			assert.Error(t, err)
+		}
+	}
+}
+
+func Test_clusterSizeBytes(t *testing.T) {
+	stats := map[string]postgresDatabaseStat{
+		"global":  {database: "global"},
+		"testdb1": {database: "testdb1", sizebytes: 485254752},
+		"testdb2": {database: "testdb2", sizebytes: 856964774},
+	}
+
+	assert.Equal(t, float64(1342219526), clusterSizeBytes(stats))
+	assert.Equal(t, float64(0), clusterSizeBytes(map[string]postgresDatabaseStat{}))
+}
@@ -14,8 +14,9 @@ const postgresFunctionsQuery = "SELECT current_database() AS database, schemanam
 
 type postgresFunctionsCollector struct {
 	calls      typedDesc
-	totaltime  typedDesc
-	selftime   typedDesc
+	time       typedDesc
+	totaltime  typedDesc // DEPRECATED: replaced by time with 'total' mode
+	selftime   typedDesc // DEPRECATED: replaced by time with 'self' mode
 	labelNames []string
 }
 
@@ -32,14 +33,22 @@ func NewPostgresFunctionsCollector(constLabels labels, settings model.CollectorS
 			labelNames, constLabels,
 			settings.Filters,
 		),
+		time: newBuiltinTypedDesc(
+			descOpts{"postgres", "function", "time_seconds_total", "Total time spent in function, in seconds. Mode 'total' includes time spent in all other functions called by it, mode 'self' doesn't.", .001},
+			prometheus.CounterValue,
+			[]string{"database", "schema", "function", "mode"}, constLabels,
+			settings.Filters,
+		),
+		// DEPRECATED.
 		totaltime: newBuiltinTypedDesc(
-			descOpts{"postgres", "function", "total_time_seconds_total", "Total time spent in function and all other functions called by it, in seconds.", .001},
+			descOpts{"postgres", "function", "total_time_seconds_total", "Total time spent in function and all other functions called by it, in seconds. DEPRECATED: consider using postgres_function_time_seconds_total.", .001},
 			prometheus.CounterValue,
 			labelNames, constLabels,
 			settings.Filters,
 		),
+		// DEPRECATED.
 		selftime: newBuiltinTypedDesc(
-			descOpts{"postgres", "function", "self_time_seconds_total", "Total time spent in function itself, not including other functions called by it, in seconds.", .001},
+			descOpts{"postgres", "function", "self_time_seconds_total", "Total time spent in function itself, not including other functions called by it, in seconds. DEPRECATED: consider using postgres_function_time_seconds_total.", .001},
 			prometheus.CounterValue,
 			labelNames, constLabels,
 			settings.Filters,
@@ -49,7 +58,7 @@ func NewPostgresFunctionsCollector(constLabels labels, settings model.CollectorS
 
 // Update method collects statistics, parse it and produces metrics that are sent to Prometheus.
 func (c *postgresFunctionsCollector) Update(config Config, ch chan<- prometheus.Metric) error {
-	conn, err := store.New(config.ConnString)
+	conn, err := store.NewPooled(config.scrapeContext(), config.Pool, config.ConnString)
 	if err != nil {
 		return err
 	}
@@ -67,13 +76,13 @@ func (c *postgresFunctionsCollector) Update(config Config, ch chan<- prometheus.
 	}
 
 	for _, d := range databases {
-		// Skip database if not matched to allowed.
-		if config.DatabasesRE != nil && !config.DatabasesRE.MatchString(d) {
+		// Skip database if not matched to allowed or matched to excluded.
+		if !isDatabaseAllowed(d, config.DatabasesRE, config.DatabasesExcludeRE) {
 			continue
 		}
 
 		pgconfig.Database = d
-		conn, err := store.NewWithConfig(pgconfig)
+		conn, err := store.NewPooledWithConfig(config.scrapeContext(), config.Pool, pgconfig)
 		if err != nil {
 			return err
 		}
@@ -89,6 +98,8 @@ func (c *postgresFunctionsCollector) Update(config Config, ch chan<- prometheus.
 
 		for _, stat := range stats {
 			ch <- c.calls.newConstMetric(stat.calls, stat.database, stat.schema, stat.function)
+			ch <- c.time.newConstMetric(stat.totaltime, stat.database, stat.schema, stat.function, "total")
+			ch <- c.time.newConstMetric(stat.selftime, stat.database, stat.schema, stat.function, "self")
 			ch <- c.totaltime.newConstMetric(stat.totaltime, stat.database, stat.schema, stat.function)
 			ch <- c.selftime.newConstMetric(stat.selftime, stat.database, stat.schema, stat.function)
 		}
@@ -14,6 +14,7 @@ func TestPostgresFunctionsCollector_Update(t *testing.T) {
 	var input = pipelineInput{
 		required: []string{
 			"postgres_function_calls_total",
+			"postgres_function_time_seconds_total",
 			"postgres_function_total_time_seconds_total",
 			"postgres_function_self_time_seconds_total",
 		},
@@ -66,6 +67,35 @@ func Test_parsePostgresFunctionsStat(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "null and invalid values",
+			res: &model.PGResult{
+				Nrows: 2,
+				Ncols: 6,
+				Colnames: []pgproto3.FieldDescription{
+					{Name: []byte("database")}, {Name: []byte("schema")}, {Name: []byte("function")},
+					{Name: []byte("calls")}, {Name: []byte("total_time")}, {Name: []byte("self_time")},
+				},
+				Rows: [][]sql.NullString{
+					{
+						{String: "testdb", Valid: true}, {String: "testschema1", Valid: true}, {String: "testfunction1", Valid: true},
+						{String: "10", Valid: true}, {String: "", Valid: false}, {String: "", Valid: false},
+					},
+					{
+						{String: "testdb", Valid: true}, {String: "testschema2", Valid: true}, {String: "testfunction2", Valid: true},
+						{String: "20", Valid: true}, {String: "invalid", Valid: true}, {String: "700", Valid: true},
+					},
+				},
+			},
+			want: map[string]postgresFunctionStat{
+				"testdb/testschema1/testfunction1": {
+					database: "testdb", schema: "testschema1", function: "testfunction1", calls: 10,
+				},
+				"testdb/testschema2/testfunction2": {
+					database: "testdb", schema: "testschema2", function: "testfunction2", calls: 20, selftime: 700,
+				},
+			},
+		},
 	}
 
 	for _, tc := range testCases {
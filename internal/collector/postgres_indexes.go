@@ -1,6 +1,7 @@
 package collector
 
 import (
+	"fmt"
 	"github.com/jackc/pgx/v4"
 	"github.com/lesovsky/pgscv/internal/log"
 	"github.com/lesovsky/pgscv/internal/model"
@@ -10,21 +11,25 @@ import (
 	"strings"
 )
 
-const (
-	userIndexesQuery = "SELECT current_database() AS database, schemaname AS schema, relname AS table, indexrelname AS index, (i.indisprimary OR i.indisunique) AS key," +
-		"idx_scan, idx_tup_read, idx_tup_fetch, idx_blks_read, idx_blks_hit,pg_relation_size(s1.indexrelid) AS size_bytes " +
-		"FROM pg_stat_user_indexes s1 " +
-		"JOIN pg_statio_user_indexes s2 USING (schemaname, relname, indexrelname) " +
-		"JOIN pg_index i ON (s1.indexrelid = i.indexrelid) " +
-		"WHERE NOT EXISTS (SELECT 1 FROM pg_locks WHERE relation = s1.indexrelid AND mode = 'AccessExclusiveLock' AND granted)"
-)
+// userIndexesQuery defines query for indexes stats. The query has single placeholder for version-specific columns.
+const userIndexesQuery = "SELECT current_database() AS database, schemaname AS schema, relname AS table, indexrelname AS index, (i.indisprimary OR i.indisunique) AS key," +
+	"(i.indisvalid AND i.indisready)::int AS valid, " +
+	"idx_scan, %sidx_tup_read, idx_tup_fetch, idx_blks_read, idx_blks_hit,pg_relation_size(s1.indexrelid) AS size_bytes " +
+	"FROM pg_stat_user_indexes s1 " +
+	"JOIN pg_statio_user_indexes s2 USING (schemaname, relname, indexrelname) " +
+	"JOIN pg_index i ON (s1.indexrelid = i.indexrelid) " +
+	"WHERE NOT EXISTS (SELECT 1 FROM pg_locks WHERE relation = s1.indexrelid AND mode = 'AccessExclusiveLock' AND granted)"
 
 // postgresIndexesCollector defines metric descriptors and stats store.
 type postgresIndexesCollector struct {
-	indexes typedDesc
-	tuples  typedDesc
-	io      typedDesc
-	sizes   typedDesc
+	indexes  typedDesc
+	tuples   typedDesc
+	io       typedDesc
+	sizes    typedDesc
+	lastScan typedDesc
+	valid    typedDesc
+	// labelNames defines names of columns used as labels.
+	labelNames []string
 }
 
 // NewPostgresIndexesCollector returns a new Collector exposing postgres indexes stats.
@@ -33,6 +38,7 @@ type postgresIndexesCollector struct {
 // https://www.postgresql.org/docs/current/monitoring-stats.html#PG-STATIO-ALL-INDEXES-VIEW
 func NewPostgresIndexesCollector(constLabels labels, settings model.CollectorSettings) (Collector, error) {
 	return &postgresIndexesCollector{
+		labelNames: []string{"database", "schema", "table", "index", "key"},
 		indexes: newBuiltinTypedDesc(
 			descOpts{"postgres", "index", "scans_total", "Total number of index scans initiated.", 0},
 			prometheus.CounterValue,
@@ -57,12 +63,24 @@ func NewPostgresIndexesCollector(constLabels labels, settings model.CollectorSet
 			[]string{"database", "schema", "table", "index"}, constLabels,
 			settings.Filters,
 		),
+		lastScan: newBuiltinTypedDesc(
+			descOpts{"postgres", "index", "last_scan_time_seconds", "Time of the last index scan, in unixtime.", 0},
+			prometheus.GaugeValue,
+			[]string{"database", "schema", "table", "index"}, constLabels,
+			settings.Filters,
+		),
+		valid: newBuiltinTypedDesc(
+			descOpts{"postgres", "index", "valid", "Whether the index is valid and ready for use: 0 is invalid or being built, 1 is valid.", 0},
+			prometheus.GaugeValue,
+			[]string{"database", "schema", "table", "index"}, constLabels,
+			settings.Filters,
+		),
 	}, nil
 }
 
 // Update method collects statistics, parse it and produces metrics that are sent to Prometheus.
 func (c *postgresIndexesCollector) Update(config Config, ch chan<- prometheus.Metric) error {
-	conn, err := store.New(config.ConnString)
+	conn, err := store.NewPooled(config.scrapeContext(), config.Pool, config.ConnString)
 	if err != nil {
 		return err
 	}
@@ -80,50 +98,61 @@ func (c *postgresIndexesCollector) Update(config Config, ch chan<- prometheus.Me
 	}
 
 	for _, d := range databases {
-		// Skip database if not matched to allowed.
-		if config.DatabasesRE != nil && !config.DatabasesRE.MatchString(d) {
+		// Skip database if not matched to allowed or matched to excluded.
+		if !isDatabaseAllowed(d, config.DatabasesRE, config.DatabasesExcludeRE) {
 			continue
 		}
 
 		pgconfig.Database = d
-		conn, err := store.NewWithConfig(pgconfig)
+		conn, err := store.NewPooledWithConfig(config.scrapeContext(), config.Pool, pgconfig)
 		if err != nil {
 			return err
 		}
 
-		res, err := conn.Query(userIndexesQuery)
+		res, err := conn.Query(selectIndexesQuery(config.serverVersionNum))
 		conn.Close()
 		if err != nil {
 			log.Warnf("get indexes stat of database %s failed: %s", d, err)
 			continue
 		}
 
-		stats := parsePostgresIndexStats(res, c.indexes.labelNames)
+		stats := parsePostgresIndexStats(res, c.labelNames)
 
 		for _, stat := range stats {
-			// always send idx scan metrics and indexes size
-			ch <- c.indexes.newConstMetric(stat.idxscan, stat.database, stat.schema, stat.table, stat.index, stat.key)
-			ch <- c.sizes.newConstMetric(stat.sizebytes, stat.database, stat.schema, stat.table, stat.index)
-
-			// avoid metrics spamming and send metrics only if they greater than zero.
-			if stat.idxtupread > 0 {
-				ch <- c.tuples.newConstMetric(stat.idxread, stat.database, stat.schema, stat.table, stat.index, "read")
-			}
-			if stat.idxtupfetch > 0 {
-				ch <- c.tuples.newConstMetric(stat.idxtupfetch, stat.database, stat.schema, stat.table, stat.index, "fetched")
-			}
-			if stat.idxread > 0 {
-				ch <- c.io.newConstMetric(stat.idxread, stat.database, stat.schema, stat.table, stat.index, "read")
-			}
-			if stat.idxhit > 0 {
-				ch <- c.io.newConstMetric(stat.idxhit, stat.database, stat.schema, stat.table, stat.index, "hit")
-			}
+			c.sendIndexStat(ch, stat)
 		}
 	}
 
 	return nil
 }
 
+// sendIndexStat sends metrics of the index.
+func (c *postgresIndexesCollector) sendIndexStat(ch chan<- prometheus.Metric, stat postgresIndexStat) {
+	// always send idx scan metrics, indexes size and validity
+	ch <- c.indexes.newConstMetric(stat.idxscan, stat.database, stat.schema, stat.table, stat.index, stat.key)
+	ch <- c.sizes.newConstMetric(stat.sizebytes, stat.database, stat.schema, stat.table, stat.index)
+	ch <- c.valid.newConstMetric(stat.valid, stat.database, stat.schema, stat.table, stat.index)
+
+	// time of the last scan is available since Postgres 16 and it is NULL if index has never been scanned.
+	if stat.lastscan > 0 {
+		ch <- c.lastScan.newConstMetric(stat.lastscan, stat.database, stat.schema, stat.table, stat.index)
+	}
+
+	// avoid metrics spamming and send metrics only if they greater than zero.
+	if stat.idxtupread > 0 {
+		ch <- c.tuples.newConstMetric(stat.idxtupread, stat.database, stat.schema, stat.table, stat.index, "read")
+	}
+	if stat.idxtupfetch > 0 {
+		ch <- c.tuples.newConstMetric(stat.idxtupfetch, stat.database, stat.schema, stat.table, stat.index, "fetched")
+	}
+	if stat.idxread > 0 {
+		ch <- c.io.newConstMetric(stat.idxread, stat.database, stat.schema, stat.table, stat.index, "read")
+	}
+	if stat.idxhit > 0 {
+		ch <- c.io.newConstMetric(stat.idxhit, stat.database, stat.schema, stat.table, stat.index, "hit")
+	}
+}
+
 // postgresIndexStat is per-index store for metrics related to how indexes are accessed.
 type postgresIndexStat struct {
 	database    string
@@ -131,7 +160,9 @@ type postgresIndexStat struct {
 	table       string
 	index       string
 	key         string
+	valid       float64
 	idxscan     float64
+	lastscan    float64
 	idxtupread  float64
 	idxtupfetch float64
 	idxread     float64
@@ -190,8 +221,12 @@ func parsePostgresIndexStats(r *model.PGResult, labelNames []string) map[string]
 			s := stats[indexname]
 
 			switch string(colname.Name) {
+			case "valid":
+				s.valid = v
 			case "idx_scan":
 				s.idxscan = v
+			case "last_scan_time":
+				s.lastscan = v
 			case "idx_tup_read":
 				s.idxtupread = v
 			case "idx_tup_fetch":
@@ -212,3 +247,14 @@ func parsePostgresIndexStats(r *model.PGResult, labelNames []string) map[string]
 
 	return stats
 }
+
+// selectIndexesQuery returns suitable indexes query depending on passed version.
+func selectIndexesQuery(version int) string {
+	// Time of the last index scan is tracked since Postgres 16.
+	var lastScan string
+	if version >= PostgresV16 {
+		lastScan = "extract(epoch from last_idx_scan) AS last_scan_time, "
+	}
+
+	return fmt.Sprintf(userIndexesQuery, lastScan)
+}
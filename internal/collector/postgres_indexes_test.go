@@ -4,7 +4,10 @@ import (
 	"database/sql"
 	"github.com/jackc/pgproto3/v2"
 	"github.com/lesovsky/pgscv/internal/model"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
+	"strings"
 	"testing"
 )
 
@@ -15,6 +18,8 @@ func TestPostgresIndexesCollector_Update(t *testing.T) {
 			"postgres_index_tuples_total",
 			"postgres_index_io_blocks_total",
 			"postgres_index_size_bytes",
+			"postgres_index_last_scan_time_seconds",
+			"postgres_index_valid",
 		},
 		collector: NewPostgresIndexesCollector,
 		service:   model.ServiceTypePostgresql,
@@ -53,12 +58,104 @@ func Test_parsePostgresIndexStats(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "with validity, size and last scan",
+			res: &model.PGResult{
+				Nrows: 2,
+				Ncols: 13,
+				Colnames: []pgproto3.FieldDescription{
+					{Name: []byte("database")}, {Name: []byte("schema")}, {Name: []byte("table")}, {Name: []byte("index")},
+					{Name: []byte("key")}, {Name: []byte("valid")}, {Name: []byte("idx_scan")}, {Name: []byte("last_scan_time")},
+					{Name: []byte("idx_tup_read")}, {Name: []byte("idx_tup_fetch")}, {Name: []byte("idx_blks_read")}, {Name: []byte("idx_blks_hit")},
+					{Name: []byte("size_bytes")},
+				},
+				Rows: [][]sql.NullString{
+					{
+						{String: "testdb", Valid: true}, {String: "testschema", Valid: true}, {String: "testrelname", Valid: true}, {String: "testindex", Valid: true},
+						{String: "false", Valid: true}, {String: "1", Valid: true}, {String: "5842", Valid: true}, {String: "1667988000.123", Valid: true},
+						{String: "84572", Valid: true}, {String: "485", Valid: true}, {String: "4128", Valid: true}, {String: "847", Valid: true},
+						{String: "16384", Valid: true},
+					},
+					{
+						{String: "testdb", Valid: true}, {String: "testschema", Valid: true}, {String: "testrelname", Valid: true}, {String: "testindex_ccnew", Valid: true},
+						{String: "false", Valid: true}, {String: "0", Valid: true}, {String: "0", Valid: true}, {String: "", Valid: false},
+						{String: "0", Valid: true}, {String: "0", Valid: true}, {String: "0", Valid: true}, {String: "0", Valid: true},
+						{String: "8192", Valid: true},
+					},
+				},
+			},
+			want: map[string]postgresIndexStat{
+				"testdb/testschema/testrelname/testindex": {
+					database: "testdb", schema: "testschema", table: "testrelname", index: "testindex", key: "false", valid: 1,
+					idxscan: 5842, lastscan: 1667988000.123, idxtupread: 84572, idxtupfetch: 485, idxread: 4128, idxhit: 847, sizebytes: 16384,
+				},
+				"testdb/testschema/testrelname/testindex_ccnew": {
+					database: "testdb", schema: "testschema", table: "testrelname", index: "testindex_ccnew", key: "false",
+					sizebytes: 8192,
+				},
+			},
+		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			got := parsePostgresIndexStats(tc.res, []string{"datname", "schemaname", "relname", "indexrelname"})
+			got := parsePostgresIndexStats(tc.res, []string{"database", "schema", "table", "index", "key"})
 			assert.EqualValues(t, tc.want, got)
 		})
 	}
 }
+
+func TestPostgresIndexesCollector_sendIndexStat(t *testing.T) {
+	c, err := NewPostgresIndexesCollector(labels{}, model.CollectorSettings{})
+	assert.NoError(t, err)
+
+	// All values are distinct, hence each metric could be matched to the source column.
+	ch := make(chan prometheus.Metric, 10)
+	c.(*postgresIndexesCollector).sendIndexStat(ch, postgresIndexStat{
+		database: "testdb", schema: "testschema", table: "testrelname", index: "testindex", key: "false", valid: 1,
+		idxscan: 5842, idxtupread: 84572, idxtupfetch: 485, idxread: 4128, idxhit: 847, sizebytes: 16384,
+	})
+	close(ch)
+
+	got := map[string]float64{}
+	for m := range ch {
+		pb := &dto.Metric{}
+		assert.NoError(t, m.Write(pb))
+
+		var name string
+		for _, lp := range pb.GetLabel() {
+			if lp.GetName() == "tuples" || lp.GetName() == "access" {
+				name = lp.GetName() + "/" + lp.GetValue()
+			}
+		}
+		if name == "" {
+			continue
+		}
+
+		got[name] = pb.GetCounter().GetValue()
+	}
+
+	// Tuples read must be reported using idx_tup_read, not idx_blks_read.
+	assert.Equal(t, map[string]float64{
+		"tuples/read": 84572, "tuples/fetched": 485, "access/read": 4128, "access/hit": 847,
+	}, got)
+}
+
+func Test_selectIndexesQuery(t *testing.T) {
+	var testcases = []struct {
+		version  int
+		lastScan bool
+	}{
+		{version: PostgresV95, lastScan: false},
+		{version: PostgresV15, lastScan: false},
+		{version: PostgresV16, lastScan: true},
+	}
+
+	for _, tc := range testcases {
+		t.Run("", func(t *testing.T) {
+			query := selectIndexesQuery(tc.version)
+			assert.Equal(t, tc.lastScan, strings.Contains(query, "last_idx_scan"))
+			assert.NotContains(t, query, "%")
+		})
+	}
+}
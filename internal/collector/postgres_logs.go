@@ -121,7 +121,7 @@ func (c *postgresLogsCollector) Update(config Config, ch chan<- prometheus.Metri
 	}
 
 	// Notify log collector goroutine if logfile has been changed.
-	logfile, err := queryCurrentLogfile(config.ConnString)
+	logfile, err := queryCurrentLogfile(config.scrapeContext(), config.Pool, config.ConnString)
 	if err != nil {
 		return err
 	}
@@ -241,19 +241,22 @@ func tailCollect(ctx context.Context, logfile string, init bool, wg *sync.WaitGr
 	}
 }
 
-// queryCurrentLogfile returns path to logfile used by database.
-func queryCurrentLogfile(conninfo string) (string, error) {
-	conn, err := store.New(conninfo)
+// queryCurrentLogfile returns path to logfile used by database. Connection is acquired from passed pool of the service.
+func queryCurrentLogfile(ctx context.Context, pool *store.Pool, conninfo string) (string, error) {
+	conn, err := store.NewPooled(ctx, pool, conninfo)
 	if err != nil {
 		return "", err
 	}
+	defer conn.Close()
+
+	queryCtx, cancel := conn.Context()
+	defer cancel()
 
 	var datadir, logfile string
-	err = conn.Conn().QueryRow(context.TODO(), "SELECT current_setting('data_directory'),pg_current_logfile()").Scan(&datadir, &logfile)
+	err = conn.Conn().QueryRow(queryCtx, "SELECT current_setting('data_directory'),pg_current_logfile()").Scan(&datadir, &logfile)
 	if err != nil {
 		return "", err
 	}
-	conn.Close()
 
 	if !strings.HasPrefix(logfile, "/") {
 		logfile = datadir + "/" + logfile
@@ -0,0 +1,119 @@
+package collector
+
+import (
+	"github.com/jackc/pgx/v4"
+	"github.com/lesovsky/pgscv/internal/log"
+	"github.com/lesovsky/pgscv/internal/model"
+	"github.com/lesovsky/pgscv/internal/store"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// postgresPartitionsQuery11 defines query for partitioned tables stats in Postgres 10 and 11. There is no
+	// pg_partition_tree() in these versions, hence size is accounted for direct partitions only.
+	postgresPartitionsQuery11 = "SELECT current_database() AS database, n.nspname AS schema, c.relname AS table, " +
+		"count(i.inhrelid) AS children, coalesce(sum(pg_total_relation_size(i.inhrelid)), 0) AS size_bytes " +
+		"FROM pg_partitioned_table p JOIN pg_class c ON c.oid = p.partrelid JOIN pg_namespace n ON n.oid = c.relnamespace " +
+		"LEFT JOIN pg_inherits i ON i.inhparent = p.partrelid " +
+		"GROUP BY n.nspname, c.relname"
+
+	// postgresPartitionsQueryLatest defines query for partitioned tables stats. Size is accounted for all leaf
+	// partitions, including partitions of sub-partitioned tables.
+	postgresPartitionsQueryLatest = "SELECT current_database() AS database, n.nspname AS schema, c.relname AS table, " +
+		"(SELECT count(*) FROM pg_inherits i WHERE i.inhparent = p.partrelid) AS children, " +
+		"(SELECT coalesce(sum(pg_total_relation_size(t.relid)), 0) FROM pg_partition_tree(p.partrelid) t WHERE t.isleaf) AS size_bytes " +
+		"FROM pg_partitioned_table p JOIN pg_class c ON c.oid = p.partrelid JOIN pg_namespace n ON n.oid = c.relnamespace"
+)
+
+type postgresPartitionsCollector struct {
+	children   typedDesc
+	size       typedDesc
+	labelNames []string
+}
+
+// NewPostgresPartitionsCollector returns a new Collector exposing stats of partitioned tables.
+// For details see https://www.postgresql.org/docs/current/ddl-partitioning.html
+func NewPostgresPartitionsCollector(constLabels labels, settings model.CollectorSettings) (Collector, error) {
+	var labelNames = []string{"database", "schema", "table"}
+
+	return &postgresPartitionsCollector{
+		labelNames: labelNames,
+		children: newBuiltinTypedDesc(
+			descOpts{"postgres", "partition", "children_total", "Total number of partitions directly attached to the partitioned table.", 0},
+			prometheus.GaugeValue,
+			labelNames, constLabels,
+			settings.Filters,
+		),
+		size: newBuiltinTypedDesc(
+			descOpts{"postgres", "partition", "size_bytes", "Total size of partitions of the partitioned table (including indexes and TOASTed data), in bytes.", 0},
+			prometheus.GaugeValue,
+			labelNames, constLabels,
+			settings.Filters,
+		),
+	}, nil
+}
+
+// Update method collects statistics, parse it and produces metrics that are sent to Prometheus.
+func (c *postgresPartitionsCollector) Update(config Config, ch chan<- prometheus.Metric) error {
+	if config.serverVersionNum < PostgresV10 {
+		log.Debugln("[postgres partitions collector]: declarative partitioning is not available, required Postgres 10 or newer")
+		return nil
+	}
+
+	conn, err := store.NewPooled(config.scrapeContext(), config.Pool, config.ConnString)
+	if err != nil {
+		return err
+	}
+
+	databases, err := listDatabases(conn)
+	if err != nil {
+		return err
+	}
+
+	conn.Close()
+
+	pgconfig, err := pgx.ParseConfig(config.ConnString)
+	if err != nil {
+		return err
+	}
+
+	// Skip databases which are not matched to allowed or matched to excluded.
+	databases = filterDatabases(databases, config.DatabasesRE, config.DatabasesExcludeRE)
+
+	query := selectPartitionsQuery(config.serverVersionNum)
+
+	runPerDatabase(databases, config.DatabasesConcurrency, func(d string) {
+		dbconfig := pgconfig.Copy()
+		dbconfig.Database = d
+		conn, err := store.NewPooledWithConfig(config.scrapeContext(), config.Pool, dbconfig)
+		if err != nil {
+			log.Warnf("connect to database '%s' failed: %s; skip", d, err)
+			return
+		}
+
+		res, err := conn.Query(query)
+		conn.Close()
+		if err != nil {
+			log.Warnf("get partitions stat of database '%s' failed: %s; skip", d, err)
+			return
+		}
+
+		stats := parsePostgresGenericStats(res, c.labelNames)
+
+		for _, stat := range stats {
+			ch <- c.children.newConstMetric(stat.values["children"], stat.labels["database"], stat.labels["schema"], stat.labels["table"])
+			ch <- c.size.newConstMetric(stat.values["size_bytes"], stat.labels["database"], stat.labels["schema"], stat.labels["table"])
+		}
+	})
+
+	return nil
+}
+
+// selectPartitionsQuery returns suitable partitions query depending on passed version.
+func selectPartitionsQuery(version int) string {
+	if version < PostgresV12 {
+		return postgresPartitionsQuery11
+	}
+
+	return postgresPartitionsQueryLatest
+}
@@ -0,0 +1,79 @@
+package collector
+
+import (
+	"database/sql"
+	"github.com/jackc/pgproto3/v2"
+	"github.com/lesovsky/pgscv/internal/model"
+	"github.com/lesovsky/pgscv/internal/store"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPostgresPartitionsCollector_Update(t *testing.T) {
+	var input = pipelineInput{
+		required: []string{
+			"postgres_partition_children_total",
+			"postgres_partition_size_bytes",
+		},
+		collector: NewPostgresPartitionsCollector,
+		service:   model.ServiceTypePostgresql,
+	}
+
+	pipeline(t, input)
+}
+
+func Test_postgresPartitionsQuery(t *testing.T) {
+	conn := store.NewTest(t)
+	defer conn.Close()
+
+	res, err := conn.Query(postgresPartitionsQueryLatest)
+	assert.NoError(t, err)
+
+	// Fixtures database has 'measurements' table with two partitions.
+	stats := parsePostgresGenericStats(res, []string{"database", "schema", "table"})
+	stat, ok := stats["pgscv_fixtures/public/measurements"]
+	assert.True(t, ok)
+	assert.Equal(t, float64(2), stat.values["children"])
+	assert.Greater(t, stat.values["size_bytes"], float64(0))
+}
+
+func Test_parsePostgresPartitionsStats(t *testing.T) {
+	res := &model.PGResult{
+		Nrows: 2,
+		Ncols: 5,
+		Colnames: []pgproto3.FieldDescription{
+			{Name: []byte("database")}, {Name: []byte("schema")}, {Name: []byte("table")},
+			{Name: []byte("children")}, {Name: []byte("size_bytes")},
+		},
+		Rows: [][]sql.NullString{
+			{
+				{String: "testdb", Valid: true}, {String: "public", Valid: true}, {String: "measurements", Valid: true},
+				{String: "12", Valid: true}, {String: "1048576", Valid: true},
+			},
+			{
+				{String: "testdb", Valid: true}, {String: "public", Valid: true}, {String: "events", Valid: true},
+				{String: "0", Valid: true}, {String: "0", Valid: true},
+			},
+		},
+	}
+
+	want := map[string]postgresGenericStat{
+		"testdb/public/measurements": {
+			labels: map[string]string{"database": "testdb", "schema": "public", "table": "measurements"},
+			values: map[string]float64{"children": 12, "size_bytes": 1048576},
+		},
+		"testdb/public/events": {
+			labels: map[string]string{"database": "testdb", "schema": "public", "table": "events"},
+			values: map[string]float64{"children": 0, "size_bytes": 0},
+		},
+	}
+
+	assert.Equal(t, want, parsePostgresGenericStats(res, []string{"database", "schema", "table"}))
+}
+
+func Test_selectPartitionsQuery(t *testing.T) {
+	assert.Equal(t, postgresPartitionsQuery11, selectPartitionsQuery(PostgresV10))
+	assert.Equal(t, postgresPartitionsQuery11, selectPartitionsQuery(PostgresV11))
+	assert.Equal(t, postgresPartitionsQueryLatest, selectPartitionsQuery(PostgresV12))
+	assert.Equal(t, postgresPartitionsQueryLatest, selectPartitionsQuery(PostgresV14))
+}
@@ -0,0 +1,259 @@
+package collector
+
+import (
+	"fmt"
+	"github.com/jackc/pgx/v4"
+	"github.com/lesovsky/pgscv/internal/log"
+	"github.com/lesovsky/pgscv/internal/model"
+	"github.com/lesovsky/pgscv/internal/store"
+	"github.com/prometheus/client_golang/prometheus"
+	"strings"
+)
+
+const (
+	// postgresRelkindQuery defines query for kind of a relation.
+	postgresRelkindQuery = "SELECT relkind FROM pg_class WHERE oid = $1::regclass"
+
+	// postgresPgstattupleQuery defines query for exact tuple-level stats of a table. The query has single placeholder
+	// for the extension schema.
+	postgresPgstattupleQuery = "SELECT dead_tuple_len, free_space, tuple_percent FROM %s.pgstattuple($1::regclass)"
+
+	// postgresPgstatindexQuery defines query for stats of a btree index. The query has single placeholder for the
+	// extension schema.
+	postgresPgstatindexQuery = "SELECT avg_leaf_density, leaf_fragmentation, empty_pages, deleted_pages FROM %s.pgstatindex($1::regclass)"
+)
+
+type postgresPgstattupleCollector struct {
+	deadBytes         typedDesc
+	freeBytes         typedDesc
+	tuplePercent      typedDesc
+	leafDensity       typedDesc
+	leafFragmentation typedDesc
+	emptyPages        typedDesc
+	deletedPages      typedDesc
+	relations         map[string][][2]string // relations grouped by databases
+}
+
+// NewPostgresPgstattupleCollector returns a new Collector exposing exact tuple-level stats of tables and stats of btree
+// indexes obtained using pgstattuple extension (pgstattuple() and pgstatindex() functions respectively). Relations
+// have to be listed explicitly in configuration, because these functions read whole relation and might be expensive.
+// For details see https://www.postgresql.org/docs/current/pgstattuple.html
+func NewPostgresPgstattupleCollector(constLabels labels, settings model.CollectorSettings) (Collector, error) {
+	var labelNames = []string{"database", "schema", "relation"}
+
+	return &postgresPgstattupleCollector{
+		deadBytes: newBuiltinTypedDesc(
+			descOpts{"postgres", "pgstattuple", "dead_bytes", "Total length of dead tuples in relation, in bytes.", 0},
+			prometheus.GaugeValue,
+			labelNames, constLabels,
+			settings.Filters,
+		),
+		freeBytes: newBuiltinTypedDesc(
+			descOpts{"postgres", "pgstattuple", "free_bytes", "Total free space in relation, in bytes.", 0},
+			prometheus.GaugeValue,
+			labelNames, constLabels,
+			settings.Filters,
+		),
+		tuplePercent: newBuiltinTypedDesc(
+			descOpts{"postgres", "pgstattuple", "tuple_percent", "Percentage of relation size occupied by live tuples.", 0},
+			prometheus.GaugeValue,
+			labelNames, constLabels,
+			settings.Filters,
+		),
+		leafDensity: newBuiltinTypedDesc(
+			descOpts{"postgres", "pgstatindex", "avg_leaf_density", "Average density of leaf pages of btree index, in percent.", 0},
+			prometheus.GaugeValue,
+			labelNames, constLabels,
+			settings.Filters,
+		),
+		leafFragmentation: newBuiltinTypedDesc(
+			descOpts{"postgres", "pgstatindex", "leaf_fragmentation", "Leaf pages fragmentation of btree index, in percent.", 0},
+			prometheus.GaugeValue,
+			labelNames, constLabels,
+			settings.Filters,
+		),
+		emptyPages: newBuiltinTypedDesc(
+			descOpts{"postgres", "pgstatindex", "empty_pages", "Number of empty pages of btree index.", 0},
+			prometheus.GaugeValue,
+			labelNames, constLabels,
+			settings.Filters,
+		),
+		deletedPages: newBuiltinTypedDesc(
+			descOpts{"postgres", "pgstatindex", "deleted_pages", "Number of deleted pages of btree index.", 0},
+			prometheus.GaugeValue,
+			labelNames, constLabels,
+			settings.Filters,
+		),
+		relations: groupPgstattupleRelations(settings.Relations),
+	}, nil
+}
+
+// Update method collects statistics, parse it and produces metrics that are sent to Prometheus.
+func (c *postgresPgstattupleCollector) Update(config Config, ch chan<- prometheus.Metric) error {
+	if len(c.relations) == 0 {
+		log.Debugln("[postgres pgstattuple collector]: no relations specified, skip")
+		return nil
+	}
+
+	pgconfig, err := pgx.ParseConfig(config.ConnString)
+	if err != nil {
+		return err
+	}
+
+	for d, rels := range c.relations {
+		// Skip database if not matched to allowed or matched to excluded.
+		if !isDatabaseAllowed(d, config.DatabasesRE, config.DatabasesExcludeRE) {
+			continue
+		}
+
+		dbconfig := pgconfig.Copy()
+		dbconfig.Database = d
+		conn, err := store.NewPooledWithConfig(config.scrapeContext(), config.Pool, dbconfig)
+		if err != nil {
+			log.Warnf("connect to database '%s' failed: %s; skip", d, err)
+			continue
+		}
+
+		// Skip database if pgstattuple is not installed.
+		schema := extensionInstalledSchema(conn, "pgstattuple")
+		if schema == "" {
+			log.Debugf("[postgres pgstattuple collector]: pgstattuple not found in database '%s', skip", d)
+			conn.Close()
+			continue
+		}
+
+		for _, rel := range rels {
+			relkind, err := getRelkind(conn, rel[0], rel[1])
+			if err != nil {
+				log.Warnf("get kind of relation %s.%s in database '%s' failed: %s; skip", rel[0], rel[1], d, err)
+				continue
+			}
+
+			// Indexes stats are requested using pgstatindex(), it is more suitable for btree indexes.
+			if relkind == "i" {
+				stat, err := getPgstatindexStat(conn, schema, rel[0], rel[1])
+				if err != nil {
+					log.Warnf("get pgstatindex stats of index %s.%s in database '%s' failed: %s; skip", rel[0], rel[1], d, err)
+					continue
+				}
+
+				ch <- c.leafDensity.newConstMetric(stat.leafDensity, d, rel[0], rel[1])
+				ch <- c.leafFragmentation.newConstMetric(stat.leafFragmentation, d, rel[0], rel[1])
+				ch <- c.emptyPages.newConstMetric(stat.emptyPages, d, rel[0], rel[1])
+				ch <- c.deletedPages.newConstMetric(stat.deletedPages, d, rel[0], rel[1])
+				continue
+			}
+
+			stat, err := getPgstattupleStat(conn, schema, rel[0], rel[1])
+			if err != nil {
+				log.Warnf("get pgstattuple stats of relation %s.%s in database '%s' failed: %s; skip", rel[0], rel[1], d, err)
+				continue
+			}
+
+			ch <- c.deadBytes.newConstMetric(stat.deadBytes, d, rel[0], rel[1])
+			ch <- c.freeBytes.newConstMetric(stat.freeBytes, d, rel[0], rel[1])
+			ch <- c.tuplePercent.newConstMetric(stat.tuplePercent, d, rel[0], rel[1])
+		}
+
+		conn.Close()
+	}
+
+	return nil
+}
+
+// postgresPgstattupleStat represents relation's stats returned by pgstattuple().
+type postgresPgstattupleStat struct {
+	deadBytes    float64
+	freeBytes    float64
+	tuplePercent float64
+}
+
+// getPgstattupleStat requests stats of the relation using pgstattuple installed in extSchema.
+func getPgstattupleStat(conn *store.DB, extSchema, schema, relation string) (postgresPgstattupleStat, error) {
+	var stat postgresPgstattupleStat
+
+	ctx, cancel := conn.Context()
+	defer cancel()
+
+	query := fmt.Sprintf(postgresPgstattupleQuery, pgx.Identifier{extSchema}.Sanitize())
+
+	err := conn.Conn().
+		QueryRow(ctx, query, pgx.Identifier{schema, relation}.Sanitize()).
+		Scan(&stat.deadBytes, &stat.freeBytes, &stat.tuplePercent)
+	if err != nil {
+		return stat, err
+	}
+
+	return stat, nil
+}
+
+// postgresPgstatindexStat represents index's stats returned by pgstatindex().
+type postgresPgstatindexStat struct {
+	leafDensity       float64
+	leafFragmentation float64
+	emptyPages        float64
+	deletedPages      float64
+}
+
+// getPgstatindexStat requests stats of the btree index using pgstattuple installed in extSchema.
+func getPgstatindexStat(conn *store.DB, extSchema, schema, index string) (postgresPgstatindexStat, error) {
+	var stat postgresPgstatindexStat
+
+	ctx, cancel := conn.Context()
+	defer cancel()
+
+	query := fmt.Sprintf(postgresPgstatindexQuery, pgx.Identifier{extSchema}.Sanitize())
+
+	err := conn.Conn().
+		QueryRow(ctx, query, pgx.Identifier{schema, index}.Sanitize()).
+		Scan(&stat.leafDensity, &stat.leafFragmentation, &stat.emptyPages, &stat.deletedPages)
+	if err != nil {
+		return stat, err
+	}
+
+	return stat, nil
+}
+
+// getRelkind returns kind of the relation, e.g. 'r' for tables or 'i' for indexes.
+func getRelkind(conn *store.DB, schema, relation string) (string, error) {
+	var relkind string
+
+	ctx, cancel := conn.Context()
+	defer cancel()
+
+	err := conn.Conn().
+		QueryRow(ctx, postgresRelkindQuery, pgx.Identifier{schema, relation}.Sanitize()).
+		Scan(&relkind)
+	if err != nil {
+		return "", err
+	}
+
+	return relkind, nil
+}
+
+// ValidatePgstattupleRelation checks relation name used for pgstattuple stats is in 'database/schema/relation' format.
+func ValidatePgstattupleRelation(name string) error {
+	parts := strings.Split(name, "/")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return fmt.Errorf("relation name must be in 'database/schema/relation' format")
+	}
+
+	return nil
+}
+
+// groupPgstattupleRelations groups relations names by databases, invalid names are skipped.
+func groupPgstattupleRelations(names []string) map[string][][2]string {
+	var relations = map[string][][2]string{}
+
+	for _, name := range names {
+		if err := ValidatePgstattupleRelation(name); err != nil {
+			log.Warnf("invalid relation name '%s': %s; skip", name, err)
+			continue
+		}
+
+		parts := strings.Split(name, "/")
+		relations[parts[0]] = append(relations[parts[0]], [2]string{parts[1], parts[2]})
+	}
+
+	return relations
+}
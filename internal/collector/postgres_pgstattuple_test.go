@@ -0,0 +1,74 @@
+package collector
+
+import (
+	"github.com/lesovsky/pgscv/internal/model"
+	"github.com/lesovsky/pgscv/internal/store"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPostgresPgstattupleCollector_Update(t *testing.T) {
+	c, err := NewPostgresPgstattupleCollector(labels{}, model.CollectorSettings{})
+	assert.NoError(t, err)
+
+	// No relations specified, collector should do nothing (even connect to Postgres).
+	ch := make(chan prometheus.Metric, 10)
+	assert.NoError(t, c.Update(Config{}, ch))
+	assert.Equal(t, 0, len(ch))
+}
+
+func Test_pgstattupleAvailability(t *testing.T) {
+	conn := store.NewTest(t)
+	defer conn.Close()
+
+	// pgstattuple is not installed in fixtures database.
+	schema := extensionInstalledSchema(conn, "pgstattuple")
+	assert.Equal(t, "", schema)
+
+	// Requesting stats without extension should fail.
+	_, err := getPgstattupleStat(conn, "public", "pg_catalog", "pg_class")
+	assert.Error(t, err)
+	_, err = getPgstatindexStat(conn, "public", "pg_catalog", "pg_class_oid_index")
+	assert.Error(t, err)
+
+	// Kind of relations is used for choosing the stats function.
+	relkind, err := getRelkind(conn, "pg_catalog", "pg_class")
+	assert.NoError(t, err)
+	assert.Equal(t, "r", relkind)
+	relkind, err = getRelkind(conn, "pg_catalog", "pg_class_oid_index")
+	assert.NoError(t, err)
+	assert.Equal(t, "i", relkind)
+}
+
+func TestValidatePgstattupleRelation(t *testing.T) {
+	testcases := []struct {
+		valid bool
+		name  string
+	}{
+		{valid: true, name: "exampledb/public/example"},
+		{valid: false, name: "public/example"},
+		{valid: false, name: "exampledb//example"},
+		{valid: false, name: "exampledb/public/example/invalid"},
+		{valid: false, name: ""},
+	}
+
+	for _, tc := range testcases {
+		if tc.valid {
+			assert.NoError(t, ValidatePgstattupleRelation(tc.name))
+		} else {
+			assert.Error(t, ValidatePgstattupleRelation(tc.name))
+		}
+	}
+}
+
+func Test_groupPgstattupleRelations(t *testing.T) {
+	got := groupPgstattupleRelations([]string{
+		"db1/public/example1", "db1/public/example2", "db2/custom/example3", "invalid/example",
+	})
+
+	assert.Equal(t, map[string][][2]string{
+		"db1": {{"public", "example1"}, {"public", "example2"}},
+		"db2": {{"custom", "example3"}},
+	}, got)
+}
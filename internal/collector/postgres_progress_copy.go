@@ -0,0 +1,168 @@
+package collector
+
+import (
+	"github.com/lesovsky/pgscv/internal/log"
+	"github.com/lesovsky/pgscv/internal/model"
+	"github.com/lesovsky/pgscv/internal/store"
+	"github.com/prometheus/client_golang/prometheus"
+	"strconv"
+)
+
+const (
+	// postgresProgressCopyQuery defines query for COPY progress stats, available since Postgres 14. Relations names
+	// could be resolved only for current database, for other databases relation OID is used. Progress view is joined
+	// to pg_stat_activity for skipping entries of already finished backends.
+	postgresProgressCopyQuery = "SELECT p.pid, p.datname AS database, coalesce(c.relname, p.relid::text) AS relation, p.command, p.type, " +
+		"p.bytes_processed, p.bytes_total, p.tuples_processed, p.tuples_excluded " +
+		"FROM pg_stat_progress_copy p JOIN pg_stat_activity a ON a.pid = p.pid " +
+		"LEFT JOIN pg_class c ON c.oid = p.relid AND p.datname = current_database()"
+)
+
+type postgresProgressCopyCollector struct {
+	bytesProcessed  typedDesc
+	bytesTotal      typedDesc
+	tuplesProcessed typedDesc
+	tuplesExcluded  typedDesc
+	labelNames      []string
+}
+
+// NewPostgresProgressCopyCollector returns a new Collector exposing postgres COPY progress stats.
+// For details see https://www.postgresql.org/docs/current/progress-reporting.html#COPY-PROGRESS-REPORTING
+func NewPostgresProgressCopyCollector(constLabels labels, settings model.CollectorSettings) (Collector, error) {
+	var labelNames = []string{"database", "relation", "command", "type", "pid"}
+
+	return &postgresProgressCopyCollector{
+		labelNames: labelNames,
+		bytesProcessed: newBuiltinTypedDesc(
+			descOpts{"postgres", "progress_copy", "bytes_processed", "Number of bytes already processed by running COPY command.", 0},
+			prometheus.GaugeValue,
+			labelNames, constLabels,
+			settings.Filters,
+		),
+		bytesTotal: newBuiltinTypedDesc(
+			descOpts{"postgres", "progress_copy", "bytes_total", "Size of source file for COPY FROM command in bytes, zero if not available.", 0},
+			prometheus.GaugeValue,
+			labelNames, constLabels,
+			settings.Filters,
+		),
+		tuplesProcessed: newBuiltinTypedDesc(
+			descOpts{"postgres", "progress_copy", "tuples_processed", "Number of tuples already processed by running COPY command.", 0},
+			prometheus.GaugeValue,
+			labelNames, constLabels,
+			settings.Filters,
+		),
+		tuplesExcluded: newBuiltinTypedDesc(
+			descOpts{"postgres", "progress_copy", "tuples_excluded", "Number of tuples not processed by running COPY command because they were excluded by the WHERE clause.", 0},
+			prometheus.GaugeValue,
+			labelNames, constLabels,
+			settings.Filters,
+		),
+	}, nil
+}
+
+// Update method collects statistics, parse it and produces metrics that are sent to Prometheus.
+func (c *postgresProgressCopyCollector) Update(config Config, ch chan<- prometheus.Metric) error {
+	// pg_stat_progress_copy is available since Postgres 14.
+	if config.serverVersionNum < PostgresV14 {
+		log.Debugln("[postgres progress copy collector]: pg_stat_progress_copy is not available, required Postgres 14 or newer")
+		return nil
+	}
+
+	conn, err := store.NewPooled(config.scrapeContext(), config.Pool, config.ConnString)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	res, err := conn.Query(postgresProgressCopyQuery)
+	if err != nil {
+		return err
+	}
+
+	stats := parsePostgresProgressCopyStats(res, c.labelNames)
+
+	for _, stat := range stats {
+		ch <- c.bytesProcessed.newConstMetric(stat.bytesProcessed, stat.database, stat.relation, stat.command, stat.copyType, stat.pid)
+		ch <- c.bytesTotal.newConstMetric(stat.bytesTotal, stat.database, stat.relation, stat.command, stat.copyType, stat.pid)
+		ch <- c.tuplesProcessed.newConstMetric(stat.tuplesProcessed, stat.database, stat.relation, stat.command, stat.copyType, stat.pid)
+		ch <- c.tuplesExcluded.newConstMetric(stat.tuplesExcluded, stat.database, stat.relation, stat.command, stat.copyType, stat.pid)
+	}
+
+	return nil
+}
+
+// postgresProgressCopyStat represents per-backend stats of running COPY commands based on pg_stat_progress_copy.
+type postgresProgressCopyStat struct {
+	pid             string
+	database        string
+	relation        string
+	command         string
+	copyType        string
+	bytesProcessed  float64
+	bytesTotal      float64
+	tuplesProcessed float64
+	tuplesExcluded  float64
+}
+
+// parsePostgresProgressCopyStats parses PGResult and returns struct with stats values.
+func parsePostgresProgressCopyStats(r *model.PGResult, labelNames []string) map[string]postgresProgressCopyStat {
+	log.Debug("parse postgres progress copy stats")
+
+	var stats = make(map[string]postgresProgressCopyStat)
+
+	for _, row := range r.Rows {
+		stat := postgresProgressCopyStat{}
+
+		// collect label values
+		for i, colname := range r.Colnames {
+			switch string(colname.Name) {
+			case "pid":
+				stat.pid = row[i].String
+			case "database":
+				stat.database = row[i].String
+			case "relation":
+				stat.relation = row[i].String
+			case "command":
+				stat.command = row[i].String
+			case "type":
+				stat.copyType = row[i].String
+			}
+		}
+
+		for i, colname := range r.Colnames {
+			// skip columns if its value used as a label
+			if stringsContains(labelNames, string(colname.Name)) {
+				continue
+			}
+
+			// Skip empty (NULL) values.
+			if !row[i].Valid {
+				continue
+			}
+
+			// Get data value and convert it to float64 used by Prometheus.
+			v, err := strconv.ParseFloat(row[i].String, 64)
+			if err != nil {
+				log.Errorf("invalid input, parse '%s' failed: %s; skip", row[i].String, err)
+				continue
+			}
+
+			switch string(colname.Name) {
+			case "bytes_processed":
+				stat.bytesProcessed = v
+			case "bytes_total":
+				stat.bytesTotal = v
+			case "tuples_processed":
+				stat.tuplesProcessed = v
+			case "tuples_excluded":
+				stat.tuplesExcluded = v
+			default:
+				continue
+			}
+		}
+
+		stats[stat.pid] = stat
+	}
+
+	return stats
+}
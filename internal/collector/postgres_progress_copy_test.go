@@ -0,0 +1,84 @@
+package collector
+
+import (
+	"database/sql"
+	"github.com/jackc/pgproto3/v2"
+	"github.com/lesovsky/pgscv/internal/model"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPostgresProgressCopyCollector_Update(t *testing.T) {
+	var input = pipelineInput{
+		optional: []string{
+			"postgres_progress_copy_bytes_processed",
+			"postgres_progress_copy_bytes_total",
+			"postgres_progress_copy_tuples_processed",
+			"postgres_progress_copy_tuples_excluded",
+		},
+		collector: NewPostgresProgressCopyCollector,
+		service:   model.ServiceTypePostgresql,
+	}
+
+	pipeline(t, input)
+}
+
+func Test_parsePostgresProgressCopyStats(t *testing.T) {
+	var testCases = []struct {
+		name string
+		res  *model.PGResult
+		want map[string]postgresProgressCopyStat
+	}{
+		{
+			name: "normal output",
+			res: &model.PGResult{
+				Nrows: 2,
+				Ncols: 9,
+				Colnames: []pgproto3.FieldDescription{
+					{Name: []byte("pid")}, {Name: []byte("database")}, {Name: []byte("relation")}, {Name: []byte("command")}, {Name: []byte("type")},
+					{Name: []byte("bytes_processed")}, {Name: []byte("bytes_total")}, {Name: []byte("tuples_processed")}, {Name: []byte("tuples_excluded")},
+				},
+				Rows: [][]sql.NullString{
+					{
+						{String: "1234", Valid: true}, {String: "testdb", Valid: true}, {String: "testrel", Valid: true}, {String: "COPY FROM", Valid: true}, {String: "FILE", Valid: true},
+						{String: "45812736", Valid: true}, {String: "104857600", Valid: true}, {String: "412587", Valid: true}, {String: "125", Valid: true},
+					},
+					{
+						{String: "1235", Valid: true}, {String: "testdb", Valid: true}, {String: "0", Valid: true}, {String: "COPY TO", Valid: true}, {String: "PIPE", Valid: true},
+						{String: "1048576", Valid: true}, {String: "0", Valid: true}, {String: "", Valid: false}, {String: "0", Valid: true},
+					},
+				},
+			},
+			want: map[string]postgresProgressCopyStat{
+				"1234": {
+					pid: "1234", database: "testdb", relation: "testrel", command: "COPY FROM", copyType: "FILE",
+					bytesProcessed: 45812736, bytesTotal: 104857600, tuplesProcessed: 412587, tuplesExcluded: 125,
+				},
+				"1235": {
+					pid: "1235", database: "testdb", relation: "0", command: "COPY TO", copyType: "PIPE",
+					bytesProcessed: 1048576, bytesTotal: 0, tuplesProcessed: 0, tuplesExcluded: 0,
+				},
+			},
+		},
+		{
+			name: "no running copy",
+			res: &model.PGResult{
+				Nrows: 0,
+				Ncols: 9,
+				Colnames: []pgproto3.FieldDescription{
+					{Name: []byte("pid")}, {Name: []byte("database")}, {Name: []byte("relation")}, {Name: []byte("command")}, {Name: []byte("type")},
+					{Name: []byte("bytes_processed")}, {Name: []byte("bytes_total")}, {Name: []byte("tuples_processed")}, {Name: []byte("tuples_excluded")},
+				},
+				Rows: [][]sql.NullString{},
+			},
+			want: map[string]postgresProgressCopyStat{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := parsePostgresProgressCopyStats(tc.res, []string{"database", "relation", "command", "type", "pid"})
+			assert.EqualValues(t, tc.want, got)
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package collector
+
+import (
+	"github.com/lesovsky/pgscv/internal/log"
+	"github.com/lesovsky/pgscv/internal/model"
+	"github.com/lesovsky/pgscv/internal/store"
+	"github.com/prometheus/client_golang/prometheus"
+	"math"
+)
+
+const (
+	// postgresProgressVacuumQuery defines query for VACUUM progress stats, available since Postgres 9.6. Relations names
+	// could be resolved only for current database, for other databases relation OID is used. Cost-based vacuum delay
+	// settings are attached to each row and used for estimating throttling.
+	postgresProgressVacuumQuery = "SELECT p.pid, p.datname AS database, coalesce(c.relname, p.relid::text) AS relation, " +
+		"CASE WHEN a.query LIKE 'autovacuum:%' THEN 'autovacuum' ELSE 'vacuum' END AS kind, " +
+		"p.heap_blks_total, p.heap_blks_scanned, p.heap_blks_vacuumed, " +
+		"(SELECT setting::float FROM pg_settings WHERE name = 'vacuum_cost_delay') AS vacuum_cost_delay, " +
+		"(SELECT setting::float FROM pg_settings WHERE name = 'vacuum_cost_limit') AS vacuum_cost_limit, " +
+		"(SELECT setting::float FROM pg_settings WHERE name = 'vacuum_cost_page_miss') AS vacuum_cost_page_miss, " +
+		"(SELECT setting::float FROM pg_settings WHERE name = 'vacuum_cost_page_dirty') AS vacuum_cost_page_dirty, " +
+		"(SELECT setting::float FROM pg_settings WHERE name = 'autovacuum_vacuum_cost_delay') AS autovacuum_vacuum_cost_delay, " +
+		"(SELECT setting::float FROM pg_settings WHERE name = 'autovacuum_vacuum_cost_limit') AS autovacuum_vacuum_cost_limit " +
+		"FROM pg_stat_progress_vacuum p JOIN pg_stat_activity a ON a.pid = p.pid " +
+		"LEFT JOIN pg_class c ON c.oid = p.relid AND p.datname = current_database()"
+)
+
+type postgresProgressVacuumCollector struct {
+	heapBlksTotal    typedDesc
+	heapBlksScanned  typedDesc
+	heapBlksVacuumed typedDesc
+	throttle         typedDesc
+	labelNames       []string
+}
+
+// NewPostgresProgressVacuumCollector returns a new Collector exposing postgres VACUUM progress stats and estimated
+// time spent in cost-based vacuum delay.
+// For details see https://www.postgresql.org/docs/current/progress-reporting.html#VACUUM-PROGRESS-REPORTING
+// and https://www.postgresql.org/docs/current/runtime-config-resource.html#RUNTIME-CONFIG-RESOURCE-VACUUM-COST
+func NewPostgresProgressVacuumCollector(constLabels labels, settings model.CollectorSettings) (Collector, error) {
+	var labelNames = []string{"database", "relation", "kind", "pid"}
+
+	return &postgresProgressVacuumCollector{
+		labelNames: labelNames,
+		heapBlksTotal: newBuiltinTypedDesc(
+			descOpts{"postgres", "progress_vacuum", "heap_blocks_total", "Total number of heap blocks in the table being vacuumed.", 0},
+			prometheus.GaugeValue,
+			labelNames, constLabels,
+			settings.Filters,
+		),
+		heapBlksScanned: newBuiltinTypedDesc(
+			descOpts{"postgres", "progress_vacuum", "heap_blocks_scanned", "Number of heap blocks scanned by running VACUUM.", 0},
+			prometheus.GaugeValue,
+			labelNames, constLabels,
+			settings.Filters,
+		),
+		heapBlksVacuumed: newBuiltinTypedDesc(
+			descOpts{"postgres", "progress_vacuum", "heap_blocks_vacuumed", "Number of heap blocks vacuumed by running VACUUM.", 0},
+			prometheus.GaugeValue,
+			labelNames, constLabels,
+			settings.Filters,
+		),
+		throttle: newBuiltinTypedDesc(
+			descOpts{"postgres", "progress_vacuum", "throttle_seconds_estimated", "Estimated time spent by running VACUUM in cost-based delay, in seconds.", 0},
+			prometheus.GaugeValue,
+			labelNames, constLabels,
+			settings.Filters,
+		),
+	}, nil
+}
+
+// Update method collects statistics, parse it and produces metrics that are sent to Prometheus.
+func (c *postgresProgressVacuumCollector) Update(config Config, ch chan<- prometheus.Metric) error {
+	// pg_stat_progress_vacuum is available since Postgres 9.6.
+	if config.serverVersionNum < PostgresV96 {
+		log.Debugln("[postgres progress vacuum collector]: pg_stat_progress_vacuum is not available, required Postgres 9.6 or newer")
+		return nil
+	}
+
+	conn, err := store.NewPooled(config.scrapeContext(), config.Pool, config.ConnString)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	res, err := conn.Query(postgresProgressVacuumQuery)
+	if err != nil {
+		return err
+	}
+
+	stats := parsePostgresGenericStats(res, c.labelNames)
+
+	for _, stat := range stats {
+		var (
+			database = stat.labels["database"]
+			relation = stat.labels["relation"]
+			kind     = stat.labels["kind"]
+			pid      = stat.labels["pid"]
+		)
+
+		ch <- c.heapBlksTotal.newConstMetric(stat.values["heap_blks_total"], database, relation, kind, pid)
+		ch <- c.heapBlksScanned.newConstMetric(stat.values["heap_blks_scanned"], database, relation, kind, pid)
+		ch <- c.heapBlksVacuumed.newConstMetric(stat.values["heap_blks_vacuumed"], database, relation, kind, pid)
+		ch <- c.throttle.newConstMetric(estimateVacuumThrottleSeconds(stat.values, kind == "autovacuum"), database, relation, kind, pid)
+	}
+
+	return nil
+}
+
+// vacuumCostLimits returns effective cost delay (in milliseconds) and cost limit used by vacuum. Autovacuum settings
+// with value -1 fall back to regular vacuum settings.
+func vacuumCostLimits(values map[string]float64, autovacuum bool) (float64, float64) {
+	delay, limit := values["vacuum_cost_delay"], values["vacuum_cost_limit"]
+
+	if !autovacuum {
+		return delay, limit
+	}
+
+	if v, ok := values["autovacuum_vacuum_cost_delay"]; ok && v >= 0 {
+		delay = v
+	}
+
+	if v, ok := values["autovacuum_vacuum_cost_limit"]; ok && v > 0 {
+		limit = v
+	}
+
+	return delay, limit
+}
+
+// estimateVacuumThrottleSeconds estimates time spent by vacuum in cost-based delay. Vacuum sleeps for cost delay each
+// time when accumulated cost reaches the cost limit. Pages accessed by vacuum are accounted as read from disk (page
+// miss) and vacuumed pages are accounted as dirtied, hence estimation is an upper bound rather than exact value. Also
+// note, autovacuum cost limit is balanced across running workers, which is not taken into account.
+func estimateVacuumThrottleSeconds(values map[string]float64, autovacuum bool) float64 {
+	delay, limit := vacuumCostLimits(values, autovacuum)
+	if delay <= 0 || limit <= 0 {
+		return 0
+	}
+
+	cost := values["heap_blks_scanned"]*values["vacuum_cost_page_miss"] + values["heap_blks_vacuumed"]*values["vacuum_cost_page_dirty"]
+
+	return math.Floor(cost/limit) * delay / 1000
+}
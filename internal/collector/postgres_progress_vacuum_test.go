@@ -0,0 +1,113 @@
+package collector
+
+import (
+	"github.com/lesovsky/pgscv/internal/model"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPostgresProgressVacuumCollector_Update(t *testing.T) {
+	var input = pipelineInput{
+		optional: []string{
+			"postgres_progress_vacuum_heap_blocks_total",
+			"postgres_progress_vacuum_heap_blocks_scanned",
+			"postgres_progress_vacuum_heap_blocks_vacuumed",
+			"postgres_progress_vacuum_throttle_seconds_estimated",
+		},
+		collector: NewPostgresProgressVacuumCollector,
+		service:   model.ServiceTypePostgresql,
+	}
+
+	pipeline(t, input)
+}
+
+func Test_vacuumCostLimits(t *testing.T) {
+	var testcases = []struct {
+		name       string
+		values     map[string]float64
+		autovacuum bool
+		wantDelay  float64
+		wantLimit  float64
+	}{
+		{
+			name:      "manual vacuum",
+			values:    map[string]float64{"vacuum_cost_delay": 0, "vacuum_cost_limit": 200, "autovacuum_vacuum_cost_delay": 2, "autovacuum_vacuum_cost_limit": -1},
+			wantDelay: 0, wantLimit: 200,
+		},
+		{
+			name:       "autovacuum with own settings",
+			values:     map[string]float64{"vacuum_cost_delay": 0, "vacuum_cost_limit": 200, "autovacuum_vacuum_cost_delay": 2, "autovacuum_vacuum_cost_limit": 1000},
+			autovacuum: true,
+			wantDelay:  2, wantLimit: 1000,
+		},
+		{
+			name:       "autovacuum falls back to vacuum settings",
+			values:     map[string]float64{"vacuum_cost_delay": 10, "vacuum_cost_limit": 200, "autovacuum_vacuum_cost_delay": -1, "autovacuum_vacuum_cost_limit": -1},
+			autovacuum: true,
+			wantDelay:  10, wantLimit: 200,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			delay, limit := vacuumCostLimits(tc.values, tc.autovacuum)
+			assert.Equal(t, tc.wantDelay, delay)
+			assert.Equal(t, tc.wantLimit, limit)
+		})
+	}
+}
+
+func Test_estimateVacuumThrottleSeconds(t *testing.T) {
+	var testcases = []struct {
+		name       string
+		values     map[string]float64
+		autovacuum bool
+		want       float64
+	}{
+		{
+			// cost: 10000*2 + 1000*20 = 40000; 40000/200 = 200 sleeps by 2ms
+			name: "autovacuum",
+			values: map[string]float64{
+				"heap_blks_scanned": 10000, "heap_blks_vacuumed": 1000,
+				"vacuum_cost_page_miss": 2, "vacuum_cost_page_dirty": 20,
+				"vacuum_cost_delay": 0, "vacuum_cost_limit": 200,
+				"autovacuum_vacuum_cost_delay": 2, "autovacuum_vacuum_cost_limit": -1,
+			},
+			autovacuum: true,
+			want:       0.4,
+		},
+		{
+			// cost: 150*10 = 1500; 1500/1000 = 1 sleep (incomplete cycles are not counted) by 20ms
+			name: "incomplete cost cycle",
+			values: map[string]float64{
+				"heap_blks_scanned": 150, "heap_blks_vacuumed": 0,
+				"vacuum_cost_page_miss": 10, "vacuum_cost_page_dirty": 20,
+				"vacuum_cost_delay": 0, "vacuum_cost_limit": 200,
+				"autovacuum_vacuum_cost_delay": 20, "autovacuum_vacuum_cost_limit": 1000,
+			},
+			autovacuum: true,
+			want:       0.02,
+		},
+		{
+			name: "manual vacuum without delay",
+			values: map[string]float64{
+				"heap_blks_scanned": 10000, "heap_blks_vacuumed": 1000,
+				"vacuum_cost_page_miss": 2, "vacuum_cost_page_dirty": 20,
+				"vacuum_cost_delay": 0, "vacuum_cost_limit": 200,
+				"autovacuum_vacuum_cost_delay": 2, "autovacuum_vacuum_cost_limit": -1,
+			},
+			want: 0,
+		},
+		{
+			name:   "no settings",
+			values: map[string]float64{"heap_blks_scanned": 10000, "heap_blks_vacuumed": 1000},
+			want:   0,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.InDelta(t, tc.want, estimateVacuumThrottleSeconds(tc.values, tc.autovacuum), 0.000001)
+		})
+	}
+}
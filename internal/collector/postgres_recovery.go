@@ -0,0 +1,56 @@
+package collector
+
+import (
+	"github.com/lesovsky/pgscv/internal/model"
+	"github.com/lesovsky/pgscv/internal/store"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// postgresRecoveryQuery defines query for time elapsed since last replayed transaction. On primary the query returns
+// no rows, even if the primary had been promoted from standby and pg_last_xact_replay_timestamp() is not NULL.
+const postgresRecoveryQuery = "SELECT extract(epoch from now() - pg_last_xact_replay_timestamp()) AS replay_lag_seconds WHERE pg_is_in_recovery()"
+
+type postgresRecoveryCollector struct {
+	replayLag typedDesc
+}
+
+// NewPostgresRecoveryCollector returns a new Collector exposing standby's recovery stats. Note, replay lag grows on
+// standby when there is no write activity on primary.
+// For details see https://www.postgresql.org/docs/current/functions-admin.html#FUNCTIONS-RECOVERY-INFO-TABLE
+func NewPostgresRecoveryCollector(constLabels labels, settings model.CollectorSettings) (Collector, error) {
+	return &postgresRecoveryCollector{
+		replayLag: newBuiltinTypedDesc(
+			descOpts{"postgres", "recovery", "replay_lag_seconds", "Time elapsed since last transaction replayed during recovery, in seconds.", 0},
+			prometheus.GaugeValue,
+			nil, constLabels,
+			settings.Filters,
+		),
+	}, nil
+}
+
+// Update method collects statistics, parse it and produces metrics that are sent to Prometheus.
+func (c *postgresRecoveryCollector) Update(config Config, ch chan<- prometheus.Metric) error {
+	if !config.inRecovery {
+		return nil
+	}
+
+	conn, err := store.NewPooled(config.scrapeContext(), config.Pool, config.ConnString)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	res, err := conn.Query(postgresRecoveryQuery)
+	if err != nil {
+		return err
+	}
+
+	// Parser skips NULL values, e.g. when standby has not replayed any transaction yet.
+	stats := parsePostgresWalStats(res)
+
+	if v, ok := stats["replay_lag_seconds"]; ok {
+		ch <- c.replayLag.newConstMetric(v)
+	}
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package collector
+
+import (
+	"database/sql"
+	"github.com/jackc/pgproto3/v2"
+	"github.com/lesovsky/pgscv/internal/model"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPostgresRecoveryCollector_Update(t *testing.T) {
+	var input = pipelineInput{
+		optional: []string{
+			"postgres_recovery_replay_lag_seconds",
+		},
+		collector: NewPostgresRecoveryCollector,
+		service:   model.ServiceTypePostgresql,
+	}
+
+	pipeline(t, input)
+}
+
+func TestPostgresRecoveryCollector_Update_primary(t *testing.T) {
+	c, err := NewPostgresRecoveryCollector(labels{}, model.CollectorSettings{})
+	assert.NoError(t, err)
+
+	// Collector should do nothing on primary (even connect to Postgres).
+	ch := make(chan prometheus.Metric, 10)
+	assert.NoError(t, c.Update(Config{postgresServiceConfig: postgresServiceConfig{inRecovery: false}}, ch))
+	assert.Equal(t, 0, len(ch))
+}
+
+func Test_parsePostgresWalStats_recovery(t *testing.T) {
+	var testCases = []struct {
+		name string
+		res  *model.PGResult
+		want map[string]float64
+	}{
+		{
+			name: "standby",
+			res: &model.PGResult{
+				Nrows:    1,
+				Ncols:    1,
+				Colnames: []pgproto3.FieldDescription{{Name: []byte("replay_lag_seconds")}},
+				Rows:     [][]sql.NullString{{{String: "12.345", Valid: true}}},
+			},
+			want: map[string]float64{"replay_lag_seconds": 12.345},
+		},
+		{
+			name: "standby without replayed transactions",
+			res: &model.PGResult{
+				Nrows:    1,
+				Ncols:    1,
+				Colnames: []pgproto3.FieldDescription{{Name: []byte("replay_lag_seconds")}},
+				Rows:     [][]sql.NullString{{{Valid: false}}},
+			},
+			want: map[string]float64{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := parsePostgresWalStats(tc.res)
+			assert.EqualValues(t, tc.want, got)
+		})
+	}
+}
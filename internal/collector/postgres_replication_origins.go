@@ -0,0 +1,68 @@
+package collector
+
+import (
+	"github.com/lesovsky/pgscv/internal/model"
+	"github.com/lesovsky/pgscv/internal/store"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// postgresReplicationOriginsQuery defines query for replication origins progress. Origins which have not replayed
+// anything yet have NULL positions.
+const postgresReplicationOriginsQuery = "SELECT external_id AS origin, " +
+	"remote_lsn - '0/00000000' AS remote_lsn, local_lsn - '0/00000000' AS local_lsn " +
+	"FROM pg_replication_origin_status"
+
+type postgresReplicationOriginsCollector struct {
+	remoteLsn typedDesc
+	localLsn  typedDesc
+}
+
+// NewPostgresReplicationOriginsCollector returns a new Collector exposing replication origins progress, used by
+// logical replication subscribers. Remote and local positions belong to different WAL streams (of publisher and
+// subscriber), hence they cannot be subtracted one from another. Replication lag could be calculated in Prometheus
+// as a difference between publisher's postgres_wal_lsn_bytes and subscriber's remote position.
+// For details see https://www.postgresql.org/docs/current/view-pg-replication-origin-status.html
+func NewPostgresReplicationOriginsCollector(constLabels labels, settings model.CollectorSettings) (Collector, error) {
+	return &postgresReplicationOriginsCollector{
+		remoteLsn: newBuiltinTypedDesc(
+			descOpts{"postgres", "replication_origin", "remote_lsn_bytes", "Position on the origin server up to which data has been replicated, in bytes.", 0},
+			prometheus.CounterValue,
+			[]string{"origin"}, constLabels,
+			settings.Filters,
+		),
+		localLsn: newBuiltinTypedDesc(
+			descOpts{"postgres", "replication_origin", "local_lsn_bytes", "Local position of the last replicated commit from the origin, in bytes.", 0},
+			prometheus.CounterValue,
+			[]string{"origin"}, constLabels,
+			settings.Filters,
+		),
+	}, nil
+}
+
+// Update method collects statistics, parse it and produces metrics that are sent to Prometheus.
+func (c *postgresReplicationOriginsCollector) Update(config Config, ch chan<- prometheus.Metric) error {
+	conn, err := store.NewPooled(config.scrapeContext(), config.Pool, config.ConnString)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	res, err := conn.Query(postgresReplicationOriginsQuery)
+	if err != nil {
+		return err
+	}
+
+	stats := parsePostgresGenericStats(res, []string{"origin"})
+
+	for _, stat := range stats {
+		// Parser skips NULL values, hence positions are not sent for origins which have not replayed anything yet.
+		if v, ok := stat.values["remote_lsn"]; ok {
+			ch <- c.remoteLsn.newConstMetric(v, stat.labels["origin"])
+		}
+		if v, ok := stat.values["local_lsn"]; ok {
+			ch <- c.localLsn.newConstMetric(v, stat.labels["origin"])
+		}
+	}
+
+	return nil
+}
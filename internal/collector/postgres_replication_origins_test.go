@@ -0,0 +1,49 @@
+package collector
+
+import (
+	"database/sql"
+	"github.com/jackc/pgproto3/v2"
+	"github.com/lesovsky/pgscv/internal/model"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPostgresReplicationOriginsCollector_Update(t *testing.T) {
+	var input = pipelineInput{
+		optional: []string{
+			"postgres_replication_origin_remote_lsn_bytes",
+			"postgres_replication_origin_local_lsn_bytes",
+		},
+		collector: NewPostgresReplicationOriginsCollector,
+		service:   model.ServiceTypePostgresql,
+	}
+
+	pipeline(t, input)
+}
+
+func Test_parsePostgresGenericStats_replicationOrigins(t *testing.T) {
+	res := &model.PGResult{
+		Nrows: 2,
+		Ncols: 3,
+		Colnames: []pgproto3.FieldDescription{
+			{Name: []byte("origin")}, {Name: []byte("remote_lsn")}, {Name: []byte("local_lsn")},
+		},
+		Rows: [][]sql.NullString{
+			{{String: "pg_16395", Valid: true}, {String: "123456789", Valid: true}, {String: "987654321", Valid: true}},
+			{{String: "pg_16396", Valid: true}, {Valid: false}, {Valid: false}},
+		},
+	}
+
+	want := map[string]postgresGenericStat{
+		"pg_16395": {
+			labels: map[string]string{"origin": "pg_16395"},
+			values: map[string]float64{"remote_lsn": 123456789, "local_lsn": 987654321},
+		},
+		"pg_16396": {
+			labels: map[string]string{"origin": "pg_16396"},
+			values: map[string]float64{},
+		},
+	}
+
+	assert.Equal(t, want, parsePostgresGenericStats(res, []string{"origin"}))
+}
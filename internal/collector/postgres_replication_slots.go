@@ -13,13 +13,21 @@ const (
 	// Query for Postgres version 9.6 and older.
 	postgresReplicationSlotQuery96 = "SELECT database, slot_name, slot_type, active, pg_current_xlog_location() - restart_lsn AS since_restart_bytes FROM pg_replication_slots"
 
-	// Query for Postgres versions from 10 and newer.
-	postgresReplicationSlotQueryLatest = "SELECT database, slot_name, slot_type, active, pg_current_wal_lsn() - restart_lsn AS since_restart_bytes FROM pg_replication_slots"
+	// Query for Postgres versions from 10 to 16.
+	postgresReplicationSlotQuery16 = "SELECT database, slot_name, slot_type, active, pg_current_wal_lsn() - restart_lsn AS since_restart_bytes FROM pg_replication_slots"
+
+	// Query for Postgres versions from 17 and newer. Since 17 logical slots could be synchronized to standbys, hence
+	// use replayed WAL location when running in recovery.
+	postgresReplicationSlotQueryLatest = "SELECT database, slot_name, slot_type, active, " +
+		"(case pg_is_in_recovery() when 't' then pg_last_wal_replay_lsn() else pg_current_wal_lsn() end) - restart_lsn AS since_restart_bytes, " +
+		"synced::int AS synced, failover::int AS failover FROM pg_replication_slots"
 )
 
 //
 type postgresReplicationSlotCollector struct {
-	restart typedDesc
+	restart  typedDesc
+	synced   typedDesc
+	failover typedDesc
 }
 
 // NewPostgresReplicationSlotsCollector returns a new Collector exposing postgres replication slots stats.
@@ -32,12 +40,24 @@ func NewPostgresReplicationSlotsCollector(constLabels labels, settings model.Col
 			[]string{"database", "slot_name", "slot_type", "active"}, constLabels,
 			settings.Filters,
 		),
+		synced: newBuiltinTypedDesc(
+			descOpts{"postgres", "replication_slot", "synced", "Whether the slot is synchronized from the primary: 1 is synced, 0 is not synced.", 0},
+			prometheus.GaugeValue,
+			[]string{"database", "slot_name", "slot_type"}, constLabels,
+			settings.Filters,
+		),
+		failover: newBuiltinTypedDesc(
+			descOpts{"postgres", "replication_slot", "failover_enabled", "Whether the slot is enabled to be synced to standbys: 1 is enabled, 0 is disabled.", 0},
+			prometheus.GaugeValue,
+			[]string{"database", "slot_name", "slot_type"}, constLabels,
+			settings.Filters,
+		),
 	}, nil
 }
 
 // Update method collects statistics, parse it and produces metrics that are sent to Prometheus.
 func (c *postgresReplicationSlotCollector) Update(config Config, ch chan<- prometheus.Metric) error {
-	conn, err := store.New(config.ConnString)
+	conn, err := store.NewPooled(config.scrapeContext(), config.Pool, config.ConnString)
 	if err != nil {
 		return err
 	}
@@ -53,6 +73,12 @@ func (c *postgresReplicationSlotCollector) Update(config Config, ch chan<- prome
 
 	for _, stat := range stats {
 		ch <- c.restart.newConstMetric(stat.retainedBytes, stat.database, stat.slotname, stat.slottype, stat.active)
+
+		// Synced and failover are available since Postgres 17.
+		if config.serverVersionNum >= PostgresV17 {
+			ch <- c.synced.newConstMetric(stat.synced, stat.database, stat.slotname, stat.slottype)
+			ch <- c.failover.newConstMetric(stat.failover, stat.database, stat.slotname, stat.slottype)
+		}
 	}
 
 	return nil
@@ -65,6 +91,8 @@ type postgresReplicationSlotStat struct {
 	slottype      string
 	active        string
 	retainedBytes float64
+	synced        float64
+	failover      float64
 }
 
 // parsePostgresReplicationSlotStats parses PGResult and returns struct with stats values.
@@ -121,6 +149,10 @@ func parsePostgresReplicationSlotStats(r *model.PGResult, labelNames []string) m
 			switch string(colname.Name) {
 			case "since_restart_bytes":
 				s.retainedBytes = v
+			case "synced":
+				s.synced = v
+			case "failover":
+				s.failover = v
 			default:
 				continue
 			}
@@ -137,6 +169,8 @@ func selectReplicationSlotQuery(version int) string {
 	switch {
 	case version < PostgresV10:
 		return postgresReplicationSlotQuery96
+	case version < PostgresV17:
+		return postgresReplicationSlotQuery16
 	default:
 		return postgresReplicationSlotQueryLatest
 	}
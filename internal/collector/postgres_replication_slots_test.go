@@ -13,6 +13,8 @@ func TestPostgresReplicationSlotCollector_Update(t *testing.T) {
 		required: []string{},
 		optional: []string{
 			"postgres_replication_slot_wal_retain_bytes",
+			"postgres_replication_slot_synced",
+			"postgres_replication_slot_failover_enabled",
 		},
 		collector: NewPostgresReplicationSlotsCollector,
 		service:   model.ServiceTypePostgresql,
@@ -45,6 +47,31 @@ func Test_parsePostgresReplicationSlotStats(t *testing.T) {
 				"testdb/testslot/testtype": {slotname: "testslot", slottype: "testtype", database: "testdb", active: "t", retainedBytes: 25485425},
 			},
 		},
+		{
+			name: "pg17 output",
+			res: &model.PGResult{
+				Nrows: 2,
+				Ncols: 7,
+				Colnames: []pgproto3.FieldDescription{
+					{Name: []byte("slot_name")}, {Name: []byte("slot_type")}, {Name: []byte("database")}, {Name: []byte("active")}, {Name: []byte("since_restart_bytes")},
+					{Name: []byte("synced")}, {Name: []byte("failover")},
+				},
+				Rows: [][]sql.NullString{
+					{
+						{String: "testslot1", Valid: true}, {String: "logical", Valid: true}, {String: "testdb", Valid: true}, {String: "f", Valid: true}, {String: "1024", Valid: true},
+						{String: "1", Valid: true}, {String: "1", Valid: true},
+					},
+					{
+						{String: "testslot2", Valid: true}, {String: "physical", Valid: true}, {String: "", Valid: false}, {String: "t", Valid: true}, {String: "2048", Valid: true},
+						{String: "0", Valid: true}, {String: "0", Valid: true},
+					},
+				},
+			},
+			want: map[string]postgresReplicationSlotStat{
+				"testdb/testslot1/logical": {slotname: "testslot1", slottype: "logical", database: "testdb", active: "f", retainedBytes: 1024, synced: 1, failover: 1},
+				"/testslot2/physical":      {slotname: "testslot2", slottype: "physical", database: "", active: "t", retainedBytes: 2048, synced: 0, failover: 0},
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -62,8 +89,10 @@ func Test_selectReplicationSlotQuery(t *testing.T) {
 	}{
 		{version: 90600, want: postgresReplicationSlotQuery96},
 		{version: 90605, want: postgresReplicationSlotQuery96},
-		{version: 100000, want: postgresReplicationSlotQueryLatest},
-		{version: 100005, want: postgresReplicationSlotQueryLatest},
+		{version: 100000, want: postgresReplicationSlotQuery16},
+		{version: 100005, want: postgresReplicationSlotQuery16},
+		{version: 160004, want: postgresReplicationSlotQuery16},
+		{version: 170000, want: postgresReplicationSlotQueryLatest},
 	}
 
 	for _, tc := range testcases {
@@ -1,12 +1,12 @@
 package collector
 
 import (
+	"fmt"
 	"github.com/jackc/pgx/v4"
 	"github.com/lesovsky/pgscv/internal/log"
 	"github.com/lesovsky/pgscv/internal/model"
 	"github.com/lesovsky/pgscv/internal/store"
 	"github.com/prometheus/client_golang/prometheus"
-	"golang.org/x/net/context"
 	"strings"
 )
 
@@ -17,13 +17,27 @@ type postgresSchemaCollector struct {
 	invalididx   typedDesc
 	nonidxfkey   typedDesc
 	redundantidx typedDesc
+	unusedidx    typedDesc
 	sequences    typedDesc
 	difftypefkey typedDesc
+	buildingidx  typedDesc
+	idxbloat     typedDesc
+	idxbloatrate typedDesc
+	tblbloat     typedDesc
+	tblbloatrate typedDesc
+	indexBloat   bool  // estimate indexes bloat
+	tableBloat   bool  // estimate tables bloat
+	bloatMinSize int64 // min size of relations for which bloat is estimated
 }
 
 // NewPostgresSchemaCollector returns a new Collector exposing postgres schema stats. Stats are based on different
 // sources inside system catalog.
 func NewPostgresSchemasCollector(constLabels labels, settings model.CollectorSettings) (Collector, error) {
+	bloatMinSize := settings.BloatMinSize
+	if bloatMinSize == 0 {
+		bloatMinSize = DefaultBloatMinSize
+	}
+
 	return &postgresSchemaCollector{
 		syscatalog: newBuiltinTypedDesc(
 			descOpts{"postgres", "schema", "system_catalog_bytes", "Number of bytes occupied by system catalog.", 0},
@@ -55,6 +69,12 @@ func NewPostgresSchemasCollector(constLabels labels, settings model.CollectorSet
 			[]string{"database", "schema", "table", "index", "indexdef", "redundantdef"}, constLabels,
 			settings.Filters,
 		),
+		unusedidx: newBuiltinTypedDesc(
+			descOpts{"postgres", "schema", "unused_indexes_bytes", "Number of bytes occupied by indexes which have not been scanned since stats reset.", 0},
+			prometheus.GaugeValue,
+			[]string{"database", "schema", "table", "index"}, constLabels,
+			settings.Filters,
+		),
 		sequences: newBuiltinTypedDesc(
 			descOpts{"postgres", "schema", "sequence_exhaustion_ratio", "Sequences usage percentage accordingly to attached column, in percent.", 0},
 			prometheus.GaugeValue,
@@ -67,12 +87,45 @@ func NewPostgresSchemasCollector(constLabels labels, settings model.CollectorSet
 			[]string{"database", "schema", "table", "column", "refschema", "reftable", "refcolumn"}, constLabels,
 			settings.Filters,
 		),
+		buildingidx: newBuiltinTypedDesc(
+			descOpts{"postgres", "schema", "indexes_building_total", "Number of invalid indexes, labeled by whether they are being built or left after failed build.", 0},
+			prometheus.GaugeValue,
+			[]string{"database", "state"}, constLabels,
+			settings.Filters,
+		),
+		idxbloat: newBuiltinTypedDesc(
+			descOpts{"postgres", "schema", "index_bloat_bytes", "Estimated number of bytes wasted by index bloat.", 0},
+			prometheus.GaugeValue,
+			[]string{"database", "schema", "table", "index"}, constLabels,
+			settings.Filters,
+		),
+		idxbloatrate: newBuiltinTypedDesc(
+			descOpts{"postgres", "schema", "index_bloat_ratio", "Estimated ratio of index size wasted by bloat.", 0},
+			prometheus.GaugeValue,
+			[]string{"database", "schema", "table", "index"}, constLabels,
+			settings.Filters,
+		),
+		tblbloat: newBuiltinTypedDesc(
+			descOpts{"postgres", "schema", "table_bloat_bytes", "Estimated number of bytes wasted by table bloat.", 0},
+			prometheus.GaugeValue,
+			[]string{"database", "schema", "table"}, constLabels,
+			settings.Filters,
+		),
+		tblbloatrate: newBuiltinTypedDesc(
+			descOpts{"postgres", "schema", "table_bloat_ratio", "Estimated ratio of table size wasted by bloat.", 0},
+			prometheus.GaugeValue,
+			[]string{"database", "schema", "table"}, constLabels,
+			settings.Filters,
+		),
+		indexBloat:   settings.IndexBloat,
+		tableBloat:   settings.TableBloat,
+		bloatMinSize: bloatMinSize,
 	}, nil
 }
 
 // Update method collects statistics, parse it and produces metrics that are sent to Prometheus.
 func (c *postgresSchemaCollector) Update(config Config, ch chan<- prometheus.Metric) error {
-	conn, err := store.New(config.ConnString)
+	conn, err := store.NewPooled(config.scrapeContext(), config.Pool, config.ConnString)
 	if err != nil {
 		return err
 	}
@@ -89,18 +142,19 @@ func (c *postgresSchemaCollector) Update(config Config, ch chan<- prometheus.Met
 		return err
 	}
 
-	// walk through all databases, connect to it and collect schema-specific stats
-	for _, d := range databases {
-		// Skip database if not matched to allowed.
-		if config.DatabasesRE != nil && !config.DatabasesRE.MatchString(d) {
-			continue
-		}
+	// Skip databases which are not matched to allowed or matched to excluded.
+	databases = filterDatabases(databases, config.DatabasesRE, config.DatabasesExcludeRE)
 
-		pgconfig.Database = d
-		conn, err := store.NewWithConfig(pgconfig)
+	// walk through all databases, connect to it and collect schema-specific stats
+	runPerDatabase(databases, config.DatabasesConcurrency, func(d string) {
+		dbconfig := pgconfig.Copy()
+		dbconfig.Database = d
+		conn, err := store.NewPooledWithConfig(config.scrapeContext(), config.Pool, dbconfig)
 		if err != nil {
-			return err
+			log.Warnf("connect to database '%s' failed: %s; skip", d, err)
+			return
 		}
+		defer conn.Close()
 
 		// 1. get system catalog size in bytes.
 		collectSystemCatalogSize(conn, ch, c.syscatalog)
@@ -108,11 +162,18 @@ func (c *postgresSchemaCollector) Update(config Config, ch chan<- prometheus.Met
 		// 2. collect metrics related to tables with no primary/unique key constraints.
 		collectSchemaNonPKTables(conn, ch, c.nonpktables)
 
+		// Bloat estimation requires heavy queries, collect it only if explicitly enabled.
+		if c.indexBloat {
+			collectSchemaIndexBloat(conn, ch, c.idxbloat, c.idxbloatrate, c.bloatMinSize)
+		}
+		if c.tableBloat {
+			collectSchemaTableBloat(conn, ch, c.tblbloat, c.tblbloatrate, c.bloatMinSize)
+		}
+
 		// Functions below uses queries with casting to regnamespace data type, which is introduced in Postgres 9.5.
 		if config.serverVersionNum < PostgresV95 {
 			log.Debugln("[postgres schema collector]: some system data types are not available, required Postgres 9.5 or newer")
-			conn.Close()
-			continue
+			return
 		}
 
 		// 3. collect metrics related to invalid indexes.
@@ -124,21 +185,30 @@ func (c *postgresSchemaCollector) Update(config Config, ch chan<- prometheus.Met
 		// 5. collect metric related to redundant indexes.
 		collectSchemaRedundantIndexes(conn, ch, c.redundantidx)
 
-		// 6. collect metrics related to foreign key constraints with different data types.
+		// 6. collect metric related to unused indexes.
+		collectSchemaUnusedIndexes(conn, ch, c.unusedidx)
+
+		// 7. collect metrics related to foreign key constraints with different data types.
 		collectSchemaFKDatatypeMismatch(conn, ch, c.difftypefkey)
 
 		// Function below uses queries pg_sequences which is introduced in Postgres 10.
 		if config.serverVersionNum < PostgresV10 {
 			log.Debugln("[postgres schema collector]: some system views are not available, required Postgres 10 or newer")
-			conn.Close()
-			continue
+			return
 		}
 
-		// 7. collect metrics related to sequences (available since Postgres 10).
+		// 8. collect metrics related to sequences (available since Postgres 10).
 		collectSchemaSequences(conn, ch, c.sequences)
 
-		conn.Close()
-	}
+		// Function below uses pg_stat_progress_create_index which is introduced in Postgres 12.
+		if config.serverVersionNum < PostgresV12 {
+			log.Debugln("[postgres schema collector]: some system views are not available, required Postgres 12 or newer")
+			return
+		}
+
+		// 9. collect metrics related to invalid indexes which are being built (available since Postgres 12).
+		collectSchemaIndexesBuilding(conn, ch, c.buildingidx)
+	})
 
 	return nil
 }
@@ -161,7 +231,10 @@ func collectSystemCatalogSize(conn *store.DB, ch chan<- prometheus.Metric, desc
 func getSystemCatalogSize(conn *store.DB) (float64, error) {
 	var query = `SELECT sum(pg_total_relation_size(relname::regclass)) AS bytes FROM pg_stat_sys_tables WHERE schemaname = 'pg_catalog'`
 	var size int64 = 0
-	if err := conn.Conn().QueryRow(context.Background(), query).Scan(&size); err != nil {
+	ctx, cancel := conn.Context()
+	defer cancel()
+
+	if err := conn.Conn().QueryRow(ctx, query).Scan(&size); err != nil {
 		return 0, err
 	}
 	return float64(size), nil
@@ -194,7 +267,10 @@ func getSchemaNonPKTables(conn *store.DB) ([]string, error) {
 		"WHERE NOT EXISTS (SELECT 1 FROM pg_index i WHERE c.oid = i.indrelid AND (i.indisprimary OR i.indisunique)) " +
 		"AND c.relkind = 'r' AND n.nspname NOT IN ('pg_catalog', 'information_schema', 'pg_toast')"
 
-	rows, err := conn.Conn().Query(context.Background(), query)
+	ctx, cancel := conn.Context()
+	defer cancel()
+
+	rows, err := conn.Conn().Query(ctx, query)
 	if err != nil {
 		return nil, err
 	}
@@ -257,6 +333,158 @@ func getSchemaInvalidIndexes(conn *store.DB) (map[string]postgresGenericStat, er
 	return parsePostgresGenericStats(res, []string{"schema", "table", "index"}), nil
 }
 
+// collectSchemaIndexesBuilding collects metrics related to invalid indexes which are being built or left after failed build.
+func collectSchemaIndexesBuilding(conn *store.DB, ch chan<- prometheus.Metric, desc typedDesc) {
+	database := conn.Conn().Config().Database
+	stats, err := getSchemaIndexesBuilding(conn)
+	if err != nil {
+		log.Errorf("get building indexes stats of database %s failed: %s; skip", database, err)
+		return
+	}
+
+	for state, value := range stats {
+		ch <- desc.newConstMetric(value, database, state)
+	}
+}
+
+// getSchemaIndexesBuilding searches invalid indexes and cross-references them with indexes which are currently being built.
+func getSchemaIndexesBuilding(conn *store.DB) (map[string]float64, error) {
+	var query = "SELECT c.relnamespace::regnamespace::text AS schema, c.relname AS index, " +
+		"p.pid IS NOT NULL AS building " +
+		"FROM pg_index i JOIN pg_class c ON i.indexrelid = c.oid " +
+		"LEFT JOIN pg_stat_progress_create_index p ON p.index_relid = i.indexrelid " +
+		"AND p.datid = (SELECT oid FROM pg_database WHERE datname = current_database()) " +
+		"WHERE NOT i.indisvalid"
+
+	res, err := conn.Query(query)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseSchemaIndexesBuilding(res), nil
+}
+
+// parseSchemaIndexesBuilding parses PGResult and returns number of invalid indexes per state: 'building' for indexes
+// which are currently being built, and 'failed' for indexes left after failed build.
+func parseSchemaIndexesBuilding(r *model.PGResult) map[string]float64 {
+	log.Debug("parse postgres building indexes stats")
+
+	var stats = map[string]float64{"building": 0, "failed": 0}
+
+	for _, row := range r.Rows {
+		for i, colname := range r.Colnames {
+			if string(colname.Name) != "building" {
+				continue
+			}
+
+			if !row[i].Valid {
+				continue
+			}
+
+			switch row[i].String {
+			case "true", "t":
+				stats["building"]++
+			case "false", "f":
+				stats["failed"]++
+			default:
+				log.Warnf("invalid input for building state: '%s'; skip", row[i].String)
+			}
+		}
+	}
+
+	return stats
+}
+
+// collectSchemaIndexBloat collects metrics related to estimated bloat of btree indexes.
+func collectSchemaIndexBloat(conn *store.DB, ch chan<- prometheus.Metric, bytesDesc, ratioDesc typedDesc, minsize int64) {
+	database := conn.Conn().Config().Database
+	stats, err := getSchemaIndexBloat(conn, minsize)
+	if err != nil {
+		log.Errorf("get indexes bloat stats of database %s failed: %s; skip", database, err)
+		return
+	}
+
+	for k, s := range stats {
+		var (
+			schema = s.labels["schema"]
+			table  = s.labels["table"]
+			index  = s.labels["index"]
+		)
+
+		if schema == "" || table == "" || index == "" {
+			log.Warnf("incomplete index FQ name: %s; skip", k)
+			continue
+		}
+
+		ch <- bytesDesc.newConstMetric(s.values["bloat_bytes"], database, schema, table, index)
+		ch <- ratioDesc.newConstMetric(s.values["bloat_ratio"], database, schema, table, index)
+	}
+}
+
+// getSchemaIndexBloat estimates bloat of btree indexes which are larger than minsize. Estimation is based on
+// pg_class and pg_stats statistics, see https://github.com/ioguix/pgsql-bloat-estimation for details.
+func getSchemaIndexBloat(conn *store.DB, minsize int64) (map[string]postgresGenericStat, error) {
+	res, err := conn.Query(fmt.Sprintf(postgresIndexBloatQuery, minsize))
+	if err != nil {
+		return nil, err
+	}
+
+	return parsePostgresGenericStats(res, []string{"schema", "table", "index"}), nil
+}
+
+// collectSchemaTableBloat collects metrics related to estimated bloat of tables.
+func collectSchemaTableBloat(conn *store.DB, ch chan<- prometheus.Metric, bytesDesc, ratioDesc typedDesc, minsize int64) {
+	database := conn.Conn().Config().Database
+	stats, err := getSchemaTableBloat(conn, minsize)
+	if err != nil {
+		log.Errorf("get tables bloat stats of database %s failed: %s; skip", database, err)
+		return
+	}
+
+	for k, s := range stats {
+		var (
+			schema = s.labels["schema"]
+			table  = s.labels["table"]
+		)
+
+		if schema == "" || table == "" {
+			log.Warnf("incomplete table FQ name: %s; skip", k)
+			continue
+		}
+
+		ch <- bytesDesc.newConstMetric(s.values["bloat_bytes"], database, schema, table)
+		ch <- ratioDesc.newConstMetric(s.values["bloat_ratio"], database, schema, table)
+	}
+}
+
+// getSchemaTableBloat estimates bloat of tables which are larger than minsize. Estimation is based on pg_class and
+// pg_stats statistics, see https://github.com/ioguix/pgsql-bloat-estimation for details.
+func getSchemaTableBloat(conn *store.DB, minsize int64) (map[string]postgresGenericStat, error) {
+	res, err := conn.Query(fmt.Sprintf(postgresTableBloatQuery, minsize))
+	if err != nil {
+		return nil, err
+	}
+
+	return parseSchemaTableBloat(res), nil
+}
+
+// parseSchemaTableBloat parses PGResult and returns tables bloat stats. Tables without enough statistics (e.g. not
+// analyzed yet) are skipped, because estimation for them is not reliable.
+func parseSchemaTableBloat(r *model.PGResult) map[string]postgresGenericStat {
+	log.Debug("parse postgres tables bloat stats")
+
+	stats := parsePostgresGenericStats(r, []string{"schema", "table"})
+
+	for k, s := range stats {
+		if s.values["is_na"] > 0 {
+			log.Debugf("not enough statistics for estimating bloat of table %s; skip", k)
+			delete(stats, k)
+		}
+	}
+
+	return stats
+}
+
 // collectSchemaNonIndexedFK collects metrics related to non indexed foreign key constraints.
 func collectSchemaNonIndexedFK(conn *store.DB, ch chan<- prometheus.Metric, desc typedDesc) {
 	database := conn.Conn().Config().Database
@@ -355,6 +583,69 @@ func getSchemaRedundantIndexes(conn *store.DB) (map[string]postgresGenericStat,
 	return parsePostgresGenericStats(res, []string{"schema", "table", "index", "indexdef", "redundantdef"}), nil
 }
 
+// unusedIndexesMinSize defines minimal size of unused indexes (in bytes) which are exposed. Small indexes are not
+// worth to drop, hence they are skipped to avoid metrics spam.
+const unusedIndexesMinSize = 1024 * 1024
+
+// collectSchemaUnusedIndexes collects metrics related to unused indexes.
+func collectSchemaUnusedIndexes(conn *store.DB, ch chan<- prometheus.Metric, desc typedDesc) {
+	database := conn.Conn().Config().Database
+	stats, err := getSchemaUnusedIndexes(conn)
+	if err != nil {
+		log.Errorf("get unused indexes stats of database %s failed: %s; skip", database, err)
+		return
+	}
+
+	for k, s := range stats {
+		var (
+			schema = s.labels["schema"]
+			table  = s.labels["table"]
+			index  = s.labels["index"]
+			value  = s.values["bytes"]
+		)
+
+		if schema == "" || table == "" || index == "" {
+			log.Warnf("incomplete unused index FQ name: %s; skip", k)
+			continue
+		}
+
+		ch <- desc.newConstMetric(value, database, schema, table, index)
+	}
+}
+
+// getSchemaUnusedIndexes searches indexes which have not been scanned since stats reset and returns its sizes. Unique
+// indexes and indexes which support constraints are not considered, because they are required regardless of scans.
+func getSchemaUnusedIndexes(conn *store.DB) (map[string]postgresGenericStat, error) {
+	var query = "SELECT s.schemaname AS schema, s.relname AS table, s.indexrelname AS index, s.idx_scan, " +
+		"pg_relation_size(s.indexrelid) AS bytes " +
+		"FROM pg_stat_user_indexes s JOIN pg_index i ON s.indexrelid = i.indexrelid " +
+		"WHERE i.indisvalid AND NOT i.indisunique AND NOT i.indisprimary " +
+		"AND NOT EXISTS (SELECT 1 FROM pg_constraint c WHERE c.conindid = s.indexrelid) " +
+		"AND NOT EXISTS (SELECT 1 FROM pg_locks WHERE relation = s.indexrelid AND mode = 'AccessExclusiveLock' AND granted)"
+
+	res, err := conn.Query(query)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseSchemaUnusedIndexes(res, unusedIndexesMinSize), nil
+}
+
+// parseSchemaUnusedIndexes parses PGResult and returns stats of indexes with zero scans which are larger than minsize.
+func parseSchemaUnusedIndexes(r *model.PGResult, minsize float64) map[string]postgresGenericStat {
+	log.Debug("parse postgres unused indexes stats")
+
+	stats := parsePostgresGenericStats(r, []string{"schema", "table", "index"})
+
+	for k, s := range stats {
+		if s.values["idx_scan"] > 0 || s.values["bytes"] < minsize {
+			delete(stats, k)
+		}
+	}
+
+	return stats
+}
+
 // collectSchemaSequences collects metrics related to sequences attached to poor-typed columns.
 func collectSchemaSequences(conn *store.DB, ch chan<- prometheus.Metric, desc typedDesc) {
 	database := conn.Conn().Config().Database
@@ -438,3 +729,86 @@ func getSchemaFKDatatypeMismatch(conn *store.DB) (map[string]postgresGenericStat
 
 	return parsePostgresGenericStats(res, []string{"schema", "table", "column", "refschema", "reftable", "refcolumn"}), nil
 }
+
+// postgresIndexBloatQuery defines query for estimating btree indexes bloat. The query has single placeholder for
+// minimal size of indexes which have to be estimated.
+const postgresIndexBloatQuery = "SELECT nspname AS schema, tblname AS table, idxname AS index, " +
+	"CASE WHEN relpages > est_pages_ff THEN bs * (relpages - est_pages_ff) ELSE 0 END AS bloat_bytes, " +
+	"CASE WHEN relpages > est_pages_ff THEN (relpages - est_pages_ff)::float / relpages ELSE 0 END AS bloat_ratio " +
+	"FROM (" +
+	"SELECT coalesce(1 + ceil(reltuples / floor((bs - pageopqdata - pagehdr) * fillfactor / (100 * (4 + nulldatahdrwidth)::float))), 0) AS est_pages_ff, " +
+	"bs, nspname, tblname, idxname, relpages, is_na " +
+	"FROM (" +
+	"SELECT maxalign, bs, nspname, tblname, idxname, reltuples, relpages, fillfactor, pagehdr, pageopqdata, is_na, " +
+	"(index_tuple_hdr_bm + maxalign - CASE WHEN mod(index_tuple_hdr_bm, maxalign) = 0 THEN maxalign ELSE mod(index_tuple_hdr_bm, maxalign) END " +
+	"+ nulldatawidth + maxalign - CASE WHEN nulldatawidth = 0 THEN 0 WHEN mod(nulldatawidth::integer, maxalign) = 0 THEN maxalign ELSE mod(nulldatawidth::integer, maxalign) END)::numeric AS nulldatahdrwidth " +
+	"FROM (" +
+	"SELECT n.nspname, i.tblname, i.idxname, i.reltuples, i.relpages, i.idxoid, i.fillfactor, " +
+	"current_setting('block_size')::numeric AS bs, " +
+	"CASE WHEN version() ~ 'mingw32' OR version() ~ '64-bit|x86_64|ppc64|ia64|amd64' THEN 8 ELSE 4 END AS maxalign, " +
+	"24 AS pagehdr, 16 AS pageopqdata, " +
+	"CASE WHEN max(coalesce(s.null_frac, 0)) = 0 THEN 8 ELSE 8 + ((32 + 8 - 1) / 8) END AS index_tuple_hdr_bm, " +
+	"sum((1 - coalesce(s.null_frac, 0)) * coalesce(s.avg_width, 1024)) AS nulldatawidth, " +
+	"max(CASE WHEN i.atttypid = 'pg_catalog.name'::regtype THEN 1 ELSE 0 END) > 0 AS is_na " +
+	"FROM (" +
+	"SELECT ct.relname AS tblname, ct.relnamespace, ic.idxname, ic.reltuples, ic.relpages, ic.idxoid, ic.fillfactor, " +
+	"coalesce(a1.attname, a2.attname) AS attname, coalesce(a1.atttypid, a2.atttypid) AS atttypid, " +
+	"CASE WHEN a1.attnum IS NULL THEN ic.idxname ELSE ct.relname END AS attrelname " +
+	"FROM (" +
+	"SELECT idxname, reltuples, relpages, tbloid, idxoid, fillfactor, indkey, generate_series(1, indnatts) AS attpos " +
+	"FROM (" +
+	"SELECT ci.relname AS idxname, ci.reltuples, ci.relpages, i.indrelid AS tbloid, i.indexrelid AS idxoid, " +
+	"coalesce(substring(array_to_string(ci.reloptions, ' ') FROM 'fillfactor=([0-9]+)')::smallint, 90) AS fillfactor, " +
+	"i.indnatts, string_to_array(textin(int2vectorout(i.indkey)), ' ')::int[] AS indkey " +
+	"FROM pg_index i JOIN pg_class ci ON ci.oid = i.indexrelid " +
+	"WHERE ci.relam = (SELECT oid FROM pg_am WHERE amname = 'btree') AND ci.relpages > 0 AND pg_relation_size(ci.oid) >= %d" +
+	") AS idx_data" +
+	") AS ic " +
+	"JOIN pg_class ct ON ct.oid = ic.tbloid " +
+	"LEFT JOIN pg_attribute a1 ON ic.indkey[ic.attpos] <> 0 AND a1.attrelid = ic.tbloid AND a1.attnum = ic.indkey[ic.attpos] " +
+	"LEFT JOIN pg_attribute a2 ON ic.indkey[ic.attpos] = 0 AND a2.attrelid = ic.idxoid AND a2.attnum = ic.attpos" +
+	") AS i " +
+	"JOIN pg_namespace n ON n.oid = i.relnamespace " +
+	"JOIN pg_stats s ON s.schemaname = n.nspname AND s.tablename = i.attrelname AND s.attname = i.attname " +
+	"GROUP BY 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11" +
+	") AS rows_data_stats" +
+	") AS rows_hdr_pdg_stats" +
+	") AS relation_stats " +
+	"WHERE NOT is_na AND nspname NOT IN ('pg_catalog', 'information_schema')"
+
+// postgresTableBloatQuery defines query for estimating tables bloat. The query has single placeholder for minimal size
+// of tables which have to be estimated. Tables with columns which have no statistics are marked with 'is_na'.
+const postgresTableBloatQuery = "SELECT schema, \"table\", " +
+	"CASE WHEN tblpages - est_tblpages_ff > 0 THEN (tblpages - est_tblpages_ff) * bs ELSE 0 END AS bloat_bytes, " +
+	"CASE WHEN tblpages > 0 AND tblpages - est_tblpages_ff > 0 THEN (tblpages - est_tblpages_ff) / tblpages::float ELSE 0 END AS bloat_ratio, " +
+	"is_na::int AS is_na " +
+	"FROM (" +
+	"SELECT ceil(reltuples / ((bs - page_hdr) * fillfactor / (tpl_size * 100))) + ceil(toasttuples / 4) AS est_tblpages_ff, " +
+	"tblpages, bs, schema, \"table\", is_na " +
+	"FROM (" +
+	"SELECT (4 + tpl_hdr_size + tpl_data_size + (2 * ma) " +
+	"- CASE WHEN mod(tpl_hdr_size, ma) = 0 THEN ma ELSE mod(tpl_hdr_size, ma) END " +
+	"- CASE WHEN mod(ceil(tpl_data_size)::int, ma) = 0 THEN ma ELSE mod(ceil(tpl_data_size)::int, ma) END) AS tpl_size, " +
+	"(heappages + toastpages) AS tblpages, reltuples, toasttuples, bs, page_hdr, schema, \"table\", fillfactor, is_na " +
+	"FROM (" +
+	"SELECT tbl.oid AS tblid, ns.nspname AS schema, tbl.relname AS \"table\", tbl.reltuples, " +
+	"tbl.relpages AS heappages, coalesce(toast.relpages, 0) AS toastpages, coalesce(toast.reltuples, 0) AS toasttuples, " +
+	"coalesce(substring(array_to_string(tbl.reloptions, ' ') FROM 'fillfactor=([0-9]+)')::smallint, 100) AS fillfactor, " +
+	"current_setting('block_size')::numeric AS bs, " +
+	"CASE WHEN version() ~ 'mingw32' OR version() ~ '64-bit|x86_64|ppc64|ia64|amd64' THEN 8 ELSE 4 END AS ma, " +
+	"24 AS page_hdr, " +
+	"23 + CASE WHEN max(coalesce(s.null_frac, 0)) > 0 THEN (7 + count(s.attname)) / 8 ELSE 0::int END " +
+	"+ CASE WHEN bool_or(att.attname = 'oid' AND att.attnum < 0) THEN 4 ELSE 0 END AS tpl_hdr_size, " +
+	"sum((1 - coalesce(s.null_frac, 0)) * coalesce(s.avg_width, 0)) AS tpl_data_size, " +
+	"bool_or(att.atttypid = 'pg_catalog.name'::regtype) OR sum(CASE WHEN att.attnum > 0 THEN 1 ELSE 0 END) <> count(s.attname) AS is_na " +
+	"FROM pg_attribute att " +
+	"JOIN pg_class tbl ON att.attrelid = tbl.oid " +
+	"JOIN pg_namespace ns ON ns.oid = tbl.relnamespace " +
+	"LEFT JOIN pg_stats s ON s.schemaname = ns.nspname AND s.tablename = tbl.relname AND s.inherited = false AND s.attname = att.attname " +
+	"LEFT JOIN pg_class toast ON tbl.reltoastrelid = toast.oid " +
+	"WHERE NOT att.attisdropped AND tbl.relkind IN ('r', 'm') AND tbl.relpages > 0 " +
+	"AND ns.nspname NOT IN ('pg_catalog', 'information_schema') AND pg_relation_size(tbl.oid) >= %d " +
+	"GROUP BY 1, 2, 3, 4, 5, 6, 7, 8, 9, 10" +
+	") AS s" +
+	") AS s2" +
+	") AS s3"
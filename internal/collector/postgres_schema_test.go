@@ -2,6 +2,8 @@ package collector
 
 import (
 	"context"
+	"database/sql"
+	"github.com/jackc/pgproto3/v2"
 	"github.com/lesovsky/pgscv/internal/model"
 	"github.com/lesovsky/pgscv/internal/store"
 	"github.com/stretchr/testify/assert"
@@ -19,6 +21,10 @@ func TestPostgresSchemaCollector_Update(t *testing.T) {
 			"postgres_schema_sequence_exhaustion_ratio",
 			"postgres_schema_mistyped_fkeys",
 		},
+		optional: []string{
+			"postgres_schema_indexes_building_total",
+			"postgres_schema_unused_indexes_bytes",
+		},
 		collector: NewPostgresSchemasCollector,
 		service:   model.ServiceTypePostgresql,
 	}
@@ -26,6 +32,21 @@ func TestPostgresSchemaCollector_Update(t *testing.T) {
 	pipeline(t, input)
 }
 
+func TestNewPostgresSchemasCollector(t *testing.T) {
+	// Bloat estimation is disabled by default.
+	c, err := NewPostgresSchemasCollector(labels{}, model.CollectorSettings{})
+	assert.NoError(t, err)
+	assert.False(t, c.(*postgresSchemaCollector).indexBloat)
+	assert.False(t, c.(*postgresSchemaCollector).tableBloat)
+	assert.Equal(t, int64(DefaultBloatMinSize), c.(*postgresSchemaCollector).bloatMinSize)
+
+	c, err = NewPostgresSchemasCollector(labels{}, model.CollectorSettings{IndexBloat: true, TableBloat: true, BloatMinSize: 1048576})
+	assert.NoError(t, err)
+	assert.True(t, c.(*postgresSchemaCollector).indexBloat)
+	assert.True(t, c.(*postgresSchemaCollector).tableBloat)
+	assert.Equal(t, int64(1048576), c.(*postgresSchemaCollector).bloatMinSize)
+}
+
 func Test_getSystemCatalogSize(t *testing.T) {
 	conn := store.NewTest(t)
 	got, err := getSystemCatalogSize(conn)
@@ -86,6 +107,54 @@ func Test_getSchemaRedundantIndexes(t *testing.T) {
 	assert.Equal(t, 0, len(got))
 }
 
+func Test_getSchemaUnusedIndexes(t *testing.T) {
+	conn := store.NewTest(t)
+	_, err := getSchemaUnusedIndexes(conn)
+	assert.NoError(t, err)
+
+	_ = conn.Conn().Close(context.Background())
+	got, err := getSchemaUnusedIndexes(conn)
+	assert.Error(t, err)
+	assert.Equal(t, 0, len(got))
+}
+
+func Test_parseSchemaUnusedIndexes(t *testing.T) {
+	res := &model.PGResult{
+		Nrows: 3,
+		Ncols: 5,
+		Colnames: []pgproto3.FieldDescription{
+			{Name: []byte("schema")}, {Name: []byte("table")}, {Name: []byte("index")},
+			{Name: []byte("idx_scan")}, {Name: []byte("bytes")},
+		},
+		Rows: [][]sql.NullString{
+			{
+				{String: "public", Valid: true}, {String: "orders", Valid: true}, {String: "orders_status_idx", Valid: true},
+				{String: "0", Valid: true}, {String: "16777216", Valid: true},
+			},
+			{
+				{String: "public", Valid: true}, {String: "orders", Valid: true}, {String: "orders_name_idx", Valid: true},
+				{String: "125", Valid: true}, {String: "16777216", Valid: true},
+			},
+			{
+				{String: "public", Valid: true}, {String: "orders", Valid: true}, {String: "orders_created_idx", Valid: true},
+				{String: "0", Valid: true}, {String: "8192", Valid: true},
+			},
+		},
+	}
+
+	want := map[string]postgresGenericStat{
+		"public/orders/orders_status_idx": {
+			labels: map[string]string{"schema": "public", "table": "orders", "index": "orders_status_idx"},
+			values: map[string]float64{"idx_scan": 0, "bytes": 16777216},
+		},
+	}
+
+	assert.Equal(t, want, parseSchemaUnusedIndexes(res, unusedIndexesMinSize))
+
+	// All indexes with zero scans are returned if there is no size threshold.
+	assert.Len(t, parseSchemaUnusedIndexes(res, 0), 2)
+}
+
 func Test_getSchemaSequences(t *testing.T) {
 	conn := store.NewTest(t)
 	got, err := getSchemaSequences(conn)
@@ -109,3 +178,174 @@ func Test_getSchemaFKDatatypeMismatch(t *testing.T) {
 	assert.Error(t, err)
 	assert.Equal(t, 0, len(got))
 }
+
+func Test_parseSchemaIndexesBuilding(t *testing.T) {
+	var testCases = []struct {
+		name string
+		res  *model.PGResult
+		want map[string]float64
+	}{
+		{
+			name: "normal output",
+			res: &model.PGResult{
+				Nrows:    4,
+				Ncols:    3,
+				Colnames: []pgproto3.FieldDescription{{Name: []byte("schema")}, {Name: []byte("index")}, {Name: []byte("building")}},
+				Rows: [][]sql.NullString{
+					{{String: "public", Valid: true}, {String: "idx1", Valid: true}, {String: "true", Valid: true}},
+					{{String: "public", Valid: true}, {String: "idx2", Valid: true}, {String: "false", Valid: true}},
+					{{String: "public", Valid: true}, {String: "idx3", Valid: true}, {String: "f", Valid: true}},
+					{{String: "public", Valid: true}, {String: "idx4", Valid: true}, {String: "invalid", Valid: true}}, // this should be ignored, but logged
+				},
+			},
+			want: map[string]float64{"building": 1, "failed": 2},
+		},
+		{
+			name: "no invalid indexes",
+			res: &model.PGResult{
+				Nrows:    0,
+				Ncols:    3,
+				Colnames: []pgproto3.FieldDescription{{Name: []byte("schema")}, {Name: []byte("index")}, {Name: []byte("building")}},
+				Rows:     [][]sql.NullString{},
+			},
+			want: map[string]float64{"building": 0, "failed": 0},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := parseSchemaIndexesBuilding(tc.res)
+			assert.EqualValues(t, tc.want, got)
+		})
+	}
+}
+
+func Test_getSchemaIndexBloat(t *testing.T) {
+	conn := store.NewTest(t)
+	_, err := getSchemaIndexBloat(conn, 0)
+	assert.NoError(t, err)
+
+	_ = conn.Conn().Close(context.Background())
+	got, err := getSchemaIndexBloat(conn, 0)
+	assert.Error(t, err)
+	assert.Equal(t, 0, len(got))
+}
+
+func Test_parseSchemaIndexBloat(t *testing.T) {
+	res := &model.PGResult{
+		Nrows: 2,
+		Ncols: 5,
+		Colnames: []pgproto3.FieldDescription{
+			{Name: []byte("schema")}, {Name: []byte("table")}, {Name: []byte("index")},
+			{Name: []byte("bloat_bytes")}, {Name: []byte("bloat_ratio")},
+		},
+		Rows: [][]sql.NullString{
+			{
+				{String: "public", Valid: true}, {String: "orders", Valid: true}, {String: "orders_pkey", Valid: true},
+				{String: "41943040", Valid: true}, {String: "0.625", Valid: true},
+			},
+			{
+				{String: "public", Valid: true}, {String: "orders", Valid: true}, {String: "orders_created_idx", Valid: true},
+				{String: "0", Valid: true}, {String: "0", Valid: true},
+			},
+		},
+	}
+
+	want := map[string]postgresGenericStat{
+		"public/orders/orders_pkey": {
+			labels: map[string]string{"schema": "public", "table": "orders", "index": "orders_pkey"},
+			values: map[string]float64{"bloat_bytes": 41943040, "bloat_ratio": 0.625},
+		},
+		"public/orders/orders_created_idx": {
+			labels: map[string]string{"schema": "public", "table": "orders", "index": "orders_created_idx"},
+			values: map[string]float64{"bloat_bytes": 0, "bloat_ratio": 0},
+		},
+	}
+
+	assert.EqualValues(t, want, parsePostgresGenericStats(res, []string{"schema", "table", "index"}))
+}
+
+func Test_getSchemaTableBloat(t *testing.T) {
+	conn := store.NewTest(t)
+	_, err := getSchemaTableBloat(conn, 0)
+	assert.NoError(t, err)
+
+	_ = conn.Conn().Close(context.Background())
+	got, err := getSchemaTableBloat(conn, 0)
+	assert.Error(t, err)
+	assert.Equal(t, 0, len(got))
+}
+
+func Test_parseSchemaTableBloat(t *testing.T) {
+	var testCases = []struct {
+		name string
+		res  *model.PGResult
+		want map[string]postgresGenericStat
+	}{
+		{
+			name: "normal output",
+			res: &model.PGResult{
+				Nrows: 2,
+				Ncols: 5,
+				Colnames: []pgproto3.FieldDescription{
+					{Name: []byte("schema")}, {Name: []byte("table")},
+					{Name: []byte("bloat_bytes")}, {Name: []byte("bloat_ratio")}, {Name: []byte("is_na")},
+				},
+				Rows: [][]sql.NullString{
+					{
+						{String: "public", Valid: true}, {String: "orders", Valid: true},
+						{String: "83886080", Valid: true}, {String: "0.4", Valid: true}, {String: "0", Valid: true},
+					},
+					{
+						{String: "public", Valid: true}, {String: "users", Valid: true},
+						{String: "0", Valid: true}, {String: "0", Valid: true}, {String: "0", Valid: true},
+					},
+				},
+			},
+			want: map[string]postgresGenericStat{
+				"public/orders": {
+					labels: map[string]string{"schema": "public", "table": "orders"},
+					values: map[string]float64{"bloat_bytes": 83886080, "bloat_ratio": 0.4, "is_na": 0},
+				},
+				"public/users": {
+					labels: map[string]string{"schema": "public", "table": "users"},
+					values: map[string]float64{"bloat_bytes": 0, "bloat_ratio": 0, "is_na": 0},
+				},
+			},
+		},
+		{
+			name: "not enough statistics",
+			res: &model.PGResult{
+				Nrows: 2,
+				Ncols: 5,
+				Colnames: []pgproto3.FieldDescription{
+					{Name: []byte("schema")}, {Name: []byte("table")},
+					{Name: []byte("bloat_bytes")}, {Name: []byte("bloat_ratio")}, {Name: []byte("is_na")},
+				},
+				Rows: [][]sql.NullString{
+					{
+						{String: "public", Valid: true}, {String: "orders", Valid: true},
+						{String: "83886080", Valid: true}, {String: "0.4", Valid: true}, {String: "0", Valid: true},
+					},
+					{
+						{String: "public", Valid: true}, {String: "not_analyzed", Valid: true},
+						{String: "104857600", Valid: true}, {String: "0.9", Valid: true}, {String: "1", Valid: true},
+					},
+				},
+			},
+			want: map[string]postgresGenericStat{
+				"public/orders": {
+					labels: map[string]string{"schema": "public", "table": "orders"},
+					values: map[string]float64{"bloat_bytes": 83886080, "bloat_ratio": 0.4, "is_na": 0},
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := parseSchemaTableBloat(tc.res)
+			assert.EqualValues(t, tc.want, got)
+		})
+	}
+}
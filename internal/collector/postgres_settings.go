@@ -12,10 +12,19 @@ import (
 	"strings"
 )
 
+// postgresNumericSettings defines list of important numeric settings exposed as gauges.
+var postgresNumericSettings = []string{
+	"shared_buffers", "work_mem", "maintenance_work_mem", "effective_cache_size",
+	"max_connections", "autovacuum_max_workers",
+}
+
 // postgresSettingsCollector defines metric descriptors and stats store.
 type postgresSettingsCollector struct {
-	settings typedDesc
-	files    typedDesc
+	settings       typedDesc
+	files          typedDesc
+	changed        typedDesc
+	pendingRestart typedDesc
+	values         typedDesc
 }
 
 // NewPostgresSettingsCollector returns a new Collector exposing postgres settings stats.
@@ -35,12 +44,30 @@ func NewPostgresSettingsCollector(constLabels labels, settings model.CollectorSe
 			[]string{"guc", "mode", "path"}, constLabels,
 			settings.Filters,
 		),
+		changed: newBuiltinTypedDesc(
+			descOpts{"postgres", "settings", "info", "Labeled information about Postgres settings changed from built-in defaults.", 0},
+			prometheus.GaugeValue,
+			[]string{"name", "setting", "boot_val", "source", "pending_restart"}, constLabels,
+			settings.Filters,
+		),
+		pendingRestart: newBuiltinTypedDesc(
+			descOpts{"postgres", "settings", "pending_restart", "Number of settings changed in configuration files which require restart to be applied.", 0},
+			prometheus.GaugeValue,
+			nil, constLabels,
+			settings.Filters,
+		),
+		values: newBuiltinTypedDesc(
+			descOpts{"postgres", "setting", "value", "Value of Postgres numeric setting, normalized to base unit.", 0},
+			prometheus.GaugeValue,
+			[]string{"name", "unit"}, constLabels,
+			settings.Filters,
+		),
 	}, nil
 }
 
 // Update method collects statistics, parse it and produces metrics that are sent to Prometheus.
 func (c *postgresSettingsCollector) Update(config Config, ch chan<- prometheus.Metric) error {
-	conn, err := store.New(config.ConnString)
+	conn, err := store.NewPooled(config.scrapeContext(), config.Pool, config.ConnString)
 	if err != nil {
 		return err
 	}
@@ -60,6 +87,38 @@ func (c *postgresSettingsCollector) Update(config Config, ch chan<- prometheus.M
 		ch <- c.settings.newConstMetric(s.value, s.name, s.setting, s.unit, s.vartype, "main")
 	}
 
+	query = "SELECT name, setting, unit, vartype FROM pg_show_all_settings() WHERE name IN ('" +
+		strings.Join(postgresNumericSettings, "','") + "')"
+	res, err = conn.Query(query)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range parsePostgresSettings(res) {
+		ch <- c.values.newConstMetric(s.value, s.name, numericSettingUnit(s.unit))
+	}
+
+	// Settings which have been set by client or within session are skipped, because these are settings
+	// of pgSCV's own session.
+	query = "SELECT name, setting, boot_val, source, pending_restart FROM pg_settings " +
+		"WHERE source NOT IN ('default','client','session') OR pending_restart"
+	res, err = conn.Query(query)
+	if err != nil {
+		return err
+	}
+
+	changed := parsePostgresChangedSettings(res)
+
+	var pending float64
+	for _, s := range changed {
+		if s.pendingRestart {
+			pending++
+		}
+		ch <- c.changed.newConstMetric(1, s.name, s.setting, s.bootVal, s.source, strconv.FormatBool(s.pendingRestart))
+	}
+
+	ch <- c.pendingRestart.newConstMetric(pending)
+
 	// Collecting metrics about filesystem attributes of configuration files, requires
 	// direct access to filesystem, which is impossible for remote services. If service
 	// is remote, stop here and return.
@@ -198,6 +257,55 @@ func newPostgresSetting(name, setting, unit, vartype string) (postgresSetting, e
 	}
 }
 
+// numericSettingUnit returns unit label value of numeric setting. Settings without unit are counters of something, e.g.
+// number of connections or workers.
+func numericSettingUnit(unit string) string {
+	if unit == "" {
+		return "count"
+	}
+	return unit
+}
+
+// postgresChangedSetting describes setting changed from its built-in default value.
+type postgresChangedSetting struct {
+	name           string // pg_settings.name
+	setting        string // pg_settings.setting
+	bootVal        string // pg_settings.boot_val
+	source         string // pg_settings.source
+	pendingRestart bool   // pg_settings.pending_restart
+}
+
+// parsePostgresChangedSettings parses PGResult and returns structs with changed settings data.
+func parsePostgresChangedSettings(r *model.PGResult) []postgresChangedSetting {
+	log.Debug("parse postgres changed settings")
+
+	var settings []postgresChangedSetting
+
+	for _, row := range r.Rows {
+		if len(row) != 5 {
+			log.Warnln("invalid input, wrong number of columns; skip")
+			continue
+		}
+
+		// Important: order of items depends on order of columns in SELECT statement.
+		pending, err := strconv.ParseBool(row[4].String)
+		if err != nil {
+			log.Warnf("invalid pending_restart value '%s' of setting %s: %s; skip", row[4].String, row[0].String, err)
+			continue
+		}
+
+		settings = append(settings, postgresChangedSetting{
+			name:           row[0].String,
+			setting:        row[1].String,
+			bootVal:        row[2].String,
+			source:         row[3].String,
+			pendingRestart: pending,
+		})
+	}
+
+	return settings
+}
+
 // postgresFile describes various info about Postgres system files.
 type postgresFile struct {
 	path string
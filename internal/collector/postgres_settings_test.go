@@ -14,6 +14,9 @@ func TestPostgresSettingsCollector_Update(t *testing.T) {
 		required: []string{
 			"postgres_service_settings_info",
 			"postgres_service_files_info",
+			"postgres_settings_info",
+			"postgres_settings_pending_restart",
+			"postgres_setting_value",
 		},
 		collector: NewPostgresSettingsCollector,
 		service:   model.ServiceTypePostgresql,
@@ -56,6 +59,41 @@ func Test_parsePostgresSettings(t *testing.T) {
 	}
 }
 
+func Test_parsePostgresChangedSettings(t *testing.T) {
+	var testCases = []struct {
+		name string
+		res  *model.PGResult
+		want []postgresChangedSetting
+	}{
+		{
+			name: "normal output",
+			res: &model.PGResult{
+				Nrows: 3,
+				Ncols: 5,
+				Colnames: []pgproto3.FieldDescription{
+					{Name: []byte("name")}, {Name: []byte("setting")}, {Name: []byte("boot_val")}, {Name: []byte("source")}, {Name: []byte("pending_restart")},
+				},
+				Rows: [][]sql.NullString{
+					{{String: "max_connections", Valid: true}, {String: "100", Valid: true}, {String: "100", Valid: true}, {String: "configuration file", Valid: true}, {String: "t", Valid: true}},
+					{{String: "work_mem", Valid: true}, {String: "16384", Valid: true}, {String: "4096", Valid: true}, {String: "configuration file", Valid: true}, {String: "f", Valid: true}},
+					{{String: "shared_buffers", Valid: true}, {String: "16384", Valid: true}, {String: "1024", Valid: true}, {String: "configuration file", Valid: true}, {String: "invalid", Valid: true}},
+				},
+			},
+			want: []postgresChangedSetting{
+				{name: "max_connections", setting: "100", bootVal: "100", source: "configuration file", pendingRestart: true},
+				{name: "work_mem", setting: "16384", bootVal: "4096", source: "configuration file", pendingRestart: false},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := parsePostgresChangedSettings(tc.res)
+			assert.EqualValues(t, tc.want, got)
+		})
+	}
+}
+
 func Test_parsePostgresFiles(t *testing.T) {
 	// set exact permissions because after CI's git clone permissions depend on used system umask.
 	assert.NoError(t, os.Chmod("testdata/datadir/postgresql.conf.golden", 0644))
@@ -306,4 +344,22 @@ func Test_parseUnit(t *testing.T) {
 
 	_, _, err = parseUnit("8k8k")
 	assert.Error(t, err)
+
+	// suffixes are case-sensitive
+	_, _, err = parseUnit("kb")
+	assert.Error(t, err)
+
+	// factor without suffix
+	_, _, err = parseUnit("8")
+	assert.Error(t, err)
+
+	// negative factor
+	_, _, err = parseUnit("-8kB")
+	assert.Error(t, err)
+}
+
+func Test_numericSettingUnit(t *testing.T) {
+	assert.Equal(t, "count", numericSettingUnit(""))
+	assert.Equal(t, "bytes", numericSettingUnit("bytes"))
+	assert.Equal(t, "seconds", numericSettingUnit("seconds"))
 }
@@ -1,12 +1,16 @@
 package collector
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"github.com/jackc/pgx/v4"
 	"github.com/lesovsky/pgscv/internal/log"
 	"github.com/lesovsky/pgscv/internal/model"
 	"github.com/lesovsky/pgscv/internal/store"
 	"github.com/prometheus/client_golang/prometheus"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -24,6 +28,7 @@ const (
 
 	// postgresStatementsQueryLatest defines query for querying statements metrics.
 	// 1. use nullif(value, 0) to nullify zero values, NULL are skipped by stats method and metrics wil not be generated.
+	// 2. the first placeholder is used for optional columns, e.g. postgresStatementsExecTimeColumns.
 	postgresStatementsQueryLatest = "SELECT d.datname AS database, pg_get_userbyid(p.userid) AS user, p.queryid, " +
 		"p.query, p.calls, p.rows, p.total_exec_time, p.total_plan_time, p.blk_read_time, p.blk_write_time, " +
 		"nullif(p.shared_blks_hit, 0) AS shared_blks_hit, nullif(p.shared_blks_read, 0) AS shared_blks_read, " +
@@ -31,30 +36,51 @@ const (
 		"nullif(p.local_blks_hit, 0) AS local_blks_hit, nullif(p.local_blks_read, 0) AS local_blks_read, " +
 		"nullif(p.local_blks_dirtied, 0) AS local_blks_dirtied, nullif(p.local_blks_written, 0) AS local_blks_written, " +
 		"nullif(p.temp_blks_read, 0) AS temp_blks_read, nullif(p.temp_blks_written, 0) AS temp_blks_written, " +
-		"nullif(p.wal_records, 0) AS wal_records, nullif(p.wal_fpi, 0) AS wal_fpi, nullif(p.wal_bytes, 0) AS wal_bytes " +
+		"nullif(p.wal_records, 0) AS wal_records, nullif(p.wal_fpi, 0) AS wal_fpi, nullif(p.wal_bytes, 0) AS wal_bytes%s " +
 		"FROM %s.pg_stat_statements p JOIN pg_database d ON d.oid=p.dbid"
+
+	// postgresStatementsExecTimeColumns defines optional columns with execution time stats, available since Postgres 13.
+	postgresStatementsExecTimeColumns = ", p.mean_exec_time, p.min_exec_time, p.max_exec_time, p.stddev_exec_time"
+
+	// postgresStatementsInfoQuery defines query for querying time of last pg_stat_statements reset, available since Postgres 14.
+	postgresStatementsInfoQuery = "SELECT coalesce(extract(epoch FROM stats_reset), 0) FROM %s.pg_stat_statements_info"
+
+	// statementsOthers defines user and queryid labels values for statements aggregated beyond top N.
+	statementsOthers = "others"
+
+	// statementsIOTimeRatioTopN defines max number of statements (with the highest IO time) for which IO time ratio is exposed.
+	statementsIOTimeRatioTopN = 100
 )
 
 // postgresStatementsCollector ...
 type postgresStatementsCollector struct {
-	query         typedDesc
-	calls         typedDesc
-	rows          typedDesc
-	times         typedDesc
-	allTimes      typedDesc
-	sharedHit     typedDesc
-	sharedRead    typedDesc
-	sharedDirtied typedDesc
-	sharedWritten typedDesc
-	localHit      typedDesc
-	localRead     typedDesc
-	localDirtied  typedDesc
-	localWritten  typedDesc
-	tempRead      typedDesc
-	tempWritten   typedDesc
-	walRecords    typedDesc
-	walAllBytes   typedDesc
-	walBytes      typedDesc
+	query          typedDesc
+	calls          typedDesc
+	rows           typedDesc
+	times          typedDesc
+	allTimes       typedDesc
+	sharedHit      typedDesc
+	sharedRead     typedDesc
+	sharedDirtied  typedDesc
+	sharedWritten  typedDesc
+	localHit       typedDesc
+	localRead      typedDesc
+	localDirtied   typedDesc
+	localWritten   typedDesc
+	tempRead       typedDesc
+	tempWritten    typedDesc
+	walRecords     typedDesc
+	walAllBytes    typedDesc
+	walBytes       typedDesc
+	ioTimeRatio    typedDesc
+	meanExecTime   typedDesc
+	minExecTime    typedDesc
+	maxExecTime    typedDesc
+	stddevExecTime typedDesc
+	statsReset     typedDesc
+	othersCalls    typedDesc
+	othersRows     typedDesc
+	othersTimes    typedDesc
 }
 
 // NewPostgresStatementsCollector returns a new Collector exposing postgres statements stats.
@@ -169,6 +195,60 @@ func NewPostgresStatementsCollector(constLabels labels, settings model.Collector
 			[]string{"user", "database", "queryid", "wal"}, constLabels,
 			settings.Filters,
 		),
+		ioTimeRatio: newBuiltinTypedDesc(
+			descOpts{"postgres", "statements", "io_time_ratio", "Share of block read and write time in total execution time of the statement.", 0},
+			prometheus.GaugeValue,
+			[]string{"user", "database", "queryid"}, constLabels,
+			settings.Filters,
+		),
+		meanExecTime: newBuiltinTypedDesc(
+			descOpts{"postgres", "statements", "mean_exec_time_seconds", "Mean time spent executing the statement, in seconds.", .001},
+			prometheus.GaugeValue,
+			[]string{"user", "database", "queryid"}, constLabels,
+			settings.Filters,
+		),
+		minExecTime: newBuiltinTypedDesc(
+			descOpts{"postgres", "statements", "min_exec_time_seconds", "Minimum time spent executing the statement, in seconds.", .001},
+			prometheus.GaugeValue,
+			[]string{"user", "database", "queryid"}, constLabels,
+			settings.Filters,
+		),
+		maxExecTime: newBuiltinTypedDesc(
+			descOpts{"postgres", "statements", "max_exec_time_seconds", "Maximum time spent executing the statement, in seconds.", .001},
+			prometheus.GaugeValue,
+			[]string{"user", "database", "queryid"}, constLabels,
+			settings.Filters,
+		),
+		stddevExecTime: newBuiltinTypedDesc(
+			descOpts{"postgres", "statements", "stddev_exec_time_seconds", "Population standard deviation of time spent executing the statement, in seconds.", .001},
+			prometheus.GaugeValue,
+			[]string{"user", "database", "queryid"}, constLabels,
+			settings.Filters,
+		),
+		statsReset: newBuiltinTypedDesc(
+			descOpts{"postgres", "statements", "stats_reset_seconds", "Time of last pg_stat_statements stats reset, in unixtime.", 0},
+			prometheus.GaugeValue,
+			nil, constLabels,
+			settings.Filters,
+		),
+		othersCalls: newBuiltinTypedDesc(
+			descOpts{"postgres", "statements", "others_calls", "Number of times statements beyond top N have been executed, might decrease when statements move in or out of the top.", 0},
+			prometheus.GaugeValue,
+			[]string{"database"}, constLabels,
+			settings.Filters,
+		),
+		othersRows: newBuiltinTypedDesc(
+			descOpts{"postgres", "statements", "others_rows", "Number of rows retrieved or affected by statements beyond top N, might decrease when statements move in or out of the top.", 0},
+			prometheus.GaugeValue,
+			[]string{"database"}, constLabels,
+			settings.Filters,
+		),
+		othersTimes: newBuiltinTypedDesc(
+			descOpts{"postgres", "statements", "others_time_seconds", "Time spent by statements beyond top N, in seconds, might decrease when statements move in or out of the top.", .001},
+			prometheus.GaugeValue,
+			[]string{"database"}, constLabels,
+			settings.Filters,
+		),
 	}, nil
 }
 
@@ -191,7 +271,7 @@ func (c *postgresStatementsCollector) Update(config Config, ch chan<- prometheus
 
 	pgconfig.Database = config.pgStatStatementsDatabase
 
-	conn, err := store.NewWithConfig(pgconfig)
+	conn, err := store.NewPooledWithConfig(config.scrapeContext(), config.Pool, pgconfig)
 	if err != nil {
 		return err
 	}
@@ -199,7 +279,7 @@ func (c *postgresStatementsCollector) Update(config Config, ch chan<- prometheus
 	defer conn.Close()
 
 	// get pg_stat_statements stats
-	res, err := conn.Query(selectStatementsQuery(config.serverVersionNum, config.pgStatStatementsSchema))
+	res, err := conn.Query(selectStatementsQuery(config.serverVersionNum, config.pgStatStatementsSchema, config.Statements.ExecTimeStats))
 	if err != nil {
 		return err
 	}
@@ -207,20 +287,28 @@ func (c *postgresStatementsCollector) Update(config Config, ch chan<- prometheus
 	// parse pg_stat_statements stats
 	stats := parsePostgresStatementsStats(res, []string{"user", "database", "queryid", "query"})
 
+	// Limit number of statements to avoid cardinality explosion, the rest statements are aggregated.
+	if config.Statements.TopN > 0 {
+		stats = statementsTopN(stats, config.Statements.TopN)
+	}
+
 	blockSize := float64(config.blockSize)
 
 	for _, stat := range stats {
-		var query string
-		if config.NoTrackMode {
-			query = stat.queryid + " /* queryid only, no-track mode enabled */"
-		} else {
-			query = stat.query
+		// Aggregate of statements beyond top N is not monotonic, hence it is sent as gauges instead of counters.
+		if stat.others {
+			ch <- c.othersCalls.newConstMetric(stat.calls, stat.database)
+			ch <- c.othersRows.newConstMetric(stat.rows, stat.database)
+			ch <- c.othersTimes.newConstMetric(stat.totalPlanTime+stat.totalExecTime, stat.database)
+			continue
 		}
 
 		// Note: pg_stat_statements.total_exec_time (and .total_time) includes blk_read_time and blk_write_time implicitly.
 		// Remember that when creating metrics.
 
-		ch <- c.query.newConstMetric(1, stat.user, stat.database, stat.queryid, query)
+		if query, ok := statementQueryText(stat, config.NoTrackMode, config.Statements.Redact, config.Statements.Normalize); ok {
+			ch <- c.query.newConstMetric(1, stat.user, stat.database, stat.queryid, query)
+		}
 
 		ch <- c.calls.newConstMetric(stat.calls, stat.user, stat.database, stat.queryid)
 		ch <- c.rows.newConstMetric(stat.rows, stat.user, stat.database, stat.queryid)
@@ -280,11 +368,264 @@ func (c *postgresStatementsCollector) Update(config Config, ch chan<- prometheus
 			ch <- c.walBytes.newConstMetric(stat.walFPI*blockSize, stat.user, stat.database, stat.queryid, "fpi")
 			ch <- c.walBytes.newConstMetric(stat.walBytes, stat.user, stat.database, stat.queryid, "regular")
 		}
+
+		// Execution time stats are optional and requested only if enabled.
+		if stat.execTimeStats {
+			ch <- c.meanExecTime.newConstMetric(stat.meanExecTime, stat.user, stat.database, stat.queryid)
+			ch <- c.minExecTime.newConstMetric(stat.minExecTime, stat.user, stat.database, stat.queryid)
+			ch <- c.maxExecTime.newConstMetric(stat.maxExecTime, stat.user, stat.database, stat.queryid)
+			ch <- c.stddevExecTime.newConstMetric(stat.stddevExecTime, stat.user, stat.database, stat.queryid)
+		}
+	}
+
+	// IO time ratio is sent only for top statements with the highest IO time to avoid metrics spamming.
+	for k, v := range statementsIOTimeRatio(stats, statementsIOTimeRatioTopN) {
+		stat := stats[k]
+		if stat.others {
+			continue
+		}
+		ch <- c.ioTimeRatio.newConstMetric(v, stat.user, stat.database, stat.queryid)
+	}
+
+	// Time of stats reset allows to distinguish counters reset made by pg_stat_statements_reset() (available since Postgres 14).
+	if config.serverVersionNum >= PostgresV14 {
+		reset, err := getStatementsStatsReset(conn, config.pgStatStatementsSchema)
+		if err != nil {
+			log.Warnf("get pg_stat_statements stats reset time failed: %s; skip", err)
+		} else {
+			ch <- c.statsReset.newConstMetric(reset)
+		}
 	}
 
 	return nil
 }
 
+// getStatementsStatsReset returns time of last pg_stat_statements stats reset in unixtime.
+func getStatementsStatsReset(conn *store.DB, schema string) (float64, error) {
+	var reset float64
+	ctx, cancel := conn.Context()
+	defer cancel()
+
+	err := conn.Conn().QueryRow(ctx, fmt.Sprintf(postgresStatementsInfoQuery, pgx.Identifier{schema}.Sanitize())).Scan(&reset)
+	if err != nil {
+		return 0, err
+	}
+
+	return reset, nil
+}
+
+// statementQueryText returns query text which should be exposed for the statement depending on no-track mode, redact
+// mode and normalization. Returns false if query text should not be exposed at all.
+func statementQueryText(stat postgresStatementStat, noTrackMode bool, redact string, normalize bool) (string, bool) {
+	query := stat.query
+	if normalize {
+		query = normalizeQuery(query)
+	}
+
+	switch {
+	case redact == StatementsRedactDrop:
+		return "", false
+	case redact == StatementsRedactHash:
+		// Normalize whitespaces, hence the same queries formatted differently produce the same hash.
+		sum := sha256.Sum256([]byte(strings.Join(strings.Fields(query), " ")))
+		return "sha256:" + hex.EncodeToString(sum[:]), true
+	case noTrackMode:
+		return stat.queryid + " /* queryid only, no-track mode enabled */", true
+	default:
+		return query, true
+	}
+}
+
+// queryInListRE matches IN-lists which consist of placeholders only.
+var queryInListRE = regexp.MustCompile(`(?i)\bIN\s*\(\s*(?:\?|\$\d+)(?:\s*,\s*(?:\?|\$\d+))*\s*\)`)
+
+// normalizeQuery replaces constants in query text with '?' placeholders and collapses IN-lists of placeholders into
+// single 'IN (...)'. It is useful when pg_stat_statements keeps queries with constants, e.g. utility statements
+// tracked with pg_stat_statements.track_utility, and helps to avoid high cardinality and exposing sensitive data.
+// Quoted identifiers, comments and query parameters ($1, $2, ...) are kept as is.
+func normalizeQuery(query string) string {
+	buf := make([]byte, 0, len(query))
+
+	for i := 0; i < len(query); {
+		c := query[i]
+
+		switch {
+		case c == '\'':
+			// String constant, including escape (E'...'), bit (B'...', X'...') and national (N'...') strings.
+			backslash := false
+			if n := len(buf); n > 0 && strings.IndexByte("EeBbXxNn", buf[n-1]) >= 0 && (n == 1 || !isQueryIdentChar(buf[n-2])) {
+				backslash = buf[n-1] == 'E' || buf[n-1] == 'e'
+				buf = buf[:n-1]
+			}
+			i = skipQueryQuoted(query, i, '\'', backslash)
+			buf = append(buf, '?')
+		case c == '"':
+			// Quoted identifier.
+			end := skipQueryQuoted(query, i, '"', false)
+			buf, i = append(buf, query[i:end]...), end
+		case c == '-' && strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				end = len(query) - i
+			}
+			buf, i = append(buf, query[i:i+end]...), i+end
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				end = len(query) - i
+			} else {
+				end += 4
+			}
+			buf, i = append(buf, query[i:i+end]...), i+end
+		case c == '$':
+			// Query parameter ($1) or dollar-quoted string constant ($$...$$, $tag$...$tag$).
+			end := i + 1
+			for end < len(query) && isQueryIdentChar(query[end]) && query[end] != '$' {
+				end++
+			}
+
+			tag := query[i:end]
+			if end < len(query) && query[end] == '$' && (len(tag) == 1 || !isQueryDigit(tag[1])) {
+				tag = query[i : end+1]
+				if closing := strings.Index(query[end+1:], tag); closing >= 0 {
+					buf, i = append(buf, '?'), end+1+closing+len(tag)
+					continue
+				}
+			}
+
+			buf, i = append(buf, query[i:end]...), end
+		case isQueryDigit(c) || (c == '.' && i+1 < len(query) && isQueryDigit(query[i+1])):
+			// Numeric constant, including decimals, exponents and hexadecimal, octal and binary integers (0x1F).
+			end := i
+			for end < len(query) && (isQueryIdentChar(query[end]) || query[end] == '.') && query[end] != '$' {
+				if (query[end] == 'e' || query[end] == 'E') && end+1 < len(query) && (query[end+1] == '+' || query[end+1] == '-') {
+					end++
+				}
+				end++
+			}
+			buf, i = append(buf, '?'), end
+		case isQueryIdentChar(c):
+			// Keyword or identifier, possibly with digits inside.
+			end := i
+			for end < len(query) && isQueryIdentChar(query[end]) {
+				end++
+			}
+			buf, i = append(buf, query[i:end]...), end
+		default:
+			buf, i = append(buf, c), i+1
+		}
+	}
+
+	return queryInListRE.ReplaceAllString(string(buf), "IN (...)")
+}
+
+// skipQueryQuoted returns position right after quoted string which starts at passed position. Doubled quotes are treated
+// as escaped quote, backslash escapes are allowed optionally.
+func skipQueryQuoted(query string, start int, quote byte, backslash bool) int {
+	for i := start + 1; i < len(query); i++ {
+		switch {
+		case backslash && query[i] == '\\':
+			i++
+		case query[i] == quote:
+			if i+1 < len(query) && query[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+
+	return len(query)
+}
+
+// isQueryDigit returns true if passed byte is a decimal digit.
+func isQueryDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// isQueryIdentChar returns true if passed byte could be a part of SQL identifier or keyword.
+func isQueryIdentChar(c byte) bool {
+	return c == '_' || c == '$' || isQueryDigit(c) || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
+
+// statementsTopN returns top N statements with the highest total time (planning and execution). Calls, rows and times
+// of the rest statements are aggregated per database into single 'others' statement. Note, stats of 'others' might
+// decrease when statements move in or out of the top, hence they must not be exposed as counters.
+func statementsTopN(stats map[string]postgresStatementStat, limit int) map[string]postgresStatementStat {
+	if len(stats) <= limit {
+		return stats
+	}
+
+	keys := make([]string, 0, len(stats))
+	for k := range stats {
+		keys = append(keys, k)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := stats[keys[i]], stats[keys[j]]
+		if a.totalPlanTime+a.totalExecTime == b.totalPlanTime+b.totalExecTime {
+			return keys[i] < keys[j]
+		}
+		return a.totalPlanTime+a.totalExecTime > b.totalPlanTime+b.totalExecTime
+	})
+
+	top := make(map[string]postgresStatementStat, limit)
+	for _, k := range keys[:limit] {
+		top[k] = stats[k]
+	}
+
+	for _, k := range keys[limit:] {
+		s := stats[k]
+		key := strings.Join([]string{s.database, statementsOthers, statementsOthers}, "/")
+
+		o, ok := top[key]
+		if !ok {
+			o = postgresStatementStat{database: s.database, user: statementsOthers, queryid: statementsOthers, query: statementsOthers, others: true}
+		}
+
+		o.calls += s.calls
+		o.rows += s.rows
+		o.totalExecTime += s.totalExecTime
+		o.totalPlanTime += s.totalPlanTime
+
+		top[key] = o
+	}
+
+	return top
+}
+
+// statementsIOTimeRatio returns share of IO time (block read and write time) in total execution time for top N
+// statements with the highest IO time. Statements without IO time or execution time are skipped.
+func statementsIOTimeRatio(stats map[string]postgresStatementStat, limit int) map[string]float64 {
+	keys := make([]string, 0, len(stats))
+	for k, stat := range stats {
+		if stat.totalExecTime <= 0 || stat.blkReadTime+stat.blkWriteTime <= 0 {
+			continue
+		}
+		keys = append(keys, k)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := stats[keys[i]], stats[keys[j]]
+		if a.blkReadTime+a.blkWriteTime == b.blkReadTime+b.blkWriteTime {
+			return keys[i] < keys[j]
+		}
+		return a.blkReadTime+a.blkWriteTime > b.blkReadTime+b.blkWriteTime
+	})
+
+	if len(keys) > limit {
+		keys = keys[:limit]
+	}
+
+	ratios := make(map[string]float64, len(keys))
+	for _, k := range keys {
+		stat := stats[k]
+		ratios[k] = (stat.blkReadTime + stat.blkWriteTime) / stat.totalExecTime
+	}
+
+	return ratios
+}
+
 // postgresStatementsStat represents stats values for single statement based on pg_stat_statements.
 type postgresStatementStat struct {
 	database          string
@@ -310,6 +651,12 @@ type postgresStatementStat struct {
 	walRecords        float64
 	walFPI            float64
 	walBytes          float64
+	execTimeStats     bool // execution time stats below are available
+	meanExecTime      float64
+	minExecTime       float64
+	maxExecTime       float64
+	stddevExecTime    float64
+	others            bool // aggregate of statements beyond top N
 }
 
 // parsePostgresStatementsStats parses PGResult and return structs with stats values.
@@ -322,6 +669,7 @@ func parsePostgresStatementsStats(r *model.PGResult, labelNames []string) map[st
 	// fields and collect stats for constructed 'statement'.
 	for _, row := range r.Rows {
 		var database, user, queryid, query string
+		var rowCalls float64
 
 		// collect label values
 		for i, colname := range r.Colnames {
@@ -334,6 +682,8 @@ func parsePostgresStatementsStats(r *model.PGResult, labelNames []string) map[st
 				queryid = row[i].String
 			case "query":
 				query = row[i].String
+			case "calls":
+				rowCalls, _ = strconv.ParseFloat(row[i].String, 64)
 			}
 		}
 
@@ -345,6 +695,9 @@ func parsePostgresStatementsStats(r *model.PGResult, labelNames []string) map[st
 			stats[statement] = postgresStatementStat{database: database, user: user, queryid: queryid, query: query}
 		}
 
+		// Number of calls accumulated from previous rows of the same statement, used for merging execution time stats.
+		prevCalls := stats[statement].calls
+
 		// fetch data values from columns
 		for i, colname := range r.Colnames {
 			// skip columns if its value used as a label
@@ -406,6 +759,28 @@ func parsePostgresStatementsStats(r *model.PGResult, labelNames []string) map[st
 				s.walFPI += v
 			case "wal_bytes":
 				s.walBytes += v
+			case "mean_exec_time":
+				// Statement could be represented by several rows (e.g. top-level and nested), merge means weighted by calls.
+				if prevCalls+rowCalls > 0 {
+					s.meanExecTime = (s.meanExecTime*prevCalls + v*rowCalls) / (prevCalls + rowCalls)
+				}
+				s.execTimeStats = true
+			case "min_exec_time":
+				if prevCalls == 0 || v < s.minExecTime {
+					s.minExecTime = v
+				}
+				s.execTimeStats = true
+			case "max_exec_time":
+				if v > s.maxExecTime {
+					s.maxExecTime = v
+				}
+				s.execTimeStats = true
+			case "stddev_exec_time":
+				// Stddev can't be merged precisely without per-row means, use the highest one.
+				if v > s.stddevExecTime {
+					s.stddevExecTime = v
+				}
+				s.execTimeStats = true
 			default:
 				continue
 			}
@@ -417,12 +792,19 @@ func parsePostgresStatementsStats(r *model.PGResult, labelNames []string) map[st
 	return stats
 }
 
-// selectStatementsQuery returns suitable statements query depending on passed version.
-func selectStatementsQuery(version int, schema string) string {
+// selectStatementsQuery returns suitable statements query depending on passed version. Execution time stats columns
+// are requested only if execTimeStats is true.
+func selectStatementsQuery(version int, schema string, execTimeStats bool) string {
+	schema = pgx.Identifier{schema}.Sanitize()
+
 	switch {
 	case version < PostgresV13:
 		return fmt.Sprintf(postgresStatementsQuery12, schema)
 	default:
-		return fmt.Sprintf(postgresStatementsQueryLatest, schema)
+		var columns string
+		if execTimeStats {
+			columns = postgresStatementsExecTimeColumns
+		}
+		return fmt.Sprintf(postgresStatementsQueryLatest, columns, schema)
 	}
 }
@@ -5,7 +5,10 @@ import (
 	"fmt"
 	"github.com/jackc/pgproto3/v2"
 	"github.com/lesovsky/pgscv/internal/model"
+	"github.com/lesovsky/pgscv/internal/store"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
+	"strconv"
 	"testing"
 )
 
@@ -32,6 +35,11 @@ func TestPostgresStatementsCollector_Update(t *testing.T) {
 			"postgres_statements_wal_records_total",
 			"postgres_statements_wal_bytes_all_total",
 			"postgres_statements_wal_bytes_total",
+			"postgres_statements_io_time_ratio",
+			"postgres_statements_stats_reset_seconds",
+			"postgres_statements_others_calls",
+			"postgres_statements_others_rows",
+			"postgres_statements_others_time_seconds",
 		},
 		collector: NewPostgresStatementsCollector,
 		service:   model.ServiceTypePostgresql,
@@ -118,6 +126,37 @@ func Test_parsePostgresStatementsStats(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "execution time stats, merged rows",
+			res: &model.PGResult{
+				Nrows: 2,
+				Ncols: 10,
+				Colnames: []pgproto3.FieldDescription{
+					{Name: []byte("database")}, {Name: []byte("user")}, {Name: []byte("queryid")}, {Name: []byte("query")},
+					{Name: []byte("calls")}, {Name: []byte("total_exec_time")},
+					{Name: []byte("mean_exec_time")}, {Name: []byte("min_exec_time")}, {Name: []byte("max_exec_time")}, {Name: []byte("stddev_exec_time")},
+				},
+				Rows: [][]sql.NullString{
+					{
+						{String: "testdb", Valid: true}, {String: "testuser", Valid: true}, {String: "example_queryid", Valid: true}, {String: "SELECT test", Valid: true},
+						{String: "300", Valid: true}, {String: "3000", Valid: true},
+						{String: "10", Valid: true}, {String: "2", Valid: true}, {String: "50", Valid: true}, {String: "4", Valid: true},
+					},
+					{
+						{String: "testdb", Valid: true}, {String: "testuser", Valid: true}, {String: "example_queryid", Valid: true}, {String: "SELECT test", Valid: true},
+						{String: "100", Valid: true}, {String: "2000", Valid: true},
+						{String: "20", Valid: true}, {String: "1", Valid: true}, {String: "40", Valid: true}, {String: "6", Valid: true},
+					},
+				},
+			},
+			want: map[string]postgresStatementStat{
+				"testdb/testuser/example_queryid": {
+					database: "testdb", user: "testuser", queryid: "example_queryid", query: "SELECT test",
+					calls: 400, totalExecTime: 5000,
+					execTimeStats: true, meanExecTime: 12.5, minExecTime: 1, maxExecTime: 50, stddevExecTime: 6,
+				},
+			},
+		},
 		{
 			name: "lot of nulls and unknown columns",
 			res: &model.PGResult{
@@ -163,14 +202,212 @@ func Test_parsePostgresStatementsStats(t *testing.T) {
 
 func Test_selectStatementsQuery(t *testing.T) {
 	testcases := []struct {
-		version int
-		want    string
+		version       int
+		execTimeStats bool
+		want          string
 	}{
-		{version: PostgresV12, want: fmt.Sprintf(postgresStatementsQuery12, "example")},
-		{version: PostgresV13, want: fmt.Sprintf(postgresStatementsQueryLatest, "example")},
+		{version: PostgresV12, want: fmt.Sprintf(postgresStatementsQuery12, `"example"`)},
+		{version: PostgresV12, execTimeStats: true, want: fmt.Sprintf(postgresStatementsQuery12, `"example"`)},
+		{version: PostgresV13, want: fmt.Sprintf(postgresStatementsQueryLatest, "", `"example"`)},
+		{version: PostgresV13, execTimeStats: true, want: fmt.Sprintf(postgresStatementsQueryLatest, postgresStatementsExecTimeColumns, `"example"`)},
 	}
 
 	for _, tc := range testcases {
-		assert.Equal(t, tc.want, selectStatementsQuery(tc.version, "example"))
+		assert.Equal(t, tc.want, selectStatementsQuery(tc.version, "example", tc.execTimeStats))
+	}
+
+	// Extension installed in custom schema.
+	assert.Contains(t, selectStatementsQuery(PostgresV13, "monitoring", false), `FROM "monitoring".pg_stat_statements`)
+	assert.Contains(t, selectStatementsQuery(PostgresV12, "monitoring", false), `FROM "monitoring".pg_stat_statements`)
+	assert.Contains(t, selectStatementsQuery(PostgresV13, "My Schema", false), `FROM "My Schema".pg_stat_statements`)
+}
+
+func Test_statementsIOTimeRatio(t *testing.T) {
+	stats := map[string]postgresStatementStat{
+		"testdb/testuser/1": {queryid: "1", totalExecTime: 1000, blkReadTime: 200, blkWriteTime: 300},
+		"testdb/testuser/2": {queryid: "2", totalExecTime: 100, blkReadTime: 90},
+		"testdb/testuser/3": {queryid: "3", totalExecTime: 500, blkWriteTime: 50},
+		"testdb/testuser/4": {queryid: "4", totalExecTime: 100},
+		"testdb/testuser/5": {queryid: "5", blkReadTime: 10},
+	}
+
+	assert.Equal(t, map[string]float64{
+		"testdb/testuser/1": 0.5,
+		"testdb/testuser/2": 0.9,
+		"testdb/testuser/3": 0.1,
+	}, statementsIOTimeRatio(stats, 10))
+
+	// Only statements with the highest IO time should be kept.
+	assert.Equal(t, map[string]float64{
+		"testdb/testuser/1": 0.5,
+		"testdb/testuser/2": 0.9,
+	}, statementsIOTimeRatio(stats, 2))
+
+	assert.Equal(t, map[string]float64{}, statementsIOTimeRatio(map[string]postgresStatementStat{}, 10))
+}
+
+func Test_getStatementsStatsReset(t *testing.T) {
+	conn := store.NewTest(t)
+	defer conn.Close()
+
+	var version int
+	ctx, cancel := conn.Context()
+	defer cancel()
+	assert.NoError(t, conn.Conn().QueryRow(ctx, "SELECT current_setting('server_version_num')::int").Scan(&version))
+
+	if version < PostgresV14 {
+		t.Skip("pg_stat_statements_info is not available, required Postgres 14 or newer")
+	}
+
+	got, err := getStatementsStatsReset(conn, "public")
+	assert.NoError(t, err)
+	assert.Greater(t, got, float64(0))
+
+	// Invalid schema.
+	_, err = getStatementsStatsReset(conn, "invalid")
+	assert.Error(t, err)
+}
+
+func Test_statementsTopN(t *testing.T) {
+	stats := map[string]postgresStatementStat{}
+	for i := 1; i <= 1000; i++ {
+		database := "testdb" + strconv.Itoa(i%2)
+		queryid := strconv.Itoa(i)
+		stats[database+"/testuser/"+queryid] = postgresStatementStat{
+			database: database, user: "testuser", queryid: queryid, query: "SELECT " + queryid,
+			calls: 1, rows: 2, totalExecTime: float64(i), totalPlanTime: 1, sharedBlksHit: 3, walBytes: 4,
+		}
+	}
+
+	got := statementsTopN(stats, 10)
+
+	// 10 top statements and 'others' for each of two databases.
+	assert.Len(t, got, 12)
+	for i := 991; i <= 1000; i++ {
+		assert.Contains(t, got, "testdb"+strconv.Itoa(i%2)+"/testuser/"+strconv.Itoa(i))
+	}
+
+	// Others are summarized per database: odd statements 1..989 and even statements 2..990. Only calls, rows and
+	// times are summarized, other stats are not exposed for 'others'.
+	assert.Equal(t, postgresStatementStat{
+		database: "testdb1", user: "others", queryid: "others", query: "others",
+		calls: 495, rows: 990, totalExecTime: 245025, totalPlanTime: 495, others: true,
+	}, got["testdb1/others/others"])
+	assert.Equal(t, postgresStatementStat{
+		database: "testdb0", user: "others", queryid: "others", query: "others",
+		calls: 495, rows: 990, totalExecTime: 245520, totalPlanTime: 495, others: true,
+	}, got["testdb0/others/others"])
+
+	// Nothing to aggregate if number of statements doesn't exceed the limit.
+	assert.Equal(t, stats, statementsTopN(stats, 1000))
+}
+
+func Test_statementQueryText(t *testing.T) {
+	c, err := NewPostgresStatementsCollector(labels{}, model.CollectorSettings{})
+	assert.NoError(t, err)
+	desc := c.(*postgresStatementsCollector).query
+
+	stat := postgresStatementStat{
+		database: "testdb", user: "testuser", queryid: "123456",
+		query: "SELECT secret_column FROM secret_table WHERE id = $1",
+	}
+
+	testcases := []struct {
+		noTrackMode bool
+		redact      string
+		wantOK      bool
+		want        string
+	}{
+		{redact: "", wantOK: true, want: stat.query},
+		{noTrackMode: true, wantOK: true, want: "123456 /* queryid only, no-track mode enabled */"},
+		{redact: StatementsRedactHash, wantOK: true},
+		{noTrackMode: true, redact: StatementsRedactHash, wantOK: true},
+		{redact: StatementsRedactDrop, wantOK: false},
+	}
+
+	for _, tc := range testcases {
+		got, ok := statementQueryText(stat, tc.noTrackMode, tc.redact, false)
+		assert.Equal(t, tc.wantOK, ok)
+		if !ok {
+			continue
+		}
+
+		if tc.redact == "" {
+			assert.Equal(t, tc.want, got)
+			continue
+		}
+
+		// Raw SQL must not leak into emitted metric under redact mode.
+		m := &dto.Metric{}
+		assert.NoError(t, desc.newConstMetric(1, stat.user, stat.database, stat.queryid, got).Write(m))
+		for _, l := range m.GetLabel() {
+			assert.NotContains(t, l.GetValue(), "secret")
+		}
+		assert.Regexp(t, `^sha256:[0-9a-f]{64}$`, got)
+	}
+
+	// Hash doesn't depend on whitespaces formatting.
+	formatted := stat
+	formatted.query = "SELECT  secret_column\n  FROM secret_table\n WHERE id = $1"
+	h1, _ := statementQueryText(stat, false, StatementsRedactHash, false)
+	h2, _ := statementQueryText(formatted, false, StatementsRedactHash, false)
+	assert.Equal(t, h1, h2)
+}
+
+func Test_statementQueryText_normalize(t *testing.T) {
+	stat := postgresStatementStat{queryid: "123456", query: "SELECT * FROM users WHERE email = 'john@example.org' AND id = 42"}
+
+	got, ok := statementQueryText(stat, false, "", true)
+	assert.True(t, ok)
+	assert.Equal(t, "SELECT * FROM users WHERE email = ? AND id = ?", got)
+
+	// Queries which differ in constants only produce the same hash.
+	other := stat
+	other.query = "SELECT * FROM users WHERE email = 'jane@example.org' AND id = 43"
+	h1, _ := statementQueryText(stat, false, StatementsRedactHash, true)
+	h2, _ := statementQueryText(other, false, StatementsRedactHash, true)
+	assert.Equal(t, h1, h2)
+
+	// Query text is exposed as is when normalization is disabled.
+	got, _ = statementQueryText(stat, false, "", false)
+	assert.Equal(t, stat.query, got)
+}
+
+func Test_normalizeQuery(t *testing.T) {
+	testcases := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{name: "no constants", query: "SELECT a, b FROM t WHERE id = $1", want: "SELECT a, b FROM t WHERE id = $1"},
+		{name: "integer", query: "SELECT * FROM t WHERE id = 42 LIMIT 10", want: "SELECT * FROM t WHERE id = ? LIMIT ?"},
+		{name: "negative", query: "SELECT * FROM t WHERE balance > -100", want: "SELECT * FROM t WHERE balance > -?"},
+		{name: "decimal", query: "SELECT 1.5, .5, 10., 1e10, 2.5E-3", want: "SELECT ?, ?, ?, ?, ?"},
+		{name: "hexadecimal", query: "SELECT 0x1F, 1_000_000", want: "SELECT ?, ?"},
+		{name: "digits in identifiers", query: "SELECT col1, t2.x_3 FROM table1 t2", want: "SELECT col1, t2.x_3 FROM table1 t2"},
+		{name: "string", query: "UPDATE t SET name = 'John' WHERE email = 'john@example.org'", want: "UPDATE t SET name = ? WHERE email = ?"},
+		{name: "escaped quotes", query: "SELECT 'it''s', 'a' || 'b'", want: "SELECT ?, ? || ?"},
+		{name: "escape string", query: `SELECT E'it\'s\n', e'x' FROM t`, want: "SELECT ?, ? FROM t"},
+		{name: "bit strings", query: "SELECT B'1010', X'1F', N'text'", want: "SELECT ?, ?, ?"},
+		{name: "typed constant", query: "SELECT * FROM t WHERE created_at > '2020-01-01'::date", want: "SELECT * FROM t WHERE created_at > ?::date"},
+		{name: "dollar-quoted", query: "SELECT $$secret$$, $tag$it's $$ nested$tag$", want: "SELECT ?, ?"},
+		{name: "quoted identifiers", query: `SELECT "col 1", "it""s" FROM "Table2"`, want: `SELECT "col 1", "it""s" FROM "Table2"`},
+		{name: "identifier with dollar", query: "SELECT a$1 FROM t", want: "SELECT a$1 FROM t"},
+		{name: "line comment", query: "SELECT 1 -- comment 2\nFROM t", want: "SELECT ? -- comment 2\nFROM t"},
+		{name: "block comment", query: "SELECT /* id = 1 */ 2", want: "SELECT /* id = 1 */ ?"},
+		{name: "in-list numbers", query: "SELECT * FROM t WHERE id IN (1, 2, 3)", want: "SELECT * FROM t WHERE id IN (...)"},
+		{name: "in-list strings", query: "SELECT * FROM t WHERE name in ('a','b')", want: "SELECT * FROM t WHERE name IN (...)"},
+		{name: "in-list parameters", query: "SELECT * FROM t WHERE id IN ($1, $2, $3)", want: "SELECT * FROM t WHERE id IN (...)"},
+		{name: "in-list single", query: "SELECT * FROM t WHERE id IN (7)", want: "SELECT * FROM t WHERE id IN (...)"},
+		{name: "in subquery", query: "SELECT * FROM t WHERE id IN (SELECT id FROM t2 WHERE x = 1)", want: "SELECT * FROM t WHERE id IN (SELECT id FROM t2 WHERE x = ?)"},
+		{name: "in-list expressions", query: "SELECT * FROM t WHERE id IN (1, a + 1)", want: "SELECT * FROM t WHERE id IN (?, a + ?)"},
+		{name: "unterminated string", query: "SELECT 'abc", want: "SELECT ?"},
+		{name: "multibyte", query: "SELECT 'привет', колонка1 FROM таблица", want: "SELECT ?, колонка1 FROM таблица"},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, normalizeQuery(tc.query))
+		})
 	}
 }
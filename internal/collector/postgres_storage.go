@@ -1,7 +1,6 @@
 package collector
 
 import (
-	"context"
 	"fmt"
 	"github.com/lesovsky/pgscv/internal/log"
 	"github.com/lesovsky/pgscv/internal/model"
@@ -26,6 +25,8 @@ type postgresStorageCollector struct {
 	tempFilesMaxAge typedDesc
 	datadirBytes    typedDesc
 	tblspcBytes     typedDesc
+	tblspcFree      typedDesc
+	tblspcTotal     typedDesc
 	waldirBytes     typedDesc
 	waldirFiles     typedDesc
 	logdirBytes     typedDesc
@@ -67,6 +68,18 @@ func NewPostgresStorageCollector(constLabels labels, settings model.CollectorSet
 			[]string{"tablespace", "device", "mountpoint", "path"}, constLabels,
 			settings.Filters,
 		),
+		tblspcFree: newBuiltinTypedDesc(
+			descOpts{"postgres", "tablespace", "free_bytes", "Number of bytes available to Postgres on the filesystem where tablespace is located.", 0},
+			prometheus.GaugeValue,
+			[]string{"tablespace", "device", "mountpoint", "path"}, constLabels,
+			settings.Filters,
+		),
+		tblspcTotal: newBuiltinTypedDesc(
+			descOpts{"postgres", "tablespace", "total_bytes", "Total size of the filesystem where tablespace is located, in bytes.", 0},
+			prometheus.GaugeValue,
+			[]string{"tablespace", "device", "mountpoint", "path"}, constLabels,
+			settings.Filters,
+		),
 		waldirBytes: newBuiltinTypedDesc(
 			descOpts{"postgres", "wal_directory", "bytes", "The size of Postgres server WAL directory, in bytes.", 0},
 			prometheus.GaugeValue,
@@ -110,7 +123,7 @@ func (c *postgresStorageCollector) Update(config Config, ch chan<- prometheus.Me
 		return nil
 	}
 
-	conn, err := store.New(config.ConnString)
+	conn, err := store.NewPooled(config.scrapeContext(), config.Pool, config.ConnString)
 	if err != nil {
 		return err
 	}
@@ -151,6 +164,12 @@ func (c *postgresStorageCollector) Update(config Config, ch chan<- prometheus.Me
 
 	for _, ts := range tblspcStats {
 		ch <- c.tblspcBytes.newConstMetric(ts.size, ts.name, ts.device, ts.mountpoint, ts.path)
+
+		// Filesystem stats might be unavailable, e.g. when filesystem is unresponsive.
+		if ts.fsStatOK {
+			ch <- c.tblspcFree.newConstMetric(ts.free, ts.name, ts.device, ts.mountpoint, ts.path)
+			ch <- c.tblspcTotal.newConstMetric(ts.total, ts.name, ts.device, ts.mountpoint, ts.path)
+		}
 	}
 
 	// WAL directory
@@ -349,12 +368,18 @@ type tablespaceStat struct {
 	mountpoint string
 	path       string
 	size       float64
+	free       float64
+	total      float64
+	fsStatOK   bool
 }
 
 // getTablespacesStat returns filesystem info related to WALDIR.
 func getTablespacesStat(conn *store.DB, mounts []mount) ([]tablespaceStat, error) {
+	ctx, cancel := conn.Context()
+	defer cancel()
+
 	rows, err := conn.Conn().
-		Query(context.Background(), "select spcname, coalesce(nullif(pg_tablespace_location(oid), ''), current_setting('data_directory')) as path, pg_tablespace_size(oid) as size from pg_tablespace")
+		Query(ctx, "select spcname, coalesce(nullif(pg_tablespace_location(oid), ''), current_setting('data_directory')) as path, pg_tablespace_size(oid) as size from pg_tablespace")
 	if err != nil {
 		return nil, fmt.Errorf("get tablespaces stats failed: %s", err)
 	}
@@ -377,24 +402,47 @@ func getTablespacesStat(conn *store.DB, mounts []mount) ([]tablespaceStat, error
 
 		device = truncateDeviceName(device)
 
-		stats = append(stats, tablespaceStat{
+		stat := tablespaceStat{
 			name:       name,
 			device:     device,
 			mountpoint: mountpoint,
 			path:       path,
 			size:       float64(size),
-		})
+		}
+
+		free, total, err := getTablespaceFilesystemStat(mountpoint)
+		if err != nil {
+			log.Warnf("get filesystem stats of tablespace %s failed: %s; skip", name, err)
+		} else {
+			stat.free, stat.total, stat.fsStatOK = free, total, true
+		}
+
+		stats = append(stats, stat)
 	}
 
 	return stats, nil
 }
 
+// getTablespaceFilesystemStat returns available and total bytes of filesystem mounted to passed mountpoint. Available
+// bytes exclude blocks reserved for superuser, because Postgres runs as unprivileged user and cannot use them.
+func getTablespaceFilesystemStat(mountpoint string) (float64, float64, error) {
+	stat, err := readMountpointStat(mountpoint)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return stat.avail, stat.size, nil
+}
+
 // getWaldirStat returns filesystem info related to WALDIR.
 func getWaldirStat(conn *store.DB, mounts []mount) (string, string, string, int64, int64, error) {
 	var path string
 	var size, count int64
+	ctx, cancel := conn.Context()
+	defer cancel()
+
 	err := conn.Conn().
-		QueryRow(context.Background(), "SELECT current_setting('data_directory')||'/pg_wal' AS path, sum(size) AS bytes, count(name) AS count FROM pg_ls_waldir()").
+		QueryRow(ctx, "SELECT current_setting('data_directory')||'/pg_wal' AS path, sum(size) AS bytes, count(name) AS count FROM pg_ls_waldir()").
 		Scan(&path, &size, &count)
 	if err != nil {
 		return "", "", "", 0, 0, fmt.Errorf("get WAL directory size failed: %s", err)
@@ -420,8 +468,11 @@ func getLogdirStat(conn *store.DB, logcollector bool, datadir string, mounts []m
 
 	var size, count int64
 	var path string
+	ctx, cancel := conn.Context()
+	defer cancel()
+
 	err := conn.Conn().
-		QueryRow(context.Background(), "SELECT current_setting('log_directory') AS path, coalesce(sum(size), 0) AS bytes, coalesce(count(name), 0) AS count FROM pg_ls_logdir()").
+		QueryRow(ctx, "SELECT current_setting('log_directory') AS path, coalesce(sum(size), 0) AS bytes, coalesce(count(name), 0) AS count FROM pg_ls_logdir()").
 		Scan(&path, &size, &count)
 	if err != nil {
 		return "", "", "", 0, 0, fmt.Errorf("get log directory size failed: %s", err)
@@ -450,8 +501,11 @@ func getTempfilesStat(conn *store.DB, version int) (int64, int64, error) {
 	}
 
 	var size, count int64
+	ctx, cancel := conn.Context()
+	defer cancel()
+
 	err := conn.Conn().
-		QueryRow(context.Background(), "SELECT coalesce(sum(size), 0) AS bytes, coalesce(count(name), 0) AS count FROM (SELECT (pg_ls_tmpdir(oid)).* FROM pg_tablespace WHERE spcname != 'pg_global') tablespaces").
+		QueryRow(ctx, "SELECT coalesce(sum(size), 0) AS bytes, coalesce(count(name), 0) AS count FROM (SELECT (pg_ls_tmpdir(oid)).* FROM pg_tablespace WHERE spcname != 'pg_global') tablespaces").
 		Scan(&size, &count)
 	if err != nil {
 		return 0, 0, fmt.Errorf("get total size of temp files failed: %s", err)
@@ -541,7 +595,7 @@ func findMountpoint(mounts []mount, path string) (string, string, error) {
 
 // getMountpoints opens /proc/mounts file and run parser.
 func getMountpoints() ([]mount, error) {
-	file, err := os.Open("/proc/mounts")
+	file, err := os.Open(procPath("mounts"))
 	if err != nil {
 		return nil, err
 	}
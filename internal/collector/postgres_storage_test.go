@@ -19,6 +19,7 @@ func TestPostgresStorageCollector_Update(t *testing.T) {
 		required: []string{
 			"postgres_temp_files_in_flight", "postgres_temp_bytes_in_flight", "postgres_temp_files_max_age_seconds",
 			"postgres_data_directory_bytes", "postgres_tablespace_directory_bytes",
+			"postgres_tablespace_free_bytes", "postgres_tablespace_total_bytes",
 			"postgres_wal_directory_bytes", "postgres_wal_directory_files",
 			"postgres_log_directory_bytes", "postgres_log_directory_files",
 			"postgres_temp_files_all_bytes",
@@ -92,6 +93,16 @@ func Test_getTablespacesStat(t *testing.T) {
 	conn.Close()
 }
 
+func Test_getTablespaceFilesystemStat(t *testing.T) {
+	free, total, err := getTablespaceFilesystemStat(t.TempDir())
+	assert.NoError(t, err)
+	assert.Greater(t, total, float64(0))
+	assert.LessOrEqual(t, free, total)
+
+	_, _, err = getTablespaceFilesystemStat("/invalid")
+	assert.Error(t, err)
+}
+
 func Test_getWaldirStat(t *testing.T) {
 	if uid := os.Geteuid(); uid != 0 {
 		t.Skipf("root privileges required, skip")
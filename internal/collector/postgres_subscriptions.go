@@ -0,0 +1,120 @@
+package collector
+
+import (
+	"github.com/lesovsky/pgscv/internal/log"
+	"github.com/lesovsky/pgscv/internal/model"
+	"github.com/lesovsky/pgscv/internal/store"
+	"github.com/prometheus/client_golang/prometheus"
+	"strconv"
+)
+
+const (
+	// postgresSubscriptionsQuery defines query for subscriptions stats, available since Postgres 15.
+	postgresSubscriptionsQuery = "SELECT subname, apply_error_count, sync_error_count FROM pg_stat_subscription_stats"
+)
+
+type postgresSubscriptionsCollector struct {
+	restarts typedDesc
+}
+
+// NewPostgresSubscriptionsCollector returns a new Collector exposing postgres logical replication subscriptions stats.
+// Logical replication workers exit on errors and are restarted by launcher, hence errors counters are used as a number
+// of workers restarts.
+// For details see https://www.postgresql.org/docs/current/monitoring-stats.html#MONITORING-PG-STAT-SUBSCRIPTION-STATS
+func NewPostgresSubscriptionsCollector(constLabels labels, settings model.CollectorSettings) (Collector, error) {
+	return &postgresSubscriptionsCollector{
+		restarts: newBuiltinTypedDesc(
+			descOpts{"postgres", "subscription", "worker_restarts_total", "Total number of times subscription workers restarted due to errors, by each worker type.", 0},
+			prometheus.CounterValue,
+			[]string{"subname", "worker"}, constLabels,
+			settings.Filters,
+		),
+	}, nil
+}
+
+// Update method collects statistics, parse it and produces metrics that are sent to Prometheus.
+func (c *postgresSubscriptionsCollector) Update(config Config, ch chan<- prometheus.Metric) error {
+	// pg_stat_subscription_stats is available since Postgres 15.
+	if config.serverVersionNum < PostgresV15 {
+		log.Debugln("[postgres subscriptions collector]: pg_stat_subscription_stats is not available, required Postgres 15 or newer")
+		return nil
+	}
+
+	conn, err := store.NewPooled(config.scrapeContext(), config.Pool, config.ConnString)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	res, err := conn.Query(postgresSubscriptionsQuery)
+	if err != nil {
+		return err
+	}
+
+	stats := parsePostgresSubscriptionsStats(res, []string{"subname"})
+
+	for _, stat := range stats {
+		ch <- c.restarts.newConstMetric(stat.applyErrors, stat.subname, "apply")
+		ch <- c.restarts.newConstMetric(stat.syncErrors, stat.subname, "sync")
+	}
+
+	return nil
+}
+
+// postgresSubscriptionStat represents per-subscription stats based on pg_stat_subscription_stats.
+type postgresSubscriptionStat struct {
+	subname     string
+	applyErrors float64
+	syncErrors  float64
+}
+
+// parsePostgresSubscriptionsStats parses PGResult and returns struct with stats values.
+func parsePostgresSubscriptionsStats(r *model.PGResult, labelNames []string) map[string]postgresSubscriptionStat {
+	log.Debug("parse postgres subscriptions stats")
+
+	var stats = make(map[string]postgresSubscriptionStat)
+
+	for _, row := range r.Rows {
+		stat := postgresSubscriptionStat{}
+
+		// collect label values
+		for i, colname := range r.Colnames {
+			switch string(colname.Name) {
+			case "subname":
+				stat.subname = row[i].String
+			}
+		}
+
+		for i, colname := range r.Colnames {
+			// skip columns if its value used as a label
+			if stringsContains(labelNames, string(colname.Name)) {
+				continue
+			}
+
+			// Skip empty (NULL) values.
+			if !row[i].Valid {
+				continue
+			}
+
+			// Get data value and convert it to float64 used by Prometheus.
+			v, err := strconv.ParseFloat(row[i].String, 64)
+			if err != nil {
+				log.Errorf("invalid input, parse '%s' failed: %s; skip", row[i].String, err)
+				continue
+			}
+
+			switch string(colname.Name) {
+			case "apply_error_count":
+				stat.applyErrors = v
+			case "sync_error_count":
+				stat.syncErrors = v
+			default:
+				continue
+			}
+		}
+
+		stats[stat.subname] = stat
+	}
+
+	return stats
+}
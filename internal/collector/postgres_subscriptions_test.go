@@ -0,0 +1,55 @@
+package collector
+
+import (
+	"database/sql"
+	"github.com/jackc/pgproto3/v2"
+	"github.com/lesovsky/pgscv/internal/model"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPostgresSubscriptionsCollector_Update(t *testing.T) {
+	var input = pipelineInput{
+		optional: []string{
+			"postgres_subscription_worker_restarts_total",
+		},
+		collector: NewPostgresSubscriptionsCollector,
+		service:   model.ServiceTypePostgresql,
+	}
+
+	pipeline(t, input)
+}
+
+func Test_parsePostgresSubscriptionsStats(t *testing.T) {
+	var testCases = []struct {
+		name string
+		res  *model.PGResult
+		want map[string]postgresSubscriptionStat
+	}{
+		{
+			name: "normal output",
+			res: &model.PGResult{
+				Nrows: 2,
+				Ncols: 3,
+				Colnames: []pgproto3.FieldDescription{
+					{Name: []byte("subname")}, {Name: []byte("apply_error_count")}, {Name: []byte("sync_error_count")},
+				},
+				Rows: [][]sql.NullString{
+					{{String: "testsub1", Valid: true}, {String: "12", Valid: true}, {String: "3", Valid: true}},
+					{{String: "testsub2", Valid: true}, {String: "0", Valid: true}, {String: "", Valid: false}},
+				},
+			},
+			want: map[string]postgresSubscriptionStat{
+				"testsub1": {subname: "testsub1", applyErrors: 12, syncErrors: 3},
+				"testsub2": {subname: "testsub2", applyErrors: 0, syncErrors: 0},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := parsePostgresSubscriptionsStats(tc.res, []string{"subname"})
+			assert.EqualValues(t, tc.want, got)
+		})
+	}
+}
@@ -34,10 +34,12 @@ type postgresTablesCollector struct {
 	tupInserted          typedDesc
 	tupUpdated           typedDesc
 	tupHotUpdated        typedDesc
+	tupHotUpdateRatio    typedDesc
 	tupDeleted           typedDesc
 	tupLive              typedDesc
 	tupDead              typedDesc
 	tupModified          typedDesc
+	statsStaleness       typedDesc
 	maintLastVacuumAge   typedDesc
 	maintLastAnalyzeAge  typedDesc
 	maintLastVacuumTime  typedDesc
@@ -100,6 +102,12 @@ func NewPostgresTablesCollector(constLabels labels, settings model.CollectorSett
 			labels, constLabels,
 			settings.Filters,
 		),
+		tupHotUpdateRatio: newBuiltinTypedDesc(
+			descOpts{"postgres", "table", "hot_update_ratio", "Ratio of HOT updates to all updates of tuples (rows) in the table.", 0},
+			prometheus.GaugeValue,
+			labels, constLabels,
+			settings.Filters,
+		),
 		tupDeleted: newBuiltinTypedDesc(
 			descOpts{"postgres", "table", "tuples_deleted_total", "Total number of tuples (rows) have been deleted in the table.", 0},
 			prometheus.CounterValue,
@@ -124,6 +132,12 @@ func NewPostgresTablesCollector(constLabels labels, settings model.CollectorSett
 			labels, constLabels,
 			settings.Filters,
 		),
+		statsStaleness: newBuiltinTypedDesc(
+			descOpts{"postgres", "table", "stats_staleness_ratio", "Ratio of tuples (rows) modified since last analyze to estimated number of tuples in the table.", 0},
+			prometheus.GaugeValue,
+			labels, constLabels,
+			settings.Filters,
+		),
 		maintLastVacuumAge: newBuiltinTypedDesc(
 			descOpts{"postgres", "table", "since_last_vacuum_seconds_total", "Total time since table was vacuumed manually or automatically (not counting VACUUM FULL), in seconds. DEPRECATED.", 0},
 			prometheus.CounterValue,
@@ -177,7 +191,7 @@ func NewPostgresTablesCollector(constLabels labels, settings model.CollectorSett
 
 // Update method collects statistics, parse it and produces metrics that are sent to Prometheus.
 func (c *postgresTablesCollector) Update(config Config, ch chan<- prometheus.Metric) error {
-	conn, err := store.New(config.ConnString)
+	conn, err := store.NewPooled(config.scrapeContext(), config.Pool, config.ConnString)
 	if err != nil {
 		return err
 	}
@@ -194,23 +208,23 @@ func (c *postgresTablesCollector) Update(config Config, ch chan<- prometheus.Met
 		return err
 	}
 
-	for _, d := range databases {
-		// Skip database if not matched to allowed.
-		if config.DatabasesRE != nil && !config.DatabasesRE.MatchString(d) {
-			continue
-		}
+	// Skip databases which are not matched to allowed or matched to excluded.
+	databases = filterDatabases(databases, config.DatabasesRE, config.DatabasesExcludeRE)
 
-		pgconfig.Database = d
-		conn, err := store.NewWithConfig(pgconfig)
+	runPerDatabase(databases, config.DatabasesConcurrency, func(d string) {
+		dbconfig := pgconfig.Copy()
+		dbconfig.Database = d
+		conn, err := store.NewPooledWithConfig(config.scrapeContext(), config.Pool, dbconfig)
 		if err != nil {
-			return err
+			log.Warnf("connect to database '%s' failed: %s; skip", d, err)
+			return
 		}
 
 		res, err := conn.Query(userTablesQuery)
 		conn.Close()
 		if err != nil {
 			log.Warnf("get tables stat of database '%s' failed: %s; skip", d, err)
-			continue
+			return
 		}
 
 		stats := parsePostgresTableStats(res, c.labelNames)
@@ -228,11 +242,21 @@ func (c *postgresTablesCollector) Update(config Config, ch chan<- prometheus.Met
 			ch <- c.tupDeleted.newConstMetric(stat.deleted, stat.database, stat.schema, stat.table)
 			ch <- c.tupHotUpdated.newConstMetric(stat.hotUpdated, stat.database, stat.schema, stat.table)
 
+			// HOT updates ratio makes no sense for tables without updates.
+			if ratio, ok := hotUpdateRatio(stat.hotUpdated, stat.updated); ok {
+				ch <- c.tupHotUpdateRatio.newConstMetric(ratio, stat.database, stat.schema, stat.table)
+			}
+
 			// tuples total stats
 			ch <- c.tupLive.newConstMetric(stat.live, stat.database, stat.schema, stat.table)
 			ch <- c.tupDead.newConstMetric(stat.dead, stat.database, stat.schema, stat.table)
 			ch <- c.tupModified.newConstMetric(stat.modified, stat.database, stat.schema, stat.table)
 
+			// Staleness ratio makes no sense for empty or never analyzed tables.
+			if ratio, ok := statsStalenessRatio(stat.modified, stat.reltuples); ok {
+				ch <- c.statsStaleness.newConstMetric(ratio, stat.database, stat.schema, stat.table)
+			}
+
 			// maintenance stats -- avoid metrics spam produced by inactive tables, don't send metrics if counters are zero.
 			if stat.lastvacuumAge > 0 {
 				ch <- c.maintLastVacuumAge.newConstMetric(stat.lastvacuumAge, stat.database, stat.schema, stat.table)
@@ -288,7 +312,7 @@ func (c *postgresTablesCollector) Update(config Config, ch chan<- prometheus.Met
 			ch <- c.sizes.newConstMetric(stat.sizebytes, stat.database, stat.schema, stat.table)
 			ch <- c.reltuples.newConstMetric(stat.reltuples, stat.database, stat.schema, stat.table)
 		}
-	}
+	})
 
 	return nil
 }
@@ -444,3 +468,22 @@ func parsePostgresTableStats(r *model.PGResult, labelNames []string) map[string]
 
 	return stats
 }
+
+// hotUpdateRatio returns ratio of HOT updates to all updates. Returns false if there were no updates.
+func hotUpdateRatio(hotUpdated, updated float64) (float64, bool) {
+	if updated <= 0 {
+		return 0, false
+	}
+
+	return hotUpdated / updated, true
+}
+
+// statsStalenessRatio returns ratio of tuples modified since last analyze to estimated number of tuples. Returns false
+// if number of tuples is unknown (never analyzed tables have negative reltuples since Postgres 14) or table is empty.
+func statsStalenessRatio(modified, reltuples float64) (float64, bool) {
+	if reltuples <= 0 {
+		return 0, false
+	}
+
+	return modified / reltuples, true
+}
@@ -32,6 +32,8 @@ func TestPostgresTablesCollector_Update(t *testing.T) {
 		},
 		optional: []string{
 			"postgres_table_io_blocks_total",
+			"postgres_table_hot_update_ratio",
+			"postgres_table_stats_staleness_ratio",
 		},
 		collector: NewPostgresTablesCollector,
 		service:   model.ServiceTypePostgresql,
@@ -96,3 +98,44 @@ func Test_parsePostgresTableStats(t *testing.T) {
 		})
 	}
 }
+
+func Test_hotUpdateRatio(t *testing.T) {
+	testcases := []struct {
+		hotUpdated float64
+		updated    float64
+		want       float64
+		ok         bool
+	}{
+		{hotUpdated: 50, updated: 100, want: 0.5, ok: true},
+		{hotUpdated: 100, updated: 100, want: 1, ok: true},
+		{hotUpdated: 0, updated: 100, want: 0, ok: true},
+		{hotUpdated: 0, updated: 0, want: 0, ok: false},
+	}
+
+	for _, tc := range testcases {
+		got, ok := hotUpdateRatio(tc.hotUpdated, tc.updated)
+		assert.Equal(t, tc.ok, ok)
+		assert.Equal(t, tc.want, got)
+	}
+}
+
+func Test_statsStalenessRatio(t *testing.T) {
+	testcases := []struct {
+		modified  float64
+		reltuples float64
+		want      float64
+		ok        bool
+	}{
+		{modified: 50, reltuples: 100, want: 0.5, ok: true},
+		{modified: 300, reltuples: 100, want: 3, ok: true},
+		{modified: 0, reltuples: 100, want: 0, ok: true},
+		{modified: 100, reltuples: 0, want: 0, ok: false},
+		{modified: 100, reltuples: -1, want: 0, ok: false},
+	}
+
+	for _, tc := range testcases {
+		got, ok := statsStalenessRatio(tc.modified, tc.reltuples)
+		assert.Equal(t, tc.ok, ok)
+		assert.Equal(t, tc.want, got)
+	}
+}
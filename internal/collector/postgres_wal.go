@@ -110,7 +110,7 @@ func NewPostgresWalCollector(constLabels labels, settings model.CollectorSetting
 
 // Update method collects statistics, parse it and produces metrics that are sent to Prometheus.
 func (c *postgresWalCollector) Update(config Config, ch chan<- prometheus.Metric) error {
-	conn, err := store.New(config.ConnString)
+	conn, err := store.NewPooled(config.scrapeContext(), config.Pool, config.ConnString)
 	if err != nil {
 		return err
 	}
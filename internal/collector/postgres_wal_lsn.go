@@ -0,0 +1,97 @@
+package collector
+
+import (
+	"github.com/lesovsky/pgscv/internal/model"
+	"github.com/lesovsky/pgscv/internal/store"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	postgresWalLsnQuery96 = "SELECT pg_current_xlog_location() - '0/00000000' AS lsn"
+
+	postgresWalLsnQuery96Standby = "SELECT pg_last_xlog_receive_location() - '0/00000000' AS receive_lsn, " +
+		"pg_last_xlog_replay_location() - '0/00000000' AS replay_lsn"
+
+	postgresWalLsnQueryLatest = "SELECT pg_current_wal_lsn() - '0/00000000' AS lsn"
+
+	postgresWalLsnQueryLatestStandby = "SELECT pg_last_wal_receive_lsn() - '0/00000000' AS receive_lsn, " +
+		"pg_last_wal_replay_lsn() - '0/00000000' AS replay_lsn"
+)
+
+type postgresWalLsnCollector struct {
+	lsn        typedDesc
+	receiveLsn typedDesc
+	replayLsn  typedDesc
+}
+
+// NewPostgresWalLsnCollector returns a new Collector exposing current WAL positions. On primary it is the current
+// write position, on standby these are the last received and replayed positions. Rates could be calculated in Prometheus.
+// For details see https://www.postgresql.org/docs/current/functions-admin.html#FUNCTIONS-ADMIN-BACKUP
+func NewPostgresWalLsnCollector(constLabels labels, settings model.CollectorSettings) (Collector, error) {
+	return &postgresWalLsnCollector{
+		lsn: newBuiltinTypedDesc(
+			descOpts{"postgres", "wal", "lsn_bytes", "Current WAL write position, in bytes.", 0},
+			prometheus.CounterValue,
+			nil, constLabels,
+			settings.Filters,
+		),
+		receiveLsn: newBuiltinTypedDesc(
+			descOpts{"postgres", "wal", "receive_lsn_bytes", "Last WAL position received and synced to disk by streaming replication, in bytes.", 0},
+			prometheus.CounterValue,
+			nil, constLabels,
+			settings.Filters,
+		),
+		replayLsn: newBuiltinTypedDesc(
+			descOpts{"postgres", "wal", "replay_lsn_bytes", "Last WAL position replayed during recovery, in bytes.", 0},
+			prometheus.CounterValue,
+			nil, constLabels,
+			settings.Filters,
+		),
+	}, nil
+}
+
+// Update method collects statistics, parse it and produces metrics that are sent to Prometheus.
+func (c *postgresWalLsnCollector) Update(config Config, ch chan<- prometheus.Metric) error {
+	conn, err := store.NewPooled(config.scrapeContext(), config.Pool, config.ConnString)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	res, err := conn.Query(selectWalLsnQuery(config.serverVersionNum, config.inRecovery))
+	if err != nil {
+		return err
+	}
+
+	// Parser skips NULL values, e.g. receive position is NULL when standby is not streaming.
+	stats := parsePostgresWalStats(res)
+
+	for k, v := range stats {
+		switch k {
+		case "lsn":
+			ch <- c.lsn.newConstMetric(v)
+		case "receive_lsn":
+			ch <- c.receiveLsn.newConstMetric(v)
+		case "replay_lsn":
+			ch <- c.replayLsn.newConstMetric(v)
+		default:
+			continue
+		}
+	}
+
+	return nil
+}
+
+// selectWalLsnQuery returns suitable WAL position query depending on passed version and recovery state.
+func selectWalLsnQuery(version int, recovery bool) string {
+	switch {
+	case version < PostgresV10 && recovery:
+		return postgresWalLsnQuery96Standby
+	case version < PostgresV10:
+		return postgresWalLsnQuery96
+	case recovery:
+		return postgresWalLsnQueryLatestStandby
+	default:
+		return postgresWalLsnQueryLatest
+	}
+}
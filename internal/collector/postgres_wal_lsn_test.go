@@ -0,0 +1,90 @@
+package collector
+
+import (
+	"database/sql"
+	"github.com/jackc/pgproto3/v2"
+	"github.com/lesovsky/pgscv/internal/model"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPostgresWalLsnCollector_Update(t *testing.T) {
+	var input = pipelineInput{
+		optional: []string{
+			"postgres_wal_lsn_bytes",
+			"postgres_wal_receive_lsn_bytes",
+			"postgres_wal_replay_lsn_bytes",
+		},
+		collector: NewPostgresWalLsnCollector,
+		service:   model.ServiceTypePostgresql,
+	}
+
+	pipeline(t, input)
+}
+
+func Test_parsePostgresWalStats_lsn(t *testing.T) {
+	var testCases = []struct {
+		name string
+		res  *model.PGResult
+		want map[string]float64
+	}{
+		{
+			name: "primary",
+			res: &model.PGResult{
+				Nrows:    1,
+				Ncols:    1,
+				Colnames: []pgproto3.FieldDescription{{Name: []byte("lsn")}},
+				Rows:     [][]sql.NullString{{{String: "123456789", Valid: true}}},
+			},
+			want: map[string]float64{"lsn": 123456789},
+		},
+		{
+			name: "standby",
+			res: &model.PGResult{
+				Nrows:    1,
+				Ncols:    2,
+				Colnames: []pgproto3.FieldDescription{{Name: []byte("receive_lsn")}, {Name: []byte("replay_lsn")}},
+				Rows:     [][]sql.NullString{{{String: "123456789", Valid: true}, {String: "123450000", Valid: true}}},
+			},
+			want: map[string]float64{"receive_lsn": 123456789, "replay_lsn": 123450000},
+		},
+		{
+			name: "standby not streaming",
+			res: &model.PGResult{
+				Nrows:    1,
+				Ncols:    2,
+				Colnames: []pgproto3.FieldDescription{{Name: []byte("receive_lsn")}, {Name: []byte("replay_lsn")}},
+				Rows:     [][]sql.NullString{{{Valid: false}, {String: "123450000", Valid: true}}},
+			},
+			want: map[string]float64{"replay_lsn": 123450000},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := parsePostgresWalStats(tc.res)
+			assert.EqualValues(t, tc.want, got)
+		})
+	}
+}
+
+func Test_selectWalLsnQuery(t *testing.T) {
+	var testcases = []struct {
+		version  int
+		recovery bool
+		want     string
+	}{
+		{version: 90605, recovery: false, want: postgresWalLsnQuery96},
+		{version: 90605, recovery: true, want: postgresWalLsnQuery96Standby},
+		{version: 100005, recovery: false, want: postgresWalLsnQueryLatest},
+		{version: 100005, recovery: true, want: postgresWalLsnQueryLatestStandby},
+		{version: 140005, recovery: false, want: postgresWalLsnQueryLatest},
+		{version: 140005, recovery: true, want: postgresWalLsnQueryLatestStandby},
+	}
+
+	for _, tc := range testcases {
+		t.Run("", func(t *testing.T) {
+			assert.Equal(t, tc.want, selectWalLsnQuery(tc.version, tc.recovery))
+		})
+	}
+}
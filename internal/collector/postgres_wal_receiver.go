@@ -0,0 +1,176 @@
+package collector
+
+import (
+	"github.com/lesovsky/pgscv/internal/log"
+	"github.com/lesovsky/pgscv/internal/model"
+	"github.com/lesovsky/pgscv/internal/store"
+	"github.com/prometheus/client_golang/prometheus"
+	"strconv"
+)
+
+const (
+	// Sender host is extracted from conninfo in versions before 12, sender_host is not available there.
+	postgresWalReceiverQuery96 = "SELECT status, coalesce(substring(conninfo from 'host=([^ ]*)'), '') AS sender_host, " +
+		"received_lsn - '0/00000000' AS received_lsn, latest_end_lsn - '0/00000000' AS latest_end_lsn, " +
+		"extract(epoch from clock_timestamp() - last_msg_send_time) AS last_msg_send_lag_seconds, " +
+		"extract(epoch from clock_timestamp() - last_msg_receipt_time) AS last_msg_receipt_lag_seconds " +
+		"FROM pg_stat_wal_receiver"
+
+	postgresWalReceiverQuery12 = "SELECT status, coalesce(sender_host, '') AS sender_host, " +
+		"received_lsn - '0/00000000' AS received_lsn, latest_end_lsn - '0/00000000' AS latest_end_lsn, " +
+		"extract(epoch from clock_timestamp() - last_msg_send_time) AS last_msg_send_lag_seconds, " +
+		"extract(epoch from clock_timestamp() - last_msg_receipt_time) AS last_msg_receipt_lag_seconds " +
+		"FROM pg_stat_wal_receiver"
+
+	// Since 13, received_lsn has been replaced with written_lsn and flushed_lsn.
+	postgresWalReceiverQueryLatest = "SELECT status, coalesce(sender_host, '') AS sender_host, " +
+		"flushed_lsn - '0/00000000' AS received_lsn, latest_end_lsn - '0/00000000' AS latest_end_lsn, " +
+		"extract(epoch from clock_timestamp() - last_msg_send_time) AS last_msg_send_lag_seconds, " +
+		"extract(epoch from clock_timestamp() - last_msg_receipt_time) AS last_msg_receipt_lag_seconds " +
+		"FROM pg_stat_wal_receiver"
+)
+
+type postgresWalReceiverCollector struct {
+	status         typedDesc
+	receivedLsn    typedDesc
+	latestEndLsn   typedDesc
+	lastMsgSendLag typedDesc
+	lastMsgRecvLag typedDesc
+}
+
+// NewPostgresWalReceiverCollector returns a new Collector exposing state of WAL receiver on standby.
+// For details see https://www.postgresql.org/docs/current/monitoring-stats.html#MONITORING-PG-STAT-WAL-RECEIVER-VIEW
+func NewPostgresWalReceiverCollector(constLabels labels, settings model.CollectorSettings) (Collector, error) {
+	return &postgresWalReceiverCollector{
+		status: newBuiltinTypedDesc(
+			descOpts{"postgres", "wal_receiver", "status", "Labeled information about WAL receiver status, always 1.", 0},
+			prometheus.GaugeValue,
+			[]string{"status", "sender_host"}, constLabels,
+			settings.Filters,
+		),
+		receivedLsn: newBuiltinTypedDesc(
+			descOpts{"postgres", "wal_receiver", "received_lsn_bytes", "Last WAL position received and flushed to disk by WAL receiver, in bytes.", 0},
+			prometheus.CounterValue,
+			[]string{"sender_host"}, constLabels,
+			settings.Filters,
+		),
+		latestEndLsn: newBuiltinTypedDesc(
+			descOpts{"postgres", "wal_receiver", "latest_end_lsn_bytes", "Last WAL position reported to origin WAL sender, in bytes.", 0},
+			prometheus.CounterValue,
+			[]string{"sender_host"}, constLabels,
+			settings.Filters,
+		),
+		lastMsgSendLag: newBuiltinTypedDesc(
+			descOpts{"postgres", "wal_receiver", "last_msg_send_lag_seconds", "Time elapsed since last message has been sent by origin WAL sender, in seconds.", 0},
+			prometheus.GaugeValue,
+			[]string{"sender_host"}, constLabels,
+			settings.Filters,
+		),
+		lastMsgRecvLag: newBuiltinTypedDesc(
+			descOpts{"postgres", "wal_receiver", "last_msg_receipt_lag_seconds", "Time elapsed since last message has been received from origin WAL sender, in seconds.", 0},
+			prometheus.GaugeValue,
+			[]string{"sender_host"}, constLabels,
+			settings.Filters,
+		),
+	}, nil
+}
+
+// Update method collects statistics, parse it and produces metrics that are sent to Prometheus.
+func (c *postgresWalReceiverCollector) Update(config Config, ch chan<- prometheus.Metric) error {
+	// WAL receiver runs only on standby, pg_stat_wal_receiver is available since 9.6.
+	if !config.inRecovery || config.serverVersionNum < PostgresV96 {
+		return nil
+	}
+
+	conn, err := store.NewPooled(config.scrapeContext(), config.Pool, config.ConnString)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	res, err := conn.Query(selectWalReceiverQuery(config.serverVersionNum))
+	if err != nil {
+		return err
+	}
+
+	// View is empty when WAL receiver is not running, e.g. standby recovers WAL from archive.
+	stats, ok := parsePostgresWalReceiverStats(res)
+	if !ok {
+		return nil
+	}
+
+	ch <- c.status.newConstMetric(1, stats.status, stats.senderHost)
+
+	for k, v := range stats.values {
+		switch k {
+		case "received_lsn":
+			ch <- c.receivedLsn.newConstMetric(v, stats.senderHost)
+		case "latest_end_lsn":
+			ch <- c.latestEndLsn.newConstMetric(v, stats.senderHost)
+		case "last_msg_send_lag_seconds":
+			ch <- c.lastMsgSendLag.newConstMetric(v, stats.senderHost)
+		case "last_msg_receipt_lag_seconds":
+			ch <- c.lastMsgRecvLag.newConstMetric(v, stats.senderHost)
+		default:
+			continue
+		}
+	}
+
+	return nil
+}
+
+// postgresWalReceiverStat describes state of WAL receiver.
+type postgresWalReceiverStat struct {
+	status     string
+	senderHost string
+	values     map[string]float64
+}
+
+// parsePostgresWalReceiverStats parses result of WAL receiver query. False is returned if WAL receiver is not running.
+func parsePostgresWalReceiverStats(r *model.PGResult) (postgresWalReceiverStat, bool) {
+	log.Debug("parse postgres WAL receiver stats")
+
+	// There is only one WAL receiver.
+	if len(r.Rows) == 0 {
+		return postgresWalReceiverStat{}, false
+	}
+
+	stat := postgresWalReceiverStat{values: map[string]float64{}}
+
+	row := r.Rows[0]
+	for i, colname := range r.Colnames {
+		switch string(colname.Name) {
+		case "status":
+			stat.status = row[i].String
+		case "sender_host":
+			stat.senderHost = row[i].String
+		default:
+			// Skip empty (NULL) values, e.g. when no messages have been received yet.
+			if !row[i].Valid {
+				continue
+			}
+
+			v, err := strconv.ParseFloat(row[i].String, 64)
+			if err != nil {
+				log.Errorf("invalid input, parse '%s' failed: %s; skip", row[i].String, err)
+				continue
+			}
+
+			stat.values[string(colname.Name)] = v
+		}
+	}
+
+	return stat, true
+}
+
+// selectWalReceiverQuery returns suitable WAL receiver query depending on passed version.
+func selectWalReceiverQuery(version int) string {
+	switch {
+	case version < PostgresV12:
+		return postgresWalReceiverQuery96
+	case version < PostgresV13:
+		return postgresWalReceiverQuery12
+	default:
+		return postgresWalReceiverQueryLatest
+	}
+}
@@ -0,0 +1,117 @@
+package collector
+
+import (
+	"database/sql"
+	"github.com/jackc/pgproto3/v2"
+	"github.com/lesovsky/pgscv/internal/model"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPostgresWalReceiverCollector_Update(t *testing.T) {
+	var input = pipelineInput{
+		optional: []string{
+			"postgres_wal_receiver_status",
+			"postgres_wal_receiver_received_lsn_bytes",
+			"postgres_wal_receiver_latest_end_lsn_bytes",
+			"postgres_wal_receiver_last_msg_send_lag_seconds",
+			"postgres_wal_receiver_last_msg_receipt_lag_seconds",
+		},
+		collector: NewPostgresWalReceiverCollector,
+		service:   model.ServiceTypePostgresql,
+	}
+
+	pipeline(t, input)
+}
+
+func TestPostgresWalReceiverCollector_Update_primary(t *testing.T) {
+	c, err := NewPostgresWalReceiverCollector(labels{}, model.CollectorSettings{})
+	assert.NoError(t, err)
+
+	// Collector should do nothing on primary (even connect to Postgres).
+	ch := make(chan prometheus.Metric, 10)
+	assert.NoError(t, c.Update(Config{postgresServiceConfig: postgresServiceConfig{inRecovery: false, serverVersionNum: PostgresV14}}, ch))
+	assert.Equal(t, 0, len(ch))
+}
+
+func Test_parsePostgresWalReceiverStats(t *testing.T) {
+	colnames := []pgproto3.FieldDescription{
+		{Name: []byte("status")}, {Name: []byte("sender_host")}, {Name: []byte("received_lsn")}, {Name: []byte("latest_end_lsn")},
+		{Name: []byte("last_msg_send_lag_seconds")}, {Name: []byte("last_msg_receipt_lag_seconds")},
+	}
+
+	var testCases = []struct {
+		name string
+		res  *model.PGResult
+		want postgresWalReceiverStat
+		ok   bool
+	}{
+		{
+			name: "streaming",
+			res: &model.PGResult{
+				Nrows:    1,
+				Ncols:    6,
+				Colnames: colnames,
+				Rows: [][]sql.NullString{{
+					{String: "streaming", Valid: true}, {String: "10.0.0.1", Valid: true}, {String: "123456789", Valid: true},
+					{String: "123450000", Valid: true}, {String: "0.512", Valid: true}, {String: "0.501", Valid: true},
+				}},
+			},
+			want: postgresWalReceiverStat{
+				status: "streaming", senderHost: "10.0.0.1",
+				values: map[string]float64{
+					"received_lsn": 123456789, "latest_end_lsn": 123450000, "last_msg_send_lag_seconds": 0.512, "last_msg_receipt_lag_seconds": 0.501,
+				},
+			},
+			ok: true,
+		},
+		{
+			name: "starting",
+			res: &model.PGResult{
+				Nrows:    1,
+				Ncols:    6,
+				Colnames: colnames,
+				Rows: [][]sql.NullString{{
+					{String: "starting", Valid: true}, {String: "", Valid: true}, {Valid: false},
+					{Valid: false}, {Valid: false}, {Valid: false},
+				}},
+			},
+			want: postgresWalReceiverStat{status: "starting", senderHost: "", values: map[string]float64{}},
+			ok:   true,
+		},
+		{
+			name: "not running",
+			res:  &model.PGResult{Nrows: 0, Ncols: 6, Colnames: colnames, Rows: [][]sql.NullString{}},
+			want: postgresWalReceiverStat{},
+			ok:   false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := parsePostgresWalReceiverStats(tc.res)
+			assert.Equal(t, tc.ok, ok)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func Test_selectWalReceiverQuery(t *testing.T) {
+	var testcases = []struct {
+		version int
+		want    string
+	}{
+		{version: 90605, want: postgresWalReceiverQuery96},
+		{version: 110005, want: postgresWalReceiverQuery96},
+		{version: 120005, want: postgresWalReceiverQuery12},
+		{version: 130005, want: postgresWalReceiverQueryLatest},
+		{version: 160002, want: postgresWalReceiverQueryLatest},
+	}
+
+	for _, tc := range testcases {
+		t.Run("", func(t *testing.T) {
+			assert.Equal(t, tc.want, selectWalReceiverQuery(tc.version))
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package collector
 
 import (
+	"context"
 	"github.com/lesovsky/pgscv/internal/log"
 	"github.com/lesovsky/pgscv/internal/model"
 	"github.com/prometheus/client_golang/prometheus"
@@ -40,7 +41,7 @@ func pipeline(t *testing.T, input pipelineInput) {
 	switch input.service {
 	case model.ServiceTypePostgresql:
 		config.ConnString = "postgres://pgscv@127.0.0.1/postgres"
-		cfg, err := newPostgresServiceConfig(config.ConnString)
+		cfg, err := newPostgresServiceConfig(context.Background(), config.Pool, config.ConnString, newPgStatStatementsDiscovery(""))
 		assert.NoError(t, err)
 		config.postgresServiceConfig = cfg
 	case model.ServiceTypePgbouncer:
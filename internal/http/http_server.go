@@ -3,22 +3,36 @@ package http
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/lesovsky/pgscv/internal/log"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"io"
+	"net"
 	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 )
 
 // AuthConfig defines configuration settings for authentication.
 type AuthConfig struct {
-	EnableAuth bool   // flag tells about authentication should be enabled
-	Username   string `yaml:"username"` // username used for basic authentication
-	Password   string `yaml:"password"` // password used for basic authentication
-	EnableTLS  bool   // flag tells about TLS should be enabled
-	Keyfile    string `yaml:"keyfile"`  // path to key file
-	Certfile   string `yaml:"certfile"` // path to certificate file
+	EnableAuth  bool   // flag tells about authentication should be enabled
+	Username    string `yaml:"username"`     // username used for basic authentication
+	Password    string `yaml:"password"`     // password used for basic authentication
+	BearerToken string `yaml:"bearer_token"` // token used for bearer authentication
+	EnableTLS   bool   // flag tells about TLS should be enabled
+	Keyfile     string `yaml:"keyfile"`   // path to key file
+	Certfile    string `yaml:"certfile"`  // path to certificate file
+	ClientCA    string `yaml:"client_ca"` // path to CA certificate used for verifying client certificates (mTLS)
 }
 
 // Validate check authentication options of AuthConfig and returns toggle flags.
@@ -33,7 +47,11 @@ func (cfg AuthConfig) Validate() (bool, bool, error) {
 		return false, false, fmt.Errorf("TLS settings invalid")
 	}
 
-	if cfg.Username != "" && cfg.Password != "" {
+	if cfg.ClientCA != "" && cfg.Keyfile == "" {
+		return false, false, fmt.Errorf("TLS settings invalid, client CA requires key and certificate files")
+	}
+
+	if (cfg.Username != "" && cfg.Password != "") || cfg.BearerToken != "" {
 		enableAuth = true
 	}
 
@@ -44,10 +62,20 @@ func (cfg AuthConfig) Validate() (bool, bool, error) {
 	return enableAuth, enableTLS, nil
 }
 
+// UnixAddrPrefix defines prefix of listen addresses which are paths to Unix domain sockets, e.g. 'unix:/run/pgscv.sock'.
+const UnixAddrPrefix = "unix:"
+
+// unixSocketMode defines permissions of created Unix domain sockets, only owner and group are allowed to connect.
+const unixSocketMode = 0660
+
 // ServerConfig defines HTTP server configuration.
 type ServerConfig struct {
-	Addr string
+	Addr string // TCP address or path to Unix domain socket prefixed with UnixAddrPrefix
 	AuthConfig
+	Services func() interface{} // returns monitored services exposed on '/services' endpoint, endpoint is disabled if nil
+	// Gatherer returns gatherer of metrics exposed on '/metrics' endpoint, collecting should be aborted when passed
+	// context is done. prometheus.DefaultGatherer is used if nil.
+	Gatherer func(ctx context.Context) prometheus.Gatherer
 }
 
 // Server defines HTTP server.
@@ -62,17 +90,35 @@ func NewServer(cfg ServerConfig) *Server {
 
 	mux.Handle("/", handleRoot())
 
+	gatherer := cfg.Gatherer
+	if gatherer == nil {
+		gatherer = defaultGatherer
+	}
+
 	if cfg.EnableAuth {
-		mux.Handle("/metrics", basicAuth(cfg.AuthConfig, promhttp.Handler()))
+		mux.Handle("/metrics", authenticate(cfg.AuthConfig, handleMetrics(gatherer)))
 	} else {
-		mux.Handle("/metrics", promhttp.Handler())
+		mux.Handle("/metrics", handleMetrics(gatherer))
 	}
 
+	if cfg.Services != nil {
+		if cfg.EnableAuth {
+			mux.Handle("/services", authenticate(cfg.AuthConfig, handleServices(cfg.Services)))
+		} else {
+			mux.Handle("/services", handleServices(cfg.Services))
+		}
+	}
+
+	return newServer(cfg, mux)
+}
+
+// newServer creates new HTTP server instance with passed handler.
+func newServer(cfg ServerConfig, handler http.Handler) *Server {
 	return &Server{
 		config: cfg,
 		server: &http.Server{
 			Addr:         cfg.Addr,
-			Handler:      mux,
+			Handler:      handler,
 			IdleTimeout:  10 * time.Second,
 			ReadTimeout:  10 * time.Second,
 			WriteTimeout: 30 * time.Second,
@@ -80,15 +126,186 @@ func NewServer(cfg ServerConfig) *Server {
 	}
 }
 
-// Serve method starts listening and serving requests.
+// WithAddr creates new HTTP server instance which listens on passed address and shares handlers and settings with
+// the server.
+func (s *Server) WithAddr(addr string) *Server {
+	cfg := s.config
+	cfg.Addr = addr
+	return newServer(cfg, s.server.Handler)
+}
+
+// Serve method starts listening and serving requests. Returns nil when server has been shut down.
 func (s *Server) Serve() error {
+	err := s.serve()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+
+	return err
+}
+
+// serve starts listening and serving requests, using TLS if it is enabled.
+func (s *Server) serve() error {
 	if s.config.EnableTLS {
+		// Require and verify client certificates if client CA is specified.
+		if s.config.ClientCA != "" {
+			tlsConfig, err := newClientAuthTLSConfig(s.config.ClientCA)
+			if err != nil {
+				return err
+			}
+			s.server.TLSConfig = tlsConfig
+		}
+
+		ln, err := listen(s.server.Addr, ":https")
+		if err != nil {
+			return err
+		}
+
 		log.Infof("listen on https://%s", s.server.Addr)
-		return s.server.ListenAndServeTLS(s.config.Certfile, s.config.Keyfile)
+		return s.server.ServeTLS(ln, s.config.Certfile, s.config.Keyfile)
+	}
+
+	ln, err := listen(s.server.Addr, ":http")
+	if err != nil {
+		return err
 	}
 
 	log.Infof("listen on http://%s", s.server.Addr)
-	return s.server.ListenAndServe()
+	return s.server.Serve(ln)
+}
+
+// listen creates TCP listener or Unix domain socket listener depending on passed address. Default address is used
+// if passed address is empty.
+func listen(addr string, defaultAddr string) (net.Listener, error) {
+	if addr == "" {
+		addr = defaultAddr
+	}
+
+	if strings.HasPrefix(addr, UnixAddrPrefix) {
+		return listenUnix(strings.TrimPrefix(addr, UnixAddrPrefix))
+	}
+
+	return net.Listen("tcp", addr)
+}
+
+// listenUnix creates Unix domain socket listener. Stale socket left after unclean shutdown is removed, but other
+// files are not touched. Socket file is removed when listener is closed.
+func listenUnix(path string) (net.Listener, error) {
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("listen on %s failed: file exists and it is not a socket", path)
+		}
+
+		// Socket is stale if nobody accepts connections on it.
+		if conn, err := net.Dial("unix", path); err == nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("listen on %s failed: socket is in use", path)
+		}
+
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Chmod(path, unixSocketMode); err != nil {
+		_ = ln.Close()
+		return nil, err
+	}
+
+	return ln, nil
+}
+
+// Shutdown gracefully stops the server, waiting for active requests until passed context is done.
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.server.Shutdown(ctx)
+}
+
+// newClientAuthTLSConfig creates TLS config which requires client certificates signed by CA from passed file.
+func newClientAuthTLSConfig(caFile string) (*tls.Config, error) {
+	content, err := os.ReadFile(filepath.Clean(caFile))
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(content) {
+		return nil, fmt.Errorf("no valid certificates found in %s", caFile)
+	}
+
+	return &tls.Config{
+		ClientCAs:  pool,
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		MinVersion: tls.VersionTLS12,
+	}, nil
+}
+
+// scrapeTimeoutHeader defines header used by Prometheus for passing scrape timeout.
+const scrapeTimeoutHeader = "X-Prometheus-Scrape-Timeout-Seconds"
+
+// scrapeTimeoutFraction defines fraction of scrape timeout given to collectors, the rest is left for sending response.
+const scrapeTimeoutFraction = 0.9
+
+// defaultGatherer returns prometheus.DefaultGatherer regardless of passed context.
+func defaultGatherer(context.Context) prometheus.Gatherer { return prometheus.DefaultGatherer }
+
+// handleMetrics defines handler for '/metrics' endpoint. It is the same as promhttp.Handler but also supports
+// OpenMetrics format when it is requested by scraper. Gatherer is requested for context of each request, and the
+// context is cancelled when scrape timeout passed by Prometheus is exceeded.
+func handleMetrics(gatherer func(ctx context.Context) prometheus.Gatherer) http.Handler {
+	opts := promhttp.HandlerOpts{EnableOpenMetrics: true}
+
+	return promhttp.InstrumentMetricHandler(
+		prometheus.DefaultRegisterer,
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			if timeout := scrapeTimeout(r.Header.Get(scrapeTimeoutHeader)); timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, timeout)
+				defer cancel()
+			}
+
+			promhttp.HandlerFor(gatherer(ctx), opts).ServeHTTP(w, r)
+		}),
+	)
+}
+
+// scrapeTimeout returns time given to collectors based on the timeout passed in scrape timeout header. Zero is
+// returned when timeout is not passed or invalid.
+func scrapeTimeout(timeout string) time.Duration {
+	if timeout == "" {
+		return 0
+	}
+
+	seconds, err := strconv.ParseFloat(timeout, 64)
+	if err != nil || seconds <= 0 {
+		log.Warnf("invalid value '%s' of %s header; ignore", timeout, scrapeTimeoutHeader)
+		return 0
+	}
+
+	return time.Duration(seconds * scrapeTimeoutFraction * float64(time.Second))
+}
+
+// handleServices defines handler for '/services' endpoint, it returns monitored services in JSON format.
+func handleServices(services func() interface{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := json.Marshal(services())
+		if err != nil {
+			log.Errorf("marshal services failed: %s", err)
+			http.Error(w, "marshal services failed", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, err = w.Write(body)
+		if err != nil {
+			log.Warnln("response write failed: ", err)
+		}
+	})
 }
 
 // handleRoot defines handler for '/' endpoint.
@@ -110,22 +327,40 @@ pgSCV / PostgreSQL metrics collector, for more info visit <a href="https://githu
 	})
 }
 
-// basicAuth is a middleware for basic authentication.
-func basicAuth(cfg AuthConfig, next http.Handler) http.Handler {
+// authenticate is a middleware for basic and bearer authentication. Request is accepted if it passes any of configured
+// authentication methods.
+func authenticate(cfg AuthConfig, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		username, password, ok := r.BasicAuth()
-		if ok {
-			if username == cfg.Username && password == cfg.Password {
+		if cfg.Username != "" {
+			username, password, ok := r.BasicAuth()
+			if ok && secureCompare(username, cfg.Username) && secureCompare(password, cfg.Password) {
 				next.ServeHTTP(w, r)
 				return
 			}
 		}
 
-		w.Header().Set("WWW-Authenticate", `Basic realm="restricted", charset="UTF-8"`)
+		if cfg.BearerToken != "" {
+			header := r.Header.Get("Authorization")
+			if strings.HasPrefix(header, "Bearer ") && secureCompare(strings.TrimPrefix(header, "Bearer "), cfg.BearerToken) {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		if cfg.Username != "" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="restricted", charset="UTF-8"`)
+		} else {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="restricted"`)
+		}
 		http.Error(w, "Unauthorized", StatusUnauthorized)
 	})
 }
 
+// secureCompare compares strings in constant time to avoid timing attacks.
+func secureCompare(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
 // NewPushRequest creates new HTTP request for sending metrics into remote service.
 func NewPushRequest(url, apiKey, hostname string, payload []byte) (*http.Request, error) {
 	req, err := http.NewRequest("POST", url, bytes.NewReader(payload))
@@ -1,11 +1,26 @@
 package http
 
 import (
+	"compress/gzip"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
 	"io"
+	"math/big"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"regexp"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -20,11 +35,14 @@ func TestAuthConfig_Validate(t *testing.T) {
 	}{
 		{valid: true, cfg: AuthConfig{}, wantAuth: false, wantTls: false},
 		{valid: true, cfg: AuthConfig{Username: "user", Password: "pass"}, wantAuth: true, wantTls: false},
+		{valid: true, cfg: AuthConfig{BearerToken: "token"}, wantAuth: true, wantTls: false},
 		{valid: true, cfg: AuthConfig{Keyfile: "key", Certfile: "cert"}, wantAuth: false, wantTls: true},
 		{valid: false, cfg: AuthConfig{Username: "user", Password: ""}},
 		{valid: false, cfg: AuthConfig{Username: "", Password: "pass"}},
 		{valid: false, cfg: AuthConfig{Keyfile: "key", Certfile: ""}},
 		{valid: false, cfg: AuthConfig{Keyfile: "", Certfile: "cert"}},
+		{valid: true, cfg: AuthConfig{Keyfile: "key", Certfile: "cert", ClientCA: "ca"}, wantAuth: false, wantTls: true},
+		{valid: false, cfg: AuthConfig{ClientCA: "ca"}},
 	}
 
 	for _, tc := range testcases {
@@ -96,6 +114,332 @@ func TestServer_Serve_HTTPS(t *testing.T) {
 	}
 }
 
+func TestServer_Serve_HTTPS_ClientCert(t *testing.T) {
+	caPEM, clientCert := newTestClientCert(t)
+	caFile := filepath.Join(t.TempDir(), "ca.crt")
+	assert.NoError(t, os.WriteFile(caFile, caPEM, 0600))
+
+	addr := "127.0.0.1:17892"
+	srv := NewServer(ServerConfig{Addr: addr, AuthConfig: AuthConfig{
+		EnableTLS: true,
+		Keyfile:   "./testdata/example.key",
+		Certfile:  "./testdata/example.crt",
+		ClientCA:  caFile,
+	}})
+
+	go func() {
+		_ = srv.Serve()
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+
+	// Client without certificate should be rejected.
+	cl := &http.Client{Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, // #nosec G402
+	}}
+	_, err := cl.Get("https://" + addr + "/metrics")
+	assert.Error(t, err)
+
+	// Client with certificate signed by trusted CA should be accepted.
+	cl = &http.Client{Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true, Certificates: []tls.Certificate{clientCert}}, // #nosec G402
+	}}
+	resp, err := cl.Get("https://" + addr + "/metrics")
+	assert.NoError(t, err)
+	assert.Equal(t, StatusOK, resp.StatusCode)
+	_ = resp.Body.Close()
+
+	// Invalid client CA file.
+	srv = NewServer(ServerConfig{Addr: "127.0.0.1:17893", AuthConfig: AuthConfig{
+		EnableTLS: true,
+		Keyfile:   "./testdata/example.key",
+		Certfile:  "./testdata/example.crt",
+		ClientCA:  "./testdata/example.key",
+	}})
+	assert.Error(t, srv.Serve())
+}
+
+func TestServer_WithAddr_Shutdown(t *testing.T) {
+	srv := NewServer(ServerConfig{Addr: "127.0.0.1:17894", AuthConfig: AuthConfig{EnableAuth: true, BearerToken: "token"}})
+	other := srv.WithAddr("127.0.0.1:17895")
+	assert.Equal(t, "127.0.0.1:17895", other.server.Addr)
+	assert.Equal(t, "token", other.config.BearerToken)
+
+	errCh := make(chan error, 2)
+	for _, s := range []*Server{srv, other} {
+		go func(s *Server) { errCh <- s.Serve() }(s)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	// Both servers share handlers, including authentication.
+	cl := NewClient(ClientConfig{})
+	for _, addr := range []string{"127.0.0.1:17894", "127.0.0.1:17895"} {
+		resp, err := cl.Get("http://" + addr + "/metrics")
+		assert.NoError(t, err)
+		assert.Equal(t, StatusUnauthorized, resp.StatusCode)
+		_ = resp.Body.Close()
+	}
+
+	// Serve returns no error after shutdown.
+	assert.NoError(t, srv.Shutdown(context.Background()))
+	assert.NoError(t, other.Shutdown(context.Background()))
+	assert.NoError(t, <-errCh)
+	assert.NoError(t, <-errCh)
+}
+
+func Test_listenUnix(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "pgscv.sock")
+
+	ln, err := listen(UnixAddrPrefix+path, ":http")
+	assert.NoError(t, err)
+	assert.Equal(t, "unix", ln.Addr().Network())
+
+	fi, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(unixSocketMode), fi.Mode().Perm())
+
+	// Socket which is in use is not removed.
+	_, err = listenUnix(path)
+	assert.Error(t, err)
+
+	// Stale socket is removed.
+	stale, err := net.ListenUnix("unix", &net.UnixAddr{Name: path + ".stale", Net: "unix"})
+	assert.NoError(t, err)
+	stale.SetUnlinkOnClose(false)
+	assert.NoError(t, stale.Close())
+
+	ln2, err := listenUnix(path + ".stale")
+	assert.NoError(t, err)
+	assert.NoError(t, ln2.Close())
+
+	// Socket file is removed when listener is closed.
+	assert.NoError(t, ln.Close())
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+
+	// Regular files are not touched.
+	file := filepath.Join(dir, "regular")
+	assert.NoError(t, os.WriteFile(file, []byte("data"), 0600))
+	_, err = listenUnix(file)
+	assert.Error(t, err)
+	_, err = os.Stat(file)
+	assert.NoError(t, err)
+}
+
+// newTestClientCert creates self-signed CA and client certificate signed by the CA. Returns CA certificate in PEM format
+// and client certificate.
+func newTestClientCert(t *testing.T) ([]byte, tls.Certificate) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "pgscv test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	assert.NoError(t, err)
+
+	clientKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	clientTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "pgscv test client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	clientDER, err := x509.CreateCertificate(rand.Reader, clientTemplate, caTemplate, &clientKey.PublicKey, caKey)
+	assert.NoError(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}),
+		tls.Certificate{Certificate: [][]byte{clientDER}, PrivateKey: clientKey}
+}
+
+func TestServer_metricsCompression(t *testing.T) {
+	srv := NewServer(ServerConfig{})
+
+	// Plain text response when compression is not requested.
+	res := httptest.NewRecorder()
+	srv.server.Handler.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, StatusOK, res.Code)
+	assert.Equal(t, "", res.Header().Get("Content-Encoding"))
+	plain := res.Body.String()
+	assert.Contains(t, plain, "# TYPE go_goroutines gauge")
+
+	// Gzipped response when compression is requested.
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	res = httptest.NewRecorder()
+	srv.server.Handler.ServeHTTP(res, req)
+	assert.Equal(t, StatusOK, res.Code)
+	assert.Equal(t, "gzip", res.Header().Get("Content-Encoding"))
+
+	r, err := gzip.NewReader(res.Body)
+	assert.NoError(t, err)
+	body, err := io.ReadAll(r)
+	assert.NoError(t, err)
+
+	// Metrics values change between requests, hence compare metrics types only.
+	assert.Equal(t, metricsTypes(plain), metricsTypes(string(body)))
+}
+
+// metricsTypes returns TYPE lines from metrics exposition.
+func metricsTypes(body string) []string {
+	var types []string
+	for _, line := range strings.Split(body, "\n") {
+		if strings.HasPrefix(line, "# TYPE ") {
+			types = append(types, line)
+		}
+	}
+	return types
+}
+
+func Test_handleMetrics(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", handleMetrics(defaultGatherer))
+
+	// Text format is used by default.
+	res := httptest.NewRecorder()
+	mux.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, StatusOK, res.Code)
+	assert.True(t, strings.HasPrefix(res.Header().Get("Content-Type"), "text/plain"))
+	assert.NotContains(t, res.Body.String(), "# EOF")
+
+	// OpenMetrics format is used when requested.
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Accept", "application/openmetrics-text; version=0.0.1")
+	res = httptest.NewRecorder()
+	mux.ServeHTTP(res, req)
+	assert.Equal(t, StatusOK, res.Code)
+	assert.True(t, strings.HasPrefix(res.Header().Get("Content-Type"), "application/openmetrics-text"))
+
+	body := res.Body.String()
+	assert.True(t, strings.HasSuffix(body, "# EOF\n"))
+
+	// Counters families are exposed without '_total' suffix, but samples have it.
+	assert.Contains(t, body, "# TYPE promhttp_metric_handler_requests counter")
+	assert.Contains(t, body, `promhttp_metric_handler_requests_total{code="200"}`)
+}
+
+func Test_handleMetrics_scrapeTimeout(t *testing.T) {
+	var ctx context.Context
+	gatherer := func(c context.Context) prometheus.Gatherer {
+		ctx = c
+		return prometheus.DefaultGatherer
+	}
+
+	// Context of the request has deadline when scrape timeout is passed.
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set(scrapeTimeoutHeader, "10")
+	res := httptest.NewRecorder()
+	start := time.Now()
+	handleMetrics(gatherer).ServeHTTP(res, req)
+	assert.Equal(t, StatusOK, res.Code)
+
+	deadline, ok := ctx.Deadline()
+	assert.True(t, ok)
+	assert.True(t, deadline.After(start.Add(8*time.Second)))
+	assert.True(t, deadline.Before(start.Add(10*time.Second)))
+
+	// Context is cancelled when the request is finished.
+	assert.Error(t, ctx.Err())
+
+	// No deadline without header.
+	res = httptest.NewRecorder()
+	handleMetrics(gatherer).ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, StatusOK, res.Code)
+	_, ok = ctx.Deadline()
+	assert.False(t, ok)
+}
+
+func Test_scrapeTimeout(t *testing.T) {
+	testcases := []struct {
+		timeout string
+		want    time.Duration
+	}{
+		{timeout: "", want: 0},
+		{timeout: "10", want: 9 * time.Second},
+		{timeout: "2.5", want: 2250 * time.Millisecond},
+		{timeout: "0", want: 0},
+		{timeout: "-1", want: 0},
+		{timeout: "invalid", want: 0},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.timeout, func(t *testing.T) {
+			assert.Equal(t, tc.want, scrapeTimeout(tc.timeout))
+		})
+	}
+}
+
+func Test_handleServices(t *testing.T) {
+	type service struct {
+		ID   string `json:"id"`
+		Type string `json:"type"`
+	}
+
+	services := func() interface{} {
+		return []service{{ID: "system:0", Type: "system"}, {ID: "postgres:5432", Type: "postgres"}}
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/services", handleServices(services))
+
+	res := httptest.NewRecorder()
+	mux.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/services", nil))
+	assert.Equal(t, StatusOK, res.Code)
+	assert.Equal(t, "application/json", res.Header().Get("Content-Type"))
+	assert.JSONEq(t, `[{"id":"system:0","type":"system"},{"id":"postgres:5432","type":"postgres"}]`, res.Body.String())
+
+	// Not serializable value.
+	mux = http.NewServeMux()
+	mux.Handle("/services", handleServices(func() interface{} { return make(chan int) }))
+
+	res = httptest.NewRecorder()
+	mux.ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/services", nil))
+	assert.Equal(t, http.StatusInternalServerError, res.Code)
+}
+
+func TestNewServer_services(t *testing.T) {
+	services := func() interface{} { return []string{} }
+
+	testcases := []struct {
+		name   string
+		cfg    ServerConfig
+		token  string
+		status int
+		json   bool
+	}{
+		{name: "disabled", cfg: ServerConfig{}, status: StatusOK, json: false}, // handled by root handler
+		{name: "enabled", cfg: ServerConfig{Services: services}, status: StatusOK, json: true},
+		{name: "auth, valid token", cfg: ServerConfig{Services: services, AuthConfig: AuthConfig{EnableAuth: true, BearerToken: "token"}}, token: "token", status: StatusOK, json: true},
+		{name: "auth, missing token", cfg: ServerConfig{Services: services, AuthConfig: AuthConfig{EnableAuth: true, BearerToken: "token"}}, status: StatusUnauthorized, json: false},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/services", nil)
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+
+			res := httptest.NewRecorder()
+			NewServer(tc.cfg).server.Handler.ServeHTTP(res, req)
+			assert.Equal(t, tc.status, res.Code)
+			assert.Equal(t, tc.json, res.Header().Get("Content-Type") == "application/json")
+		})
+	}
+}
+
 func Test_handleRoot(t *testing.T) {
 	req := httptest.NewRequest(http.MethodGet, "/", nil)
 	res := httptest.NewRecorder()
@@ -112,28 +456,40 @@ func Test_handleRoot(t *testing.T) {
 	res.Flush()
 }
 
-func Test_basicAuth(t *testing.T) {
+func Test_authenticate(t *testing.T) {
 	testcases := []struct {
 		name   string
+		cfg    AuthConfig
 		user   string
 		pass   string
+		token  string
 		status int
 	}{
-		{name: "valid", user: "user", pass: "pass", status: StatusOK},
-		{name: "empty creds", user: "", pass: "", status: StatusUnauthorized},
-		{name: "empty pass", user: "user", pass: "", status: StatusUnauthorized},
-		{name: "empty user", user: "", pass: "pass", status: StatusUnauthorized},
-		{name: "invalid pass", user: "user", pass: "invalid", status: StatusUnauthorized},
+		{name: "valid", cfg: AuthConfig{Username: "user", Password: "pass"}, user: "user", pass: "pass", status: StatusOK},
+		{name: "empty creds", cfg: AuthConfig{Username: "user", Password: "pass"}, user: "", pass: "", status: StatusUnauthorized},
+		{name: "empty pass", cfg: AuthConfig{Username: "user", Password: "pass"}, user: "user", pass: "", status: StatusUnauthorized},
+		{name: "empty user", cfg: AuthConfig{Username: "user", Password: "pass"}, user: "", pass: "pass", status: StatusUnauthorized},
+		{name: "invalid pass", cfg: AuthConfig{Username: "user", Password: "pass"}, user: "user", pass: "invalid", status: StatusUnauthorized},
+		{name: "valid token", cfg: AuthConfig{BearerToken: "token"}, token: "token", status: StatusOK},
+		{name: "invalid token", cfg: AuthConfig{BearerToken: "token"}, token: "invalid", status: StatusUnauthorized},
+		{name: "missing token", cfg: AuthConfig{BearerToken: "token"}, status: StatusUnauthorized},
+		{name: "basic creds when token required", cfg: AuthConfig{BearerToken: "token"}, user: "user", pass: "pass", status: StatusUnauthorized},
+		{name: "both methods, valid token", cfg: AuthConfig{Username: "user", Password: "pass", BearerToken: "token"}, token: "token", status: StatusOK},
+		{name: "both methods, valid creds", cfg: AuthConfig{Username: "user", Password: "pass", BearerToken: "token"}, user: "user", pass: "pass", status: StatusOK},
 	}
 
 	for _, tc := range testcases {
 		t.Run(tc.name, func(t *testing.T) {
 			mux := http.NewServeMux()
-			mux.Handle("/", basicAuth(AuthConfig{Username: "user", Password: "pass"}, handleRoot()))
+			mux.Handle("/", authenticate(tc.cfg, handleRoot()))
 
 			res := httptest.NewRecorder()
 			req := httptest.NewRequest(http.MethodGet, "/", nil)
-			req.SetBasicAuth(tc.user, tc.pass)
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			} else if tc.user != "" || tc.pass != "" || tc.cfg.Username != "" {
+				req.SetBasicAuth(tc.user, tc.pass)
+			}
 			mux.ServeHTTP(res, req)
 			assert.Equal(t, tc.status, res.Code)
 			res.Flush()
@@ -5,6 +5,7 @@ import (
 	"github.com/jackc/pgproto3/v2"
 	"github.com/lesovsky/pgscv/internal/filter"
 	"regexp"
+	"time"
 )
 
 const (
@@ -14,8 +15,20 @@ const (
 	ServiceTypePostgresql = "postgres"
 	// ServiceTypePgbouncer defines label string for Pgbouncer services.
 	ServiceTypePgbouncer = "pgbouncer"
+
+	// ServiceRolePrimary defines label string for Postgres services which accept writes.
+	ServiceRolePrimary = "primary"
+	// ServiceRoleStandby defines label string for Postgres services which are in recovery.
+	ServiceRoleStandby = "standby"
 )
 
+// BuildInfo describes version information of the application.
+type BuildInfo struct {
+	Version string
+	Commit  string
+	Branch  string
+}
+
 // PGResult is the iterable store that contains query result (data and metadata) returned from Postgres
 type PGResult struct {
 	Nrows    int
@@ -37,6 +50,7 @@ type PGResult struct {
 //        activity:                                             <- MetricsSubsystem
 //          databases: "^db(1|2)$"                              <- MetricsSubsystem.Databases
 //          query: "SELECT l1, l2, l3, v1 FROM t1 WHERE t1"     <- MetricsSubsystem.Query
+//          cache_ttl: 5m                                       <- MetricsSubsystem.CacheTTL
 //          metrics:                                            <- MetricsSubsystem.Metrics
 //            - name: l1                                        <- UserMetric
 //              usage: COUNTER                                  <- UserMetric.Usage
@@ -48,6 +62,14 @@ type PGResult struct {
 //              labeledValues:                                  <- UserMetric.LabeledValues
 //                extra: [ l2, l3 ]
 //              description: v1 description
+//            - name: h1
+//              usage: HISTOGRAM                                <- histogram, query returns a row per bucket with 'le' column
+//              labels: [ l1 ]
+//              value: v2                                       <- cumulative count of observations in the bucket
+//              buckets: [ 0.1, 1, 10 ]                         <- UserMetric.Buckets, buckets upper bounds
+//              sum: v3                                         <- UserMetric.Sum, sum of all observations
+//              count: v4                                       <- UserMetric.Count, count of all observations
+//              description: h1 description
 
 // CollectorsSettings unions all collectors settings in one place.
 type CollectorsSettings map[string]CollectorSettings
@@ -58,6 +80,36 @@ type CollectorSettings struct {
 	Filters filter.Filters `yaml:"filters"`
 	// Subsystems defines subsystem with user-defined metrics.
 	Subsystems Subsystems `yaml:"subsystems"`
+	// ConnectionsBy defines breakdown of connections used by 'postgres/activity' collector.
+	ConnectionsBy ConnectionsBySettings `yaml:"connections_by"`
+	// RunOnlyOnPrimary defines collector runs only if Postgres is not in recovery.
+	RunOnlyOnPrimary bool `yaml:"run_only_on_primary"`
+	// RunOnlyOnStandby defines collector runs only if Postgres is in recovery.
+	RunOnlyOnStandby bool `yaml:"run_only_on_standby"`
+	// Enabled enables opt-in collectors which collect nothing by default, e.g. 'postgres/backend_memory'.
+	Enabled bool `yaml:"enabled"`
+	// PerCPU enables collecting usage stats of each CPU core by 'system/cpu' collector.
+	PerCPU bool `yaml:"per_cpu"`
+	// IndexBloat enables estimation of indexes bloat by 'postgres/schemas' collector (requires heavy queries).
+	IndexBloat bool `yaml:"index_bloat"`
+	// TableBloat enables estimation of tables bloat by 'postgres/schemas' collector (requires heavy queries).
+	TableBloat bool `yaml:"table_bloat"`
+	// Relations defines relations (in 'database/schema/relation' format) which stats are collected by
+	// 'postgres/pgstattuple' collector.
+	Relations []string `yaml:"relations"`
+	// BloatMinSize defines minimal size of relations (in bytes) for which bloat is estimated, zero means default size.
+	BloatMinSize int64 `yaml:"bloat_min_size"`
+}
+
+// ConnectionsBySettings defines breakdown of connections by user, database, application_name and state. Number of
+// distinct application names is limited, excess names are collapsed into "other".
+type ConnectionsBySettings struct {
+	// Enabled enables breakdown of connections.
+	Enabled bool `yaml:"enabled"`
+	// ApplicationNames defines allow-list of application names. If specified, TopN is not used.
+	ApplicationNames []string `yaml:"application_names"`
+	// TopN defines max number of application names with the most connections, zero means default limit.
+	TopN int `yaml:"top_n"`
 }
 
 // Subsystems unions all subsystems in one place.
@@ -71,6 +123,8 @@ type MetricsSubsystem struct {
 	DatabasesRE *regexp.Regexp
 	// Query defines a SQL statement used for getting label/values for metrics.
 	Query string `yaml:"query"`
+	// CacheTTL defines period during which query result is cached and reused, zero value means caching is disabled.
+	CacheTTL time.Duration `yaml:"cache_ttl"`
 	// Metrics defines a list of labels and metrics should be extracted from Query result.
 	Metrics Metrics `yaml:"metrics"`
 }
@@ -86,4 +140,7 @@ type UserMetric struct {
 	Value         string              `yaml:"value"`
 	LabeledValues map[string][]string `yaml:"labeled_values,omitempty"`
 	Description   string              `yaml:"description"`
+	Buckets       []float64           `yaml:"buckets,omitempty"`
+	Sum           string              `yaml:"sum,omitempty"`
+	Count         string              `yaml:"count,omitempty"`
 }
@@ -0,0 +1,52 @@
+package pgscv
+
+import (
+	"fmt"
+	"github.com/lesovsky/pgscv/internal/log"
+	"github.com/lesovsky/pgscv/internal/service"
+	"io"
+)
+
+// Check connects to configured services, runs each enabled collector once and prints results into passed writer.
+// Error is returned if any service or collector has failed.
+func Check(config *Config, w io.Writer) error {
+	log.Debug("check collectors")
+
+	setupGlobals(config)
+
+	serviceRepo, err := newServiceRepo(config)
+	if err != nil {
+		return err
+	}
+
+	return printCheckResults(w, serviceRepo.CheckServices())
+}
+
+// printCheckResults prints results of collectors checks and returns error if any service or collector has failed.
+func printCheckResults(w io.Writer, results []service.CheckResult) error {
+	var failed int
+
+	for _, sr := range results {
+		if sr.Err != nil {
+			failed++
+			_, _ = fmt.Fprintf(w, "[%s] FAILED: %s\n", sr.ServiceID, sr.Err)
+			continue
+		}
+
+		for _, cr := range sr.Results {
+			if cr.Err != nil {
+				failed++
+				_, _ = fmt.Fprintf(w, "[%s] %s: FAILED, %d metrics: %s\n", sr.ServiceID, cr.Collector, cr.Metrics, cr.Err)
+				continue
+			}
+
+			_, _ = fmt.Fprintf(w, "[%s] %s: OK, %d metrics\n", sr.ServiceID, cr.Collector, cr.Metrics)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d checks failed", failed)
+	}
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package pgscv
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/lesovsky/pgscv/internal/collector"
+	"github.com/lesovsky/pgscv/internal/service"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_printCheckResults(t *testing.T) {
+	testcases := []struct {
+		name    string
+		results []service.CheckResult
+		want    string
+		valid   bool
+	}{
+		{
+			name: "all succeeded",
+			results: []service.CheckResult{
+				{ServiceID: "system:0", Results: []collector.CheckResult{
+					{Collector: "system/cpu", Metrics: 10},
+					{Collector: "system/memory", Metrics: 0},
+				}},
+			},
+			want:  "[system:0] system/cpu: OK, 10 metrics\n[system:0] system/memory: OK, 0 metrics\n",
+			valid: true,
+		},
+		{
+			name: "collector failed",
+			results: []service.CheckResult{
+				{ServiceID: "postgres:5432", Results: []collector.CheckResult{
+					{Collector: "postgres/activity", Metrics: 5},
+					{Collector: "postgres/wal", Metrics: 1, Err: fmt.Errorf("test error")},
+				}},
+			},
+			want:  "[postgres:5432] postgres/activity: OK, 5 metrics\n[postgres:5432] postgres/wal: FAILED, 1 metrics: test error\n",
+			valid: false,
+		},
+		{
+			name: "service failed",
+			results: []service.CheckResult{
+				{ServiceID: "postgres:5432", Err: fmt.Errorf("test error")},
+			},
+			want:  "[postgres:5432] FAILED: test error\n",
+			valid: false,
+		},
+		{
+			name:  "nothing checked",
+			want:  "",
+			valid: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			err := printCheckResults(buf, tc.results)
+			if tc.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+			assert.Equal(t, tc.want, buf.String())
+		})
+	}
+}
@@ -3,15 +3,21 @@ package pgscv
 import (
 	"fmt"
 	"github.com/jackc/pgx/v4"
+	"github.com/lesovsky/pgscv/internal/collector"
 	"github.com/lesovsky/pgscv/internal/http"
 	"github.com/lesovsky/pgscv/internal/log"
 	"github.com/lesovsky/pgscv/internal/model"
+	"github.com/lesovsky/pgscv/internal/remotewrite"
 	"github.com/lesovsky/pgscv/internal/service"
+	"github.com/lesovsky/pgscv/internal/store"
 	"gopkg.in/yaml.v2"
+	"net"
 	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 )
 
 const (
@@ -24,15 +30,38 @@ const (
 
 // Config defines application's configuration.
 type Config struct {
-	NoTrackMode           bool                     `yaml:"no_track_mode"`      // controls tracking sensitive information (query texts, etc)
-	ListenAddress         string                   `yaml:"listen_address"`     // Network address and port where the application should listen on
-	ServicesConnsSettings service.ConnsSettings    `yaml:"services"`           // All connections settings for exact services
-	Defaults              map[string]string        `yaml:"defaults"`           // Defaults
-	DisableCollectors     []string                 `yaml:"disable_collectors"` // List of collectors which should be disabled. DEPRECATED in favor collectors settings
-	CollectorsSettings    model.CollectorsSettings `yaml:"collectors"`         // Collectors settings propagated from main YAML configuration
-	Databases             string                   `yaml:"databases"`          // Regular expression string specifies databases from which metrics should be collected
-	DatabasesRE           *regexp.Regexp           // Regular expression object compiled from Databases
-	AuthConfig            http.AuthConfig          `yaml:"authentication"` // TLS and Basic auth configuration
+	NoTrackMode           bool                       `yaml:"no_track_mode"`      // controls tracking sensitive information (query texts, etc)
+	ListenAddress         string                     `yaml:"listen_address"`     // Network address and port where the application should listen on
+	ListenAddresses       []string                   `yaml:"listen_addresses"`   // Extra addresses where the application should listen on, 'unix:/path' for Unix domain sockets
+	ServicesConnsSettings service.ConnsSettings      `yaml:"services"`           // All connections settings for exact services
+	Defaults              map[string]string          `yaml:"defaults"`           // Defaults
+	DisableCollectors     []string                   `yaml:"disable_collectors"` // List of collectors which should be disabled. DEPRECATED in favor collectors settings
+	EnableCollectors      []string                   `yaml:"enable_collectors"`  // List of collectors which should be enabled even if they are disabled
+	CollectorsSettings    model.CollectorsSettings   `yaml:"collectors"`         // Collectors settings propagated from main YAML configuration
+	Databases             string                     `yaml:"databases"`          // Regular expression string specifies databases from which metrics should be collected
+	DatabasesRE           *regexp.Regexp             // Regular expression object compiled from Databases
+	DatabasesExclude      string                     `yaml:"databases_exclude"` // Regular expression string specifies databases which should be skipped by per-database collectors
+	DatabasesExcludeRE    *regexp.Regexp             // Regular expression object compiled from DatabasesExclude
+	AuthConfig            http.AuthConfig            `yaml:"authentication"`           // TLS and Basic auth configuration
+	WarmupPeriod          time.Duration              `yaml:"warmup_period"`            // Period after start during which failed services are not marked as down
+	ConnectTimeout        time.Duration              `yaml:"connect_timeout"`          // Timeout used for establishing connections to services
+	StatementTimeout      time.Duration              `yaml:"statement_timeout"`        // Timeout used for executing queries
+	DatabasesConcurrency  int                        `yaml:"databases_concurrency"`    // Max number of databases processed concurrently by per-database collectors
+	MaxSeriesPerService   int                        `yaml:"max_series_per_service"`   // Max number of series exported per service during single scrape, 0 means unlimited
+	MaxSeriesPerCollector int                        `yaml:"max_series_per_collector"` // Max number of series exported by each collector during single scrape, 0 means unlimited
+	MaxLabelValueLength   int                        `yaml:"max_label_value_length"`   // Max length of labels values, longer values are truncated, 0 means unlimited
+	DockerDiscovery       bool                       `yaml:"docker_discovery"`         // Discover Postgres services running in Docker containers
+	DockerSocket          string                     `yaml:"docker_socket"`            // Path to Docker API socket used for discovery
+	StateFile             string                     `yaml:"state_file"`               // Path to file where last-known services are kept across restarts
+	Statements            collector.StatementsConfig `yaml:"statements"`               // Settings of pg_stat_statements collector
+	Labels                map[string]string          `yaml:"labels"`                   // Constant labels attached to metrics of all services
+	MetricsPrefix         string                     `yaml:"metrics_prefix"`           // Prefix prepended to names of all metrics
+	MetricsAllowList      []string                   `yaml:"metrics_allow_list"`       // Glob patterns of metrics names allowed to be exposed, empty list means all metrics
+	ProcfsPath            string                     `yaml:"procfs_path"`              // Mount point of proc filesystem used for system metrics
+	SysfsPath             string                     `yaml:"sysfs_path"`               // Mount point of sys filesystem used for system metrics
+	RemoteWrite           remotewrite.Config         `yaml:"remote_write"`             // Settings of pushing metrics using Prometheus remote write protocol
+	BuildInfo             model.BuildInfo            `yaml:"-"`                        // Version information of the application
+	ConfigFile            string                     `yaml:"-"`                        // Path to configuration file, empty if configuration is read from environment
 }
 
 // NewConfig creates new config based on config file or return default config if config file is not specified.
@@ -59,16 +88,84 @@ func NewConfig(configFilePath string) (*Config, error) {
 
 // Validate checks configuration for stupid values and set defaults
 func (c *Config) Validate() error {
-	if c.ListenAddress == "" {
+	if c.ListenAddress == "" && len(c.ListenAddresses) == 0 {
 		c.ListenAddress = defaultListenAddress
 	}
 
+	for _, addr := range c.listenAddresses() {
+		// Unix domain socket should be specified with absolute path.
+		if strings.HasPrefix(addr, http.UnixAddrPrefix) {
+			if !filepath.IsAbs(strings.TrimPrefix(addr, http.UnixAddrPrefix)) {
+				return fmt.Errorf("invalid listen address '%s': absolute path to socket required", addr)
+			}
+			continue
+		}
+
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			return fmt.Errorf("invalid listen address '%s': %s", addr, err)
+		}
+	}
+
 	if c.NoTrackMode {
 		log.Infoln("no-track enabled for [pg_stat_statements.query].")
 	} else {
 		log.Infoln("no-track disabled, for details check the documentation about 'no_track_mode' option.")
 	}
 
+	if c.WarmupPeriod < 0 {
+		return fmt.Errorf("invalid warmup_period: %s", c.WarmupPeriod)
+	}
+
+	if c.ConnectTimeout < 0 {
+		return fmt.Errorf("invalid connect_timeout: %s", c.ConnectTimeout)
+	}
+
+	if c.StatementTimeout < 0 {
+		return fmt.Errorf("invalid statement_timeout: %s", c.StatementTimeout)
+	}
+
+	if c.DatabasesConcurrency < 0 {
+		return fmt.Errorf("invalid databases_concurrency: %d", c.DatabasesConcurrency)
+	}
+
+	if c.MaxSeriesPerService < 0 {
+		return fmt.Errorf("invalid max_series_per_service: %d", c.MaxSeriesPerService)
+	}
+
+	if c.MaxSeriesPerCollector < 0 {
+		return fmt.Errorf("invalid max_series_per_collector: %d", c.MaxSeriesPerCollector)
+	}
+
+	if c.MaxLabelValueLength < 0 {
+		return fmt.Errorf("invalid max_label_value_length: %d", c.MaxLabelValueLength)
+	}
+
+	if c.Statements.TopN < 0 {
+		return fmt.Errorf("invalid statements top_n: %d", c.Statements.TopN)
+	}
+
+	switch c.Statements.Redact {
+	case "", collector.StatementsRedactHash, collector.StatementsRedactDrop:
+	default:
+		return fmt.Errorf("invalid statements redact mode: '%s'", c.Statements.Redact)
+	}
+
+	if c.DatabasesConcurrency == 0 {
+		c.DatabasesConcurrency = collector.DefaultDatabasesConcurrency
+	}
+
+	if c.ConnectTimeout == 0 {
+		c.ConnectTimeout = store.DefaultConnectTimeout
+	}
+
+	if c.StatementTimeout == 0 {
+		c.StatementTimeout = store.DefaultStatementTimeout
+	}
+
+	if c.DockerDiscovery && c.DockerSocket == "" {
+		c.DockerSocket = service.DefaultDockerSocket
+	}
+
 	// setup defaults
 	if c.Defaults == nil {
 		c.Defaults = map[string]string{}
@@ -106,6 +203,14 @@ func (c *Config) Validate() error {
 				if err != nil {
 					return fmt.Errorf("invalid conninfo for %s: %s", k, err)
 				}
+
+				if s.LocalAddress != "" && net.ParseIP(s.LocalAddress) == nil {
+					return fmt.Errorf("invalid local_address for %s: %s", k, s.LocalAddress)
+				}
+
+				if err := collector.ValidateConstLabels(s.Labels); err != nil {
+					return fmt.Errorf("invalid labels for %s: %s", k, err)
+				}
 			}
 		}
 	}
@@ -117,12 +222,49 @@ func (c *Config) Validate() error {
 	}
 	c.DatabasesRE = re
 
+	// Create 'databases exclude' regexp object for builtin metrics.
+	if c.DatabasesExclude != "" {
+		re, err := regexp.Compile(c.DatabasesExclude)
+		if err != nil {
+			return err
+		}
+		c.DatabasesExcludeRE = re
+	}
+
+	// Validate constant labels attached to metrics of all services.
+	if err := collector.ValidateConstLabels(c.Labels); err != nil {
+		return fmt.Errorf("invalid labels: %s", err)
+	}
+
+	if err := collector.ValidateMetricsPrefix(c.MetricsPrefix); err != nil {
+		return err
+	}
+
+	// Validate patterns used in metrics allow-list.
+	for _, pattern := range c.MetricsAllowList {
+		if err := collector.ValidateMetricPattern(pattern); err != nil {
+			return fmt.Errorf("invalid metrics allow-list pattern '%s': %s", pattern, err)
+		}
+	}
+
+	// Validate patterns used for disabling/enabling collectors.
+	for _, pattern := range append(append([]string{}, c.DisableCollectors...), c.EnableCollectors...) {
+		if err := collector.ValidateCollectorPattern(pattern); err != nil {
+			return fmt.Errorf("invalid collector pattern '%s': %s", pattern, err)
+		}
+	}
+
 	// Validate collector settings.
 	err = validateCollectorSettings(c.CollectorsSettings)
 	if err != nil {
 		return err
 	}
 
+	// Validate remote write settings.
+	if err := c.RemoteWrite.Validate(); err != nil {
+		return err
+	}
+
 	// Validate authentication settings.
 	enableAuth, enableTLS, err := c.AuthConfig.Validate()
 	if err != nil {
@@ -134,6 +276,22 @@ func (c *Config) Validate() error {
 	return nil
 }
 
+// listenAddresses returns all addresses where the application should listen on, without duplicates.
+func (c *Config) listenAddresses() []string {
+	addresses := make([]string, 0, len(c.ListenAddresses)+1)
+	seen := map[string]bool{}
+
+	for _, addr := range append([]string{c.ListenAddress}, c.ListenAddresses...) {
+		if addr == "" || seen[addr] {
+			continue
+		}
+		seen[addr] = true
+		addresses = append(addresses, addr)
+	}
+
+	return addresses
+}
+
 // validateCollectorSettings validates collectors settings passed from main YAML configuration.
 func validateCollectorSettings(cs model.CollectorsSettings) error {
 	if cs == nil || len(cs) == 0 {
@@ -151,6 +309,24 @@ func validateCollectorSettings(cs model.CollectorsSettings) error {
 			return err
 		}
 
+		if settings.RunOnlyOnPrimary && settings.RunOnlyOnStandby {
+			return fmt.Errorf("run_only_on_primary and run_only_on_standby cannot be used together for collector '%s'", csName)
+		}
+
+		if settings.ConnectionsBy.TopN < 0 {
+			return fmt.Errorf("invalid connections_by top_n specified for collector '%s': %d", csName, settings.ConnectionsBy.TopN)
+		}
+
+		if settings.BloatMinSize < 0 {
+			return fmt.Errorf("invalid bloat_min_size specified for collector '%s': %d", csName, settings.BloatMinSize)
+		}
+
+		for _, rel := range settings.Relations {
+			if err := collector.ValidatePgstattupleRelation(rel); err != nil {
+				return fmt.Errorf("invalid relation '%s' specified for collector '%s': %s", rel, csName, err)
+			}
+		}
+
 		// Validate subsystems level
 		for ssName, subsys := range settings.Subsystems {
 			re2 := regexp.MustCompilePOSIX(`^[a-zA-Z0-9_]+$`)
@@ -165,6 +341,10 @@ func validateCollectorSettings(cs model.CollectorsSettings) error {
 				return fmt.Errorf("databases invalid regular expression specified: %s", err)
 			}
 
+			if subsys.CacheTTL < 0 {
+				return fmt.Errorf("invalid cache_ttl specified for subsystem '%s': %s", ssName, subsys.CacheTTL)
+			}
+
 			// Query must be specified if any metrics.
 			if len(subsys.Metrics) > 0 && subsys.Query == "" {
 				return fmt.Errorf("query is not specified for subsystem '%s' metrics", ssName)
@@ -173,7 +353,13 @@ func validateCollectorSettings(cs model.CollectorsSettings) error {
 			// Validate metrics level
 			reMetric := regexp.MustCompile(`^[a-zA-Z0-9_]+$`)
 
+			names := map[string]bool{}
 			for _, m := range subsys.Metrics {
+				if names[m.ShortName] {
+					return fmt.Errorf("metric '%s' is specified more than once in subsystem '%s'", m.ShortName, ssName)
+				}
+				names[m.ShortName] = true
+
 				if m.Value == "" && m.LabeledValues == nil {
 					return fmt.Errorf("value or labeled_values should be specified for metric '%s'", m.ShortName)
 				}
@@ -191,6 +377,21 @@ func validateCollectorSettings(cs model.CollectorsSettings) error {
 					if m.Description == "" {
 						return fmt.Errorf("metric description is not specified for %s", m.ShortName)
 					}
+				case "HISTOGRAM":
+					if !reMetric.MatchString(m.ShortName) {
+						return fmt.Errorf("invalid metric name '%s'", m.ShortName)
+					}
+					if m.Description == "" {
+						return fmt.Errorf("metric description is not specified for %s", m.ShortName)
+					}
+					if m.Value == "" || len(m.Buckets) == 0 || m.Sum == "" || m.Count == "" {
+						return fmt.Errorf("value, buckets, sum and count should be specified for histogram '%s'", m.ShortName)
+					}
+					for _, l := range m.Labels {
+						if l == "le" {
+							return fmt.Errorf("label 'le' is reserved for histogram '%s' buckets", m.ShortName)
+						}
+					}
 				default:
 					return fmt.Errorf("invalid metric usage '%s'", usage)
 				}
@@ -246,6 +447,8 @@ func newConfigFromEnv() (*Config, error) {
 		switch key {
 		case "PGSCV_LISTEN_ADDRESS":
 			config.ListenAddress = value
+		case "PGSCV_LISTEN_ADDRESSES":
+			config.ListenAddresses = strings.Split(strings.Replace(value, " ", "", -1), ",")
 		case "PGSCV_NO_TRACK_MODE":
 			switch value {
 			case "y", "yes", "Yes", "YES", "t", "true", "True", "TRUE", "1", "on":
@@ -255,22 +458,211 @@ func newConfigFromEnv() (*Config, error) {
 			}
 		case "PGSCV_DATABASES":
 			config.Databases = value
+		case "PGSCV_DATABASES_EXCLUDE":
+			config.DatabasesExclude = value
 		case "PGSCV_DISABLE_COLLECTORS":
 			config.DisableCollectors = strings.Split(strings.Replace(value, " ", "", -1), ",")
+		case "PGSCV_ENABLE_COLLECTORS":
+			config.EnableCollectors = strings.Split(strings.Replace(value, " ", "", -1), ",")
+		case "PGSCV_STATEMENTS_EXEC_TIME_STATS":
+			switch value {
+			case "y", "yes", "Yes", "YES", "t", "true", "True", "TRUE", "1", "on":
+				config.Statements.ExecTimeStats = true
+			default:
+				config.Statements.ExecTimeStats = false
+			}
+		case "PGSCV_STATEMENTS_TOP_N":
+			topN, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value '%s' for PGSCV_STATEMENTS_TOP_N: %s", value, err)
+			}
+			config.Statements.TopN = topN
+		case "PGSCV_STATEMENTS_REDACT":
+			config.Statements.Redact = value
+		case "PGSCV_STATEMENTS_DATABASE":
+			config.Statements.Database = value
+		case "PGSCV_STATEMENTS_NORMALIZE":
+			switch value {
+			case "y", "yes", "Yes", "YES", "t", "true", "True", "TRUE", "1", "on":
+				config.Statements.Normalize = true
+			default:
+				config.Statements.Normalize = false
+			}
+		case "PGSCV_METRICS_PREFIX":
+			config.MetricsPrefix = value
+		case "PGSCV_METRICS_ALLOW_LIST":
+			config.MetricsAllowList = strings.Split(strings.Replace(value, " ", "", -1), ",")
+		case "PGSCV_PROCFS_PATH":
+			config.ProcfsPath = value
+		case "PGSCV_SYSFS_PATH":
+			config.SysfsPath = value
+		case "PGSCV_REMOTE_WRITE_URL":
+			config.RemoteWrite.URL = value
+		case "PGSCV_REMOTE_WRITE_INTERVAL":
+			interval, err := time.ParseDuration(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value '%s' for PGSCV_REMOTE_WRITE_INTERVAL: %s", value, err)
+			}
+			config.RemoteWrite.Interval = interval
+		case "PGSCV_REMOTE_WRITE_TIMEOUT":
+			timeout, err := time.ParseDuration(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value '%s' for PGSCV_REMOTE_WRITE_TIMEOUT: %s", value, err)
+			}
+			config.RemoteWrite.Timeout = timeout
+		case "PGSCV_REMOTE_WRITE_RETRIES":
+			retries, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value '%s' for PGSCV_REMOTE_WRITE_RETRIES: %s", value, err)
+			}
+			config.RemoteWrite.Retries = &retries
+		case "PGSCV_REMOTE_WRITE_BACKOFF":
+			backoff, err := time.ParseDuration(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value '%s' for PGSCV_REMOTE_WRITE_BACKOFF: %s", value, err)
+			}
+			config.RemoteWrite.Backoff = backoff
+		case "PGSCV_LABELS":
+			labels, err := parseLabelsEnv(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value '%s' for PGSCV_LABELS: %s", value, err)
+			}
+			config.Labels = labels
+		case "PGSCV_PGSTATTUPLE_RELATIONS":
+			relations := strings.Split(strings.Replace(value, " ", "", -1), ",")
+			updateCollectorSettings(config, "postgres/pgstattuple", func(s *model.CollectorSettings) { s.Relations = relations })
 		case "PGSCV_AUTH_USERNAME":
 			config.AuthConfig.Username = value
 		case "PGSCV_AUTH_PASSWORD":
 			config.AuthConfig.Password = value
+		case "PGSCV_AUTH_BEARER_TOKEN":
+			config.AuthConfig.BearerToken = value
 		case "PGSCV_AUTH_KEYFILE":
 			config.AuthConfig.Keyfile = value
 		case "PGSCV_AUTH_CERTFILE":
 			config.AuthConfig.Certfile = value
+		case "PGSCV_AUTH_CLIENT_CA":
+			config.AuthConfig.ClientCA = value
+		case "PGSCV_WARMUP_PERIOD":
+			period, err := time.ParseDuration(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value '%s' for PGSCV_WARMUP_PERIOD: %s", value, err)
+			}
+			config.WarmupPeriod = period
+		case "PGSCV_CONNECT_TIMEOUT":
+			timeout, err := time.ParseDuration(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value '%s' for PGSCV_CONNECT_TIMEOUT: %s", value, err)
+			}
+			config.ConnectTimeout = timeout
+		case "PGSCV_STATEMENT_TIMEOUT":
+			timeout, err := time.ParseDuration(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value '%s' for PGSCV_STATEMENT_TIMEOUT: %s", value, err)
+			}
+			config.StatementTimeout = timeout
+		case "PGSCV_DATABASES_CONCURRENCY":
+			concurrency, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value '%s' for PGSCV_DATABASES_CONCURRENCY: %s", value, err)
+			}
+			config.DatabasesConcurrency = concurrency
+		case "PGSCV_DOCKER_DISCOVERY":
+			switch value {
+			case "y", "yes", "Yes", "YES", "t", "true", "True", "TRUE", "1", "on":
+				config.DockerDiscovery = true
+			default:
+				config.DockerDiscovery = false
+			}
+		case "PGSCV_DOCKER_SOCKET":
+			config.DockerSocket = value
+		case "PGSCV_STATE_FILE":
+			config.StateFile = value
+		case "PGSCV_MAX_SERIES_PER_SERVICE":
+			limit, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value '%s' for PGSCV_MAX_SERIES_PER_SERVICE: %s", value, err)
+			}
+			config.MaxSeriesPerService = limit
+		case "PGSCV_MAX_SERIES_PER_COLLECTOR":
+			limit, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value '%s' for PGSCV_MAX_SERIES_PER_COLLECTOR: %s", value, err)
+			}
+			config.MaxSeriesPerCollector = limit
+		case "PGSCV_MAX_LABEL_VALUE_LENGTH":
+			length, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value '%s' for PGSCV_MAX_LABEL_VALUE_LENGTH: %s", value, err)
+			}
+			config.MaxLabelValueLength = length
+		case "PGSCV_INDEX_BLOAT":
+			switch value {
+			case "y", "yes", "Yes", "YES", "t", "true", "True", "TRUE", "1", "on":
+				updateCollectorSettings(config, "postgres/schemas", func(s *model.CollectorSettings) { s.IndexBloat = true })
+			default:
+				updateCollectorSettings(config, "postgres/schemas", func(s *model.CollectorSettings) { s.IndexBloat = false })
+			}
+		case "PGSCV_TABLE_BLOAT":
+			switch value {
+			case "y", "yes", "Yes", "YES", "t", "true", "True", "TRUE", "1", "on":
+				updateCollectorSettings(config, "postgres/schemas", func(s *model.CollectorSettings) { s.TableBloat = true })
+			default:
+				updateCollectorSettings(config, "postgres/schemas", func(s *model.CollectorSettings) { s.TableBloat = false })
+			}
+		case "PGSCV_BACKEND_MEMORY":
+			switch value {
+			case "y", "yes", "Yes", "YES", "t", "true", "True", "TRUE", "1", "on":
+				updateCollectorSettings(config, "postgres/backend_memory", func(s *model.CollectorSettings) { s.Enabled = true })
+			default:
+				updateCollectorSettings(config, "postgres/backend_memory", func(s *model.CollectorSettings) { s.Enabled = false })
+			}
+		case "PGSCV_PER_CPU":
+			switch value {
+			case "y", "yes", "Yes", "YES", "t", "true", "True", "TRUE", "1", "on":
+				updateCollectorSettings(config, "system/cpu", func(s *model.CollectorSettings) { s.PerCPU = true })
+			default:
+				updateCollectorSettings(config, "system/cpu", func(s *model.CollectorSettings) { s.PerCPU = false })
+			}
+		case "PGSCV_BLOAT_MIN_SIZE":
+			size, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value '%s' for PGSCV_BLOAT_MIN_SIZE: %s", value, err)
+			}
+			updateCollectorSettings(config, "postgres/schemas", func(s *model.CollectorSettings) { s.BloatMinSize = size })
 		}
 	}
 
 	return config, nil
 }
 
+// updateCollectorSettings applies update to settings of the named collector, settings are created if not exist.
+func updateCollectorSettings(config *Config, name string, update func(s *model.CollectorSettings)) {
+	if config.CollectorsSettings == nil {
+		config.CollectorsSettings = model.CollectorsSettings{}
+	}
+
+	settings := config.CollectorsSettings[name]
+	update(&settings)
+	config.CollectorsSettings[name] = settings
+}
+
+// parseLabelsEnv parses labels specified in 'name1=value1,name2=value2' format.
+func parseLabelsEnv(value string) (map[string]string, error) {
+	labels := map[string]string{}
+
+	for _, pair := range strings.Split(value, ",") {
+		parts := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("label '%s' must be in 'name=value' format", pair)
+		}
+
+		labels[parts[0]] = parts[1]
+	}
+
+	return labels, nil
+}
+
 // toggleAutoupdate control auto-update setting.
 func toggleAutoupdate(value string) (string, error) {
 	// Empty value explicitly set to 'off'.
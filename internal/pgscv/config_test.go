@@ -1,13 +1,16 @@
 package pgscv
 
 import (
+	"github.com/lesovsky/pgscv/internal/collector"
 	"github.com/lesovsky/pgscv/internal/filter"
 	"github.com/lesovsky/pgscv/internal/http"
 	"github.com/lesovsky/pgscv/internal/model"
+	"github.com/lesovsky/pgscv/internal/remotewrite"
 	"github.com/lesovsky/pgscv/internal/service"
 	"github.com/stretchr/testify/assert"
 	"os"
 	"testing"
+	"time"
 )
 
 func TestNewConfig(t *testing.T) {
@@ -175,6 +178,20 @@ func TestConfig_Validate(t *testing.T) {
 				"test": {ServiceType: model.ServiceTypePostgresql, Conninfo: "invalid"},
 			}},
 		},
+		{
+			name:  "valid config with specified services: local address",
+			valid: true,
+			in: &Config{ListenAddress: "127.0.0.1:8080", ServicesConnsSettings: service.ConnsSettings{
+				"test": {ServiceType: model.ServiceTypePostgresql, Conninfo: "host=127.0.0.1 dbname=pgscv_fixtures user=pgscv", LocalAddress: "127.0.0.1"},
+			}},
+		},
+		{
+			name:  "invalid config with specified services: invalid local address",
+			valid: false,
+			in: &Config{ListenAddress: "127.0.0.1:8080", ServicesConnsSettings: service.ConnsSettings{
+				"test": {ServiceType: model.ServiceTypePostgresql, Conninfo: "host=127.0.0.1 dbname=pgscv_fixtures user=pgscv", LocalAddress: "invalid"},
+			}},
+		},
 		{
 			name:  "invalid config: invalid databases string",
 			valid: false,
@@ -190,6 +207,130 @@ func TestConfig_Validate(t *testing.T) {
 			valid: false,
 			in:    &Config{ListenAddress: "127.0.0.1:8080", AuthConfig: http.AuthConfig{Keyfile: "example.key"}},
 		},
+		{
+			name:  "invalid config: negative warm-up period",
+			valid: false,
+			in:    &Config{ListenAddress: "127.0.0.1:8080", WarmupPeriod: -time.Second},
+		},
+		{
+			name:  "invalid config: negative connect timeout",
+			valid: false,
+			in:    &Config{ListenAddress: "127.0.0.1:8080", ConnectTimeout: -time.Second},
+		},
+		{
+			name:  "invalid config: negative statement timeout",
+			valid: false,
+			in:    &Config{ListenAddress: "127.0.0.1:8080", StatementTimeout: -time.Second},
+		},
+		{
+			name:  "invalid config: invalid databases exclude string",
+			valid: false,
+			in:    &Config{ListenAddress: "127.0.0.1:8080", DatabasesExclude: "["},
+		},
+		{
+			name:  "invalid config: invalid collector pattern",
+			valid: false,
+			in:    &Config{ListenAddress: "127.0.0.1:8080", DisableCollectors: []string{"postgres/[invalid"}},
+		},
+		{
+			name:  "invalid config: negative databases concurrency",
+			valid: false,
+			in:    &Config{ListenAddress: "127.0.0.1:8080", DatabasesConcurrency: -1},
+		},
+		{
+			name:  "invalid config: negative max series per service",
+			valid: false,
+			in:    &Config{ListenAddress: "127.0.0.1:8080", MaxSeriesPerService: -1},
+		},
+		{
+			name:  "valid config: multiple listen addresses",
+			valid: true,
+			in:    &Config{ListenAddresses: []string{"127.0.0.1:8080", "[::1]:8080"}},
+		},
+		{
+			name:  "valid config: unix socket listen address",
+			valid: true,
+			in:    &Config{ListenAddresses: []string{"unix:/run/pgscv/pgscv.sock"}},
+		},
+		{
+			name:  "invalid config: relative unix socket path",
+			valid: false,
+			in:    &Config{ListenAddresses: []string{"unix:pgscv.sock"}},
+		},
+		{
+			name:  "invalid config: invalid listen address",
+			valid: false,
+			in:    &Config{ListenAddress: "127.0.0.1:8080", ListenAddresses: []string{"127.0.0.1"}},
+		},
+		{
+			name:  "invalid config: negative max series per collector",
+			valid: false,
+			in:    &Config{ListenAddress: "127.0.0.1:8080", MaxSeriesPerCollector: -1},
+		},
+		{
+			name:  "invalid config: negative max label value length",
+			valid: false,
+			in:    &Config{ListenAddress: "127.0.0.1:8080", MaxLabelValueLength: -1},
+		},
+		{
+			name:  "invalid config: negative statements top_n",
+			valid: false,
+			in:    &Config{ListenAddress: "127.0.0.1:8080", Statements: collector.StatementsConfig{TopN: -1}},
+		},
+		{
+			name:  "invalid config: unknown statements redact mode",
+			valid: false,
+			in:    &Config{ListenAddress: "127.0.0.1:8080", Statements: collector.StatementsConfig{Redact: "invalid"}},
+		},
+		{
+			name:  "valid config with labels",
+			valid: true,
+			in: &Config{ListenAddress: "127.0.0.1:8080", Labels: map[string]string{"env": "prod"}, ServicesConnsSettings: service.ConnsSettings{
+				"test": {ServiceType: model.ServiceTypePostgresql, Conninfo: "host=127.0.0.1", Labels: map[string]string{"cluster": "payments"}},
+			}},
+		},
+		{
+			name:  "invalid config: invalid label name",
+			valid: false,
+			in:    &Config{ListenAddress: "127.0.0.1:8080", Labels: map[string]string{"invalid-name": "prod"}},
+		},
+		{
+			name:  "invalid config: invalid service label name",
+			valid: false,
+			in: &Config{ListenAddress: "127.0.0.1:8080", ServicesConnsSettings: service.ConnsSettings{
+				"test": {ServiceType: model.ServiceTypePostgresql, Conninfo: "host=127.0.0.1", Labels: map[string]string{"service_id": "test"}},
+			}},
+		},
+		{
+			name:  "valid config: metrics prefix",
+			valid: true,
+			in:    &Config{ListenAddress: "127.0.0.1:8080", MetricsPrefix: "acme"},
+		},
+		{
+			name:  "invalid config: invalid metrics prefix",
+			valid: false,
+			in:    &Config{ListenAddress: "127.0.0.1:8080", MetricsPrefix: "acme-prod"},
+		},
+		{
+			name:  "valid config: metrics allow-list",
+			valid: true,
+			in:    &Config{ListenAddress: "127.0.0.1:8080", MetricsAllowList: []string{"postgres_up", "postgres_database_*"}},
+		},
+		{
+			name:  "invalid config: invalid metrics allow-list pattern",
+			valid: false,
+			in:    &Config{ListenAddress: "127.0.0.1:8080", MetricsAllowList: []string{"postgres_[up"}},
+		},
+		{
+			name:  "valid config: remote write",
+			valid: true,
+			in:    &Config{ListenAddress: "127.0.0.1:8080", RemoteWrite: remotewrite.Config{URL: "http://127.0.0.1:9009/api/v1/push"}},
+		},
+		{
+			name:  "invalid config: invalid remote write url",
+			valid: false,
+			in:    &Config{ListenAddress: "127.0.0.1:8080", RemoteWrite: remotewrite.Config{URL: "127.0.0.1:9009/api/v1/push"}},
+		},
 	}
 
 	for _, tc := range testcases {
@@ -243,6 +384,18 @@ func Test_validateCollectorSettings(t *testing.T) {
 				},
 			},
 		},
+		// recovery state gating
+		{valid: true, settings: map[string]model.CollectorSettings{"postgres/conflicts": {RunOnlyOnStandby: true}}},
+		{valid: false, settings: map[string]model.CollectorSettings{"postgres/conflicts": {RunOnlyOnStandby: true, RunOnlyOnPrimary: true}}},
+		// connections breakdown
+		{valid: true, settings: map[string]model.CollectorSettings{"postgres/activity": {ConnectionsBy: model.ConnectionsBySettings{Enabled: true, TopN: 5}}}},
+		{valid: false, settings: map[string]model.CollectorSettings{"postgres/activity": {ConnectionsBy: model.ConnectionsBySettings{Enabled: true, TopN: -1}}}},
+		// bloat estimation
+		{valid: true, settings: map[string]model.CollectorSettings{"postgres/schemas": {IndexBloat: true, BloatMinSize: 1048576}}},
+		{valid: false, settings: map[string]model.CollectorSettings{"postgres/schemas": {IndexBloat: true, BloatMinSize: -1}}},
+		// pgstattuple relations
+		{valid: true, settings: map[string]model.CollectorSettings{"postgres/pgstattuple": {Relations: []string{"exampledb/public/example"}}}},
+		{valid: false, settings: map[string]model.CollectorSettings{"postgres/pgstattuple": {Relations: []string{"public/example"}}}},
 		// invalid collectors names
 		{valid: false, settings: map[string]model.CollectorSettings{"invalid": {}}},
 		{valid: false, settings: map[string]model.CollectorSettings{"invalid/": {}}},
@@ -277,6 +430,22 @@ func Test_validateCollectorSettings(t *testing.T) {
 				},
 			},
 		},
+		{
+			valid: false, // Duplicate metric names in subsystem
+			settings: map[string]model.CollectorSettings{
+				"example/example": {
+					Subsystems: map[string]model.MetricsSubsystem{
+						"example1": {
+							Query: "SELECT 1 as value1, 2 as value2",
+							Metrics: model.Metrics{
+								{ShortName: "v1", Usage: "COUNTER", Value: "value1", Description: "v1 description"},
+								{ShortName: "v1", Usage: "COUNTER", Value: "value2", Description: "v1 description"},
+							},
+						},
+					},
+				},
+			},
+		},
 		{
 			valid: false, // Invalid name for metric
 			settings: map[string]model.CollectorSettings{
@@ -364,6 +533,67 @@ func Test_validateCollectorSettings(t *testing.T) {
 				},
 			},
 		},
+		{
+			valid: false, // Negative cache TTL
+			settings: map[string]model.CollectorSettings{
+				"example/example": {
+					Subsystems: map[string]model.MetricsSubsystem{
+						"example1": {
+							Query:    "SELECT 'L1' as label1, 1 as value1",
+							CacheTTL: -time.Second,
+							Metrics: model.Metrics{
+								{ShortName: "v1", Usage: "COUNTER", Value: "value1", Labels: []string{"label1"}, Description: "description"},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			valid: true, // Histogram
+			settings: map[string]model.CollectorSettings{
+				"example/example": {
+					Subsystems: map[string]model.MetricsSubsystem{
+						"example1": {
+							Query: "SELECT 'L1' as label1, 1 as le, 10 as bucket, 15 as sum, 20 as count",
+							Metrics: model.Metrics{
+								{ShortName: "h1", Usage: "HISTOGRAM", Value: "bucket", Buckets: []float64{1, 10}, Sum: "sum", Count: "count", Labels: []string{"label1"}, Description: "description"},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			valid: false, // Histogram without buckets
+			settings: map[string]model.CollectorSettings{
+				"example/example": {
+					Subsystems: map[string]model.MetricsSubsystem{
+						"example1": {
+							Query: "SELECT 'L1' as label1, 1 as le, 10 as bucket, 15 as sum, 20 as count",
+							Metrics: model.Metrics{
+								{ShortName: "h1", Usage: "HISTOGRAM", Value: "bucket", Sum: "sum", Count: "count", Labels: []string{"label1"}, Description: "description"},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			valid: false, // Histogram with reserved 'le' label
+			settings: map[string]model.CollectorSettings{
+				"example/example": {
+					Subsystems: map[string]model.MetricsSubsystem{
+						"example1": {
+							Query: "SELECT 'L1' as label1, 1 as le, 10 as bucket, 15 as sum, 20 as count",
+							Metrics: model.Metrics{
+								{ShortName: "h1", Usage: "HISTOGRAM", Value: "bucket", Buckets: []float64{1, 10}, Sum: "sum", Count: "count", Labels: []string{"le"}, Description: "description"},
+							},
+						},
+					},
+				},
+			},
+		},
 	}
 
 	for _, tc := range testcases {
@@ -376,6 +606,8 @@ func Test_validateCollectorSettings(t *testing.T) {
 }
 
 func Test_newConfigFromEnv(t *testing.T) {
+	retries := 5
+
 	testcases := []struct {
 		valid   bool
 		envvars map[string]string
@@ -389,24 +621,63 @@ func Test_newConfigFromEnv(t *testing.T) {
 		{
 			valid: true, // Completely valid variables
 			envvars: map[string]string{
-				"PGSCV_LISTEN_ADDRESS":     "127.0.0.1:12345",
-				"PGSCV_NO_TRACK_MODE":      "yes",
-				"PGSCV_DATABASES":          "exampledb",
-				"PGSCV_DISABLE_COLLECTORS": "example/1,example/2, example/3",
-				"POSTGRES_DSN":             "example_dsn",
-				"POSTGRES_DSN_EXAMPLE1":    "example_dsn",
-				"PGBOUNCER_DSN":            "example_dsn",
-				"PGBOUNCER_DSN_EXAMPLE2":   "example_dsn",
-				"PGSCV_AUTH_USERNAME":      "user",
-				"PGSCV_AUTH_PASSWORD":      "pass",
-				"PGSCV_AUTH_KEYFILE":       "keyfile.key",
-				"PGSCV_AUTH_CERTFILE":      "certfile.cert",
+				"PGSCV_LISTEN_ADDRESS":             "127.0.0.1:12345",
+				"PGSCV_LISTEN_ADDRESSES":           "10.0.0.1:12345, [::1]:12345",
+				"PGSCV_NO_TRACK_MODE":              "yes",
+				"PGSCV_DATABASES":                  "exampledb",
+				"PGSCV_DATABASES_EXCLUDE":          "excludedb",
+				"PGSCV_DISABLE_COLLECTORS":         "example/1,example/2, example/3",
+				"PGSCV_ENABLE_COLLECTORS":          "example/2",
+				"POSTGRES_DSN":                     "example_dsn",
+				"POSTGRES_DSN_EXAMPLE1":            "example_dsn",
+				"PGBOUNCER_DSN":                    "example_dsn",
+				"PGBOUNCER_DSN_EXAMPLE2":           "example_dsn",
+				"PGSCV_AUTH_USERNAME":              "user",
+				"PGSCV_AUTH_BEARER_TOKEN":          "token",
+				"PGSCV_AUTH_PASSWORD":              "pass",
+				"PGSCV_AUTH_KEYFILE":               "keyfile.key",
+				"PGSCV_AUTH_CLIENT_CA":             "ca.crt",
+				"PGSCV_AUTH_CERTFILE":              "certfile.cert",
+				"PGSCV_WARMUP_PERIOD":              "30s",
+				"PGSCV_CONNECT_TIMEOUT":            "3s",
+				"PGSCV_STATEMENT_TIMEOUT":          "15s",
+				"PGSCV_DATABASES_CONCURRENCY":      "8",
+				"PGSCV_MAX_SERIES_PER_SERVICE":     "10000",
+				"PGSCV_MAX_SERIES_PER_COLLECTOR":   "1000",
+				"PGSCV_MAX_LABEL_VALUE_LENGTH":     "256",
+				"PGSCV_DOCKER_DISCOVERY":           "yes",
+				"PGSCV_DOCKER_SOCKET":              "/run/docker.sock",
+				"PGSCV_STATE_FILE":                 "/var/lib/pgscv/state.json",
+				"PGSCV_INDEX_BLOAT":                "yes",
+				"PGSCV_TABLE_BLOAT":                "yes",
+				"PGSCV_BACKEND_MEMORY":             "yes",
+				"PGSCV_PER_CPU":                    "yes",
+				"PGSCV_STATEMENTS_EXEC_TIME_STATS": "yes",
+				"PGSCV_STATEMENTS_TOP_N":           "100",
+				"PGSCV_STATEMENTS_REDACT":          "hash",
+				"PGSCV_STATEMENTS_DATABASE":        "exampledb",
+				"PGSCV_STATEMENTS_NORMALIZE":       "yes",
+				"PGSCV_PGSTATTUPLE_RELATIONS":      "exampledb/public/example1, exampledb/public/example2",
+				"PGSCV_BLOAT_MIN_SIZE":             "1048576",
+				"PGSCV_LABELS":                     "env=prod, cluster=payments",
+				"PGSCV_METRICS_PREFIX":             "acme",
+				"PGSCV_METRICS_ALLOW_LIST":         "acme_postgres_up, acme_postgres_database_*",
+				"PGSCV_PROCFS_PATH":                "/host/proc",
+				"PGSCV_SYSFS_PATH":                 "/host/sys",
+				"PGSCV_REMOTE_WRITE_URL":           "http://127.0.0.1:9009/api/v1/push",
+				"PGSCV_REMOTE_WRITE_INTERVAL":      "30s",
+				"PGSCV_REMOTE_WRITE_TIMEOUT":       "5s",
+				"PGSCV_REMOTE_WRITE_RETRIES":       "5",
+				"PGSCV_REMOTE_WRITE_BACKOFF":       "2s",
 			},
 			want: &Config{
 				ListenAddress:     "127.0.0.1:12345",
+				ListenAddresses:   []string{"10.0.0.1:12345", "[::1]:12345"},
 				NoTrackMode:       true,
 				Databases:         "exampledb",
+				DatabasesExclude:  "excludedb",
 				DisableCollectors: []string{"example/1", "example/2", "example/3"},
+				EnableCollectors:  []string{"example/2"},
 				ServicesConnsSettings: map[string]service.ConnSetting{
 					"postgres":  {ServiceType: model.ServiceTypePostgresql, Conninfo: "example_dsn"},
 					"EXAMPLE1":  {ServiceType: model.ServiceTypePostgresql, Conninfo: "example_dsn"},
@@ -414,12 +685,37 @@ func Test_newConfigFromEnv(t *testing.T) {
 					"EXAMPLE2":  {ServiceType: model.ServiceTypePgbouncer, Conninfo: "example_dsn"},
 				},
 				AuthConfig: http.AuthConfig{
-					Username: "user",
-					Password: "pass",
-					Keyfile:  "keyfile.key",
-					Certfile: "certfile.cert",
+					Username:    "user",
+					Password:    "pass",
+					BearerToken: "token",
+					Keyfile:     "keyfile.key",
+					Certfile:    "certfile.cert",
+					ClientCA:    "ca.crt",
+				},
+				WarmupPeriod:          30 * time.Second,
+				ConnectTimeout:        3 * time.Second,
+				StatementTimeout:      15 * time.Second,
+				DatabasesConcurrency:  8,
+				MaxSeriesPerService:   10000,
+				MaxSeriesPerCollector: 1000,
+				MaxLabelValueLength:   256,
+				DockerDiscovery:       true,
+				DockerSocket:          "/run/docker.sock",
+				StateFile:             "/var/lib/pgscv/state.json",
+				CollectorsSettings: model.CollectorsSettings{
+					"postgres/schemas":        {IndexBloat: true, TableBloat: true, BloatMinSize: 1048576},
+					"postgres/pgstattuple":    {Relations: []string{"exampledb/public/example1", "exampledb/public/example2"}},
+					"postgres/backend_memory": {Enabled: true},
+					"system/cpu":              {PerCPU: true},
 				},
-				Defaults: map[string]string{},
+				Statements:       collector.StatementsConfig{ExecTimeStats: true, TopN: 100, Redact: "hash", Database: "exampledb", Normalize: true},
+				Labels:           map[string]string{"env": "prod", "cluster": "payments"},
+				MetricsPrefix:    "acme",
+				MetricsAllowList: []string{"acme_postgres_up", "acme_postgres_database_*"},
+				ProcfsPath:       "/host/proc",
+				SysfsPath:        "/host/sys",
+				RemoteWrite:      remotewrite.Config{URL: "http://127.0.0.1:9009/api/v1/push", Interval: 30 * time.Second, Timeout: 5 * time.Second, Retries: &retries, Backoff: 2 * time.Second},
+				Defaults:         map[string]string{},
 			},
 		},
 		{
@@ -430,6 +726,62 @@ func Test_newConfigFromEnv(t *testing.T) {
 			valid:   false, // Invalid pgbouncer DSN key
 			envvars: map[string]string{"PGBOUNCER_DSN_": "example_dsn"},
 		},
+		{
+			valid:   false, // Invalid warm-up period
+			envvars: map[string]string{"PGSCV_WARMUP_PERIOD": "invalid"},
+		},
+		{
+			valid:   false, // Invalid connect timeout
+			envvars: map[string]string{"PGSCV_CONNECT_TIMEOUT": "invalid"},
+		},
+		{
+			valid:   false, // Invalid statement timeout
+			envvars: map[string]string{"PGSCV_STATEMENT_TIMEOUT": "invalid"},
+		},
+		{
+			valid:   false, // Invalid databases concurrency
+			envvars: map[string]string{"PGSCV_DATABASES_CONCURRENCY": "invalid"},
+		},
+		{
+			valid:   false, // Invalid remote write interval
+			envvars: map[string]string{"PGSCV_REMOTE_WRITE_INTERVAL": "invalid"},
+		},
+		{
+			valid:   false, // Invalid remote write timeout
+			envvars: map[string]string{"PGSCV_REMOTE_WRITE_TIMEOUT": "invalid"},
+		},
+		{
+			valid:   false, // Invalid remote write retries
+			envvars: map[string]string{"PGSCV_REMOTE_WRITE_RETRIES": "invalid"},
+		},
+		{
+			valid:   false, // Invalid remote write backoff
+			envvars: map[string]string{"PGSCV_REMOTE_WRITE_BACKOFF": "invalid"},
+		},
+		{
+			valid:   false, // Invalid max series per service
+			envvars: map[string]string{"PGSCV_MAX_SERIES_PER_SERVICE": "invalid"},
+		},
+		{
+			valid:   false, // Invalid max series per collector
+			envvars: map[string]string{"PGSCV_MAX_SERIES_PER_COLLECTOR": "invalid"},
+		},
+		{
+			valid:   false, // Invalid max label value length
+			envvars: map[string]string{"PGSCV_MAX_LABEL_VALUE_LENGTH": "invalid"},
+		},
+		{
+			valid:   false, // Invalid bloat min size
+			envvars: map[string]string{"PGSCV_BLOAT_MIN_SIZE": "invalid"},
+		},
+		{
+			valid:   false, // Invalid statements top N
+			envvars: map[string]string{"PGSCV_STATEMENTS_TOP_N": "invalid"},
+		},
+		{
+			valid:   false, // Invalid labels
+			envvars: map[string]string{"PGSCV_LABELS": "env"},
+		},
 	}
 
 	for _, tc := range testcases {
@@ -496,3 +848,32 @@ func Test_newDatabasesRegexp(t *testing.T) {
 		}
 	}
 }
+
+func TestConfig_listenAddresses(t *testing.T) {
+	testcases := []struct {
+		config *Config
+		want   []string
+	}{
+		{config: &Config{}, want: []string{}},
+		{config: &Config{ListenAddress: "127.0.0.1:9890"}, want: []string{"127.0.0.1:9890"}},
+		{config: &Config{ListenAddresses: []string{"127.0.0.1:9890", "[::1]:9890"}}, want: []string{"127.0.0.1:9890", "[::1]:9890"}},
+		{
+			config: &Config{ListenAddress: "127.0.0.1:9890", ListenAddresses: []string{"10.0.0.1:9890", "127.0.0.1:9890", "", "10.0.0.1:9890"}},
+			want:   []string{"127.0.0.1:9890", "10.0.0.1:9890"},
+		},
+	}
+
+	for _, tc := range testcases {
+		assert.Equal(t, tc.want, tc.config.listenAddresses())
+	}
+
+	// Default address is used if no addresses specified.
+	config := &Config{}
+	assert.NoError(t, config.Validate())
+	assert.Equal(t, []string{defaultListenAddress}, config.listenAddresses())
+
+	// Default address is not added if extra addresses specified.
+	config = &Config{ListenAddresses: []string{"127.0.0.1:8080"}}
+	assert.NoError(t, config.Validate())
+	assert.Equal(t, []string{"127.0.0.1:8080"}, config.listenAddresses())
+}
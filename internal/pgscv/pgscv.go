@@ -3,36 +3,59 @@ package pgscv
 import (
 	"context"
 	"errors"
+	"fmt"
+	"github.com/lesovsky/pgscv/internal/collector"
 	"github.com/lesovsky/pgscv/internal/http"
 	"github.com/lesovsky/pgscv/internal/log"
+	"github.com/lesovsky/pgscv/internal/remotewrite"
 	"github.com/lesovsky/pgscv/internal/service"
+	"github.com/lesovsky/pgscv/internal/store"
+	"github.com/prometheus/client_golang/prometheus"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
 	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// roleRefreshInterval defines how often roles of Postgres services are refreshed and services in Docker containers
+	// are discovered.
+	roleRefreshInterval = time.Minute
+
+	// listenerShutdownTimeout defines how long metrics listeners wait for active requests during shutdown.
+	listenerShutdownTimeout = 5 * time.Second
 )
 
 // Start is the application's starting point.
 func Start(ctx context.Context, config *Config) error {
 	log.Debug("start application")
 
-	serviceRepo := service.NewRepository()
+	setupGlobals(config)
 
-	serviceConfig := service.Config{
-		NoTrackMode:        config.NoTrackMode,
-		ConnDefaults:       config.Defaults,
-		ConnsSettings:      config.ServicesConnsSettings,
-		DatabasesRE:        config.DatabasesRE,
-		DisabledCollectors: config.DisableCollectors,
-		CollectorsSettings: config.CollectorsSettings,
+	// Register pgSCV own metrics, it should be done after metrics prefix and allow-list are configured.
+	err := service.RegisterMetrics(prometheus.DefaultRegisterer)
+	if err != nil {
+		return err
 	}
 
-	if len(config.ServicesConnsSettings) == 0 {
-		return errors.New("no services defined")
+	err = remotewrite.RegisterMetrics(prometheus.DefaultRegisterer)
+	if err != nil {
+		return err
 	}
 
-	// fulfill service repo using passed services
-	serviceRepo.AddServicesFromConfig(serviceConfig)
+	serviceRepo, err := newServiceRepo(config)
+	if err != nil {
+		return err
+	}
 
-	// setup exporters for all services
-	err := serviceRepo.SetupServices(serviceConfig)
+	// Keep services in state file, hence they could be restored after restart.
+	saveServices(serviceRepo, config)
+
+	// Register collector with pgSCV build info, it doesn't depend on services.
+	err = prometheus.Register(collector.NewBuildInfoCollector(config.BuildInfo))
 	if err != nil {
 		return err
 	}
@@ -46,12 +69,34 @@ func Start(ctx context.Context, config *Config) error {
 	// Start HTTP metrics listener.
 	wg.Add(1)
 	go func() {
-		if err := runMetricsListener(ctx, config); err != nil {
+		if err := runMetricsListener(ctx, config, serviceRepo); err != nil {
 			errCh <- err
 		}
 		wg.Done()
 	}()
 
+	// Reload services on SIGHUP.
+	reloadCh := make(chan os.Signal, 1)
+	signal.Notify(reloadCh, syscall.SIGHUP)
+	defer signal.Stop(reloadCh)
+
+	// Start periodic refresh of services roles and services reloading.
+	wg.Add(1)
+	go func() {
+		runServicesRefresher(ctx, serviceRepo, config, reloadCh)
+		wg.Done()
+	}()
+
+	// Start pushing metrics to remote storage, if configured.
+	if config.RemoteWrite.URL != "" {
+		writer := remotewrite.NewWriter(config.RemoteWrite, newGatherer(ctx, serviceRepo))
+		wg.Add(1)
+		go func() {
+			writer.Run(ctx)
+			wg.Done()
+		}()
+	}
+
 	// Waiting for errors or context cancelling.
 	for {
 		select {
@@ -68,29 +113,271 @@ func Start(ctx context.Context, config *Config) error {
 	}
 }
 
-// runMetricsListener start HTTP listener accordingly to passed configuration.
-func runMetricsListener(ctx context.Context, config *Config) error {
-	srv := http.NewServer(http.ServerConfig{
+// newServiceRepo creates services repository, fulfills it with configured (and discovered) services and setups
+// collectors of the services.
+func newServiceRepo(config *Config) (*service.Repository, error) {
+	serviceRepo := service.NewRepository()
+
+	serviceConfig := newServiceConfig(config, restoreServices(config, discoverServices(config)))
+
+	if len(serviceConfig.ConnsSettings) == 0 {
+		return nil, errors.New("no services defined")
+	}
+
+	// fulfill service repo using passed services
+	serviceRepo.AddServicesFromConfig(serviceConfig)
+
+	// setup exporters for all services
+	err := serviceRepo.SetupServices(serviceConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return serviceRepo, nil
+}
+
+// discoverServices returns connection settings of configured services merged with services discovered in Docker
+// containers, if discovery is enabled. Services defined in config have precedence.
+func discoverServices(config *Config) service.ConnsSettings {
+	if !config.DockerDiscovery {
+		return config.ServicesConnsSettings
+	}
+
+	discovered, err := service.DiscoverDockerServices(config.DockerSocket, config.Defaults)
+	if err != nil {
+		log.Warnf("discover services in docker failed: %s; skip", err)
+	}
+
+	return mergeServices(config.ServicesConnsSettings, discovered)
+}
+
+// rediscoverServices discovers services in Docker containers again and reconciles services in the repository: services
+// of containers started after startup are added, services of stopped containers are removed. Services are kept as is if
+// discovery failed.
+func rediscoverServices(repo *service.Repository, config *Config) error {
+	discovered, err := service.DiscoverDockerServices(config.DockerSocket, config.Defaults)
+	if err != nil {
+		return err
+	}
+
+	return repo.ReconcileServices(newServiceConfig(config, mergeServices(config.ServicesConnsSettings, discovered)))
+}
+
+// mergeServices returns configured services merged with discovered services. Configured services have precedence.
+func mergeServices(configured, discovered service.ConnsSettings) service.ConnsSettings {
+	merged := make(service.ConnsSettings, len(configured)+len(discovered))
+	for id, cs := range discovered {
+		merged[id] = cs
+	}
+	for id, cs := range configured {
+		merged[id] = cs
+	}
+
+	return merged
+}
+
+// setupGlobals applies configuration settings which are shared by all services and collectors. It has to be called
+// before services and collectors are created.
+func setupGlobals(config *Config) {
+	// Setup timeouts used by all connections to services.
+	store.SetTimeouts(config.ConnectTimeout, config.StatementTimeout)
+
+	// Setup prefix of metrics names, it should be done before collectors are created.
+	collector.SetMetricsPrefix(config.MetricsPrefix)
+
+	// Setup allow-list of metrics names, it should be done before collectors are created.
+	collector.SetMetricsAllowList(config.MetricsAllowList)
+
+	// Setup max length of labels values, longer values are truncated.
+	collector.SetMaxLabelValueLength(config.MaxLabelValueLength)
+
+	// Setup mount points of system filesystems used by system collectors.
+	collector.SetSystemPaths(config.ProcfsPath, config.SysfsPath)
+}
+
+// restoreServices returns passed connection settings merged with last-known services restored from state file, if
+// it is configured. Passed services have precedence. Connection to restored services is verified when they are added.
+func restoreServices(config *Config, connsSettings service.ConnsSettings) service.ConnsSettings {
+	if config.StateFile == "" {
+		return connsSettings
+	}
+
+	restored, err := service.LoadState(config.StateFile)
+	if err != nil {
+		log.Warnf("restore services from state file failed: %s; skip", err)
+		return connsSettings
+	}
+
+	return mergeServices(connsSettings, restored)
+}
+
+// saveServices saves services of the repo into state file, if it is configured.
+func saveServices(repo *service.Repository, config *Config) {
+	if config.StateFile == "" {
+		return
+	}
+
+	err := repo.SaveState(config.StateFile)
+	if err != nil {
+		log.Warnf("save services to state file failed: %s", err)
+	}
+}
+
+// newServiceConfig creates services configuration from application's configuration and passed services connection settings.
+func newServiceConfig(config *Config, connsSettings service.ConnsSettings) service.Config {
+	return service.Config{
+		NoTrackMode:           config.NoTrackMode,
+		ConnDefaults:          config.Defaults,
+		ConnsSettings:         connsSettings,
+		DatabasesRE:           config.DatabasesRE,
+		DatabasesExcludeRE:    config.DatabasesExcludeRE,
+		DisabledCollectors:    config.DisableCollectors,
+		EnabledCollectors:     config.EnableCollectors,
+		CollectorsSettings:    config.CollectorsSettings,
+		WarmupPeriod:          config.WarmupPeriod,
+		DatabasesConcurrency:  config.DatabasesConcurrency,
+		MaxSeriesPerService:   config.MaxSeriesPerService,
+		MaxSeriesPerCollector: config.MaxSeriesPerCollector,
+		Statements:            config.Statements,
+		Labels:                config.Labels,
+	}
+}
+
+// reloadServices re-reads configuration and reconciles services in the repository: new services are added, removed
+// services are unregistered, services with changed connection settings are re-created. Last-known services from state
+// file are merged the same way as at startup. Only services are reloaded, reload is rejected if other settings have
+// been changed, they require restart to be applied.
+func reloadServices(repo *service.Repository, current *Config) error {
+	config, err := NewConfig(current.ConfigFile)
+	if err != nil {
+		return err
+	}
+
+	err = config.Validate()
+	if err != nil {
+		return err
+	}
+
+	if changed := changedSettings(current, config); len(changed) > 0 {
+		log.Warnf("settings changed: %s; restart is required to apply them", strings.Join(changed, ", "))
+		return fmt.Errorf("settings other than services changed: %s", strings.Join(changed, ", "))
+	}
+
+	connsSettings := discoverServices(config)
+	if len(connsSettings) == 0 {
+		return errors.New("no services defined")
+	}
+
+	return repo.ReconcileServices(newServiceConfig(config, restoreServices(config, connsSettings)))
+}
+
+// changedSettings returns names of settings which differ in passed configs. Services and settings which are not read
+// from configuration are not compared.
+func changedSettings(current, config *Config) []string {
+	var changed []string
+
+	cv, nv := reflect.ValueOf(*current), reflect.ValueOf(*config)
+	for i := 0; i < cv.NumField(); i++ {
+		name := strings.Split(cv.Type().Field(i).Tag.Get("yaml"), ",")[0]
+
+		// Services are reloaded, regexps are compiled from compared strings.
+		if name == "" || name == "-" || name == "services" {
+			continue
+		}
+
+		if !reflect.DeepEqual(cv.Field(i).Interface(), nv.Field(i).Interface()) {
+			changed = append(changed, name)
+		}
+	}
+
+	return changed
+}
+
+// newGatherer returns gatherer of all metrics - metrics of services and metrics registered in default registry.
+// Collecting metrics of services is aborted when passed context is done.
+func newGatherer(ctx context.Context, repo *service.Repository) prometheus.Gatherer {
+	return prometheus.Gatherers{prometheus.DefaultGatherer, repo.Gatherer(ctx)}
+}
+
+// runMetricsListener starts HTTP listeners on all configured addresses accordingly to passed configuration. Listeners
+// share the same handlers, when one of them fails the others are stopped too. Metrics and info of services are taken
+// from passed repo, nil means only metrics of default registry are exposed.
+func runMetricsListener(ctx context.Context, config *Config, repo *service.Repository) error {
+	srvConfig := http.ServerConfig{
 		Addr:       config.ListenAddress,
 		AuthConfig: config.AuthConfig,
-	})
+	}
 
-	errCh := make(chan error)
-	defer close(errCh)
+	if repo != nil {
+		srvConfig.Services = func() interface{} { return repo.ServicesInfo() }
+		srvConfig.Gatherer = func(ctx context.Context) prometheus.Gatherer { return newGatherer(ctx, repo) }
+	}
 
-	// Run default listener.
-	go func() {
-		errCh <- srv.Serve()
-	}()
+	srv := http.NewServer(srvConfig)
+
+	addresses := config.listenAddresses()
+	servers := make([]*http.Server, 0, len(addresses))
+	for _, addr := range addresses {
+		servers = append(servers, srv.WithAddr(addr))
+	}
+
+	// Channel is buffered, hence listeners don't block when nobody reads errors after shutdown.
+	errCh := make(chan error, len(servers))
+
+	for _, s := range servers {
+		go func(s *http.Server) {
+			errCh <- s.Serve()
+		}(s)
+	}
 
 	// Waiting for errors or context cancelling.
+	var err error
+	select {
+	case <-ctx.Done():
+		log.Info("exit signaled, stop metrics listener")
+	case err = <-errCh:
+		log.Errorf("metrics listener failed: %s; stop other listeners", err)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), listenerShutdownTimeout)
+	defer cancel()
+
+	for _, s := range servers {
+		if e := s.Shutdown(shutdownCtx); e != nil {
+			log.Warnf("shutdown metrics listener failed: %s", e)
+		}
+	}
+
+	return err
+}
+
+// runServicesRefresher periodically refreshes roles of Postgres services, e.g. after failover or promote, discovers
+// services in Docker containers (if enabled) and reloads services when signaled. Both are done in the same goroutine to avoid concurrent changes of the same services.
+func runServicesRefresher(ctx context.Context, repo *service.Repository, config *Config, reloadCh <-chan os.Signal) {
+	ticker := time.NewTicker(roleRefreshInterval)
+	defer ticker.Stop()
+
 	for {
 		select {
 		case <-ctx.Done():
-			log.Info("exit signaled, stop metrics listener")
-			return nil
-		case err := <-errCh:
-			return err
+			log.Info("exit signaled, stop services refresher")
+			return
+		case <-ticker.C:
+			repo.RefreshRoles()
+			if config.DockerDiscovery {
+				if err := rediscoverServices(repo, config); err != nil {
+					log.Warnf("discover services in docker failed: %s; keep current services", err)
+				}
+			}
+			saveServices(repo, config)
+		case sig := <-reloadCh:
+			log.Infof("received %s signal, reload services", sig)
+			if err := reloadServices(repo, config); err != nil {
+				log.Errorf("reload services failed: %s; keep current services", err)
+				continue
+			}
+			log.Info("services reloaded")
+			saveServices(repo, config)
 		}
 	}
 }
@@ -8,6 +8,10 @@ import (
 	"github.com/lesovsky/pgscv/internal/store"
 	"github.com/stretchr/testify/assert"
 	"io"
+	"net"
+	nethttp "net/http"
+	"os"
+	"path/filepath"
 	"sync"
 	"testing"
 	"time"
@@ -41,7 +45,7 @@ func Test_runMetricsListener(t *testing.T) {
 		ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
 		defer cancel()
 
-		err := runMetricsListener(ctx, config)
+		err := runMetricsListener(ctx, config, nil)
 		assert.NoError(t, err)
 		wg.Done()
 	}()
@@ -74,3 +78,261 @@ func Test_runMetricsListener(t *testing.T) {
 	// Waiting for listener goroutine.
 	wg.Wait()
 }
+
+func Test_runMetricsListener_multipleAddresses(t *testing.T) {
+	config := &Config{ListenAddress: "127.0.0.1:5004", ListenAddresses: []string{"127.0.0.1:5005"}}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	errCh := make(chan error)
+	go func() {
+		errCh <- runMetricsListener(ctx, config, nil)
+	}()
+
+	// Sleep a little hoping it will be enough for running listeners.
+	time.Sleep(500 * time.Millisecond)
+
+	// Both listeners serve metrics.
+	cl := http.NewClient(http.ClientConfig{})
+	for _, addr := range []string{"127.0.0.1:5004", "127.0.0.1:5005"} {
+		resp, err := cl.Get("http://" + addr + "/metrics")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		body, err := io.ReadAll(resp.Body)
+		assert.NoError(t, err)
+		assert.Contains(t, string(body), "promhttp_metric_handler_requests_total")
+		assert.NoError(t, resp.Body.Close())
+	}
+
+	// All listeners are stopped on cancel.
+	cancel()
+	assert.NoError(t, <-errCh)
+
+	for _, addr := range []string{"127.0.0.1:5004", "127.0.0.1:5005"} {
+		_, err := cl.Get("http://" + addr + "/metrics")
+		assert.Error(t, err)
+	}
+}
+
+func Test_runMetricsListener_failure(t *testing.T) {
+	// Occupy the address, hence one of listeners fails.
+	ln, err := net.Listen("tcp", "127.0.0.1:5007")
+	assert.NoError(t, err)
+	defer func() { _ = ln.Close() }()
+
+	config := &Config{ListenAddresses: []string{"127.0.0.1:5006", "127.0.0.1:5007"}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Failed listener stops the others and its error is returned without waiting for context.
+	assert.Error(t, runMetricsListener(ctx, config, nil))
+	assert.NoError(t, ctx.Err())
+
+	_, err = http.NewClient(http.ClientConfig{}).Get("http://127.0.0.1:5006/metrics")
+	assert.Error(t, err)
+}
+
+func Test_runMetricsListener_unixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pgscv.sock")
+	config := &Config{ListenAddress: "127.0.0.1:5008", ListenAddresses: []string{"unix:" + path}}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	errCh := make(chan error)
+	go func() {
+		errCh <- runMetricsListener(ctx, config, nil)
+	}()
+
+	// Sleep a little hoping it will be enough for running listeners.
+	time.Sleep(500 * time.Millisecond)
+
+	fi, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0660), fi.Mode().Perm())
+
+	cl := &nethttp.Client{Transport: &nethttp.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+
+	resp, err := cl.Get("http://localhost/metrics")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	assert.Contains(t, string(body), "promhttp_metric_handler_requests_total")
+	assert.NoError(t, resp.Body.Close())
+	cl.CloseIdleConnections()
+
+	// Socket is removed on shutdown.
+	cancel()
+	assert.NoError(t, <-errCh)
+
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func Test_reloadServices(t *testing.T) {
+	repo := service.NewRepository()
+
+	testcases := []struct {
+		name   string
+		config *Config
+	}{
+		{name: "missing config file", config: &Config{ConfigFile: "testdata/nonexistent.yaml"}},
+		{name: "invalid config file", config: &Config{ConfigFile: "testdata/invalid.txt"}},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Error(t, reloadServices(repo, tc.config))
+		})
+	}
+}
+
+func Test_reloadServices_changedSettings(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "pgscv.yaml")
+	data := "services:\n  \"postgres:5432\":\n    service_type: postgres\n    conninfo: \"" + store.TestPostgresConnStr + "\"\n"
+	assert.NoError(t, os.WriteFile(filename, []byte(data), 0600))
+
+	current, err := NewConfig(filename)
+	assert.NoError(t, err)
+	assert.NoError(t, current.Validate())
+	current.ConfigFile = filename
+
+	// Reload is rejected when settings other than services changed, services are not touched.
+	assert.NoError(t, os.WriteFile(filename, []byte(data+"metrics_prefix: test\nmax_series_per_service: 10\n"), 0600))
+
+	err = reloadServices(service.NewRepository(), current)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "max_series_per_service, metrics_prefix")
+}
+
+func Test_changedSettings(t *testing.T) {
+	current := &Config{
+		ServicesConnsSettings: service.ConnsSettings{"test1": {ServiceType: model.ServiceTypePostgresql}},
+		Labels:                map[string]string{"env": "prod"},
+		ConfigFile:            "pgscv.yaml",
+	}
+
+	// Services and settings not read from config are not compared.
+	config := &Config{
+		ServicesConnsSettings: service.ConnsSettings{"test2": {ServiceType: model.ServiceTypePgbouncer}},
+		Labels:                map[string]string{"env": "prod"},
+	}
+	assert.Nil(t, changedSettings(current, config))
+
+	config.Labels = map[string]string{"env": "test"}
+	config.RemoteWrite.URL = "http://127.0.0.1/write"
+	assert.Equal(t, []string{"labels", "remote_write"}, changedSettings(current, config))
+}
+
+func Test_reloadServices_removeService(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "pgscv.yaml")
+
+	writeConfig := func(ids ...string) {
+		data := "state_file: " + filepath.Join(dir, "state.json") + "\nservices:\n"
+		for _, id := range ids {
+			data += "  \"" + id + "\":\n    service_type: postgres\n    conninfo: \"" + store.TestPostgresConnStr + "\"\n"
+		}
+		assert.NoError(t, os.WriteFile(filename, []byte(data), 0600))
+	}
+
+	newConfig := func() *Config {
+		config, err := NewConfig(filename)
+		assert.NoError(t, err)
+		assert.NoError(t, config.Validate())
+		config.ConfigFile = filename
+		return config
+	}
+
+	serviceIDs := func(repo *service.Repository) []string {
+		var ids []string
+		for _, info := range repo.ServicesInfo() {
+			ids = append(ids, info.ID)
+		}
+		return ids
+	}
+
+	writeConfig("postgres:5432", "postgres:5433")
+	config := newConfig()
+	repo, err := newServiceRepo(config)
+	assert.NoError(t, err)
+	assert.Contains(t, serviceIDs(repo), "postgres:5433")
+	saveServices(repo, config)
+
+	// Service removed from config is removed on reload.
+	writeConfig("postgres:5432")
+	assert.NoError(t, reloadServices(repo, config))
+	saveServices(repo, config)
+	assert.Contains(t, serviceIDs(repo), "postgres:5432")
+	assert.NotContains(t, serviceIDs(repo), "postgres:5433")
+
+	// Service removed from config is not restored from state file after restart.
+	config = newConfig()
+	repo, err = newServiceRepo(config)
+	assert.NoError(t, err)
+	assert.Contains(t, serviceIDs(repo), "postgres:5432")
+	assert.NotContains(t, serviceIDs(repo), "postgres:5433")
+}
+
+func Test_newServiceConfig(t *testing.T) {
+	config := &Config{
+		NoTrackMode:           true,
+		Defaults:              map[string]string{"postgres_username": "pgscv"},
+		ServicesConnsSettings: service.ConnsSettings{"test1": {ServiceType: model.ServiceTypePostgresql}},
+		MaxSeriesPerService:   100,
+	}
+
+	// Connection settings are taken from passed settings, other settings are taken from config.
+	connsSettings := service.ConnsSettings{"test2": {ServiceType: model.ServiceTypePgbouncer}}
+	got := newServiceConfig(config, connsSettings)
+
+	assert.Equal(t, connsSettings, got.ConnsSettings)
+	assert.True(t, got.NoTrackMode)
+	assert.Equal(t, config.Defaults, got.ConnDefaults)
+	assert.Equal(t, 100, got.MaxSeriesPerService)
+}
+
+func Test_mergeServices(t *testing.T) {
+	configured := service.ConnsSettings{"postgres:5432": {ServiceType: model.ServiceTypePostgresql, Conninfo: "host=127.0.0.1"}}
+	discovered := service.ConnsSettings{
+		"postgres:5432":       {ServiceType: model.ServiceTypePostgresql, Conninfo: "host=127.0.0.2", Discovered: true},
+		"docker:example:5432": {ServiceType: model.ServiceTypePostgresql, Conninfo: "host=127.0.0.1 port=5432", Discovered: true},
+	}
+
+	// Configured services have precedence, passed settings are not changed.
+	assert.Equal(t, service.ConnsSettings{
+		"postgres:5432":       {ServiceType: model.ServiceTypePostgresql, Conninfo: "host=127.0.0.1"},
+		"docker:example:5432": {ServiceType: model.ServiceTypePostgresql, Conninfo: "host=127.0.0.1 port=5432", Discovered: true},
+	}, mergeServices(configured, discovered))
+	assert.Len(t, configured, 1)
+
+	assert.Equal(t, service.ConnsSettings{}, mergeServices(nil, nil))
+}
+
+func Test_restoreServices(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "state.json")
+	assert.NoError(t, os.WriteFile(filename, []byte(`{"services":[
+		{"id":"postgres:5432","service_type":"postgres","conninfo":"host=127.0.0.2","last_seen":"`+time.Now().Format(time.RFC3339)+`"},
+		{"id":"docker:example:5432","service_type":"postgres","conninfo":"host=127.0.0.1 port=5432","discovered":true,"last_seen":"`+time.Now().Format(time.RFC3339)+`"}
+	]}`), 0600))
+
+	configured := service.ConnsSettings{"postgres:5432": {ServiceType: model.ServiceTypePostgresql, Conninfo: "host=127.0.0.1"}}
+
+	// State file is not configured.
+	assert.Equal(t, configured, restoreServices(&Config{}, configured))
+
+	// Configured services have precedence over restored.
+	assert.Equal(t, service.ConnsSettings{
+		"postgres:5432":       {ServiceType: model.ServiceTypePostgresql, Conninfo: "host=127.0.0.1"},
+		"docker:example:5432": {ServiceType: model.ServiceTypePostgresql, Conninfo: "host=127.0.0.1 port=5432", Discovered: true},
+	}, restoreServices(&Config{StateFile: filename}, configured))
+
+	// Invalid state file is skipped.
+	assert.NoError(t, os.WriteFile(filename, []byte("invalid"), 0600))
+	assert.Equal(t, configured, restoreServices(&Config{StateFile: filename}, configured))
+}
@@ -0,0 +1,56 @@
+package pgscv
+
+import (
+	"context"
+	"github.com/lesovsky/pgscv/internal/collector"
+	"github.com/lesovsky/pgscv/internal/log"
+	"github.com/lesovsky/pgscv/internal/service"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+	"io"
+)
+
+// PrintMetrics connects to configured services, collects metrics once and writes them into passed writer using
+// Prometheus text exposition format.
+func PrintMetrics(config *Config, w io.Writer) error {
+	log.Debug("print metrics")
+
+	setupGlobals(config)
+
+	// Register pgSCV own metrics, it should be done after metrics prefix and allow-list are configured.
+	err := service.RegisterMetrics(prometheus.DefaultRegisterer)
+	if err != nil {
+		return err
+	}
+
+	repo, err := newServiceRepo(config)
+	if err != nil {
+		return err
+	}
+
+	// Register collector with pgSCV build info, it doesn't depend on services.
+	err = prometheus.Register(collector.NewBuildInfoCollector(config.BuildInfo))
+	if err != nil {
+		return err
+	}
+
+	return writeMetrics(w, newGatherer(context.Background(), repo))
+}
+
+// writeMetrics gathers metrics from passed gatherer once and writes them into passed writer.
+func writeMetrics(w io.Writer, g prometheus.Gatherer) error {
+	families, err := g.Gather()
+	if err != nil {
+		return err
+	}
+
+	enc := expfmt.NewEncoder(w, expfmt.FmtText)
+	for _, f := range families {
+		err := enc.Encode(f)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
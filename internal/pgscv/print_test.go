@@ -0,0 +1,24 @@
+package pgscv
+
+import (
+	"bytes"
+	"github.com/lesovsky/pgscv/internal/collector"
+	"github.com/lesovsky/pgscv/internal/model"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_writeMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	assert.NoError(t, reg.Register(collector.NewBuildInfoCollector(model.BuildInfo{Version: "v0.0.1", Commit: "abc", Branch: "master"})))
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, writeMetrics(buf, reg))
+
+	got := buf.String()
+	assert.Contains(t, got, "# HELP pgscv_build_info Labeled information about pgSCV build.\n")
+	assert.Contains(t, got, "# TYPE pgscv_build_info gauge\n")
+	assert.Contains(t, got, `pgscv_build_info{branch="master",commit="abc",goversion=`)
+	assert.Contains(t, got, `version="v0.0.1"} 1`)
+}
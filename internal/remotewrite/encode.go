@@ -0,0 +1,53 @@
+package remotewrite
+
+import (
+	"google.golang.org/protobuf/encoding/protowire"
+	"math"
+)
+
+// marshalWriteRequest encodes time series into protobuf WriteRequest message. Messages are encoded by hand to avoid
+// dependency on Prometheus server's generated code.
+// For messages definitions see https://github.com/prometheus/prometheus/blob/main/prompb/remote.proto
+func marshalWriteRequest(series []timeseries) []byte {
+	var b []byte
+
+	// message WriteRequest { repeated TimeSeries timeseries = 1; }
+	for _, ts := range series {
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendBytes(b, marshalTimeseries(ts))
+	}
+
+	return b
+}
+
+// marshalTimeseries encodes time series into protobuf TimeSeries message.
+func marshalTimeseries(ts timeseries) []byte {
+	var b []byte
+
+	// message TimeSeries { repeated Label labels = 1; repeated Sample samples = 2; }
+	for _, l := range ts.labels {
+		var lb []byte
+		// message Label { string name = 1; string value = 2; }
+		lb = protowire.AppendTag(lb, 1, protowire.BytesType)
+		lb = protowire.AppendString(lb, l.name)
+		lb = protowire.AppendTag(lb, 2, protowire.BytesType)
+		lb = protowire.AppendString(lb, l.value)
+
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendBytes(b, lb)
+	}
+
+	for _, s := range ts.samples {
+		var sb []byte
+		// message Sample { double value = 1; int64 timestamp = 2; }
+		sb = protowire.AppendTag(sb, 1, protowire.Fixed64Type)
+		sb = protowire.AppendFixed64(sb, math.Float64bits(s.value))
+		sb = protowire.AppendTag(sb, 2, protowire.VarintType)
+		sb = protowire.AppendVarint(sb, uint64(s.timestamp))
+
+		b = protowire.AppendTag(b, 2, protowire.BytesType)
+		b = protowire.AppendBytes(b, sb)
+	}
+
+	return b
+}
@@ -0,0 +1,143 @@
+package remotewrite
+
+import (
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"math"
+	"testing"
+)
+
+func Test_marshalWriteRequest(t *testing.T) {
+	series := []timeseries{
+		{
+			labels:  []label{{name: "__name__", value: "up"}},
+			samples: []sample{{value: 1, timestamp: 1000}},
+		},
+	}
+
+	want := []byte{
+		0x0a, 0x1e, // WriteRequest.timeseries, 30 bytes
+		0x0a, 0x0e, // TimeSeries.labels, 14 bytes
+		0x0a, 0x08, '_', '_', 'n', 'a', 'm', 'e', '_', '_', // Label.name
+		0x12, 0x02, 'u', 'p', // Label.value
+		0x12, 0x0c, // TimeSeries.samples, 12 bytes
+		0x09, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xf0, 0x3f, // Sample.value, 1.0
+		0x10, 0xe8, 0x07, // Sample.timestamp, 1000
+	}
+
+	assert.Equal(t, want, marshalWriteRequest(series))
+	assert.Nil(t, marshalWriteRequest(nil))
+}
+
+func Test_marshalWriteRequest_unmarshal(t *testing.T) {
+	series := []timeseries{
+		{
+			labels:  []label{{name: "__name__", value: "postgres_up"}, {name: "service_id", value: "postgres:5432"}},
+			samples: []sample{{value: 1, timestamp: 1622548800000}},
+		},
+		{
+			labels:  []label{{name: "__name__", value: "node_load1"}, {name: "instance", value: "тест"}},
+			samples: []sample{{value: -0.5, timestamp: -1}, {value: math.Inf(1), timestamp: 0}},
+		},
+	}
+
+	// Encoded message must be decoded by protobuf runtime using messages definitions from remote.proto.
+	msg := dynamicpb.NewMessage(newWriteRequestDescriptor(t))
+	assert.NoError(t, proto.Unmarshal(marshalWriteRequest(series), msg))
+
+	var got []timeseries
+	list := msg.Get(msg.Descriptor().Fields().ByName("timeseries")).List()
+	for i := 0; i < list.Len(); i++ {
+		tsMsg := list.Get(i).Message()
+		fields := tsMsg.Descriptor().Fields()
+
+		var ts timeseries
+		labels := tsMsg.Get(fields.ByName("labels")).List()
+		for j := 0; j < labels.Len(); j++ {
+			l := labels.Get(j).Message()
+			ts.labels = append(ts.labels, label{
+				name:  l.Get(l.Descriptor().Fields().ByName("name")).String(),
+				value: l.Get(l.Descriptor().Fields().ByName("value")).String(),
+			})
+		}
+
+		samples := tsMsg.Get(fields.ByName("samples")).List()
+		for j := 0; j < samples.Len(); j++ {
+			s := samples.Get(j).Message()
+			ts.samples = append(ts.samples, sample{
+				value:     s.Get(s.Descriptor().Fields().ByName("value")).Float(),
+				timestamp: s.Get(s.Descriptor().Fields().ByName("timestamp")).Int(),
+			})
+		}
+
+		got = append(got, ts)
+	}
+
+	assert.Equal(t, series, got)
+	assert.Len(t, msg.GetUnknown(), 0)
+}
+
+// newWriteRequestDescriptor returns descriptor of WriteRequest message built from remote.proto and types.proto
+// definitions of Prometheus (only fields used by remote write sender are defined).
+func newWriteRequestDescriptor(t *testing.T) protoreflect.MessageDescriptor {
+	field := func(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type, label descriptorpb.FieldDescriptorProto_Label, typeName string) *descriptorpb.FieldDescriptorProto {
+		f := &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			JsonName: proto.String(name),
+			Number:   proto.Int32(number),
+			Type:     typ.Enum(),
+			Label:    label.Enum(),
+		}
+		if typeName != "" {
+			f.TypeName = proto.String(typeName)
+		}
+		return f
+	}
+
+	optional := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+	repeated := descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+
+	fdp := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("remote.proto"),
+		Package: proto.String("prometheus"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name: proto.String("WriteRequest"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("timeseries", 1, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, repeated, ".prometheus.TimeSeries"),
+				},
+			},
+			{
+				Name: proto.String("TimeSeries"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("labels", 1, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, repeated, ".prometheus.Label"),
+					field("samples", 2, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, repeated, ".prometheus.Sample"),
+				},
+			},
+			{
+				Name: proto.String("Label"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("name", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, optional, ""),
+					field("value", 2, descriptorpb.FieldDescriptorProto_TYPE_STRING, optional, ""),
+				},
+			},
+			{
+				Name: proto.String("Sample"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("value", 1, descriptorpb.FieldDescriptorProto_TYPE_DOUBLE, optional, ""),
+					field("timestamp", 2, descriptorpb.FieldDescriptorProto_TYPE_INT64, optional, ""),
+				},
+			},
+		},
+	}
+
+	fd, err := protodesc.NewFile(fdp, nil)
+	assert.NoError(t, err)
+
+	return fd.Messages().ByName("WriteRequest")
+}
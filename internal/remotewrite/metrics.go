@@ -0,0 +1,39 @@
+package remotewrite
+
+import (
+	"github.com/lesovsky/pgscv/internal/collector"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics about pushing metrics to remote storage.
+var (
+	// pushFailures is the total number of failed push attempts, including retried ones.
+	pushFailures prometheus.Counter
+)
+
+func init() {
+	newMetrics()
+}
+
+// newMetrics creates metrics about pushing using configured metrics prefix.
+func newMetrics() {
+	pushFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: collector.WithMetricsPrefix("pgscv"),
+		Subsystem: "push",
+		Name:      "failures_total",
+		Help:      "Total number of failed attempts to push metrics to remote storage.",
+	})
+}
+
+// RegisterMetrics re-creates metrics about pushing according to configured metrics prefix and registers metrics
+// allowed by metrics allow-list. It has to be called once metrics prefix and allow-list are configured and before
+// pushing is started.
+func RegisterMetrics(reg prometheus.Registerer) error {
+	newMetrics()
+
+	if !collector.IsMetricAllowed(prometheus.BuildFQName(collector.WithMetricsPrefix("pgscv"), "push", "failures_total")) {
+		return nil
+	}
+
+	return reg.Register(pushFailures)
+}
@@ -0,0 +1,218 @@
+// Package remotewrite implements pushing metrics to remote storages using Prometheus remote write protocol.
+// For details see https://prometheus.io/docs/concepts/remote_write_spec/
+package remotewrite
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/golang/snappy"
+	"github.com/lesovsky/pgscv/internal/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	// DefaultInterval defines default interval between pushes.
+	DefaultInterval = time.Minute
+
+	// DefaultTimeout defines default timeout of push requests.
+	DefaultTimeout = 10 * time.Second
+
+	// DefaultRetries defines default number of retries of failed pushes.
+	DefaultRetries = 3
+
+	// DefaultBackoff defines default delay before the first retry, next delays are doubled.
+	DefaultBackoff = time.Second
+
+	// maxBackoff defines max delay between retries.
+	maxBackoff = 30 * time.Second
+)
+
+// Config defines settings of pushing metrics using remote write protocol.
+type Config struct {
+	// URL defines remote write endpoint, e.g. http://mimir:9009/api/v1/push. Pushing is disabled if URL is empty.
+	URL string `yaml:"url"`
+	// Interval defines interval between pushes.
+	Interval time.Duration `yaml:"interval"`
+	// Timeout defines timeout of single push request.
+	Timeout time.Duration `yaml:"timeout"`
+	// Retries defines max number of retries of failed push, zero disables retries, default is used if not specified.
+	Retries *int `yaml:"retries"`
+	// Backoff defines delay before the first retry, each next delay is doubled.
+	Backoff time.Duration `yaml:"backoff"`
+}
+
+// Validate checks configuration and set defaults.
+func (c *Config) Validate() error {
+	if c.URL == "" {
+		return nil
+	}
+
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return fmt.Errorf("invalid remote_write url: %s", err)
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid remote_write url: unsupported scheme '%s'", u.Scheme)
+	}
+
+	if c.Interval < 0 {
+		return fmt.Errorf("invalid remote_write interval: %s", c.Interval)
+	}
+
+	if c.Interval == 0 {
+		c.Interval = DefaultInterval
+	}
+
+	if c.Timeout < 0 {
+		return fmt.Errorf("invalid remote_write timeout: %s", c.Timeout)
+	}
+
+	if c.Timeout == 0 {
+		c.Timeout = DefaultTimeout
+	}
+
+	if c.Retries == nil {
+		retries := DefaultRetries
+		c.Retries = &retries
+	}
+
+	if *c.Retries < 0 {
+		return fmt.Errorf("invalid remote_write retries: %d", *c.Retries)
+	}
+
+	if c.Backoff < 0 {
+		return fmt.Errorf("invalid remote_write backoff: %s", c.Backoff)
+	}
+
+	if c.Backoff == 0 {
+		c.Backoff = DefaultBackoff
+	}
+
+	return nil
+}
+
+// Writer periodically gathers metrics and pushes them to remote write endpoint.
+type Writer struct {
+	url      string
+	interval time.Duration
+	retries  int
+	backoff  time.Duration
+	client   *http.Client
+	gatherer prometheus.Gatherer
+}
+
+// NewWriter creates new Writer which pushes metrics gathered from passed gatherer.
+func NewWriter(config Config, gatherer prometheus.Gatherer) *Writer {
+	retries := DefaultRetries
+	if config.Retries != nil {
+		retries = *config.Retries
+	}
+
+	return &Writer{
+		url:      config.URL,
+		interval: config.Interval,
+		retries:  retries,
+		backoff:  config.Backoff,
+		client:   &http.Client{Timeout: config.Timeout},
+		gatherer: gatherer,
+	}
+}
+
+// Run pushes metrics periodically until context is cancelled.
+func (w *Writer) Run(ctx context.Context) {
+	log.Infof("pushing metrics to %s every %s", w.url, w.interval)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info("exit signaled, stop pushing metrics")
+			return
+		case <-ticker.C:
+			if err := w.Push(ctx); err != nil {
+				log.Errorf("push metrics failed: %s", err)
+			}
+		}
+	}
+}
+
+// Push gathers metrics once and sends them to remote write endpoint. Failed sends are retried with exponential
+// backoff unless the endpoint rejects data as invalid.
+func (w *Writer) Push(ctx context.Context) error {
+	families, err := w.gatherer.Gather()
+	if err != nil {
+		// Gatherer returns as many metrics as possible even in case of errors, send them.
+		log.Warnf("gather metrics failed: %s", err)
+	}
+
+	series := newTimeseries(families, time.Now().UnixNano()/int64(time.Millisecond))
+	if len(series) == 0 {
+		return nil
+	}
+
+	body := snappy.Encode(nil, marshalWriteRequest(series))
+
+	backoff := w.backoff
+	for attempt := 0; ; attempt++ {
+		retryable, err := w.send(ctx, body)
+		if err == nil {
+			return nil
+		}
+
+		pushFailures.Inc()
+
+		if !retryable || attempt >= w.retries {
+			return err
+		}
+
+		log.Warnf("push metrics failed: %s; retry in %s", err, backoff)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// send makes single push request. Returned flag tells whether request could be retried: network errors, throttling
+// and server-side errors are retryable, other client-side errors are not.
+func (w *Writer) send(ctx context.Context, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("User-Agent", "pgscv")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode/100 != 2 {
+		// Read limited part of response, it should be enough for error message.
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		retryable := resp.StatusCode/100 == 5 || resp.StatusCode == http.StatusTooManyRequests
+		return retryable, fmt.Errorf("server returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+
+	return true, nil
+}
@@ -0,0 +1,328 @@
+package remotewrite
+
+import (
+	"context"
+	"encoding/binary"
+	"github.com/golang/snappy"
+	"github.com/lesovsky/pgscv/internal/collector"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/encoding/protowire"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	retries := func(n int) *int { return &n }
+
+	var testcases = []struct {
+		valid  bool
+		config Config
+		want   Config
+	}{
+		{valid: true, config: Config{}, want: Config{}},
+		{
+			valid:  true,
+			config: Config{URL: "http://127.0.0.1:9009/api/v1/push"},
+			want: Config{
+				URL: "http://127.0.0.1:9009/api/v1/push", Interval: DefaultInterval,
+				Timeout: DefaultTimeout, Retries: retries(DefaultRetries), Backoff: DefaultBackoff,
+			},
+		},
+		{
+			valid:  true,
+			config: Config{URL: "https://example.org/push", Interval: 10 * time.Second, Timeout: 5 * time.Second, Retries: retries(5), Backoff: 100 * time.Millisecond},
+			want:   Config{URL: "https://example.org/push", Interval: 10 * time.Second, Timeout: 5 * time.Second, Retries: retries(5), Backoff: 100 * time.Millisecond},
+		},
+		{
+			valid:  true, // retries are disabled
+			config: Config{URL: "https://example.org/push", Retries: retries(0)},
+			want: Config{
+				URL: "https://example.org/push", Interval: DefaultInterval,
+				Timeout: DefaultTimeout, Retries: retries(0), Backoff: DefaultBackoff,
+			},
+		},
+		{valid: false, config: Config{URL: "ftp://example.org/push"}},
+		{valid: false, config: Config{URL: "http://example.org:port/push"}},
+		{valid: false, config: Config{URL: "http://example.org/push", Interval: -time.Second}},
+		{valid: false, config: Config{URL: "http://example.org/push", Timeout: -time.Second}},
+		{valid: false, config: Config{URL: "http://example.org/push", Retries: retries(-1)}},
+		{valid: false, config: Config{URL: "http://example.org/push", Backoff: -time.Second}},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.config.URL, func(t *testing.T) {
+			err := tc.config.Validate()
+			if tc.valid {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.want, tc.config)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestWriter_Push(t *testing.T) {
+	var got []byte
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "snappy", r.Header.Get("Content-Encoding"))
+		assert.Equal(t, "application/x-protobuf", r.Header.Get("Content-Type"))
+		assert.Equal(t, "0.1.0", r.Header.Get("X-Prometheus-Remote-Write-Version"))
+
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		got, err = snappy.Decode(nil, body)
+		assert.NoError(t, err)
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	registry := prometheus.NewRegistry()
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "up", Help: "test"})
+	gauge.Set(1)
+	registry.MustRegister(gauge)
+
+	w := NewWriter(Config{URL: ts.URL, Interval: time.Second, Timeout: time.Second}, registry)
+	assert.NoError(t, w.Push(context.Background()))
+
+	series, err := unmarshalWriteRequest(got)
+	assert.NoError(t, err)
+	assert.Len(t, series, 1)
+	assert.Equal(t, []label{{name: "__name__", value: "up"}}, series[0].labels)
+	assert.Len(t, series[0].samples, 1)
+	assert.Equal(t, float64(1), series[0].samples[0].value)
+	assert.Greater(t, series[0].samples[0].timestamp, int64(0))
+}
+
+// unmarshalWriteRequest decodes protobuf WriteRequest message, used for testing encoder.
+func unmarshalWriteRequest(b []byte) ([]timeseries, error) {
+	var series []timeseries
+
+	err := consumeMessage(b, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		if num != 1 {
+			return nil
+		}
+		var ts timeseries
+		err := consumeMessage(v, func(num protowire.Number, typ protowire.Type, v []byte) error {
+			switch num {
+			case 1:
+				var l label
+				err := consumeMessage(v, func(num protowire.Number, typ protowire.Type, v []byte) error {
+					if num == 1 {
+						l.name = string(v)
+					} else {
+						l.value = string(v)
+					}
+					return nil
+				})
+				ts.labels = append(ts.labels, l)
+				return err
+			case 2:
+				var s sample
+				err := consumeMessage(v, func(num protowire.Number, typ protowire.Type, v []byte) error {
+					if num == 1 {
+						s.value = math.Float64frombits(binary.LittleEndian.Uint64(v))
+					} else {
+						n, _ := protowire.ConsumeVarint(v)
+						s.timestamp = int64(n)
+					}
+					return nil
+				})
+				ts.samples = append(ts.samples, s)
+				return err
+			}
+			return nil
+		})
+		series = append(series, ts)
+		return err
+	})
+
+	return series, err
+}
+
+// consumeMessage iterates over fields of protobuf message and calls fn for each of them. Passed value contains
+// field payload: content for length-delimited fields, raw encoded bytes for others.
+func consumeMessage(b []byte, fn func(num protowire.Number, typ protowire.Type, v []byte) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		var v []byte
+		if typ == protowire.BytesType {
+			v, n = protowire.ConsumeBytes(b)
+		} else {
+			n = protowire.ConsumeFieldValue(num, typ, b)
+			if n >= 0 {
+				v = b[:n]
+			}
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		if err := fn(num, typ, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestWriter_Push_retry(t *testing.T) {
+	var testcases = []struct {
+		name         string
+		statuses     []int // response statuses of consecutive requests, the last one is repeated
+		retries      int
+		wantErr      string
+		wantRequests int
+		wantFailures float64
+	}{
+		{name: "success", statuses: []int{http.StatusOK}, retries: 3, wantRequests: 1, wantFailures: 0},
+		{name: "recovered", statuses: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK}, retries: 3, wantRequests: 3, wantFailures: 2},
+		{name: "retries exhausted", statuses: []int{http.StatusInternalServerError}, retries: 2, wantErr: "500 Internal Server Error: failed", wantRequests: 3, wantFailures: 3},
+		{name: "not retryable", statuses: []int{http.StatusBadRequest}, retries: 3, wantErr: "400 Bad Request: failed", wantRequests: 1, wantFailures: 1},
+		{name: "retries disabled", statuses: []int{http.StatusServiceUnavailable}, retries: 0, wantErr: "503 Service Unavailable: failed", wantRequests: 1, wantFailures: 1},
+	}
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(prometheus.NewGauge(prometheus.GaugeOpts{Name: "up", Help: "test"}))
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var requests int
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				status := tc.statuses[len(tc.statuses)-1]
+				if requests < len(tc.statuses) {
+					status = tc.statuses[requests]
+				}
+				requests++
+
+				if status != http.StatusOK {
+					http.Error(w, "failed", status)
+				}
+			}))
+			defer ts.Close()
+
+			failures := testutil.ToFloat64(pushFailures)
+
+			w := NewWriter(Config{URL: ts.URL, Interval: time.Second, Timeout: time.Second, Retries: &tc.retries, Backoff: time.Millisecond}, registry)
+			err := w.Push(context.Background())
+			if tc.wantErr != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tc.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			assert.Equal(t, tc.wantRequests, requests)
+			assert.Equal(t, tc.wantFailures, testutil.ToFloat64(pushFailures)-failures)
+		})
+	}
+}
+
+func TestWriter_Push_timeout(t *testing.T) {
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer ts.Close()
+	defer close(release)
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(prometheus.NewGauge(prometheus.GaugeOpts{Name: "up", Help: "test"}))
+
+	failures := testutil.ToFloat64(pushFailures)
+
+	retries := 1
+	w := NewWriter(Config{URL: ts.URL, Interval: time.Second, Timeout: 20 * time.Millisecond, Retries: &retries, Backoff: time.Millisecond}, registry)
+	assert.Error(t, w.Push(context.Background()))
+	assert.Equal(t, float64(2), testutil.ToFloat64(pushFailures)-failures)
+}
+
+func TestWriter_Push_cancel(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "failed", http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(prometheus.NewGauge(prometheus.GaugeOpts{Name: "up", Help: "test"}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// Retries should be interrupted by cancelled context instead of waiting for the long backoff.
+	retries := 3
+	w := NewWriter(Config{URL: ts.URL, Interval: time.Second, Timeout: time.Second, Retries: &retries, Backoff: time.Hour}, registry)
+	start := time.Now()
+	assert.Error(t, w.Push(ctx))
+	assert.Less(t, int64(time.Since(start)), int64(5*time.Second))
+}
+
+func TestWriter_Run(t *testing.T) {
+	pushed := make(chan struct{}, 10)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pushed <- struct{}{}
+	}))
+	defer ts.Close()
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(prometheus.NewGauge(prometheus.GaugeOpts{Name: "up", Help: "test"}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	w := NewWriter(Config{URL: ts.URL, Interval: 10 * time.Millisecond, Timeout: time.Second}, registry)
+	go func() {
+		w.Run(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-pushed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no push received")
+	}
+
+	cancel()
+	<-done
+}
+
+func TestRegisterMetrics(t *testing.T) {
+	collector.SetMetricsPrefix("example")
+	defer func() {
+		collector.SetMetricsPrefix("")
+		collector.SetMetricsAllowList(nil)
+		newMetrics()
+	}()
+
+	reg := prometheus.NewRegistry()
+	assert.NoError(t, RegisterMetrics(reg))
+	pushFailures.Inc()
+
+	families, err := reg.Gather()
+	assert.NoError(t, err)
+	assert.Len(t, families, 1)
+	assert.Equal(t, "example_pgscv_push_failures_total", families[0].GetName())
+
+	// Not allowed metric is not registered.
+	collector.SetMetricsAllowList([]string{"example_pgscv_up"})
+	reg = prometheus.NewRegistry()
+	assert.NoError(t, RegisterMetrics(reg))
+	pushFailures.Inc()
+
+	families, err = reg.Gather()
+	assert.NoError(t, err)
+	assert.Len(t, families, 0)
+}
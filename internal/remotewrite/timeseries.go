@@ -0,0 +1,108 @@
+package remotewrite
+
+import (
+	dto "github.com/prometheus/client_model/go"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// label defines single label of time series.
+type label struct {
+	name  string
+	value string
+}
+
+// sample defines single value of time series.
+type sample struct {
+	value     float64
+	timestamp int64 // in milliseconds
+}
+
+// timeseries defines labeled series of samples.
+type timeseries struct {
+	labels  []label
+	samples []sample
+}
+
+// newTimeseries converts passed metrics families into time series. Summaries and histograms are converted into
+// several series in the same way as Prometheus does when scrapes them. Passed timestamp is used for metrics which
+// have no explicit timestamp.
+func newTimeseries(families []*dto.MetricFamily, timestamp int64) []timeseries {
+	var series []timeseries
+
+	for _, mf := range families {
+		name := mf.GetName()
+
+		for _, m := range mf.GetMetric() {
+			ts := timestamp
+			if m.TimestampMs != nil {
+				ts = m.GetTimestampMs()
+			}
+
+			add := func(suffix string, value float64, extra ...label) {
+				series = append(series, newSeries(name+suffix, m.GetLabel(), extra, sample{value: value, timestamp: ts}))
+			}
+
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				add("", m.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				add("", m.GetGauge().GetValue())
+			case dto.MetricType_UNTYPED:
+				add("", m.GetUntyped().GetValue())
+			case dto.MetricType_SUMMARY:
+				s := m.GetSummary()
+				for _, q := range s.GetQuantile() {
+					add("", q.GetValue(), label{name: "quantile", value: formatFloat(q.GetQuantile())})
+				}
+				add("_sum", s.GetSampleSum())
+				add("_count", float64(s.GetSampleCount()))
+			case dto.MetricType_HISTOGRAM:
+				h := m.GetHistogram()
+				var hasInf bool
+				for _, b := range h.GetBucket() {
+					if math.IsInf(b.GetUpperBound(), +1) {
+						hasInf = true
+					}
+					add("_bucket", float64(b.GetCumulativeCount()), label{name: "le", value: formatFloat(b.GetUpperBound())})
+				}
+				if !hasInf {
+					add("_bucket", float64(h.GetSampleCount()), label{name: "le", value: "+Inf"})
+				}
+				add("_sum", h.GetSampleSum())
+				add("_count", float64(h.GetSampleCount()))
+			}
+		}
+	}
+
+	return series
+}
+
+// newSeries creates time series with single sample. Labels are sorted by name as required by remote write protocol.
+func newSeries(name string, pairs []*dto.LabelPair, extra []label, s sample) timeseries {
+	labels := make([]label, 0, len(pairs)+len(extra)+1)
+	labels = append(labels, label{name: "__name__", value: name})
+
+	for _, p := range pairs {
+		labels = append(labels, label{name: p.GetName(), value: p.GetValue()})
+	}
+
+	labels = append(labels, extra...)
+
+	sort.Slice(labels, func(i, j int) bool { return labels[i].name < labels[j].name })
+
+	return timeseries{labels: labels, samples: []sample{s}}
+}
+
+// formatFloat formats float in the same way as Prometheus formats values of 'le' and 'quantile' labels.
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, +1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
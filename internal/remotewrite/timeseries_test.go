@@ -0,0 +1,52 @@
+package remotewrite
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+func Test_newTimeseries(t *testing.T) {
+	registry := prometheus.NewRegistry()
+
+	counter := prometheus.NewCounter(prometheus.CounterOpts{Name: "test_total", Help: "test", ConstLabels: prometheus.Labels{"b": "2", "a": "1"}})
+	counter.Add(5)
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_gauge", Help: "test"})
+	gauge.Set(1.5)
+	untyped := prometheus.NewUntypedFunc(prometheus.UntypedOpts{Name: "test_untyped", Help: "test"}, func() float64 { return 7 })
+	summary := prometheus.NewSummary(prometheus.SummaryOpts{Name: "test_summary", Help: "test", Objectives: map[float64]float64{0.5: 0.05}})
+	summary.Observe(2)
+	histogram := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "test_histogram", Help: "test", Buckets: []float64{1, 10}})
+	histogram.Observe(5)
+
+	registry.MustRegister(counter, gauge, untyped, summary, histogram)
+
+	families, err := registry.Gather()
+	assert.NoError(t, err)
+
+	got := newTimeseries(families, 1000)
+
+	want := []timeseries{
+		{labels: []label{{"__name__", "test_gauge"}}, samples: []sample{{1.5, 1000}}},
+		{labels: []label{{"__name__", "test_histogram_bucket"}, {"le", "1"}}, samples: []sample{{0, 1000}}},
+		{labels: []label{{"__name__", "test_histogram_bucket"}, {"le", "10"}}, samples: []sample{{1, 1000}}},
+		{labels: []label{{"__name__", "test_histogram_bucket"}, {"le", "+Inf"}}, samples: []sample{{1, 1000}}},
+		{labels: []label{{"__name__", "test_histogram_sum"}}, samples: []sample{{5, 1000}}},
+		{labels: []label{{"__name__", "test_histogram_count"}}, samples: []sample{{1, 1000}}},
+		{labels: []label{{"__name__", "test_summary"}, {"quantile", "0.5"}}, samples: []sample{{2, 1000}}},
+		{labels: []label{{"__name__", "test_summary_sum"}}, samples: []sample{{2, 1000}}},
+		{labels: []label{{"__name__", "test_summary_count"}}, samples: []sample{{1, 1000}}},
+		{labels: []label{{"__name__", "test_total"}, {"a", "1"}, {"b", "2"}}, samples: []sample{{5, 1000}}},
+		{labels: []label{{"__name__", "test_untyped"}}, samples: []sample{{7, 1000}}},
+	}
+
+	assert.Equal(t, want, got)
+}
+
+func Test_formatFloat(t *testing.T) {
+	assert.Equal(t, "0.5", formatFloat(0.5))
+	assert.Equal(t, "10", formatFloat(10))
+	assert.Equal(t, "+Inf", formatFloat(math.Inf(1)))
+	assert.Equal(t, "-Inf", formatFloat(math.Inf(-1)))
+}
@@ -13,6 +13,13 @@ type ConnSetting struct {
 	ServiceType string `yaml:"service_type"`
 	// Conninfo is the connection string in service-specific format.
 	Conninfo string `yaml:"conninfo"`
+	// LocalAddress defines source IP address used for connecting to the service.
+	LocalAddress string `yaml:"local_address"`
+	// Labels defines constant labels attached to all metrics of the service. Overrides global labels with the same names.
+	Labels map[string]string `yaml:"labels"`
+	// Discovered defines the service has been discovered (e.g. in Docker) rather than configured. Only discovered
+	// services are kept in the state file, configured services are always taken from the config.
+	Discovered bool `yaml:"-"`
 }
 
 // ConnsSettings defines a set of all connection settings of exact services.
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/lesovsky/pgscv/internal/log"
+	"github.com/lesovsky/pgscv/internal/model"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultDockerSocket defines default path to Docker API socket.
+	DefaultDockerSocket = "/var/run/docker.sock"
+
+	// dockerPostgresPort defines container port which is considered as Postgres port.
+	dockerPostgresPort = 5432
+)
+
+// dockerContainer describes container returned by Docker API (only necessary fields).
+type dockerContainer struct {
+	ID    string       `json:"Id"`
+	Names []string     `json:"Names"`
+	Ports []dockerPort `json:"Ports"`
+}
+
+// dockerPort describes container's port returned by Docker API.
+type dockerPort struct {
+	IP          string `json:"IP"`
+	PrivatePort int    `json:"PrivatePort"`
+	PublicPort  int    `json:"PublicPort"`
+	Type        string `json:"Type"`
+}
+
+// dockerClient is an interface for listing running containers.
+type dockerClient interface {
+	listContainers(ctx context.Context) ([]dockerContainer, error)
+}
+
+// dockerSocketClient implements dockerClient using Docker API available through UNIX socket.
+type dockerSocketClient struct {
+	client *http.Client
+}
+
+// newDockerSocketClient creates new Docker API client which works through passed UNIX socket.
+func newDockerSocketClient(socket string) *dockerSocketClient {
+	return &dockerSocketClient{
+		client: &http.Client{
+			Timeout: 5 * time.Second,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+				},
+			},
+		},
+	}
+}
+
+// listContainers requests list of running containers from Docker API.
+func (c *dockerSocketClient) listContainers(ctx context.Context) ([]dockerContainer, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://docker/containers/json", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("docker api responded with status %s", resp.Status)
+	}
+
+	var containers []dockerContainer
+	err = json.NewDecoder(resp.Body).Decode(&containers)
+	if err != nil {
+		return nil, err
+	}
+
+	return containers, nil
+}
+
+// DiscoverDockerServices is a public wrapper over discoverDockerServices which uses Docker API available through
+// passed UNIX socket.
+func DiscoverDockerServices(socket string, defaults map[string]string) (ConnsSettings, error) {
+	if socket == "" {
+		socket = DefaultDockerSocket
+	}
+
+	return discoverDockerServices(newDockerSocketClient(socket), defaults)
+}
+
+// discoverDockerServices enumerates running containers which publish Postgres port and returns connection settings
+// for them. Credentials and database name are taken from defaults.
+func discoverDockerServices(client dockerClient, defaults map[string]string) (ConnsSettings, error) {
+	log.Debug("discover services in docker containers")
+
+	containers, err := client.listContainers(context.Background())
+	if err != nil {
+		discoveryFailures.WithLabelValues(model.ServiceTypePostgresql).Inc()
+		return nil, err
+	}
+
+	var settings = ConnsSettings{}
+
+	for _, c := range containers {
+		for _, p := range c.Ports {
+			// Skip ports which are not Postgres ports or not published on the host.
+			if p.PrivatePort != dockerPostgresPort || p.Type != "tcp" || p.PublicPort == 0 {
+				continue
+			}
+
+			host := p.IP
+			if host == "" || host == "0.0.0.0" || host == "::" {
+				host = "127.0.0.1"
+			}
+
+			// Docker might publish the same port on IPv4 and IPv6 addresses, use the first one.
+			id := dockerServiceID(c, p.PublicPort)
+			if _, ok := settings[id]; ok {
+				continue
+			}
+
+			conninfo := fmt.Sprintf("host=%s port=%d user=%s dbname=%s",
+				quoteConnValue(host), p.PublicPort, quoteConnValue(defaults["postgres_username"]), quoteConnValue(defaults["postgres_dbname"]),
+			)
+
+			settings[id] = ConnSetting{ServiceType: model.ServiceTypePostgresql, Conninfo: conninfo, Discovered: true}
+			discoveredServices.Inc()
+			log.Debugf("discovered postgres in docker container %s, published port %d", id, p.PublicPort)
+		}
+	}
+
+	return settings, nil
+}
+
+// quoteConnValue returns value suitable for keyword/value connection string. Values which are empty or contain spaces,
+// quotes or backslashes are quoted, quotes and backslashes are escaped.
+func quoteConnValue(v string) string {
+	if v != "" && !strings.ContainsAny(v, " \t\n'\\") {
+		return v
+	}
+
+	r := strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+	return "'" + r.Replace(v) + "'"
+}
+
+// dockerServiceID returns service ID for the container based on its name (or ID if name is not available) and port.
+func dockerServiceID(c dockerContainer, port int) string {
+	name := c.ID
+	if len(name) > 12 {
+		name = name[:12]
+	}
+
+	if len(c.Names) > 0 {
+		name = strings.TrimPrefix(c.Names[0], "/")
+	}
+
+	return fmt.Sprintf("docker:%s:%d", name, port)
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v4"
+	"github.com/lesovsky/pgscv/internal/model"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// mockDockerClient implements dockerClient for testing purposes.
+type mockDockerClient struct {
+	containers []dockerContainer
+	err        error
+}
+
+func (c mockDockerClient) listContainers(_ context.Context) ([]dockerContainer, error) {
+	return c.containers, c.err
+}
+
+func Test_discoverDockerServices(t *testing.T) {
+	defaults := map[string]string{"postgres_username": "pgscv", "postgres_dbname": "postgres"}
+
+	testcases := []struct {
+		name         string
+		client       mockDockerClient
+		valid        bool
+		want         ConnsSettings
+		wantFailures float64
+	}{
+		{
+			name: "valid",
+			client: mockDockerClient{containers: []dockerContainer{
+				{ID: "1a2b3c4d5e6f7a8b", Names: []string{"/pg1"}, Ports: []dockerPort{
+					{IP: "0.0.0.0", PrivatePort: 5432, PublicPort: 15432, Type: "tcp"},
+					{IP: "::", PrivatePort: 5432, PublicPort: 15432, Type: "tcp"},
+				}},
+				{ID: "2a2b3c4d5e6f7a8b", Ports: []dockerPort{
+					{IP: "10.0.0.1", PrivatePort: 5432, PublicPort: 25432, Type: "tcp"},
+				}},
+				{ID: "3a2b3c4d5e6f7a8b", Names: []string{"/not-published"}, Ports: []dockerPort{
+					{PrivatePort: 5432, Type: "tcp"},
+				}},
+				{ID: "4a2b3c4d5e6f7a8b", Names: []string{"/web"}, Ports: []dockerPort{
+					{IP: "0.0.0.0", PrivatePort: 80, PublicPort: 8080, Type: "tcp"},
+				}},
+			}},
+			valid: true,
+			want: ConnsSettings{
+				"docker:pg1:15432":          {ServiceType: model.ServiceTypePostgresql, Conninfo: "host=127.0.0.1 port=15432 user=pgscv dbname=postgres", Discovered: true},
+				"docker:2a2b3c4d5e6f:25432": {ServiceType: model.ServiceTypePostgresql, Conninfo: "host=10.0.0.1 port=25432 user=pgscv dbname=postgres", Discovered: true},
+			},
+		},
+		{
+			name:   "no containers",
+			client: mockDockerClient{},
+			valid:  true,
+			want:   ConnsSettings{},
+		},
+		{
+			name:         "docker api error",
+			client:       mockDockerClient{err: errors.New("docker is not available")},
+			valid:        false,
+			wantFailures: 1,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			discovered := testutil.ToFloat64(discoveredServices)
+			failures := testutil.ToFloat64(discoveryFailures.WithLabelValues(model.ServiceTypePostgresql))
+
+			got, err := discoverDockerServices(tc.client, defaults)
+			assert.Equal(t, float64(len(got)), testutil.ToFloat64(discoveredServices)-discovered)
+			assert.Equal(t, tc.wantFailures, testutil.ToFloat64(discoveryFailures.WithLabelValues(model.ServiceTypePostgresql))-failures)
+			if tc.valid {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.want, got)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func Test_quoteConnValue(t *testing.T) {
+	var testcases = []struct {
+		value string
+		want  string
+	}{
+		{value: "pgscv", want: "pgscv"},
+		{value: "", want: "''"},
+		{value: "my user", want: "'my user'"},
+		{value: "it's", want: `'it\'s'`},
+		{value: `back\slash`, want: `'back\\slash'`},
+	}
+
+	for _, tc := range testcases {
+		got := quoteConnValue(tc.value)
+		assert.Equal(t, tc.want, got)
+
+		// Value should be parsed by the driver as is.
+		config, err := pgx.ParseConfig("host=127.0.0.1 user=" + got)
+		assert.NoError(t, err)
+		if tc.value != "" {
+			assert.Equal(t, tc.value, config.User)
+		}
+	}
+}
+
+func Test_dockerSocketClient_listContainers(t *testing.T) {
+	dir, err := os.MkdirTemp("", "pgscv-docker-")
+	assert.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	socket := filepath.Join(dir, "docker.sock")
+	listener, err := net.Listen("unix", socket)
+	assert.NoError(t, err)
+
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/containers/json" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`[{"Id":"1a2b3c","Names":["/pg1"],"Ports":[{"IP":"0.0.0.0","PrivatePort":5432,"PublicPort":15432,"Type":"tcp"}]}]`))
+	})}
+	go func() { _ = server.Serve(listener) }()
+	defer func() { _ = server.Close() }()
+
+	got, err := newDockerSocketClient(socket).listContainers(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []dockerContainer{
+		{ID: "1a2b3c", Names: []string{"/pg1"}, Ports: []dockerPort{{IP: "0.0.0.0", PrivatePort: 5432, PublicPort: 15432, Type: "tcp"}}},
+	}, got)
+
+	// Unavailable socket.
+	_, err = newDockerSocketClient(filepath.Join(dir, "unknown.sock")).listContainers(context.Background())
+	assert.Error(t, err)
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"github.com/lesovsky/pgscv/internal/collector"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics about services discovery. These metrics are not related to any exact service, hence they are registered once
+// and have no service-specific labels. Registered services are reported by 'pgscv_services_registered_total' metric
+// of each service.
+var (
+	// discoveredServices is the total number of services found by discovery.
+	discoveredServices prometheus.Counter
+
+	// discoveryFailures is the total number of failed attempts to discover or register services, by service type.
+	discoveryFailures *prometheus.CounterVec
+)
+
+func init() {
+	newMetrics()
+}
+
+// newMetrics creates metrics about services discovery using configured metrics prefix.
+func newMetrics() {
+	namespace := collector.WithMetricsPrefix("pgscv")
+
+	discoveredServices = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "discovery",
+		Name:      "services_discovered_total",
+		Help:      "Total number of services found by discovery.",
+	})
+
+	discoveryFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "discovery",
+		Name:      "failures_total",
+		Help:      "Total number of failed attempts to discover or register services.",
+	}, []string{"type"})
+}
+
+// RegisterMetrics re-creates metrics about services discovery according to configured metrics prefix and registers
+// metrics allowed by metrics allow-list. It has to be called once metrics prefix and allow-list are configured and
+// before services are discovered.
+func RegisterMetrics(reg prometheus.Registerer) error {
+	newMetrics()
+
+	namespace := collector.WithMetricsPrefix("pgscv")
+	metrics := []struct {
+		name string
+		c    prometheus.Collector
+	}{
+		{name: prometheus.BuildFQName(namespace, "discovery", "services_discovered_total"), c: discoveredServices},
+		{name: prometheus.BuildFQName(namespace, "discovery", "failures_total"), c: discoveryFailures},
+	}
+
+	for _, m := range metrics {
+		if !collector.IsMetricAllowed(m.name) {
+			continue
+		}
+
+		err := reg.Register(m.c)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -1,29 +1,38 @@
 package service
 
 import (
+	"context"
 	"github.com/jackc/pgx/v4"
 	"github.com/lesovsky/pgscv/internal/collector"
 	"github.com/lesovsky/pgscv/internal/log"
 	"github.com/lesovsky/pgscv/internal/model"
 	"github.com/lesovsky/pgscv/internal/store"
 	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"reflect"
 	"regexp"
+	"sort"
 	"sync"
+	"time"
 )
 
 // Service struct describes service - the target from which should be collected metrics.
 type Service struct {
 	// Service identifier is unique key across all monitored services and used to distinguish services of the same type
 	// running on the single host (two Postgres services running on the same host but listening on different ports).
-	// Hence not to mix their metrics the ServiceID is introduced and attached to metrics as "sid" label:
-	// metric_xact_commits{database="test", sid="postgres:5432"} -- metric from the first postgres running on 5432 port
-	// metric_xact_commits{database="test", sid="postgres:5433"} -- metric from the second postgres running on 5433 port
+	// Hence not to mix their metrics the ServiceID is introduced and attached to metrics as "service_id" label:
+	// metric_xact_commits{database="test", service_id="postgres:5432"} -- metric from the first postgres running on 5432 port
+	// metric_xact_commits{database="test", service_id="postgres:5433"} -- metric from the second postgres running on 5433 port
 	ServiceID string
 	// Connection settings required for connecting to the service.
 	ConnSettings ConnSetting
 	// Prometheus-based metrics collector associated with the service. Each 'service' has its own dedicated collector instance
 	// which implements a service-specific set of metric collectors.
 	Collector Collector
+	// Role of the service (primary or standby), attached to metrics as "role" label. Empty if not applicable.
+	Role string
+	// Pool of connections to the service used by collectors, nil for services without connections (e.g. system).
+	Pool *store.Pool
 }
 
 // Config defines service's configuration.
@@ -33,10 +42,26 @@ type Config struct {
 	ConnDefaults  map[string]string `yaml:"defaults"` // Defaults
 	ConnsSettings ConnsSettings
 	// DatabasesRE defines regexp with databases from which builtin metrics should be collected.
-	DatabasesRE        *regexp.Regexp
+	DatabasesRE *regexp.Regexp
+	// DatabasesExcludeRE defines regexp with databases which should be skipped by per-database collectors.
+	DatabasesExcludeRE *regexp.Regexp
 	DisabledCollectors []string
+	// EnabledCollectors defines collectors which should be enabled even if they are disabled.
+	EnabledCollectors []string
 	// CollectorsSettings defines all collector settings propagated from main YAML configuration.
 	CollectorsSettings model.CollectorsSettings
+	// WarmupPeriod defines period after start during which failed services are not marked as down.
+	WarmupPeriod time.Duration
+	// DatabasesConcurrency defines max number of databases processed concurrently by per-database collectors.
+	DatabasesConcurrency int
+	// MaxSeriesPerService defines max number of series exported per service during single scrape, 0 means unlimited.
+	MaxSeriesPerService int
+	// MaxSeriesPerCollector defines max number of series exported by each collector during single scrape, 0 means unlimited.
+	MaxSeriesPerCollector int
+	// Statements defines settings of pg_stat_statements collector.
+	Statements collector.StatementsConfig
+	// Labels defines constant labels attached to metrics of all services.
+	Labels map[string]string
 }
 
 // Collector is an interface for prometheus.Collector.
@@ -45,16 +70,71 @@ type Collector interface {
 	Collect(chan<- prometheus.Metric)
 }
 
+// ContextCollector is an interface for collectors which are able to abort collecting when passed context is done.
+type ContextCollector interface {
+	Collector
+	CollectContext(ctx context.Context, ch chan<- prometheus.Metric)
+}
+
+// Checker is an interface for collectors which are able to run their collectors once and report results.
+type Checker interface {
+	Check() ([]collector.CheckResult, error)
+}
+
+// ScrapeReporter is an interface for collectors which are able to report result of the last scrape.
+type ScrapeReporter interface {
+	LastScrape() collector.ScrapeStatus
+}
+
+// Info describes service in the repo, it is used for exposing services for operational debugging.
+type Info struct {
+	ID         string     `json:"id"`
+	Type       string     `json:"type"`
+	Host       string     `json:"host,omitempty"`
+	Port       uint16     `json:"port,omitempty"`
+	Role       string     `json:"role,omitempty"`
+	Status     string     `json:"status"`                // status of the last scrape: unknown, ok or failed
+	LastScrape *time.Time `json:"last_scrape,omitempty"` // time of the last scrape, empty if not scraped yet
+	LastError  string     `json:"last_error,omitempty"`  // error occurred during the last scrape
+}
+
+const (
+	// scrapeStatusUnknown defines status of services which have not been scraped yet.
+	scrapeStatusUnknown = "unknown"
+	// scrapeStatusOK defines status of services which have been scraped successfully.
+	scrapeStatusOK = "ok"
+	// scrapeStatusFailed defines status of services which scrape has failed.
+	scrapeStatusFailed = "failed"
+	// queryRoleTimeout defines timeout of requesting role of Postgres service, including acquiring connection.
+	queryRoleTimeout = 10 * time.Second
+)
+
+// CheckResult describes result of checking collectors of the service.
+type CheckResult struct {
+	// ServiceID defines ID of the checked service.
+	ServiceID string
+	// Results defines results of the service's collectors.
+	Results []collector.CheckResult
+	// Err defines error occurred during check of the service, e.g. service is not available.
+	Err error
+}
+
 // Repository is the repository with services.
 type Repository struct {
 	sync.RWMutex                    // protect concurrent access
 	Services     map[string]Service // service repo store
+	// queryRole defines function used for requesting role of Postgres service.
+	queryRole func(pool *store.Pool, conninfo string) (string, error)
+	// checkConn defines function used for checking connection to the service before it is added.
+	checkConn func(config *pgx.ConnConfig) error
 }
 
 // NewRepository creates new services repository.
 func NewRepository() *Repository {
 	return &Repository{
-		Services: make(map[string]Service),
+		Services:  make(map[string]Service),
+		queryRole: queryPostgresRole,
+		checkConn: checkConnection,
 	}
 }
 
@@ -70,6 +150,31 @@ func (repo *Repository) SetupServices(config Config) error {
 	return repo.setupServices(config)
 }
 
+// RefreshRoles is a public wrapper on refreshRoles method.
+func (repo *Repository) RefreshRoles() {
+	repo.refreshRoles()
+}
+
+// CheckServices is a public wrapper on checkServices method.
+func (repo *Repository) CheckServices() []CheckResult {
+	return repo.checkServices()
+}
+
+// ReconcileServices is a public wrapper on reconcileServices method.
+func (repo *Repository) ReconcileServices(config Config) error {
+	return repo.reconcileServices(config)
+}
+
+// ServicesInfo is a public wrapper on servicesInfo method.
+func (repo *Repository) ServicesInfo() []Info {
+	return repo.servicesInfo()
+}
+
+// RemoveService is a public wrapper on removeService method.
+func (repo *Repository) RemoveService(id string) {
+	repo.removeService(id)
+}
+
 /* Private methods of Repository */
 
 // addService adds service to the repo.
@@ -79,6 +184,25 @@ func (repo *Repository) addService(s Service) {
 	repo.Unlock()
 }
 
+// removeService removes the service from the repo, metrics of the service are not exported anymore.
+func (repo *Repository) removeService(id string) {
+	repo.Lock()
+	defer repo.Unlock()
+
+	s, ok := repo.Services[id]
+	if !ok {
+		return
+	}
+
+	// Close connections of the service, they are not needed anymore.
+	if s.Pool != nil {
+		s.Pool.Close()
+	}
+
+	delete(repo.Services, id)
+	log.Infof("unregistered service [%s]", id)
+}
+
 // getService returns the service from repo with specified ID.
 func (repo *Repository) getService(id string) Service {
 	repo.RLock()
@@ -123,36 +247,103 @@ func (repo *Repository) addServicesFromConfig(config Config) {
 	// Check all passed connection settings and try to connect using them. In case of success, create a 'Service' instance
 	// in the repo.
 	for k, cs := range config.ConnsSettings {
-		// each ConnSetting struct is used for
-		//   1) doing connection;
-		//   2) getting connection properties to define service-specific parameters.
-		pgconfig, err := pgx.ParseConfig(cs.Conninfo)
+		repo.addServiceFromConnSetting(k, cs)
+	}
+}
+
+// addServiceFromConnSetting checks connection to the service using passed connection settings and adds the service to
+// the repo if connection succeeded.
+func (repo *Repository) addServiceFromConnSetting(id string, cs ConnSetting) {
+	cs, err := prepareConnSetting(cs)
+	if err != nil {
+		log.Warnf("%s: %s, skip", cs.Conninfo, err)
+		discoveryFailures.WithLabelValues(cs.ServiceType).Inc()
+		return
+	}
+
+	// each ConnSetting struct is used for
+	//   1) doing connection;
+	//   2) getting connection properties to define service-specific parameters.
+	pgconfig, err := pgx.ParseConfig(cs.Conninfo)
+	if err != nil {
+		log.Warnf("%s: %s, skip", cs.Conninfo, err)
+		discoveryFailures.WithLabelValues(cs.ServiceType).Inc()
+		return
+	}
+
+	// Check connection using created *ConnConfig, go next if connection failed.
+	err = repo.checkConn(pgconfig)
+	if err != nil {
+		log.Warnf("%s: %s, skip", cs.Conninfo, err)
+		discoveryFailures.WithLabelValues(cs.ServiceType).Inc()
+		return
+	}
+
+	// Connection was successful, create 'Service' struct with service-related properties and add it to service repo.
+	s := Service{
+		ServiceID:    id,
+		ConnSettings: cs,
+		Collector:    nil,
+	}
+
+	// Use entry key as ServiceID unique identifier.
+	repo.addService(s)
+
+	log.Infof("registered new service [%s]", s.ServiceID)
+	log.Debugf("service [%s] available through: %s@%s:%d/%s", s.ServiceID, pgconfig.User, pgconfig.Host, pgconfig.Port, pgconfig.Database)
+}
+
+// prepareConnSetting returns connection settings in the form they are kept in the repo, e.g. connection string is
+// bound to the specified local address.
+func prepareConnSetting(cs ConnSetting) (ConnSetting, error) {
+	if cs.LocalAddress != "" {
+		conninfo, err := store.ConnStringWithLocalAddress(cs.Conninfo, cs.LocalAddress)
 		if err != nil {
-			log.Warnf("%s: %s, skip", cs.Conninfo, err)
-			continue
+			return cs, err
 		}
+		cs.Conninfo = conninfo
+	}
 
-		// Check connection using created *ConnConfig, go next if connection failed.
-		db, err := store.NewWithConfig(pgconfig)
-		if err != nil {
-			log.Warnf("%s: %s, skip", cs.Conninfo, err)
+	return cs, nil
+}
+
+// reconcileServices brings services in the repo in line with passed config, e.g. after configuration reload. Services
+// which are not configured anymore are removed, services with changed connection settings (e.g. credentials) are
+// re-created, new services are added. Unchanged services are kept as is, including their collectors.
+func (repo *Repository) reconcileServices(config Config) error {
+	log.Debug("config: reconcile services")
+
+	for _, id := range repo.getServiceIDs() {
+		s := repo.getService(id)
+
+		// System service is not configured explicitly, it is always kept.
+		if s.ConnSettings.ServiceType == model.ServiceTypeSystem {
 			continue
 		}
-		db.Close()
 
-		// Connection was successful, create 'Service' struct with service-related properties and add it to service repo.
-		s := Service{
-			ServiceID:    k,
-			ConnSettings: cs,
-			Collector:    nil,
+		if cs, ok := config.ConnsSettings[id]; ok {
+			prepared, err := prepareConnSetting(cs)
+			if err == nil && reflect.DeepEqual(prepared, s.ConnSettings) {
+				continue
+			}
+			log.Infof("connection settings of service [%s] changed, re-create the service", id)
 		}
 
-		// Use entry key as ServiceID unique identifier.
-		repo.addService(s)
+		repo.removeService(id)
+	}
+
+	for id, cs := range config.ConnsSettings {
+		repo.RLock()
+		_, ok := repo.Services[id]
+		repo.RUnlock()
 
-		log.Infof("registered new service [%s]", s.ServiceID)
-		log.Debugf("service [%s] available through: %s@%s:%d/%s", s.ServiceID, pgconfig.User, pgconfig.Host, pgconfig.Port, pgconfig.Database)
+		if !ok {
+			repo.addServiceFromConnSetting(id, cs)
+		}
 	}
+
+	// Setup collectors of added services, collectors of unchanged services are kept.
+	return repo.setupServices(config)
 }
 
 // setupServices attaches metrics exporters to the services in the repo.
@@ -163,21 +354,33 @@ func (repo *Repository) setupServices(config Config) error {
 		var service = repo.getService(id)
 		if service.Collector == nil {
 			factories := collector.Factories{}
+			if service.ConnSettings.ServiceType != model.ServiceTypeSystem && service.Pool == nil {
+				service.Pool = store.NewPool(service.ConnSettings.ServiceType)
+			}
+
 			collectorConfig := collector.Config{
-				NoTrackMode: config.NoTrackMode,
-				ServiceType: service.ConnSettings.ServiceType,
-				ConnString:  service.ConnSettings.Conninfo,
-				Settings:    config.CollectorsSettings,
-				DatabasesRE: config.DatabasesRE,
+				NoTrackMode:           config.NoTrackMode,
+				ServiceType:           service.ConnSettings.ServiceType,
+				ConnString:            service.ConnSettings.Conninfo,
+				Pool:                  service.Pool,
+				Settings:              config.CollectorsSettings,
+				DatabasesRE:           config.DatabasesRE,
+				DatabasesExcludeRE:    config.DatabasesExcludeRE,
+				WarmupPeriod:          config.WarmupPeriod,
+				DatabasesConcurrency:  config.DatabasesConcurrency,
+				MaxSeriesPerService:   config.MaxSeriesPerService,
+				MaxSeriesPerCollector: config.MaxSeriesPerCollector,
+				Statements:            config.Statements,
+				Labels:                mergeLabels(config.Labels, service.ConnSettings.Labels),
 			}
 
 			switch service.ConnSettings.ServiceType {
 			case model.ServiceTypeSystem:
-				factories.RegisterSystemCollectors(config.DisabledCollectors)
+				factories.RegisterSystemCollectors(config.DisabledCollectors, config.EnabledCollectors)
 			case model.ServiceTypePostgresql:
-				factories.RegisterPostgresCollectors(config.DisabledCollectors)
+				factories.RegisterPostgresCollectors(config.DisabledCollectors, config.EnabledCollectors)
 			case model.ServiceTypePgbouncer:
-				factories.RegisterPgbouncerCollectors(config.DisabledCollectors)
+				factories.RegisterPgbouncerCollectors(config.DisabledCollectors, config.EnabledCollectors)
 			default:
 				continue
 			}
@@ -188,8 +391,14 @@ func (repo *Repository) setupServices(config Config) error {
 			}
 			service.Collector = mc
 
-			// Register collector.
-			prometheus.MustRegister(service.Collector)
+			// Determine role of Postgres service, role is attached to all service's metrics.
+			if service.ConnSettings.ServiceType == model.ServiceTypePostgresql {
+				role, err := repo.queryRole(service.Pool, service.ConnSettings.Conninfo)
+				if err != nil {
+					log.Warnf("query role of service [%s] failed: %s; skip", id, err)
+				}
+				service.Role = role
+			}
 
 			// Put updated service into repo.
 			repo.addService(service)
@@ -199,3 +408,196 @@ func (repo *Repository) setupServices(config Config) error {
 
 	return nil
 }
+
+// mergeLabels merges global and service-specific labels, service-specific labels have precedence.
+func mergeLabels(global, local map[string]string) map[string]string {
+	if len(global) == 0 && len(local) == 0 {
+		return nil
+	}
+
+	merged := make(map[string]string, len(global)+len(local))
+	for k, v := range global {
+		merged[k] = v
+	}
+	for k, v := range local {
+		merged[k] = v
+	}
+
+	return merged
+}
+
+// refreshRoles requests roles of Postgres services and updates roles which have been changed (e.g. due to failover or
+// promote).
+func (repo *Repository) refreshRoles() {
+	for _, id := range repo.getServiceIDs() {
+		s := repo.getService(id)
+		if s.ConnSettings.ServiceType != model.ServiceTypePostgresql || s.Collector == nil {
+			continue
+		}
+
+		role, err := repo.queryRole(s.Pool, s.ConnSettings.Conninfo)
+		if err != nil {
+			log.Warnf("query role of service [%s] failed: %s; skip", id, err)
+			continue
+		}
+
+		if role == s.Role {
+			continue
+		}
+
+		log.Infof("service [%s] role changed from '%s' to '%s'", id, s.Role, role)
+
+		s.Role = role
+		repo.addService(s)
+	}
+}
+
+// checkServices runs collectors of all configured services once and returns results sorted by services IDs.
+func (repo *Repository) checkServices() []CheckResult {
+	ids := repo.getServiceIDs()
+	sort.Strings(ids)
+
+	results := make([]CheckResult, 0, len(ids))
+	for _, id := range ids {
+		s := repo.getService(id)
+
+		c, ok := s.Collector.(Checker)
+		if !ok {
+			continue
+		}
+
+		res, err := c.Check()
+		results = append(results, CheckResult{ServiceID: id, Results: res, Err: err})
+	}
+
+	return results
+}
+
+// servicesInfo returns info about all services in the repo sorted by services IDs.
+func (repo *Repository) servicesInfo() []Info {
+	ids := repo.getServiceIDs()
+	sort.Strings(ids)
+
+	infos := make([]Info, 0, len(ids))
+	for _, id := range ids {
+		s := repo.getService(id)
+
+		info := Info{ID: id, Type: s.ConnSettings.ServiceType, Role: s.Role, Status: scrapeStatusUnknown}
+
+		// Connection string is not used by system service, there is nothing to parse.
+		if s.ConnSettings.ServiceType != model.ServiceTypeSystem {
+			pgconfig, err := pgx.ParseConfig(s.ConnSettings.Conninfo)
+			if err == nil {
+				info.Host, info.Port = pgconfig.Host, pgconfig.Port
+			}
+		}
+
+		if c, ok := s.Collector.(ScrapeReporter); ok {
+			status := c.LastScrape()
+			if !status.Time.IsZero() {
+				info.LastScrape = &status.Time
+				info.Status = scrapeStatusOK
+				if status.Err != nil {
+					info.Status = scrapeStatusFailed
+					info.LastError = status.Err.Error()
+				}
+			}
+		}
+
+		infos = append(infos, info)
+	}
+
+	return infos
+}
+
+// Gatherer returns gatherer of metrics of the services in the repo. Collectors of the services are registered on each
+// gathering, hence removed services and changed roles are taken into account. Collectors which implement
+// ContextCollector are aborted when passed context is done.
+func (repo *Repository) Gatherer(ctx context.Context) prometheus.Gatherer {
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		return repo.gather(ctx)
+	})
+}
+
+// gather registers collectors of the services in the new registry and gathers metrics from it.
+func (repo *Repository) gather(ctx context.Context) ([]*dto.MetricFamily, error) {
+	reg := prometheus.NewRegistry()
+
+	for _, id := range repo.getServiceIDs() {
+		s := repo.getService(id)
+		if s.Collector == nil {
+			continue
+		}
+
+		var c prometheus.Collector = s.Collector
+		if cc, ok := s.Collector.(ContextCollector); ok {
+			c = contextCollector{ctx: ctx, collector: cc}
+		}
+
+		err := serviceRegisterer(reg, s.Role).Register(c)
+		if err != nil {
+			log.Errorf("register collector of service [%s] failed: %s; skip", id, err)
+		}
+	}
+
+	return reg.Gather()
+}
+
+// contextCollector is the adapter which passes context to the collector when metrics are collected.
+type contextCollector struct {
+	ctx       context.Context
+	collector ContextCollector
+}
+
+// Describe implements prometheus.Collector interface.
+func (c contextCollector) Describe(ch chan<- *prometheus.Desc) { c.collector.Describe(ch) }
+
+// Collect implements prometheus.Collector interface.
+func (c contextCollector) Collect(ch chan<- prometheus.Metric) { c.collector.CollectContext(c.ctx, ch) }
+
+// serviceRegisterer returns registerer which attaches role label to metrics of collectors registered in passed registerer.
+func serviceRegisterer(reg prometheus.Registerer, role string) prometheus.Registerer {
+	if role == "" {
+		return reg
+	}
+
+	return prometheus.WrapRegistererWith(prometheus.Labels{"role": role}, reg)
+}
+
+// checkConnection checks that connection to the service could be established.
+func checkConnection(config *pgx.ConnConfig) error {
+	db, err := store.NewWithConfig(config)
+	if err != nil {
+		return err
+	}
+	db.Close()
+
+	return nil
+}
+
+// queryPostgresRole returns role of Postgres service using connection from the service's pool.
+func queryPostgresRole(pool *store.Pool, conninfo string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryRoleTimeout)
+	defer cancel()
+
+	conn, err := store.NewPooled(ctx, pool, conninfo)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	queryCtx, queryCancel := conn.Context()
+	defer queryCancel()
+
+	var recovery bool
+	err = conn.Conn().QueryRow(queryCtx, "SELECT pg_is_in_recovery()").Scan(&recovery)
+	if err != nil {
+		return "", err
+	}
+
+	if recovery {
+		return model.ServiceRoleStandby, nil
+	}
+
+	return model.ServiceRolePrimary, nil
+}
@@ -1,10 +1,19 @@
 package service
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/jackc/pgx/v4"
+	"github.com/lesovsky/pgscv/internal/collector"
 	"github.com/lesovsky/pgscv/internal/model"
+	"github.com/lesovsky/pgscv/internal/store"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	"sort"
 	"testing"
+	"time"
 )
 
 func TestRepository_addService(t *testing.T) {
@@ -28,6 +37,33 @@ func TestRepository_getService(t *testing.T) {
 	assert.Equal(t, s.ConnSettings, got.ConnSettings)
 }
 
+func TestRepository_removeService(t *testing.T) {
+	r := NewRepository()
+	r.addServicesFromConfig(Config{})
+	assert.NoError(t, r.setupServices(Config{}))
+
+	s := r.getService("system:0")
+	assert.NotNil(t, s.Collector)
+	assert.Equal(t, map[string]bool{"system:0": true}, gatheredServices(t, r))
+
+	// Metrics of removed service are not gathered anymore.
+	r.removeService("system:0")
+	assert.Equal(t, 0, r.totalServices())
+	assert.Equal(t, map[string]bool{}, gatheredServices(t, r))
+
+	// Removing unknown service should do nothing.
+	r.removeService("unknown:0")
+
+	// Service with the same ID could be added again.
+	r.addServicesFromConfig(Config{})
+	assert.NotPanics(t, func() { assert.NoError(t, r.setupServices(Config{})) })
+	assert.NotNil(t, r.getService("system:0").Collector)
+	assert.Equal(t, map[string]bool{"system:0": true}, gatheredServices(t, r))
+
+	r.removeService("system:0")
+	assert.Equal(t, 0, r.totalServices())
+}
+
 func TestRepository_getServiceIDs(t *testing.T) {
 	r := NewRepository()
 	s1 := TestSystemService()
@@ -116,7 +152,343 @@ func TestRepository_setupServices(t *testing.T) {
 		assert.NoError(t, r.setupServices(tc.config))
 		s := r.getService("test")
 		assert.NotNil(t, s.Collector)
+		assert.Equal(t, model.ServiceRolePrimary, s.Role)
 
-		prometheus.Unregister(s.Collector)
+		r.removeService("test")
 	}
 }
+
+func TestRepository_reconcileServices(t *testing.T) {
+	r := NewRepository()
+	r.queryRole = func(*store.Pool, string) (string, error) { return model.ServiceRolePrimary, nil }
+	r.checkConn = func(config *pgx.ConnConfig) error {
+		if config.Host == "unavailable" {
+			return errors.New("connection refused")
+		}
+		return nil
+	}
+
+	config := Config{ConnsSettings: ConnsSettings{
+		"postgres:1": {ServiceType: model.ServiceTypePostgresql, Conninfo: "host=127.0.0.1 port=5432 user=pgscv password=old"},
+		"postgres:2": {ServiceType: model.ServiceTypePostgresql, Conninfo: "host=127.0.0.1 port=5433 user=pgscv password=old"},
+		"postgres:3": {ServiceType: model.ServiceTypePostgresql, Conninfo: "host=127.0.0.1 port=5434 user=pgscv"},
+	}}
+
+	r.addServicesFromConfig(config)
+	assert.NoError(t, r.setupServices(config))
+	assert.Equal(t, 4, r.totalServices())
+
+	system := r.getService("system:0").Collector
+	unchanged := r.getService("postgres:1").Collector
+	changed := r.getService("postgres:2").Collector
+
+	// Service 1 is unchanged, service 2 gets new credentials, service 3 is gone, service 4 is new, service 5 is
+	// unavailable.
+	config = Config{ConnsSettings: ConnsSettings{
+		"postgres:1": {ServiceType: model.ServiceTypePostgresql, Conninfo: "host=127.0.0.1 port=5432 user=pgscv password=old"},
+		"postgres:2": {ServiceType: model.ServiceTypePostgresql, Conninfo: "host=127.0.0.1 port=5433 user=pgscv password=new"},
+		"postgres:4": {ServiceType: model.ServiceTypePostgresql, Conninfo: "host=127.0.0.1 port=5435 user=pgscv"},
+		"postgres:5": {ServiceType: model.ServiceTypePostgresql, Conninfo: "host=unavailable port=5436 user=pgscv"},
+	}}
+
+	assert.NotPanics(t, func() { assert.NoError(t, r.reconcileServices(config)) })
+
+	ids := r.getServiceIDs()
+	sort.Strings(ids)
+	assert.Equal(t, []string{"postgres:1", "postgres:2", "postgres:4", "system:0"}, ids)
+
+	// Unchanged services keep their collectors.
+	assert.True(t, system == r.getService("system:0").Collector)
+	assert.True(t, unchanged == r.getService("postgres:1").Collector)
+
+	// Changed service is re-created with new settings.
+	s := r.getService("postgres:2")
+	assert.Equal(t, "host=127.0.0.1 port=5433 user=pgscv password=new", s.ConnSettings.Conninfo)
+	assert.NotNil(t, s.Collector)
+	assert.False(t, changed == s.Collector)
+
+	// New service is set up.
+	assert.NotNil(t, r.getService("postgres:4").Collector)
+	assert.Equal(t, model.ServiceRolePrimary, r.getService("postgres:4").Role)
+
+	// Reconciling with the same config changes nothing.
+	before := map[string]Collector{}
+	for _, id := range r.getServiceIDs() {
+		before[id] = r.getService(id).Collector
+	}
+	assert.NoError(t, r.reconcileServices(config))
+	for _, id := range r.getServiceIDs() {
+		assert.True(t, before[id] == r.getService(id).Collector)
+	}
+	assert.Equal(t, len(before), r.totalServices())
+
+	// Removed services could be added again after next reload.
+	config.ConnsSettings["postgres:3"] = ConnSetting{ServiceType: model.ServiceTypePostgresql, Conninfo: "host=127.0.0.1 port=5434 user=pgscv"}
+	assert.NotPanics(t, func() { assert.NoError(t, r.reconcileServices(config)) })
+	assert.NotNil(t, r.getService("postgres:3").Collector)
+
+	for _, id := range r.getServiceIDs() {
+		r.removeService(id)
+	}
+}
+
+// gatheredServices returns IDs of services which metrics are gathered from the repo.
+func gatheredServices(t *testing.T, r *Repository) map[string]bool {
+	families, err := r.Gatherer(context.Background()).Gather()
+	assert.NoError(t, err)
+
+	ids := map[string]bool{}
+	for _, f := range families {
+		for _, m := range f.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "service_id" {
+					ids[l.GetValue()] = true
+				}
+			}
+		}
+	}
+	return ids
+}
+
+// roleTestCollector is the simple collector used for testing role labeling.
+type roleTestCollector struct {
+	desc *prometheus.Desc
+}
+
+func (c roleTestCollector) Describe(ch chan<- *prometheus.Desc) { ch <- c.desc }
+func (c roleTestCollector) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, 1)
+}
+
+func TestRepository_refreshRoles(t *testing.T) {
+	var recovery bool
+	r := NewRepository()
+	r.queryRole = func(*store.Pool, string) (string, error) {
+		if recovery {
+			return model.ServiceRoleStandby, nil
+		}
+		return model.ServiceRolePrimary, nil
+	}
+
+	// gatherRole returns value of role label of the test metric.
+	gatherRole := func() string {
+		families, err := r.Gatherer(context.Background()).Gather()
+		assert.NoError(t, err)
+		for _, f := range families {
+			if f.GetName() != "pgscv_role_test" {
+				continue
+			}
+			for _, l := range f.GetMetric()[0].GetLabel() {
+				if l.GetName() == "role" {
+					return l.GetValue()
+				}
+			}
+		}
+		return ""
+	}
+
+	s := Service{
+		ServiceID:    "test",
+		ConnSettings: ConnSetting{ServiceType: model.ServiceTypePostgresql},
+		Collector:    roleTestCollector{desc: prometheus.NewDesc("pgscv_role_test", "Test metric.", nil, nil)},
+		Role:         model.ServiceRolePrimary,
+	}
+	r.addService(s)
+	assert.Equal(t, model.ServiceRolePrimary, gatherRole())
+
+	// Role is not changed, nothing should happen.
+	r.refreshRoles()
+	assert.Equal(t, model.ServiceRolePrimary, r.getService("test").Role)
+	assert.Equal(t, model.ServiceRolePrimary, gatherRole())
+
+	// Service went into recovery, role label should be changed.
+	recovery = true
+	r.refreshRoles()
+	assert.Equal(t, model.ServiceRoleStandby, r.getService("test").Role)
+	assert.Equal(t, model.ServiceRoleStandby, gatherRole())
+
+	r.removeService("test")
+	assert.Equal(t, "", gatherRole())
+}
+
+// contextTestCollector is the simple collector which remembers context passed for collecting.
+type contextTestCollector struct {
+	roleTestCollector
+	ctx *context.Context
+}
+
+func (c contextTestCollector) CollectContext(ctx context.Context, ch chan<- prometheus.Metric) {
+	*c.ctx = ctx
+	c.Collect(ch)
+}
+
+func TestRepository_Gatherer(t *testing.T) {
+	var got context.Context
+	r := NewRepository()
+	r.addService(Service{
+		ServiceID:    "test",
+		ConnSettings: ConnSetting{ServiceType: model.ServiceTypePostgresql},
+		Collector:    contextTestCollector{roleTestCollector: roleTestCollector{desc: prometheus.NewDesc("pgscv_context_test", "Test metric.", nil, nil)}, ctx: &got},
+	})
+
+	type ctxKey struct{}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "value")
+
+	families, err := r.Gatherer(ctx).Gather()
+	assert.NoError(t, err)
+	assert.Len(t, families, 1)
+	assert.Equal(t, "pgscv_context_test", families[0].GetName())
+
+	// Context is passed to the collector.
+	assert.NotNil(t, got)
+	assert.Equal(t, "value", got.Value(ctxKey{}))
+}
+
+// checkTestCollector is the simple collector used for testing services checks.
+type checkTestCollector struct {
+	roleTestCollector
+	err error
+}
+
+func (c checkTestCollector) Check() ([]collector.CheckResult, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	return []collector.CheckResult{{Collector: "test/ok", Metrics: 1}}, nil
+}
+
+func TestRepository_checkServices(t *testing.T) {
+	r := NewRepository()
+	r.addService(Service{ServiceID: "test:2", Collector: checkTestCollector{err: errors.New("test error")}})
+	r.addService(Service{ServiceID: "test:1", Collector: checkTestCollector{}})
+	r.addService(Service{ServiceID: "test:3", Collector: roleTestCollector{}}) // doesn't support checks
+	r.addService(Service{ServiceID: "test:4"})                                 // has no collector
+
+	assert.Equal(t, []CheckResult{
+		{ServiceID: "test:1", Results: []collector.CheckResult{{Collector: "test/ok", Metrics: 1}}},
+		{ServiceID: "test:2", Err: errors.New("test error")},
+	}, r.checkServices())
+}
+
+// scrapeTestCollector is the simple collector used for testing services info.
+type scrapeTestCollector struct {
+	roleTestCollector
+	status collector.ScrapeStatus
+}
+
+func (c scrapeTestCollector) LastScrape() collector.ScrapeStatus { return c.status }
+
+func TestRepository_servicesInfo(t *testing.T) {
+	scraped := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	r := NewRepository()
+	r.addService(Service{ServiceID: "system:0", ConnSettings: ConnSetting{ServiceType: model.ServiceTypeSystem}})
+	r.addService(Service{
+		ServiceID:    "postgres:5432",
+		ConnSettings: ConnSetting{ServiceType: model.ServiceTypePostgresql, Conninfo: "host=127.0.0.1 port=5432 user=pgscv password=secret"},
+		Role:         "primary",
+		Collector:    scrapeTestCollector{status: collector.ScrapeStatus{Time: scraped}},
+	})
+	r.addService(Service{
+		ServiceID:    "pgbouncer:6432",
+		ConnSettings: ConnSetting{ServiceType: model.ServiceTypePgbouncer, Conninfo: "host=127.0.0.2 port=6432 user=pgscv"},
+		Collector:    scrapeTestCollector{status: collector.ScrapeStatus{Time: scraped, Err: errors.New("test error")}},
+	})
+
+	got := r.servicesInfo()
+	assert.Equal(t, []Info{
+		{ID: "pgbouncer:6432", Type: "pgbouncer", Host: "127.0.0.2", Port: 6432, Status: "failed", LastScrape: &scraped, LastError: "test error"},
+		{ID: "postgres:5432", Type: "postgres", Host: "127.0.0.1", Port: 5432, Role: "primary", Status: "ok", LastScrape: &scraped},
+		{ID: "system:0", Type: "system", Status: "unknown"},
+	}, got)
+
+	// Check JSON structure, secrets are not exposed.
+	data, err := json.Marshal(got)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `[
+		{"id":"pgbouncer:6432","type":"pgbouncer","host":"127.0.0.2","port":6432,"status":"failed","last_scrape":"2021-06-01T12:00:00Z","last_error":"test error"},
+		{"id":"postgres:5432","type":"postgres","host":"127.0.0.1","port":5432,"role":"primary","status":"ok","last_scrape":"2021-06-01T12:00:00Z"},
+		{"id":"system:0","type":"system","status":"unknown"}
+	]`, string(data))
+}
+
+func TestRepository_discoveryFailures(t *testing.T) {
+	failures := func(serviceType string) float64 {
+		return testutil.ToFloat64(discoveryFailures.WithLabelValues(serviceType))
+	}
+
+	r := NewRepository()
+	before := failures(model.ServiceTypePgbouncer)
+
+	// Unavailable service is not registered and counted as failure, system service is always registered.
+	r.addServicesFromConfig(Config{ConnsSettings: ConnsSettings{
+		"test": {ServiceType: model.ServiceTypePgbouncer, Conninfo: "port=1"},
+	}})
+	assert.Equal(t, []string{"system:0"}, r.getServiceIDs())
+	assert.Equal(t, before+1, failures(model.ServiceTypePgbouncer))
+}
+
+func Test_mergeLabels(t *testing.T) {
+	assert.Nil(t, mergeLabels(nil, nil))
+	assert.Equal(t, map[string]string{"env": "prod"}, mergeLabels(map[string]string{"env": "prod"}, nil))
+	assert.Equal(t, map[string]string{"env": "prod"}, mergeLabels(nil, map[string]string{"env": "prod"}))
+
+	// Service-specific labels override global.
+	assert.Equal(t,
+		map[string]string{"env": "staging", "cluster": "payments", "dc": "eu"},
+		mergeLabels(map[string]string{"env": "prod", "dc": "eu"}, map[string]string{"env": "staging", "cluster": "payments"}),
+	)
+}
+
+func TestRegisterMetrics(t *testing.T) {
+	collector.SetMetricsPrefix("example")
+	collector.SetMetricsAllowList([]string{"example_pgscv_discovery_failures_total", "example_pgscv_discovery_services_*"})
+	defer func() {
+		collector.SetMetricsPrefix("")
+		collector.SetMetricsAllowList(nil)
+		newMetrics()
+	}()
+
+	reg := prometheus.NewRegistry()
+	assert.NoError(t, RegisterMetrics(reg))
+
+	discoveredServices.Inc()
+	discoveryFailures.WithLabelValues(model.ServiceTypePostgresql).Inc()
+
+	families, err := reg.Gather()
+	assert.NoError(t, err)
+
+	var names []string
+	for _, f := range families {
+		names = append(names, f.GetName())
+	}
+	assert.Equal(t, []string{
+		"example_pgscv_discovery_failures_total",
+		"example_pgscv_discovery_services_discovered_total",
+	}, names)
+
+	// Not allowed metrics are not registered.
+	collector.SetMetricsAllowList([]string{"example_pgscv_discovery_failures_total"})
+	reg = prometheus.NewRegistry()
+	assert.NoError(t, RegisterMetrics(reg))
+	discoveryFailures.WithLabelValues(model.ServiceTypePostgresql).Inc()
+
+	families, err = reg.Gather()
+	assert.NoError(t, err)
+	assert.Len(t, families, 1)
+	assert.Equal(t, "example_pgscv_discovery_failures_total", families[0].GetName())
+}
+
+func Test_queryPostgresRole(t *testing.T) {
+	pool := store.NewPool(model.ServiceTypePostgresql)
+	defer pool.Close()
+
+	role, err := queryPostgresRole(pool, store.TestPostgresConnStr)
+	assert.NoError(t, err)
+	assert.Equal(t, model.ServiceRolePrimary, role)
+
+	// Connection is returned to the pool and reused by next request.
+	role, err = queryPostgresRole(pool, store.TestPostgresConnStr)
+	assert.NoError(t, err)
+	assert.Equal(t, model.ServiceRolePrimary, role)
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/lesovsky/pgscv/internal/log"
+	"github.com/lesovsky/pgscv/internal/model"
+	"github.com/lesovsky/pgscv/internal/store"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// stateMaxAge defines how long services are kept in state file since they have been seen last time.
+const stateMaxAge = 24 * time.Hour
+
+// state describes content of the state file with last-known services.
+type state struct {
+	Services []stateService `json:"services"`
+}
+
+// stateService describes discovered service kept in the state file. Passwords are not kept, hence restored services
+// use passwords from environment or password file, the same as discovered services.
+type stateService struct {
+	ID          string            `json:"id"`
+	ServiceType string            `json:"service_type"`
+	Conninfo    string            `json:"conninfo"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	LastSeen    time.Time         `json:"last_seen"`
+	Discovered  bool              `json:"discovered"`
+}
+
+// SaveState is a public wrapper on saveState method.
+func (repo *Repository) SaveState(filename string) error {
+	return repo.saveState(filename, time.Now())
+}
+
+// LoadState is a public wrapper on loadState function.
+func LoadState(filename string) (ConnsSettings, error) {
+	return loadState(filename, time.Now())
+}
+
+// saveState writes discovered services of the repo into state file. Configured services are not saved, they are
+// always taken from the config, hence services removed from the config are not restored. Discovered services kept in
+// the existing state file but missing in the repo (e.g. restored services which are not reachable yet) are saved with
+// their original last seen time, hence they are pruned only when not seen longer than stateMaxAge.
+func (repo *Repository) saveState(filename string, now time.Time) error {
+	ids := repo.getServiceIDs()
+	sort.Strings(ids)
+
+	prev, err := readState(filename)
+	if err != nil {
+		log.Warnf("read state file failed: %s; overwrite it", err)
+	}
+
+	st := state{Services: make([]stateService, 0, len(ids))}
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		s := repo.getService(id)
+		seen[id] = true
+		if !s.ConnSettings.Discovered {
+			continue
+		}
+
+		conninfo, err := store.ConnStringWithoutPassword(s.ConnSettings.Conninfo)
+		if err != nil {
+			log.Warnf("remove password from connection string of service [%s] failed: %s; skip", id, err)
+			continue
+		}
+
+		st.Services = append(st.Services, stateService{
+			ID:          id,
+			ServiceType: s.ConnSettings.ServiceType,
+			Conninfo:    conninfo,
+			Labels:      s.ConnSettings.Labels,
+			LastSeen:    now,
+			Discovered:  true,
+		})
+	}
+
+	for _, s := range prev.Services {
+		if seen[s.ID] || !s.Discovered || now.Sub(s.LastSeen) > stateMaxAge {
+			continue
+		}
+
+		st.Services = append(st.Services, s)
+		seen[s.ID] = true
+	}
+
+	sort.Slice(st.Services, func(i, j int) bool { return st.Services[i].ID < st.Services[j].ID })
+
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write into temporary file and rename it, hence the state file is never left partially written.
+	tmp := filename + ".tmp"
+	err = os.WriteFile(tmp, data, 0600)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, filepath.Clean(filename))
+}
+
+// loadState reads discovered services from state file. Services which have not been seen longer than stateMaxAge are
+// pruned, services which have not been discovered (e.g. saved by previous versions) are skipped. Missing state file is
+// not an error, there is nothing to restore.
+func loadState(filename string, now time.Time) (ConnsSettings, error) {
+	st, err := readState(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	settings := ConnsSettings{}
+	for _, s := range st.Services {
+		if now.Sub(s.LastSeen) > stateMaxAge {
+			log.Infof("service [%s] has not been seen since %s, prune it from state", s.ID, s.LastSeen.Format(time.RFC3339))
+			continue
+		}
+
+		if !s.Discovered {
+			log.Infof("service [%s] has not been discovered, skip restoring it from state", s.ID)
+			continue
+		}
+
+		if s.ID == "" || (s.ServiceType != model.ServiceTypePostgresql && s.ServiceType != model.ServiceTypePgbouncer) {
+			log.Warnf("invalid service [%s] of type '%s' in state file; skip", s.ID, s.ServiceType)
+			continue
+		}
+
+		settings[s.ID] = ConnSetting{ServiceType: s.ServiceType, Conninfo: s.Conninfo, Labels: s.Labels, Discovered: true}
+	}
+
+	return settings, nil
+}
+
+// readState reads and parses state file. Missing state file is not an error, empty state is returned.
+func readState(filename string) (state, error) {
+	data, err := os.ReadFile(filepath.Clean(filename))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return state{}, nil
+		}
+		return state{}, err
+	}
+
+	var st state
+	err = json.Unmarshal(data, &st)
+	if err != nil {
+		return state{}, fmt.Errorf("parse state file failed: %s", err)
+	}
+
+	return st, nil
+}
@@ -0,0 +1,120 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/lesovsky/pgscv/internal/log"
+	"github.com/lesovsky/pgscv/internal/model"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultPoolMaxConns defines default max number of connections kept per database of the service.
+	defaultPoolMaxConns = 4
+	// defaultPoolMaxConnLifetime defines default max time since connection established during which connection could be reused.
+	defaultPoolMaxConnLifetime = 5 * time.Minute
+	// defaultPoolMaxConnIdleTime defines default max time connection could be idle before it is closed.
+	defaultPoolMaxConnIdleTime = time.Minute
+)
+
+// errPoolClosed is returned when connection is requested from the pool of the removed service.
+var errPoolClosed = errors.New("pool is closed")
+
+// Pool keeps connections of the service. Connections to each database of the service are kept in separate pgxpool
+// pools. Pool is owned by the service and should be closed when the service is removed.
+type Pool struct {
+	mu         sync.Mutex
+	pools      map[string]*pgxpool.Pool // pools of connections keyed by database name
+	aliveQuery string                   // query used for checking idle connection is alive before it is reused
+	closed     bool
+}
+
+// NewPool creates new pool of connections for the service of passed type.
+func NewPool(serviceType string) *Pool {
+	aliveQuery := "SELECT 1"
+	if serviceType == model.ServiceTypePgbouncer {
+		// Pgbouncer admin console accepts SHOW commands only.
+		aliveQuery = "SHOW VERSION"
+	}
+
+	return &Pool{pools: map[string]*pgxpool.Pool{}, aliveQuery: aliveQuery}
+}
+
+// acquire returns connection to the database specified in passed config, pool of the database is created if necessary.
+func (p *Pool) acquire(ctx context.Context, config *pgx.ConnConfig) (*pgxpool.Conn, error) {
+	pool, err := p.databasePool(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return pool.Acquire(ctx)
+}
+
+// databasePool returns pool of connections to the database specified in passed config.
+func (p *Pool) databasePool(config *pgx.ConnConfig) (*pgxpool.Pool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return nil, errPoolClosed
+	}
+
+	if pool, ok := p.pools[config.Database]; ok {
+		return pool, nil
+	}
+
+	poolConfig, err := pgxpool.ParseConfig(config.ConnString())
+	if err != nil {
+		return nil, err
+	}
+
+	poolConfig.ConnConfig = config
+	poolConfig.MaxConns = defaultPoolMaxConns
+	poolConfig.MaxConnLifetime = defaultPoolMaxConnLifetime
+	poolConfig.MaxConnIdleTime = defaultPoolMaxConnIdleTime
+	poolConfig.BeforeAcquire = p.isAlive
+	// Connections are established on demand, using context of the caller.
+	poolConfig.LazyConnect = true
+
+	pool, err := pgxpool.ConnectConfig(context.Background(), poolConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	p.pools[config.Database] = pool
+
+	return pool, nil
+}
+
+// isAlive checks connection has not been terminated by the server (e.g. when it has been restarted) before it is
+// reused. Connections which are not alive are destroyed by pgxpool.
+func (p *Pool) isAlive(ctx context.Context, conn *pgx.Conn) bool {
+	ctx, cancel := context.WithTimeout(ctx, statementTimeout)
+	defer cancel()
+
+	_, err := conn.Exec(ctx, p.aliveQuery)
+	if err != nil {
+		log.Debugf("connection to database %s is not alive: %s; skip", conn.Config().Database, err)
+		return false
+	}
+
+	return true
+}
+
+// Close closes pools of all databases of the service. Idle connections are closed immediately, connections being in
+// use are closed when they are released.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, pool := range p.pools {
+		// pgxpool.Close waits for acquired connections, don't block the caller.
+		go pool.Close()
+	}
+
+	p.pools = map[string]*pgxpool.Pool{}
+	p.closed = true
+}
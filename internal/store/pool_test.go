@@ -0,0 +1,96 @@
+package store
+
+import (
+	"context"
+	"github.com/jackc/pgx/v4"
+	"github.com/lesovsky/pgscv/internal/model"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestNewPool(t *testing.T) {
+	assert.Equal(t, "SELECT 1", NewPool(model.ServiceTypePostgresql).aliveQuery)
+	assert.Equal(t, "SHOW VERSION", NewPool(model.ServiceTypePgbouncer).aliveQuery)
+}
+
+func TestPool_databasePool(t *testing.T) {
+	p := NewPool(model.ServiceTypePostgresql)
+
+	config, err := pgx.ParseConfig("host=127.0.0.1 port=1 user=test dbname=test1")
+	assert.NoError(t, err)
+	assert.NoError(t, prepareConfig(config))
+
+	// Pools are created lazily and kept per database.
+	pool1, err := p.databasePool(config)
+	assert.NoError(t, err)
+	pool2, err := p.databasePool(config)
+	assert.NoError(t, err)
+	assert.True(t, pool1 == pool2)
+
+	config2 := config.Copy()
+	config2.Database = "test2"
+	pool3, err := p.databasePool(config2)
+	assert.NoError(t, err)
+	assert.False(t, pool1 == pool3)
+	assert.Len(t, p.pools, 2)
+
+	// Connections can't be acquired from closed pool.
+	p.Close()
+	assert.Len(t, p.pools, 0)
+	_, err = NewPooledWithConfig(context.Background(), p, config)
+	assert.Equal(t, errPoolClosed, err)
+}
+
+func TestNewPooled(t *testing.T) {
+	p := NewPool(model.ServiceTypePostgresql)
+	defer p.Close()
+
+	db1, err := NewPooled(context.Background(), p, TestPostgresConnStr)
+	assert.NoError(t, err)
+	pid := db1.Conn().PgConn().PID()
+	db1.Close()
+
+	// Idle connection should be reused.
+	db2, err := NewPooled(context.Background(), p, TestPostgresConnStr)
+	assert.NoError(t, err)
+	assert.Equal(t, pid, db2.Conn().PgConn().PID())
+
+	// Terminate idle connection, like it happens when Postgres is restarted.
+	db3, err := NewPooled(context.Background(), p, TestPostgresConnStr)
+	assert.NoError(t, err)
+	db2.Close()
+	_, err = db3.Query("SELECT pg_terminate_backend(" + strconv.Itoa(int(pid)) + ")")
+	assert.NoError(t, err)
+	time.Sleep(100 * time.Millisecond)
+	db3.Close()
+
+	// Terminated connection should not be reused.
+	db4, err := NewPooled(context.Background(), p, TestPostgresConnStr)
+	assert.NoError(t, err)
+	assert.NotEqual(t, pid, db4.Conn().PgConn().PID())
+	_, err = db4.Query("SELECT 1")
+	assert.NoError(t, err)
+	db4.Close()
+}
+
+func TestPool_Close(t *testing.T) {
+	p := NewPool(model.ServiceTypePostgresql)
+
+	db1, err := NewPooled(context.Background(), p, TestPostgresConnStr)
+	assert.NoError(t, err)
+	db2, err := NewPooled(context.Background(), p, TestPostgresConnStr)
+	assert.NoError(t, err)
+	db1.Close()
+
+	// Idle connections are closed, connections in use are closed when released.
+	p.Close()
+	time.Sleep(100 * time.Millisecond)
+	assert.True(t, db1.Conn().IsClosed())
+	assert.False(t, db2.Conn().IsClosed())
+
+	db2.Close()
+	time.Sleep(100 * time.Millisecond)
+	assert.True(t, db2.Conn().IsClosed())
+}
@@ -4,9 +4,15 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"github.com/jackc/pgconn"
 	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/lesovsky/pgscv/internal/log"
 	"github.com/lesovsky/pgscv/internal/model"
+	"net"
+	"net/url"
+	"strings"
+	"time"
 )
 
 const (
@@ -27,38 +33,314 @@ const (
 	dataTypeNumeric uint32 = 1700
 )
 
+const (
+	// DefaultConnectTimeout defines default timeout for establishing connections.
+	DefaultConnectTimeout = 5 * time.Second
+	// DefaultStatementTimeout defines default timeout for executing queries.
+	DefaultStatementTimeout = 10 * time.Second
+)
+
+// LocalAddressParam defines connection string parameter used for specifying local address of connections.
+const LocalAddressParam = "pgscv_local_address"
+
+// clientParams defines connection string parameters which are not handled by the driver and are not Postgres runtime
+// parameters. These parameters are valid in libpq connection strings but must not be sent to Postgres.
+var clientParams = []string{
+	LocalAddressParam, "channel_binding", "gssencmode", "krbsrvname", "gsslib", "requirepeer", "sslcompression",
+	"sslcrl", "sslpassword", "sslsni", "ssl_min_protocol_version", "ssl_max_protocol_version", "keepalives",
+	"keepalives_idle", "keepalives_interval", "keepalives_count", "tcp_user_timeout", "hostaddr", "load_balance_hosts",
+}
+
+var (
+	// connectTimeout defines timeout used for establishing connections, unless connection string has its own.
+	connectTimeout = DefaultConnectTimeout
+	// statementTimeout defines timeout used for executing queries.
+	statementTimeout = DefaultStatementTimeout
+)
+
+// SetTimeouts sets timeouts used for establishing new connections and executing queries. Zero values are ignored.
+func SetTimeouts(connect, statement time.Duration) {
+	if connect > 0 {
+		connectTimeout = connect
+	}
+	if statement > 0 {
+		statementTimeout = statement
+	}
+}
+
 // DB is the database representation
 type DB struct {
-	conn *pgx.Conn // database connection object
+	conn    *pgx.Conn       // database connection object
+	pconn   *pgxpool.Conn   // pooled connection, nil if connection is not pooled
+	ctx     context.Context // parent context of queries, queries are cancelled when it is done
+	timeout time.Duration   // timeout used for executing queries
 }
 
 // New creates new connection to Postgres/Pgbouncer using passed DSN
 func New(connString string) (*DB, error) {
+	return NewContext(context.Background(), connString)
+}
+
+// NewContext creates new connection to Postgres/Pgbouncer using passed DSN. Connecting and queries executed using the
+// connection are cancelled when passed context is done.
+func NewContext(ctx context.Context, connString string) (*DB, error) {
+	return NewPooled(ctx, nil, connString)
+}
+
+// NewWithConfig creates new connection to Postgres/Pgbouncer using passed Config.
+func NewWithConfig(config *pgx.ConnConfig) (*DB, error) {
+	return NewWithConfigContext(context.Background(), config)
+}
+
+// NewWithConfigContext creates new connection to Postgres/Pgbouncer using passed Config. Connecting and queries
+// executed using the connection are cancelled when passed context is done.
+func NewWithConfigContext(ctx context.Context, config *pgx.ConnConfig) (*DB, error) {
+	return NewPooledWithConfig(ctx, nil, config)
+}
+
+// NewPooled returns connection to Postgres/Pgbouncer using passed DSN. Connection is acquired from passed pool of the
+// service, new connection is established if pool is nil.
+func NewPooled(ctx context.Context, pool *Pool, connString string) (*DB, error) {
 	config, err := pgx.ParseConfig(connString)
 	if err != nil {
 		return nil, err
 	}
 
-	return NewWithConfig(config)
+	return NewPooledWithConfig(ctx, pool, config)
 }
 
-// NewWithConfig creates new connection to Postgres/Pgbouncer using passed Config.
-func NewWithConfig(config *pgx.ConnConfig) (*DB, error) {
+// NewPooledWithConfig returns connection to Postgres/Pgbouncer using passed Config. Connection is acquired from passed
+// pool of the service, new connection is established if pool is nil.
+func NewPooledWithConfig(ctx context.Context, pool *Pool, config *pgx.ConnConfig) (*DB, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	err := prepareConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	if pool == nil {
+		conn, err := pgx.ConnectConfig(ctx, config)
+		if err != nil {
+			return nil, err
+		}
+
+		return &DB{conn: conn, ctx: ctx, timeout: statementTimeout}, nil
+	}
+
+	pconn, err := pool.acquire(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DB{conn: pconn.Conn(), pconn: pconn, ctx: ctx, timeout: statementTimeout}, nil
+}
+
+// prepareConfig adjusts passed config for connecting to Postgres/Pgbouncer.
+func prepareConfig(config *pgx.ConnConfig) error {
+	// Local address is not a Postgres runtime parameter, use it for dialing connection.
+	if addr, ok := config.RuntimeParams[LocalAddressParam]; ok && addr != "" {
+		dialFunc, err := newDialFunc(addr)
+		if err != nil {
+			return err
+		}
+		config.DialFunc = dialFunc
+	}
+
+	// Channel binding is not supported by the driver, hence it can't be required.
+	if config.RuntimeParams["channel_binding"] == "require" {
+		return fmt.Errorf("channel_binding=require is not supported")
+	}
+
 	// Enable simple protocol for compatibility with Pgbouncer.
 	config.PreferSimpleProtocol = true
 
+	config.RuntimeParams = newRuntimeParams(config.RuntimeParams)
+
+	// Use default connect timeout if it is not specified in connection string.
+	if config.ConnectTimeout == 0 {
+		config.ConnectTimeout = connectTimeout
+	}
+
+	return nil
+}
+
+// newRuntimeParams returns runtime parameters sent to Postgres. Parameters specified in connection string (e.g.
+// application_name) are kept, except client-side parameters which are unknown to Postgres.
+func newRuntimeParams(params map[string]string) map[string]string {
+	runtimeParams := make(map[string]string, len(params)+2)
+	for k, v := range params {
+		runtimeParams[k] = v
+	}
+
+	for _, p := range clientParams {
+		delete(runtimeParams, p)
+	}
+
 	// Using simple protocol requires explicit options to be set.
-	config.RuntimeParams = map[string]string{
-		"standard_conforming_strings": "on",
-		"client_encoding":             "UTF8",
+	runtimeParams["standard_conforming_strings"] = "on"
+	runtimeParams["client_encoding"] = "UTF8"
+
+	return runtimeParams
+}
+
+// ConnStringWithLocalAddress appends local address parameter to passed connection string.
+func ConnStringWithLocalAddress(connString string, addr string) (string, error) {
+	if strings.HasPrefix(connString, "postgres://") || strings.HasPrefix(connString, "postgresql://") {
+		u, err := url.Parse(connString)
+		if err != nil {
+			return "", err
+		}
+
+		q := u.Query()
+		q.Set(LocalAddressParam, addr)
+		u.RawQuery = q.Encode()
+
+		return u.String(), nil
 	}
 
-	conn, err := pgx.ConnectConfig(context.Background(), config)
+	return connString + " " + LocalAddressParam + "=" + addr, nil
+}
+
+// passwordParams defines connection string parameters with secrets which should not be stored outside of configuration.
+var passwordParams = []string{"password", "sslpassword"}
+
+// ConnStringWithoutPassword returns passed connection string with passwords (including password of SSL key) removed.
+// It is used when connection strings are stored outside of configuration. Connection string is validated by the driver
+// before and after passwords removal, hence other settings are kept the same as the driver sees them.
+func ConnStringWithoutPassword(connString string) (string, error) {
+	config, err := pgconn.ParseConfig(connString)
 	if err != nil {
-		return nil, err
+		return "", err
+	}
+
+	stripped, err := connStringWithoutPassword(connString)
+	if err != nil {
+		return "", err
+	}
+
+	strippedConfig, err := pgconn.ParseConfig(stripped)
+	if err != nil {
+		return "", err
+	}
+
+	// Password might be taken from environment or password file, hence only other settings are compared.
+	if strippedConfig.Host != config.Host || strippedConfig.Port != config.Port ||
+		strippedConfig.User != config.User || strippedConfig.Database != config.Database {
+		return "", fmt.Errorf("invalid connection string: settings are changed after password removal")
+	}
+
+	return stripped, nil
+}
+
+// connStringWithoutPassword removes passwords from passed connection string in URL or keyword/value format.
+func connStringWithoutPassword(connString string) (string, error) {
+	// Use the same rule of detecting URL format as the driver uses.
+	if strings.HasPrefix(connString, "postgres://") || strings.HasPrefix(connString, "postgresql://") {
+		u, err := url.Parse(connString)
+		if err != nil {
+			return "", err
+		}
+
+		if u.User != nil {
+			u.User = url.User(u.User.Username())
+		}
+
+		q := u.Query()
+		for _, p := range passwordParams {
+			q.Del(p)
+		}
+		u.RawQuery = q.Encode()
+
+		return u.String(), nil
+	}
+
+	// Keyword/value connection string: split it to 'keyword=value' pairs and skip password. Values might be quoted
+	// and contain escaped characters, pairs are kept as is.
+	var pairs []string
+	s := strings.TrimSpace(connString)
+	for s != "" {
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			return "", fmt.Errorf("invalid connection string: missing '=' after '%s'", s)
+		}
+
+		key := strings.TrimSpace(s[:eq])
+
+		// Skip spaces between '=' and value.
+		i := eq + 1
+		for i < len(s) && (s[i] == ' ' || s[i] == '\t' || s[i] == '\n') {
+			i++
+		}
+
+		quoted := i < len(s) && s[i] == '\''
+		if quoted {
+			i++
+		}
+
+		end := -1
+		for ; i < len(s); i++ {
+			if s[i] == '\\' {
+				i++
+				continue
+			}
+			if quoted && s[i] == '\'' {
+				end = i + 1
+				break
+			}
+			if !quoted && (s[i] == ' ' || s[i] == '\t' || s[i] == '\n') {
+				end = i
+				break
+			}
+		}
+
+		if end < 0 {
+			if quoted {
+				return "", fmt.Errorf("invalid connection string: unterminated quoted value of '%s'", key)
+			}
+			end = len(s)
+		}
+
+		if !isPasswordParam(key) {
+			pairs = append(pairs, s[:end])
+		}
+
+		s = strings.TrimSpace(s[end:])
 	}
 
-	return &DB{conn: conn}, nil
+	return strings.Join(pairs, " "), nil
+}
+
+// isPasswordParam returns true if passed connection string parameter contains secret.
+func isPasswordParam(key string) bool {
+	for _, p := range passwordParams {
+		if key == p {
+			return true
+		}
+	}
+	return false
+}
+
+// newDialFunc returns dial function which binds TCP connections to passed local address.
+func newDialFunc(addr string) (pgconn.DialFunc, error) {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid local address: %s", addr)
+	}
+
+	// Use the same keepalive as pgconn uses by default.
+	defaultDialer := &net.Dialer{KeepAlive: 5 * time.Minute}
+	localDialer := &net.Dialer{KeepAlive: 5 * time.Minute, LocalAddr: &net.TCPAddr{IP: ip}}
+
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		// Local address is not applicable to UNIX sockets.
+		if network != "tcp" {
+			return defaultDialer.DialContext(ctx, network, address)
+		}
+		return localDialer.DialContext(ctx, network, address)
+	}, nil
 }
 
 /* public db methods */
@@ -72,11 +354,19 @@ func (db *DB) Close() { db.close() }
 // Conn provides access to public methods of *pgx.Conn struct
 func (db *DB) Conn() *pgx.Conn { return db.conn }
 
+// Context returns context with deadline which should be used for executing queries.
+func (db *DB) Context() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(db.ctx, db.timeout)
+}
+
 /* private db methods */
 
 // Query method executes passed query and wraps result into model.PGResult struct.
 func (db *DB) query(query string) (*model.PGResult, error) {
-	rows, err := db.Conn().Query(context.Background(), query)
+	ctx, cancel := db.Context()
+	defer cancel()
+
+	rows, err := db.Conn().Query(ctx, query)
 	if err != nil {
 		return nil, err
 	}
@@ -93,6 +383,8 @@ func (db *DB) query(query string) (*model.PGResult, error) {
 	// Check the data types are safe in returned result.
 	for _, c := range colnames {
 		if !isDataTypeSupported(c.DataTypeOID) {
+			// Rows must be closed, otherwise connection remains busy and can't be reused.
+			rows.Close()
 			return nil, fmt.Errorf("query '%s', unsupported data type OID: %d", query, c.DataTypeOID)
 		}
 	}
@@ -123,6 +415,16 @@ func (db *DB) query(query string) (*model.PGResult, error) {
 
 	rows.Close()
 
+	// Check query has not been cancelled due to timeout, in this case result is incomplete.
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("query '%s' cancelled: %w", query, err)
+	}
+
+	// Check query has not failed in the middle of reading rows, in this case result is incomplete.
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query '%s' failed: %w", query, err)
+	}
+
 	return &model.PGResult{
 		Nrows:    nrows,
 		Ncols:    ncols,
@@ -131,9 +433,18 @@ func (db *DB) query(query string) (*model.PGResult, error) {
 	}, nil
 }
 
-// Close method closes database connections gracefully.
+// Close method returns connection to the pool, or closes it if connection is not pooled.
 func (db *DB) close() {
-	err := db.Conn().Close(context.Background())
+	if db.pconn != nil {
+		db.pconn.Release()
+		return
+	}
+
+	if db.conn.IsClosed() {
+		return
+	}
+
+	err := db.conn.Close(context.Background())
 	if err != nil {
 		log.Warnf("failed to close database connection: %s; ignore", err)
 	}
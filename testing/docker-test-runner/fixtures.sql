@@ -35,3 +35,9 @@ ALTER TABLE persons ADD CONSTRAINT persons_properties_constraint FOREIGN KEY (pr
 -- create table with no primary/unique key
 CREATE TABLE migrations (id INT, created_at TIMESTAMP, description TEXT);
 CREATE INDEX migrations_created_at_idx ON migrations (created_at);
+
+-- create partitioned table with partitions
+CREATE TABLE measurements (id INT, logdate DATE, value INT, PRIMARY KEY (id, logdate)) PARTITION BY RANGE (logdate);
+CREATE TABLE measurements_2021 PARTITION OF measurements FOR VALUES FROM ('2021-01-01') TO ('2022-01-01');
+CREATE TABLE measurements_2022 PARTITION OF measurements FOR VALUES FROM ('2022-01-01') TO ('2023-01-01');
+INSERT INTO measurements SELECT i, '2021-01-01'::date + (i % 700), i FROM generate_series(1, 1000) i;